@@ -1,5 +1,5 @@
 module github.com/SimonMTaye/gitgo
 
-go 1.16
+go 1.18
 
 require github.com/teris-io/cli v1.0.1
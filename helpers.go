@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/SimonMTaye/gitgo/objects"
 	"github.com/SimonMTaye/gitgo/repo"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // AddHelper Helper functions for the cli
@@ -24,21 +30,38 @@ func AddHelper(repodir string, filepath string) error {
 	return repoStruct.AddFile(filepath)
 }
 
-// CatfileHelper Find an object based on a search string
-func CatfileHelper(repodir string, srchstr string) (objects.GitObject, error) {
-	repoStruct, err := repo.OpenRepo(repodir)
-	if err != nil {
-		return nil, err
+// HashObject Compute the hash of an object of the given type whose content is read from src with
+// algo, saving it to the repo if repoStruct isn't nil (in which case algo must be the repo's).
+// Unless literally is set, the type must be one of the four object types and the content must
+// parse as that type. Blobs are streamed, other objects are small enough to be read into memory
+// for validation
+func HashObject(repoStruct *repo.Repo, algo *objects.HashAlgorithm, objType objects.GitObjectType,
+	src io.Reader, size int64, literally bool) (objects.ObjectID, error) {
+	if literally {
+		if objType == "" || strings.ContainsAny(string(objType), " \x00") {
+			return objects.ObjectID{}, fmt.Errorf("invalid object type \"%s\"", objType)
+		}
+	} else {
+		switch objType {
+		case objects.Blob:
+		case objects.Tree, objects.Commit, objects.Tag:
+			data, err := io.ReadAll(io.LimitReader(src, size))
+			if err != nil {
+				return objects.ObjectID{}, err
+			}
+			err = objects.ValidateObject(algo, objType, data)
+			if err != nil {
+				return objects.ObjectID{}, err
+			}
+			src = bytes.NewReader(data)
+		default:
+			return objects.ObjectID{}, fmt.Errorf("invalid object type \"%s\"", objType)
+		}
 	}
-	objHash, err := repoStruct.FindObject(srchstr)
-	if err != nil {
-		return nil, err
-	}
-	obj, err := repoStruct.GetObject(objHash)
-	if err != nil {
-		return nil, err
+	if repoStruct != nil {
+		return repoStruct.SaveStream(objType, size, src)
 	}
-	return obj, nil
+	return algo.HashReader(objType, size, src)
 }
 
 // CommitHelper Create a commit based on the contents of the index and previous commit
@@ -57,3 +80,99 @@ func FindandOpenRepo() (*repo.Repo, error) {
 	}
 	return repo.OpenRepo(repoDir)
 }
+
+// RepoPaths Convert paths given on the command line (relative to the current directory) into
+// paths relative to the worktree of the repo, as used by the index
+func RepoPaths(repoStruct *repo.Repo, args []string) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		absPath := arg
+		if !filepath.IsAbs(arg) {
+			absPath = filepath.Join(cwd, arg)
+		}
+		relPath, err := filepath.Rel(repoStruct.Worktree, absPath)
+		if err != nil {
+			return nil, err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == ".." || strings.HasPrefix(relPath, "../") {
+			return nil, fmt.Errorf("%s: '%s' is outside repository", arg, relPath)
+		}
+		paths = append(paths, relPath)
+	}
+	return paths, nil
+}
+
+// LogOptionsFromFlags Read the options of 'log' that choose how commits are shown. --oneline is
+// short for '--pretty=oneline --abbrev-commit'; --pretty and --format override it
+func LogOptionsFromFlags(options map[string]string) (repo.LogOptions, error) {
+	logOptions := repo.LogOptions{
+		AbbrevCommit: options["abbrev-commit"] == "true",
+		Graph:        options["graph"] == "true",
+	}
+	pretty := options["pretty"]
+	if options["format"] != "" {
+		pretty = options["format"]
+	}
+	if pretty == "" && options["oneline"] == "true" {
+		pretty = "oneline"
+		logOptions.AbbrevCommit = true
+	}
+	format, err := repo.ParseLogFormat(pretty)
+	if err != nil {
+		return logOptions, err
+	}
+	logOptions.Format = format
+	if options["date"] != "" {
+		logOptions.Dates, err = repo.ParseDateMode(options["date"])
+		if err != nil {
+			return logOptions, err
+		}
+	}
+	return logOptions, nil
+}
+
+// CommitFilterFromFlags Read the options of 'log' that limit which commits are shown. paths are
+// relative to the current directory. --since and --until take the dates repo.ParseDate accepts
+func CommitFilterFromFlags(repoStruct *repo.Repo, options map[string]string, paths []string) (repo.CommitFilter, error) {
+	filter := repo.CommitFilter{Follow: options["follow"] == "true"}
+	var err error
+	if len(paths) > 0 {
+		filter.Paths, err = RepoPaths(repoStruct, paths)
+		if err != nil {
+			return filter, err
+		}
+		// The root of the worktree contains every file
+		for i, path := range filter.Paths {
+			if path == "." {
+				filter.Paths[i] = ""
+			}
+		}
+	}
+	patterns := []**regexp.Regexp{&filter.Author, &filter.Committer, &filter.Grep}
+	for i, key := range []string{"author", "committer", "grep"} {
+		if options[key] == "" {
+			continue
+		}
+		*patterns[i], err = regexp.Compile(options[key])
+		if err != nil {
+			return filter, err
+		}
+	}
+	now := time.Now()
+	dates := []*time.Time{&filter.Since, &filter.Until}
+	for i, key := range []string{"since", "until"} {
+		if options[key] == "" {
+			continue
+		}
+		*dates[i], err = repo.ParseDate(options[key], now)
+		if err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
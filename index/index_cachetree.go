@@ -0,0 +1,61 @@
+package index
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// cacheTreeSignature The signature of the extension where git caches the trees of the index's
+// directories
+var cacheTreeSignature = [4]byte{'T', 'R', 'E', 'E'}
+
+// CacheTree Return the hashes of the trees in the index's TREE extension, i.e. the trees git
+// wrote for directories that haven't changed since. Directories whose cached tree was
+// invalidated are skipped. An index without the extension has none
+func (idx *Index) CacheTree() ([]objects.ObjectID, error) {
+	trees := make([]objects.ObjectID, 0)
+	for _, ext := range idx.Extensions {
+		if ext.Metadata.Signature != cacheTreeSignature {
+			continue
+		}
+		// Each directory is stored as: path NUL entry-count SP subtree-count LF [hash]. The hash
+		// is left out when the entry count is -1
+		data := ext.Data
+		for len(data) > 0 {
+			end := bytes.IndexByte(data, 0)
+			if end == -1 {
+				return nil, &ErrIndexBadlyFormated{reason: "cache tree path is not terminated"}
+			}
+			data = data[end+1:]
+			end = bytes.IndexByte(data, '\n')
+			if end == -1 {
+				return nil, &ErrIndexBadlyFormated{reason: "cache tree counts are not terminated"}
+			}
+			counts := bytes.Fields(data[:end])
+			data = data[end+1:]
+			if len(counts) != 2 {
+				return nil, &ErrIndexBadlyFormated{reason: "bad cache tree counts"}
+			}
+			entries, err := strconv.Atoi(string(counts[0]))
+			if err != nil {
+				return nil, &ErrIndexBadlyFormated{reason: "bad cache tree entry count"}
+			}
+			if entries < 0 {
+				continue
+			}
+			size := idx.HashAlgorithm().Size()
+			if len(data) < size {
+				return nil, &ErrIndexBadlyFormated{reason: "cache tree hash is cut short"}
+			}
+			hash, err := idx.HashAlgorithm().IDFromBytes(data[:size])
+			if err != nil {
+				return nil, err
+			}
+			trees = append(trees, hash)
+			data = data[size:]
+		}
+	}
+	return trees, nil
+}
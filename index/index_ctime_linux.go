@@ -0,0 +1,8 @@
+package index
+
+import "syscall"
+
+// statCtime The time the metadata of a file last changed
+func statCtime(stat *syscall.Stat_t) TimePair {
+	return covertTimespec(stat.Ctim)
+}
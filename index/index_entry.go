@@ -4,46 +4,33 @@
 package index
 
 import (
-	"errors"
 	"os"
-	"path"
 	"syscall"
 )
 
-// Contains code for reading a file a creating an entry in the index
+// Contains code for reading a file's 'stat' information for an entry in the index
 // linux or darwin only. Windows code is found in index_entry_windows.go
 
-// Read a file path and create an entry
-func createEntry(rootDir string, fileName string) (*Entry, error) {
-	fileInfo, err := os.Stat(path.Join(rootDir, fileName))
-	if err != nil {
-		return nil, err
-	}
+// Read the 'stat' information of a file into the metadata stored by the index. The hash and
+// flags are left empty. Values wider than 32 bits are truncated the same way git does it
+func statMetadata(fileInfo os.FileInfo) (*indexEntryMetadata, error) {
 	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
 	if !ok {
-		return nil, errors.New("Error getting 'stat' information for file: " +
-			path.Join(rootDir, fileName))
-	}
-	hash, err := getHash(path.Join(rootDir, fileName))
-	if err != nil {
-		return nil, err
+		// Files that aren't on disk (e.g. in memory) only have a modification time
+		return fallbackMetadata(fileInfo), nil
 	}
-	entryMetdata := &indexEntryMetadata{
-		Ctime:    covertTimespec(stat.Ctim),
-		Mtime:    TimePair{Sec: int32(fileInfo.ModTime().Second()), Nsec: int32(fileInfo.ModTime().Nanosecond())},
+	return &indexEntryMetadata{
+		Ctime:    statCtime(stat),
+		Mtime:    TimePairFromTime(fileInfo.ModTime()),
 		Ino:      uint32(stat.Ino),
 		Dev:      uint32(stat.Dev),
 		Uid:      stat.Uid,
 		Gid:      stat.Gid,
 		FileMode: getFileMode(fileInfo),
-		FileSize: int32(fileInfo.Size()),
-		Flags:    createFlag(false, false, fileName),
-		ObjHash:  hash,
-	}
-	idxEntry := &Entry{Metadata: entryMetdata, Name: fileName, V3Flags: nil}
-	return idxEntry, nil
+		FileSize: uint32(fileInfo.Size()),
+	}, nil
 }
 
 func covertTimespec(timeSpec syscall.Timespec) TimePair {
-	return TimePair{Sec: int32(timeSpec.Sec), Nsec: int32(timeSpec.Nsec)}
+	return TimePair{Sec: uint32(timeSpec.Sec), Nsec: uint32(timeSpec.Nsec)}
 }
@@ -1,41 +1,57 @@
 package index
 
 import (
-	"encoding/hex"
-	"errors"
-	"github.com/SimonMTaye/gitgo/objects"
 	"os"
 )
 
-//const Regular0755 uint32 = 33261
-//const SymbolicLink uint32 = 40960
+const Regular0755 uint32 = 33261
+const SymbolicLink uint32 = 40960
 const Regular0644 uint32 = 33188
 const GitLink uint32 = 57344
 
-func getHash(filepath string) ([20]byte, error) {
-	hashBytes := [20]byte{}
-	fileBytes, err := os.ReadFile(filepath)
-	if err != nil {
-		return hashBytes, err
+// Process the file mode returned by a 'lstat' call into the format git expects
+// Regular files are either 0644 or 0755 (if the owner's execute bit is set, like git), symbolic links
+// are recorded as such and anything else (i.e. directories for submodules) is a gitlink
+func getFileMode(info os.FileInfo) uint32 {
+	mode := info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		return SymbolicLink
+	}
+	if mode.IsRegular() {
+		if mode&0100 != 0 {
+			return Regular0755
+		}
+		return Regular0644
+	}
+	return GitLink
+}
+
+// ModeMatches Check if the mode recorded for the entry matches the file described by info
+// (which should come from a 'lstat' call). When trustExecutable is false (i.e. core.filemode
+// is false) a difference in only the executable bit is ignored
+func (idx *Entry) ModeMatches(info os.FileInfo, trustExecutable bool) bool {
+	fileMode := getFileMode(info)
+	if fileMode == idx.Metadata.FileMode {
+		return true
 	}
-	emptyBlob := &objects.GitBlob{}
-	emptyBlob.Deserialize(fileBytes)
-	hashStr := objects.Hash(emptyBlob)
-	hash, err := hex.DecodeString(hashStr)
-	if err != nil {
-		return hashBytes, err
+	if trustExecutable {
+		return false
 	}
-	n := copy(hashBytes[:], hash)
-	if n != 20 {
-		return hashBytes, errors.New("expected 20 hash bytes")
+	isRegular := func(mode uint32) bool {
+		return mode == Regular0644 || mode == Regular0755
 	}
-	return hashBytes, nil
+	return isRegular(fileMode) && isRegular(idx.Metadata.FileMode)
 }
 
-// Process the file mode returned by a 'stat' call into the format git expects
-func getFileMode(info os.FileInfo) uint32 {
-	if info.Mode().IsRegular() {
-		return Regular0644
+// Build the metadata of a file whose 'stat' information isn't available (e.g. one kept in
+// memory). The inode, device, uid and gid are left as 0 and the change time is taken to be the
+// modification time
+func fallbackMetadata(fileInfo os.FileInfo) *indexEntryMetadata {
+	mtime := TimePairFromTime(fileInfo.ModTime())
+	return &indexEntryMetadata{
+		Ctime:    mtime,
+		Mtime:    mtime,
+		FileMode: getFileMode(fileInfo),
+		FileSize: uint32(fileInfo.Size()),
 	}
-	return GitLink
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+
+	"github.com/SimonMTaye/gitgo/objects"
 )
 
 type ErrIndexBadlyFormated struct {
@@ -91,10 +93,11 @@ func (eF *entryFlags) nameLength() int {
 // 3 -  External (Version being merged)
 // Sourced from : https://mincong.io/2018/04/28/git-index on 21:50, July 16, 2021
 func (eF *entryFlags) stage() int {
-	// Shift the bits so that the staging bits are at the right end (assuming bits are l-t-r)
-	wantedBits := *eF >> 13
+	// Shift the bits so that the staging bits (the 3rd and 4th most significant bits) are at
+	// the right end
+	wantedBits := *eF >> 12
 	// Zero out all preceding bits, leaving only the staging ones
-	wantedBits = wantedBits & 0x11
+	wantedBits = wantedBits & 0x3
 	return int(wantedBits)
 }
 
@@ -108,21 +111,22 @@ func (idx *Entry) nameLength() int {
 	return idx.Metadata.Flags.nameLength()
 }
 
-// stage Alias for flag.stage()
-func (idx *Entry) stage() int {
+// Stage Alias for flag.stage()
+func (idx *Entry) Stage() int {
 	return idx.Metadata.Flags.stage()
 }
 
 type version3Flags uint16
 
-// TimePair Struct for storing Sec and Nano-sec pair (for c-time and m-time) as an int32 pair
+// TimePair Struct for storing Sec and Nano-sec pair (for c-time and m-time) as an uint32 pair
+// The seconds are the lower 32 bits of the Unix time, as stored by git
 type TimePair struct {
-	Sec  int32
-	Nsec int32
+	Sec  uint32
+	Nsec uint32
 }
 
 // Metadata for an index entry, does not include a name.
-// This struct is used as the length of the struct is fixed allowing for easy parsing
+// Every field has a fixed length for a given hash algorithm, allowing for easy parsing
 // Variable length items (such as v3 headers and names) will be parsed separetly
 type indexEntryMetadata struct {
 	// Last time metadata changed. First int is seconds, second one is fractional nanoseconds
@@ -142,11 +146,36 @@ type indexEntryMetadata struct {
 	// User and group id of the owner of the file
 	Uid uint32
 	Gid uint32
-	// size of file in bytes
-	FileSize int32
+	// size of file in bytes, truncated to 32 bits
+	FileSize uint32
+	// The hash of the object; 20 bytes for SHA1 and 32 bytes for SHA256 repositories
+	ObjHash objects.ObjectID
 	// flags, are 16 bits wide
-	ObjHash [20]byte
-	Flags   entryFlags
+	Flags entryFlags
+}
+
+// The size of the metadata of an entry apart from the hash: 10 32-bit fields and the flags
+const entryMetadataSize = 42
+
+// Read the metadata of an entry whose hash is made with algo
+func readEntryMetadata(src io.Reader, algo *objects.HashAlgorithm) (*indexEntryMetadata, error) {
+	metadata := &indexEntryMetadata{}
+	hash := make([]byte, algo.Size())
+	fields := []interface{}{&metadata.Ctime, &metadata.Mtime, &metadata.Ino, &metadata.Dev,
+		&metadata.FileMode, &metadata.Uid, &metadata.Gid, &metadata.FileSize, hash,
+		&metadata.Flags}
+	for _, field := range fields {
+		err := binary.Read(src, binary.BigEndian, field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	metadata.ObjHash, err = algo.IDFromBytes(hash)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
 }
 
 // Entry Struct that represents a single index entry, usually a file
@@ -162,8 +191,8 @@ type ExtensionMetadata struct {
 	Size      int32
 }
 
-// Extension Holds index extension information. This program won't handle extensions, this is merely
-// for parsing  an index file correctly
+// Extension Holds index extension information. Only the cache tree (see CacheTree) is interpreted,
+// the rest are kept so the index is written back unchanged
 type Extension struct {
 	Metadata *ExtensionMetadata
 	Data     []byte
@@ -174,8 +203,21 @@ type Index struct {
 	Header     *indexHeader
 	Entries    []*Entry
 	Extensions []*Extension
-	// 20 because that's how long sha1 hashes are
+	// Hash The checksum of the index, made with the same algorithm as the objects
 	Hash []byte
+	// Timestamp The modification time of the index file the struct was read from. Entries
+	// modified at or after this time are racy. Zero if the index wasn't read from a file
+	Timestamp TimePair
+	// algo The algorithm of the hashes of the entries and the checksum; nil means SHA1
+	algo *objects.HashAlgorithm
+}
+
+// HashAlgorithm The algorithm of the hashes in the index
+func (idx *Index) HashAlgorithm() *objects.HashAlgorithm {
+	if idx.algo == nil {
+		return objects.SHA1
+	}
+	return idx.algo
 }
 
 // Parse bytes from src into an indexHeader struct
@@ -188,19 +230,18 @@ func parseHeader(src io.Reader) (*indexHeader, error) {
 	return header, nil
 }
 
-// Parse bytes from io.Reader into an IndexEntry
-func parseEntry(src io.Reader, idxVersion int) (*Entry, int, error) {
+// Parse bytes from io.Reader into an IndexEntry whose hash is made with algo
+func parseEntry(src io.Reader, idxVersion int, algo *objects.HashAlgorithm) (*Entry, int, error) {
 	// Does not handle version 4 index so throw Error
 	if idxVersion > 3 {
 		return nil, 0, &ErrIndexBadlyFormated{reason: "only index version 2 and 3 are supported"}
 	}
-	metadata := &indexEntryMetadata{}
-	err := binary.Read(src, binary.BigEndian, metadata)
+	metadata, err := readEntryMetadata(src, algo)
 	if err != nil {
 		return nil, 0, err
 	}
-	// Since the size of the metadata is 62 bytes
-	bytesRead := 62
+	// The metadata is 62 bytes for SHA1 and 74 bytes for SHA256
+	bytesRead := entryMetadataSize + algo.Size()
 	entry := &Entry{Metadata: metadata}
 	// Shortcut to minimize repition
 	flags := entry.Metadata.Flags
@@ -249,11 +290,11 @@ func parseEntry(src io.Reader, idxVersion int) (*Entry, int, error) {
 // If an Error is encounterd, the extensions read so far and the number of bytes read
 // successfully will be returned
 // Expects the data and not an io.reader because the length of the remaining data is
-// required to determine when the extension data is over and the hash data begins
-func parseExtension(data []byte) ([]*Extension, int, error) {
+// required to determine when the extension data is over and the hash (hashSize bytes) begins
+func parseExtension(data []byte, hashSize int) ([]*Extension, int, error) {
 	n := 0
 	extensions := make([]*Extension, 0)
-	for len(data)-n > 20 {
+	for len(data)-n > hashSize {
 		// TODO This is very wasteful, find a better way
 		breader := bytes.NewReader(data[n:])
 		extMeta := &ExtensionMetadata{}
@@ -273,8 +314,8 @@ func parseExtension(data []byte) ([]*Extension, int, error) {
 
 }
 
-// ParseIndex Parse an Index file
-func ParseIndex(src io.Reader) (*Index, error) {
+// ParseIndex Parse an Index file of a repository whose objects are named with algo
+func ParseIndex(src io.Reader, algo *objects.HashAlgorithm) (*Index, error) {
 	header, err := parseHeader(src)
 	if err != nil {
 		return nil, err
@@ -285,7 +326,7 @@ func ParseIndex(src io.Reader) (*Index, error) {
 	entries := make([]*Entry, 0, header.NumEntry)
 	for i := int32(0); i < header.NumEntry; i++ {
 		// Amount of padding bytes : total bytes of entry % 8 (i.e. it is so the entry takes up a multiple of 8 amount of bytes
-		entry, n, err := parseEntry(src, header.versionNum())
+		entry, n, err := parseEntry(src, header.versionNum(), algo)
 		// If the version is 2 or 3, reading the padding bytes
 		// This check is here even though the version is already guaranteed to be 2 or 3
 		// in case version 4 is supported by future versions
@@ -303,23 +344,21 @@ func ParseIndex(src io.Reader) (*Index, error) {
 		}
 		entries = append(entries, entry)
 	}
-	//Extensions are currently ignored, return Error if extensions are found Extensions
-	idx := &Index{Entries: entries, Header: header}
-	// Storage for extension data, won't be parsed for now
+	idx := &Index{Entries: entries, Header: header, algo: algo}
+	// Storage for extension data, interpreted when needed (see CacheTree)
 	remaining, err := io.ReadAll(src)
 	if err != nil {
 		return nil, err
 	}
-	extensions, n, err := parseExtension(remaining)
+	extensions, n, err := parseExtension(remaining, algo.Size())
 	if err != nil {
 		return nil, err
 	}
 	// The hash will be the reminaing bytes without the the extension data
 	hash := remaining[n:]
 
-	// if the hash is not 20 bytes long (i.e. it is not a valid sha1 hash, return an
-	// Error)
-	hashLen := 20
+	// if the hash is not as long as the algorithm's hashes, return an Error
+	hashLen := algo.Size()
 	if len(hash) < hashLen {
 		return nil, &ErrIndexBadlyFormated{reason: "index is invalid; expeceted more data"}
 	} else if len(hash) > hashLen {
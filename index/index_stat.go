@@ -0,0 +1,117 @@
+package index
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Read a file path and create an entry holding the hash of its blob made with algo
+func createEntry(rootDir string, fileName string, algo *objects.HashAlgorithm) (*Entry, error) {
+	fileInfo, err := os.Lstat(path.Join(rootDir, fileName))
+	if err != nil {
+		return nil, err
+	}
+	// HashFile takes care of hashing the target of symbolic links instead of following them
+	// and doesn't read the whole file into memory
+	hash, err := algo.HashFile(path.Join(rootDir, fileName))
+	if err != nil {
+		return nil, err
+	}
+	return NewFileEntry(fileName, fileInfo, hash)
+}
+
+// NewFileEntry Create an entry for a file whose blob has already been hashed. fileInfo should
+// come from a 'lstat' call made before the file was read, so changes made while it was being
+// hashed are detected later
+func NewFileEntry(fileName string, fileInfo os.FileInfo, hash objects.ObjectID) (*Entry, error) {
+	entryMetadata, err := statMetadata(fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	entryMetadata.ObjHash = hash
+	entryMetadata.Flags = createFlag(false, false, fileName)
+	return &Entry{Metadata: entryMetadata, Name: fileName, V3Flags: nil}, nil
+}
+
+// NewEntry Create an entry for a file that is known only by its name, mode and hash (e.g. when
+// it comes from a tree). The entry has no 'stat' information, so it will always be compared by
+// content until RefreshStat is called
+func NewEntry(name string, mode uint32, hash objects.ObjectID) *Entry {
+	metadata := &indexEntryMetadata{
+		FileMode: mode,
+		ObjHash:  hash,
+		Flags:    createFlag(false, false, name),
+	}
+	return &Entry{Metadata: metadata, Name: name, V3Flags: nil}
+}
+
+// RefreshStat Update the 'stat' information of an entry from the file in rootDir, keeping the
+// recorded hash. Should only be used when the file is known to match the hash
+func (idx *Entry) RefreshStat(rootDir string) error {
+	fileInfo, err := os.Lstat(path.Join(rootDir, idx.Name))
+	if err != nil {
+		return err
+	}
+	return idx.SetStat(fileInfo)
+}
+
+// SetStat Update the 'stat' information of an entry from fileInfo (from a 'lstat' call),
+// keeping the recorded hash. Like RefreshStat but for files that aren't on the OS filesystem
+func (idx *Entry) SetStat(fileInfo os.FileInfo) error {
+	entryMetadata, err := statMetadata(fileInfo)
+	if err != nil {
+		return err
+	}
+	entryMetadata.ObjHash = idx.Metadata.ObjHash
+	entryMetadata.Flags = idx.Metadata.Flags
+	idx.Metadata = entryMetadata
+	return nil
+}
+
+// TimePairFromTime Convert a time into the seconds + nanoseconds pair stored in the index. The seconds are
+// the Unix time truncated to 32 bits
+func TimePairFromTime(t time.Time) TimePair {
+	return TimePair{Sec: uint32(t.Unix()), Nsec: uint32(t.Nanosecond())}
+}
+
+// Before Check if the time pair is strictly earlier than other
+func (tp TimePair) Before(other TimePair) bool {
+	if tp.Sec != other.Sec {
+		return tp.Sec < other.Sec
+	}
+	return tp.Nsec < other.Nsec
+}
+
+// StatMatches Check if the 'stat' information of a file (from a 'lstat' call) is the same as
+// what was recorded in the entry when it was added. If it is, the file can be assumed to be
+// unchanged without reading it unless the entry is racy (see IsRacy).
+// The executable bit is not compared here, use ModeMatches for that
+func (idx *Entry) StatMatches(info os.FileInfo) bool {
+	stat, err := statMetadata(info)
+	if err != nil {
+		return false
+	}
+	recorded := idx.Metadata
+	// Only compare the object type; permissions are handled by ModeMatches
+	if stat.FileMode&0170000 != recorded.FileMode&0170000 {
+		return false
+	}
+	return stat.Mtime == recorded.Mtime &&
+		stat.Ctime == recorded.Ctime &&
+		stat.Ino == recorded.Ino &&
+		stat.Dev == recorded.Dev &&
+		stat.Uid == recorded.Uid &&
+		stat.Gid == recorded.Gid &&
+		stat.FileSize == recorded.FileSize
+}
+
+// IsRacy Check if an entry is 'racily clean'. If a file was modified in the same instant the
+// index was written, its 'stat' information can match the entry even though the contents
+// differ. Entries that weren't modified strictly before the index was written (indexTime)
+// must have their contents compared instead of relying on StatMatches
+func (idx *Entry) IsRacy(indexTime TimePair) bool {
+	return !idx.Metadata.Mtime.Before(indexTime)
+}
@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path"
 	"testing"
 	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
 )
 
 // V3 Flag parsing/reading NOT tested
@@ -176,7 +181,7 @@ func TestIndexRead(t *testing.T) {
 	hash := sha1.Sum(indexBytes)
 	indexBytes = append(indexBytes, hash[:]...)
 
-	index, err := ParseIndex(bytes.NewReader(indexBytes))
+	index, err := ParseIndex(bytes.NewReader(indexBytes), objects.SHA1)
 	if err != nil {
 		t.Fatalf("Unexpected Error when parsing index:\n%s", err.Error())
 	}
@@ -200,16 +205,16 @@ func TestIndexRead(t *testing.T) {
 	readEntry2Bytes := index.Entries[0].Serialize()
 	equal, i := equalSlices(readEntry2Bytes, entry2)
 	if !equal {
-		readEntry2, _, _ := parseEntry(bytes.NewReader(readEntry2Bytes), 3)
-		entry2parsed, _, _ := parseEntry(bytes.NewReader(entry2), 3)
+		readEntry2, _, _ := parseEntry(bytes.NewReader(readEntry2Bytes), 3, objects.SHA1)
+		entry2parsed, _, _ := parseEntry(bytes.NewReader(entry2), 3, objects.SHA1)
 		t.Errorf("Expected entry2 to be equal to the first entry in index but byte %d is different.\nEntries:\n%s\n%s\n",
 			i, entry2parsed.String(), readEntry2.String())
 	}
 	readEntry1Bytes := index.Entries[1].Serialize()
 	equal, i = equalSlices(readEntry1Bytes, entry1)
 	if !equal {
-		readEntry1, _, _ := parseEntry(bytes.NewReader(readEntry1Bytes), 3)
-		entry1parsed, _, _ := parseEntry(bytes.NewReader(entry1), 3)
+		readEntry1, _, _ := parseEntry(bytes.NewReader(readEntry1Bytes), 3, objects.SHA1)
+		entry1parsed, _, _ := parseEntry(bytes.NewReader(entry1), 3, objects.SHA1)
 		t.Errorf("Expected entry1 to be equal to the second entry in index but byte %d is different.\nEntries:\n%s\n%s\n",
 			i, entry1parsed.String(), readEntry1.String())
 	}
@@ -320,7 +325,7 @@ func TestIndexExists(t *testing.T) {
 	hash := sha1.Sum(indexBytes)
 	indexBytes = append(indexBytes, hash[:]...)
 
-	index, err := ParseIndex(bytes.NewReader(indexBytes))
+	index, err := ParseIndex(bytes.NewReader(indexBytes), objects.SHA1)
 	if err != nil {
 		t.Fatalf("Unexpected Error when parsing index: \n%s", err.Error())
 	}
@@ -428,7 +433,7 @@ func TestIndexDelete(t *testing.T) {
 	hash := sha1.Sum(indexBytes)
 	indexBytes = append(indexBytes, hash[:]...)
 
-	index, err := ParseIndex(bytes.NewReader(indexBytes))
+	index, err := ParseIndex(bytes.NewReader(indexBytes), objects.SHA1)
 	if err != nil {
 		t.Fatalf("Unexpected Error when parsing index: \n%s", err.Error())
 	}
@@ -518,3 +523,262 @@ func EntryBytes(ctime [2]int32,
 	data = append(data, 0x0)
 	return data, nil
 }
+
+// Test that regular, executable and symbolic link files get the mode git expects
+func TestGetFileMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	regular := path.Join(tmpDir, "regular")
+	executable := path.Join(tmpDir, "script.sh")
+	link := path.Join(tmpDir, "link")
+	// Only the owner's execute bit makes a file executable
+	groupExec := path.Join(tmpDir, "group-exec")
+	if err := os.WriteFile(groupExec, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Unexpected Error when creating file:\n%s", err.Error())
+	}
+	if err := os.Chmod(groupExec, 0655); err != nil {
+		t.Fatalf("Unexpected Error when changing mode:\n%s", err.Error())
+	}
+	if err := os.WriteFile(regular, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Unexpected Error when creating file:\n%s", err.Error())
+	}
+	if err := os.WriteFile(executable, []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatalf("Unexpected Error when creating file:\n%s", err.Error())
+	}
+	if err := os.Symlink("regular", link); err != nil {
+		t.Fatalf("Unexpected Error when creating symlink:\n%s", err.Error())
+	}
+	expected := map[string]uint32{regular: Regular0644, executable: Regular0755, link: SymbolicLink,
+		groupExec: Regular0644}
+	for file, mode := range expected {
+		info, err := os.Lstat(file)
+		if err != nil {
+			t.Fatalf("Unexpected Error when reading file info:\n%s", err.Error())
+		}
+		if getFileMode(info) != mode {
+			t.Errorf("Expected mode of %s to be %o, Got: %o", file, mode, getFileMode(info))
+		}
+	}
+
+	entry, err := createEntry(tmpDir, "link", objects.SHA1)
+	if err != nil {
+		t.Fatalf("Unexpected Error when creating entry for symlink:\n%s", err.Error())
+	}
+	// The hash of a symlink is the hash of a blob containing its target
+	targetHash := sha1.Sum([]byte("blob 7\x00regular"))
+	if entry.Metadata.ObjHash != rawID(targetHash[:]) {
+		t.Errorf("Expected symlink entry to hold the hash of its target")
+	}
+	// A file that lost its executable bit only matches when core.filemode is false
+	if err := os.Chmod(executable, 0644); err != nil {
+		t.Fatalf("Unexpected Error when changing file mode:\n%s", err.Error())
+	}
+	info, err := os.Lstat(executable)
+	if err != nil {
+		t.Fatalf("Unexpected Error when reading file info:\n%s", err.Error())
+	}
+	execEntry := &Entry{Metadata: &indexEntryMetadata{FileMode: Regular0755}, Name: "script.sh"}
+	if execEntry.ModeMatches(info, true) {
+		t.Errorf("Expected mode change to be detected when the executable bit is trusted")
+	}
+	if !execEntry.ModeMatches(info, false) {
+		t.Errorf("Expected mode change to be ignored when the executable bit isn't trusted")
+	}
+}
+
+// Test that entries record the full Unix mtime and that stat and racy checks work
+func TestEntryStatData(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := path.Join(tmpDir, "file.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Unexpected Error when creating file:\n%s", err.Error())
+	}
+	mtime := time.Date(2021, 7, 16, 21, 50, 13, 500, time.UTC)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("Unexpected Error when setting file times:\n%s", err.Error())
+	}
+	entry, err := createEntry(tmpDir, "file.txt", objects.SHA1)
+	if err != nil {
+		t.Fatalf("Unexpected Error when creating entry:\n%s", err.Error())
+	}
+	if entry.Metadata.Mtime.Sec != uint32(mtime.Unix()) || entry.Metadata.Mtime.Nsec != 500 {
+		t.Errorf("Expected mtime to be %d.%d, Got: %d.%d", mtime.Unix(), 500,
+			entry.Metadata.Mtime.Sec, entry.Metadata.Mtime.Nsec)
+	}
+	if entry.Metadata.FileSize != 5 {
+		t.Errorf("Expected file size to be 5, Got: %d", entry.Metadata.FileSize)
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		t.Fatalf("Unexpected Error when reading file info:\n%s", err.Error())
+	}
+	if !entry.StatMatches(info) {
+		t.Errorf("Expected stat information of an unchanged file to match")
+	}
+	if err := os.WriteFile(file, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Unexpected Error when modifying file:\n%s", err.Error())
+	}
+	info, err = os.Lstat(file)
+	if err != nil {
+		t.Fatalf("Unexpected Error when reading file info:\n%s", err.Error())
+	}
+	if entry.StatMatches(info) {
+		t.Errorf("Expected stat information of a modified file to differ")
+	}
+
+	// Entries are racy unless they were modified strictly before the index was written
+	if entry.IsRacy(TimePairFromTime(mtime.Add(time.Second))) {
+		t.Errorf("Did not expect entry modified before the index to be racy")
+	}
+	if !entry.IsRacy(TimePairFromTime(mtime)) {
+		t.Errorf("Expected entry modified at the same time as the index to be racy")
+	}
+	if !entry.IsRacy(TimePair{}) {
+		t.Errorf("Expected entry to be racy when the index time is unknown")
+	}
+}
+
+// Test that renaming an entry updates its name length and keeps the index sorted
+func TestRenameEntry(t *testing.T) {
+	idx := EmptyIndex(objects.SHA1)
+	for _, name := range []string{"a", "c", "dir/file"} {
+		hash := sha1.Sum([]byte(name))
+		err := idx.AddEntry(NewEntry(name, Regular0644, rawID(hash[:])))
+		if err != nil {
+			t.Fatalf("Unexpected Error when adding entry: %s", err)
+		}
+	}
+	err := idx.RenameEntry("a", "renamed/longer name")
+	if err != nil {
+		t.Fatalf("Unexpected Error when renaming entry: %s", err)
+	}
+	names := []string{"c", "dir/file", "renamed/longer name"}
+	for i, entry := range idx.Entries {
+		if entry.Name != names[i] {
+			t.Errorf("Expected entry %d to be %s, Got: %s", i, names[i], entry.Name)
+		}
+	}
+	_, pos := idx.EntryExists("renamed/longer name")
+	entry := idx.Entries[pos]
+	if entry.nameLength() != len("renamed/longer name") {
+		t.Errorf("Expected name length %d, Got: %d", len("renamed/longer name"), entry.nameLength())
+	}
+	if hash := sha1.Sum([]byte("a")); entry.Metadata.ObjHash != rawID(hash[:]) {
+		t.Errorf("Expected the hash to be kept when renaming")
+	}
+	if idx.RenameEntry("c", "dir/file") == nil {
+		t.Errorf("Expected an Error when renaming onto an existing entry")
+	}
+	if idx.RenameEntry("missing", "other") == nil {
+		t.Errorf("Expected an Error when renaming a missing entry")
+	}
+}
+
+// An index written by 'git add' in a SHA256 repository holding 'file' and 'dir/other', with the
+// TREE extension
+const sha256IndexHex = "4449524300000002000000026ad53b6403e30d566ad53b6403e30d560000fe000092c353000081a4" +
+	"000000000000000000000006e18941661c834f08aa0a19e626484916937df12c0e08d5f015b3b53d0284aa0200096469" +
+	"722f6f7468657200000000006ad53b6403e30d566ad53b6403e30d560000fe000092c334000081a40000000000000000" +
+	"000000087c490ebf9db90b84753749c721ef2bedfeb85c1da94160f2619df1249c64bdda000466696c65000054524545" +
+	"0000004d003220310aefe77f7a73d2ec0d0d8f78392bfb0e52955333a87f4275c7944c57ee797fe07164697200312030" +
+	"0a34ccd66e427e0303fcf0f1b91e69b72ff0a7a899af7746162bcc6a1e978e7f95a9524db7cd3559b4a3348f1d7a3384" +
+	"5666f5f612a1b7fc63b472299b26b26f34"
+
+// Test reading and writing the index of a SHA256 repository
+func TestSHA256Index(t *testing.T) {
+	indexBytes, _ := hex.DecodeString(sha256IndexHex)
+	idx, err := ParseIndex(bytes.NewReader(indexBytes), objects.SHA256)
+	if err != nil {
+		t.Fatalf("Unexpected Error when parsing index:\n%s", err.Error())
+	}
+	hashes := map[string]string{
+		"dir/other": "e18941661c834f08aa0a19e626484916937df12c0e08d5f015b3b53d0284aa02",
+		"file":      "7c490ebf9db90b84753749c721ef2bedfeb85c1da94160f2619df1249c64bdda",
+	}
+	if len(idx.Entries) != len(hashes) {
+		t.Fatalf("Expected %d entries, Got: %d", len(hashes), len(idx.Entries))
+	}
+	for _, entry := range idx.Entries {
+		if entry.Hash().String() != hashes[entry.Name] {
+			t.Errorf("Expected %s to have hash %s, Got: %s", entry.Name, hashes[entry.Name], entry.Hash())
+		}
+	}
+	if equal, i := equalSlices(idx.Serialize(), indexBytes); !equal {
+		t.Errorf("Expected the serialized index to match the one written by git, differs at byte %d", i)
+	}
+	if _, err := ParseIndex(bytes.NewReader(indexBytes), objects.SHA1); err == nil {
+		t.Errorf("Expected an Error when reading a SHA256 index as SHA1")
+	}
+	sha1Hash := sha1.Sum([]byte("file"))
+	if idx.AddEntry(NewEntry("sha1", Regular0644, rawID(sha1Hash[:]))) == nil {
+		t.Errorf("Expected a SHA1 hash to be rejected by a SHA256 index")
+	}
+}
+
+// Test that entries are formatted like 'git ls-files --stage' and that the stage bits are read
+func TestEntryString(t *testing.T) {
+	hash := sha1.Sum([]byte("content"))
+	entry := NewEntry("dir/file", Regular0755, rawID(hash[:]))
+	expected := "100755 " + hex.EncodeToString(hash[:]) + " 0\tdir/file"
+	if entry.String() != expected {
+		t.Errorf("Expected '%s', Got: '%s'", expected, entry.String())
+	}
+	for stage := 0; stage < 4; stage++ {
+		flags := entryFlags(0x8000|stage<<12) | entryFlags(len(entry.Name))
+		entry.Metadata.Flags = flags
+		if entry.Stage() != stage {
+			t.Errorf("Expected stage %d from flags %016b, Got: %d", stage, flags, entry.Stage())
+		}
+	}
+}
+
+// rawID The ObjectID of a raw SHA1 or SHA256 hash
+func rawID(raw []byte) objects.ObjectID {
+	for _, algo := range []*objects.HashAlgorithm{objects.SHA1, objects.SHA256} {
+		if oid, err := algo.IDFromBytes(raw); err == nil {
+			return oid
+		}
+	}
+	panic("not a hash")
+}
+
+// Index written by git for a commit of 'f' and 'd/g', with the trees of both directories cached
+const cacheTreeIndexHex = "4449524300000002000000026ad54bc516471caa6ad54bc516471caa0000fe000092e3a1000081a4" +
+	"00000000000000000000000261780798228d17af2d34fce4cfbdf355568324720003642f67000000000000006ad54bc5" +
+	"16471caa6ad54bc516471caa0000fe000092e392000081a400000000000000000000000278981922613b2afb6025042f" +
+	"f6bd878ac1994e85000166005452454500000033003220310ab9d77cfae9c01d71f6ea7da972e0badcb9630f70640031" +
+	"20300a152b59ab314c9865b262493a7747921526c46a1e1914c4d55aafbc3be09ae4008ab3a9598a5d6dd5"
+
+// Test reading the trees cached in the TREE extension, skipping invalidated ones
+func TestCacheTree(t *testing.T) {
+	indexBytes, _ := hex.DecodeString(cacheTreeIndexHex)
+	idx, err := ParseIndex(bytes.NewReader(indexBytes), objects.SHA1)
+	if err != nil {
+		t.Fatalf("Unexpected Error when parsing index:\n%s", err.Error())
+	}
+	trees, err := idx.CacheTree()
+	expected := []string{"b9d77cfae9c01d71f6ea7da972e0badcb9630f70", "152b59ab314c9865b262493a7747921526c46a1e"}
+	if err != nil || len(trees) != len(expected) {
+		t.Fatalf("Expected trees %v, Got: %v, %v", expected, trees, err)
+	}
+	for i, tree := range trees {
+		if tree.String() != expected[i] {
+			t.Errorf("Expected tree %s, Got: %s", expected[i], tree)
+		}
+	}
+	// The root's tree was invalidated by a change to 'f'
+	invalidated := EmptyIndex(objects.SHA1)
+	subtree := objects.MustParseObjectID(expected[1])
+	err = invalidated.AddExtension(cacheTreeSignature, append([]byte("\x00-1 1\nd\x001 0\n"), subtree.Bytes()...))
+	if err != nil {
+		t.Fatalf("Unexpected Error when adding extension:\n%s", err.Error())
+	}
+	trees, err = invalidated.CacheTree()
+	if err != nil || len(trees) != 1 || trees[0] != subtree {
+		t.Errorf("Expected only the tree of 'd', Got: %v, %v", trees, err)
+	}
+	truncated := EmptyIndex(objects.SHA1)
+	truncated.AddExtension(cacheTreeSignature, []byte("\x001 0\nshort"))
+	if _, err = truncated.CacheTree(); err == nil {
+		t.Errorf("Expected an Error for a truncated cache tree")
+	}
+}
@@ -2,12 +2,12 @@ package index
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"encoding/binary"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"sort"
+
+	"github.com/SimonMTaye/gitgo/objects"
 )
 
 // Returns a header struct with the signature bits set to the default
@@ -52,9 +52,13 @@ func createFlag(assumeValid bool, extended bool, name string) entryFlags {
 // needs to be done
 func (idxMdt *indexEntryMetadata) Serialize() []byte {
 	buf := &bytes.Buffer{}
-	err := binary.Write(buf, binary.BigEndian, idxMdt)
-	if err != nil {
-		panic(err)
+	fields := []interface{}{idxMdt.Ctime, idxMdt.Mtime, idxMdt.Ino, idxMdt.Dev, idxMdt.FileMode,
+		idxMdt.Uid, idxMdt.Gid, idxMdt.FileSize, idxMdt.ObjHash.Bytes(), idxMdt.Flags}
+	for _, field := range fields {
+		err := binary.Write(buf, binary.BigEndian, field)
+		if err != nil {
+			panic(err)
+		}
 	}
 	return buf.Bytes()
 }
@@ -82,37 +86,21 @@ func (idx *Entry) Serialize() []byte {
 }
 
 // Hash Conveinience function for getting the hash of an object
-func (idx *Entry) Hash() []byte {
-	return idx.Metadata.ObjHash[:]
+func (idx *Entry) Hash() objects.ObjectID {
+	return idx.Metadata.ObjHash
 }
 
-// Convert a file mode (stored as a uint32) into a human-readable string
+// Convert a file mode (stored as a uint32) into a human-readable string, i.e. the octal
+// representation used by git (e.g. 100644)
 func formattedMode(mode uint32) string {
-	modestr := ""
-	for i := 0; i < 3; i++ {
-		// +16 because the first 16 bits are always set to 0 and the data
-		// begins at bit 16 (i.e. the 17th bit)
-		if BitSet32(mode, i+16) {
-			modestr += "1"
-		} else {
-			modestr += "0"
-		}
-	}
-	for i := 0; i < 3; i++ {
-		// Check the 3-bit values that represent user, group and everyone's permission
-		// each permission is set by 3 bits (i.e. value of 0-7)
-		bitgroup := mode >> (6 - (i * 3))
-		bitgroup = bitgroup & 0x7
-		modestr += fmt.Sprint(bitgroup)
-	}
-	return modestr
+	return fmt.Sprintf("%06o", mode)
 }
 
-// Converts an entry into its string form
+// Converts an entry into its string form; the same format as 'git ls-files --stage'
+// (mode, hash, stage and name)
 func (idx *Entry) String() string {
-	hashString := hex.EncodeToString(idx.Hash())
 	modeString := formattedMode(idx.Metadata.FileMode)
-	return fmt.Sprintf("%s %s %d\t%s", modeString, hashString, idx.stage(), idx.Name)
+	return fmt.Sprintf("%s %s %d\t%s", modeString, idx.Hash(), idx.Stage(), idx.Name)
 }
 
 // Serialize Convert an Extension's metadata into a byte slice
@@ -165,17 +153,22 @@ func (idx *Index) bytesWithoutHash() []byte {
 
 // Sets the indexs hash field based on the data it contains
 func (idx *Index) calculateHash() error {
-	dataInBytes := idx.bytesWithoutHash()
-	hash := sha1.Sum(dataInBytes)
-	idx.Hash = hash[:]
+	hasher := idx.HashAlgorithm().NewChecksum()
+	hasher.Write(idx.bytesWithoutHash())
+	idx.Hash = hasher.Sum(nil)
 	// In case there is an Error in this process, return it
 	return nil
 }
 
 // Serialize Adds the hash to the []byte returned by bytesWithoutHash. Functions are separated
-// for use when computing the hash it self
+// for use when computing the hash it self. The hash is recomputed first since entries may have
+// been modified in place (e.g. by RefreshStat)
 func (idx *Index) Serialize() []byte {
-	return append(idx.bytesWithoutHash(), idx.Hash...)
+	data := idx.bytesWithoutHash()
+	hasher := idx.HashAlgorithm().NewChecksum()
+	hasher.Write(data)
+	idx.Hash = hasher.Sum(nil)
+	return append(data, idx.Hash...)
 }
 
 // EntryExists Check if an entry with the specified name exists
@@ -211,9 +204,19 @@ func (idx *Index) updateEntry(entry *Entry) error {
 	return idx.addEntry(entry)
 }
 
+// AddEntry Adds an entry to the index, replacing any existing entry with the same name. The
+// hash of the entry must be as long as the hashes of the index's algorithm
+func (idx *Index) AddEntry(entry *Entry) error {
+	if entry.Hash().Size() != idx.HashAlgorithm().Size() {
+		return fmt.Errorf("entry %s has a %d byte hash, but %s hashes are %d bytes", entry.Name,
+			entry.Hash().Size(), idx.HashAlgorithm().Name(), idx.HashAlgorithm().Size())
+	}
+	return idx.updateEntry(entry)
+}
+
 // AddFile AddFiles adds a file to the index or updates its information if it already exists
 func (idx *Index) AddFile(rootDir string, fileName string) error {
-	entry, err := createEntry(rootDir, fileName)
+	entry, err := createEntry(rootDir, fileName, idx.HashAlgorithm())
 	if err != nil {
 		return err
 	}
@@ -223,16 +226,42 @@ func (idx *Index) AddFile(rootDir string, fileName string) error {
 }
 
 // ModifyFileHash Sets the hash of an entry to the provided hash
-func (idx *Index) ModifyFileHash(fileName string, newHash *[20]byte) error {
+func (idx *Index) ModifyFileHash(fileName string, newHash objects.ObjectID) error {
 	exists, pos := idx.EntryExists(fileName)
 	if !exists {
 		return fmt.Errorf("file %s does not exist in index", fileName)
 	}
+	if newHash.Size() != idx.HashAlgorithm().Size() {
+		return fmt.Errorf("%d byte hash is not a valid %s hash", newHash.Size(), idx.HashAlgorithm().Name())
+	}
 	entry := idx.Entries[pos]
-	if entry.Metadata.ObjHash == *newHash {
+	if entry.Metadata.ObjHash == newHash {
 		return fmt.Errorf("file %s already has same hash", fileName)
 	}
-	return nil
+	entry.Metadata.ObjHash = newHash
+	return idx.calculateHash()
+}
+
+// RenameEntry Give an entry a new name, keeping its hash, mode and stat data. Fails if there is
+// no entry called oldName or if an entry called newName already exists
+func (idx *Index) RenameEntry(oldName string, newName string) error {
+	exists, pos := idx.EntryExists(oldName)
+	if !exists {
+		return fmt.Errorf("file %s does not exist in index", oldName)
+	}
+	if exists, _ := idx.EntryExists(newName); exists {
+		return fmt.Errorf("file %s already exists in index", newName)
+	}
+	entry := idx.Entries[pos]
+	entry.Name = newName
+	// Replace the name length stored in the lower 12 bits of the flags
+	nameLen := len(newName)
+	if nameLen > 4095 {
+		nameLen = 4095
+	}
+	entry.Metadata.Flags = entry.Metadata.Flags&^0x0fff | entryFlags(nameLen)
+	idx.sortEntries()
+	return idx.calculateHash()
 }
 
 // DeleteEntry Delete an Entry from the index
@@ -242,7 +271,8 @@ func (idx *Index) DeleteEntry(pos int) error {
 	}
 	// Costly operation
 	idx.Entries = append(idx.Entries[:pos], idx.Entries[pos+1:]...)
-	return nil
+	idx.Header.NumEntry--
+	return idx.calculateHash()
 }
 
 // Sorts the entries in an index based on their Name (i.e. file name) and if they match
@@ -252,7 +282,7 @@ func (idx *Index) sortEntries() {
 	// Index is ALWAYS assumed to be sorted
 	sort.Slice(idx.Entries, func(i, j int) bool {
 		if idx.Entries[i].Name == idx.Entries[j].Name {
-			return idx.Entries[i].stage() < idx.Entries[j].stage()
+			return idx.Entries[i].Stage() < idx.Entries[j].Stage()
 		}
 		return idx.Entries[i].Name < idx.Entries[j].Name
 	})
@@ -279,14 +309,16 @@ func (idx *Index) IsEmpty() bool {
 	return idx.Header.NumEntry == 0
 }
 
-// EmptyIndex Create an empty index. Used for repositories where the staging file is not present
-func EmptyIndex() *Index {
+// EmptyIndex Create an empty index for a repository whose objects are named with algo. Used for
+// repositories where the staging file is not present
+func EmptyIndex(algo *objects.HashAlgorithm) *Index {
 	header := createIndexHeader(2, 0)
 	index := &Index{
 		Header:     &header,
 		Entries:    make([]*Entry, 0),
 		Extensions: make([]*Extension, 0),
-		Hash:       make([]byte, 20),
+		Hash:       make([]byte, algo.Size()),
+		algo:       algo,
 	}
 	err := index.calculateHash()
 	if err != nil {
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SimonMTaye/gitgo/config"
 	"github.com/SimonMTaye/gitgo/objects"
 	"github.com/SimonMTaye/gitgo/repo"
+	"github.com/SimonMTaye/gitgo/vfs"
 	"github.com/teris-io/cli"
 )
 
@@ -43,9 +48,11 @@ var add = cli.NewCommand("add", "stage a file").
 	})
 
 // cat file command
-var catFileHelp = "Usage cat-file [hash]"
+var catFileHelp = "Usage:\n\tcat-file (-p | -t | -s | -e) <object>\n\tcat-file (--batch | --batch-check)"
 var catFile = cli.NewCommand("cat-file", "display content of an object").
-	WithArg(cli.NewArg("hash", "a uniquely identifiying hash")).
+	WithArg(
+		cli.NewArg("hash", "a uniquely identifiying hash").
+			AsOptional()).
 	WithOption(
 		cli.NewOption("type", "show only the type of the object").
 			WithChar('t').
@@ -54,47 +61,155 @@ var catFile = cli.NewCommand("cat-file", "display content of an object").
 		cli.NewOption("size", "show only the size of the object").
 			WithChar('s').
 			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("pretty", "pretty-print the contents of the object based on its type").
+			WithChar('p').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("exists", "exit with zero status if the object exists and is valid").
+			WithChar('e').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("batch", "print the hash, type, size and contents of each object named on stdin").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("batch-check", "print the hash, type and size of each object named on stdin").
+			WithType(cli.TypeBool)).
 	WithAction(func(args []string, options map[string]string) int {
-		if len(args) == 0 {
-			fmt.Println(catFileHelp)
-			return 1
-		} else if len(args) > 1 {
+		batch := options["batch"] == "true"
+		batchCheck := options["batch-check"] == "true"
+		if batch || batchCheck {
+			if len(args) != 0 {
+				fmt.Println(catFileHelp)
+				return 1
+			}
+			repoStruct, err := FindandOpenRepo()
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			err = repoStruct.CatFileBatch(os.Stdin, os.Stdout, batch)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			return 0
+		}
+		if len(args) != 1 {
 			fmt.Println(catFileHelp)
 			return 1
 		}
-		cwd, err := os.Getwd()
+		repoStruct, err := FindandOpenRepo()
 		if err != nil {
 			fmt.Println(err)
+			return 1
 		}
-		repoDir, err := repo.FindRepo(cwd)
-		if err != nil {
-			fmt.Println(err)
+		hash, err := repoStruct.FindObject(args[0])
+		// Only the header is read until the content is needed, so large blobs are streamed
+		var reader *objects.ObjectReader
+		if err == nil {
+			reader, err = repoStruct.OpenObject(hash)
+		}
+		if options["exists"] == "true" {
+			// Only the exit code is used
+			if err != nil {
+				return 1
+			}
+			reader.Close()
+			return 0
 		}
-		obj, err := CatfileHelper(repoDir, args[0])
 		if err != nil {
 			fmt.Println(err)
+			return 1
 		}
+		defer reader.Close()
 		if options["size"] == "true" {
-			fmt.Println(obj.Size())
-			// Print object size
+			fmt.Println(reader.Size)
 			return 0
 		} else if options["type"] == "true" {
-			fmt.Println(obj.Type())
+			fmt.Println(reader.Type)
+			return 0
+		} else if options["pretty"] == "true" && reader.Type != objects.Tree {
+			// Everything but trees is printed as it is stored
+			_, err = io.Copy(os.Stdout, reader)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			return 0
+		} else if options["pretty"] == "true" {
+			content, err := io.ReadAll(reader)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			pretty, err := repo.PrettyObject(repoStruct.HashAlgorithm(), reader.Type, content)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			os.Stdout.Write(pretty)
 			return 0
 		} else {
+			// Kept for compatibility; print the parsed object
+			obj, err := repoStruct.GetObject(hash)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
 			fmt.Println(obj)
 			return 0
 		}
 	})
 
 var initCommand = cli.NewCommand("init", "create a new repository").
+	WithOption(
+		cli.NewOption("shared-with", "read objects from another repository or objects directory").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("object-format", "the hash used to name objects: sha1 (default) or sha256").
+			WithType(cli.TypeString)).
 	WithAction(func(args []string, options map[string]string) int {
 		cwd, err := os.Getwd()
 		if err != nil {
 			fmt.Println(err)
 			return 1
 		}
-		err = repo.CreateRepo(cwd, "", "")
+		algo := objects.SHA1
+		if format, ok := options["object-format"]; ok {
+			algo, err = objects.HashAlgorithmByName(format)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+		}
+		shared, sharing := options["shared-with"]
+		if sharing {
+			// Store an absolute path so the alternate still works if the new repo is moved. It is
+			// checked before the repo is created so a bad path doesn't leave a repo behind
+			shared, err = filepath.Abs(shared)
+			if err == nil {
+				_, err = repo.FindObjectsDir(vfs.OS, filepath.ToSlash(shared))
+			}
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+		}
+		err = repo.CreateRepoWithHash(cwd, "", "", algo)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if !sharing {
+			return 0
+		}
+		repoStruct, err := repo.OpenRepo(cwd)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		err = repoStruct.AddAlternate(filepath.ToSlash(shared))
 		if err != nil {
 			fmt.Println(err)
 			return 1
@@ -208,7 +323,7 @@ var tagCommand = cli.NewCommand("tag", "tag an object with a name").
 				fmt.Println(err)
 				return 1
 			}
-			err = repoStruct.SaveTag(args[0], objects.Hash(tag))
+			err = repoStruct.SaveTag(args[0], repoStruct.HashAlgorithm().Hash(tag))
 			if err != nil {
 				fmt.Println(err)
 				return 1
@@ -226,8 +341,32 @@ var tagCommand = cli.NewCommand("tag", "tag an object with a name").
 		}
 	})
 
-//List files in the index (doesn't support other files for now)
+// List files in the index and/or the worktree
 var lsFilesCommand = cli.NewCommand("ls-files", "show information about files in the work tree or index").
+	WithOption(
+		cli.NewOption("cached", "show cached files in the output (default)").
+			WithChar('c').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("stage", "show staged contents' mode bits, object name and stage number").
+			WithChar('s').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("modified", "show modified files").
+			WithChar('m').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("deleted", "show deleted files").
+			WithChar('d').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("others", "show untracked files that aren't ignored").
+			WithChar('o').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("z", "terminate entries with NUL instead of a newline").
+			WithChar('z').
+			WithType(cli.TypeBool)).
 	WithAction(func(args []string, options map[string]string) int {
 		repoStruct, err := FindandOpenRepo()
 		if err != nil {
@@ -239,190 +378,925 @@ var lsFilesCommand = cli.NewCommand("ls-files", "show information about files in
 			fmt.Println(err)
 			return 1
 		}
-		for _, entry := range index.Entries {
-			fmt.Println(entry.Name)
+		terminator := "\n"
+		if options["z"] == "true" {
+			terminator = "\x00"
 		}
-		return 0
-	})
+		showModified := options["modified"] == "true"
+		showDeleted := options["deleted"] == "true"
+		showOthers := options["others"] == "true"
+		showStage := options["stage"] == "true"
+		// Cached files are shown by default if nothing else is asked for
+		showCached := options["cached"] == "true" || showStage || (!showModified && !showDeleted && !showOthers)
 
-// Compute object ID and optionally create a blob from a file
-var hashObjectCommand = cli.NewCommand("hash-object", "Compute object ID and optionally create a blob from a file").
-	WithOption(
-		cli.NewOption("write", "write the object to the database").
-			WithChar('w').
-			WithType(cli.TypeBool)).
-	WithOption(
-		cli.NewOption("stdin", "read object from stdin").
-			WithType(cli.TypeBool)).
-	WithArg(
-		cli.NewArg("path", "path to file to be hashed").
-			AsOptional().
-			WithType(cli.TypeString)).
-	WithAction(func(args []string, options map[string]string) int {
-		blob := &objects.GitBlob{}
-		if options["stdin"] == "true" {
-			data, err := io.ReadAll(os.Stdin)
+		if showCached {
+			for _, entry := range index.Entries {
+				if showStage {
+					fmt.Print(entry.String() + terminator)
+				} else {
+					fmt.Print(entry.Name + terminator)
+				}
+			}
+		}
+		if showModified || showDeleted {
+			modified, deleted, err := repoStruct.ChangedFiles(index)
 			if err != nil {
 				fmt.Println(err)
 				return 1
 			}
-			blob.Deserialize(data)
-		} else {
-			if len(args) != 1 {
-				fmt.Println("fatal: must provide path to file or use --stdin")
-				return 1
+			isModified := make(map[string]bool, len(modified))
+			for _, name := range modified {
+				isModified[name] = true
 			}
-			data, err := os.ReadFile(args[0])
-			if err != nil {
-				// If the string provided isn't an actual path
-				// check if its a file name in the current directory
-				cwd, err := os.Getwd()
-				if err != nil {
-					fmt.Println(err)
-					return 1
+			isDeleted := make(map[string]bool, len(deleted))
+			for _, name := range deleted {
+				isDeleted[name] = true
+			}
+			// Like git, a deleted file is listed twice if both are shown
+			for _, entry := range index.Entries {
+				if showDeleted && isDeleted[entry.Name] {
+					fmt.Print(entry.Name + terminator)
 				}
-				file := path.Join(cwd, args[0])
-				data, err = os.ReadFile(file)
-				if err != nil {
-					fmt.Println(err)
-					return 1
+				if showModified && isModified[entry.Name] {
+					fmt.Print(entry.Name + terminator)
 				}
-				blob.Deserialize(data)
-			} else {
-				// We successfully read the bytes from the path provided
-				blob.Deserialize(data)
 			}
 		}
-		// Print the hash
-		fmt.Println(objects.Hash(blob))
-		if options["write"] == "true" {
-			repoStruct, err := FindandOpenRepo()
+		if showOthers {
+			untracked, err := repoStruct.UntrackedFiles(index)
 			if err != nil {
 				fmt.Println(err)
 				return 1
 			}
-			// Save the object if the "-w" is used
-			err = repoStruct.SaveObject(blob)
-			if err != nil {
-				return 0
+			for _, name := range untracked {
+				fmt.Print(name + terminator)
 			}
 		}
 		return 0
 	})
 
-var commitCommand = cli.NewCommand("commit", "record changes to the repository").
-	WithArg(cli.NewArg("message", "commit message")).
+var lsTreeCommandHelp = "Usage: ls-tree [-r] [-t] [-l] [--name-only] [--full-name] [--full-tree] <tree-ish> [<path>...]"
+var lsTreeCommand = cli.NewCommand("ls-tree", "List the contents of a tree object").
+	WithOption(
+		cli.NewOption("recursive", "recurse into sub-trees").
+			WithChar('r').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("trees", "show tree entries even when going to recurse them").
+			WithChar('t').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("long", "show the size of blob objects").
+			WithChar('l').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("name-only", "list only filenames").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("abbrev", "show the shortest unique object names of at least n hex digits").
+			WithType(cli.TypeInt)).
+	WithOption(
+		cli.NewOption("full-name", "show paths relative to the root of the repository instead of the current directory").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("full-tree", "list the whole tree and take paths relative to the root, whatever the current directory").
+			WithType(cli.TypeBool)).
+	WithArg(
+		cli.NewArg("tree-ish", "the tree (or commit, tag, branch...) to list followed by optional paths").
+			AsOptional().
+			WithType(cli.TypeString)).
 	WithAction(func(args []string, options map[string]string) int {
+		if len(args) == 0 {
+			fmt.Println(lsTreeCommandHelp)
+			return 1
+		}
 		repoStruct, err := FindandOpenRepo()
 		if err != nil {
 			fmt.Println(err)
 			return 1
 		}
-		_, err = repoStruct.Index()
-		if err != nil {
-			// If there's a path error, then its likely the index doesn't exist yet and nothing has been added to the directory
-			_, ok := err.(*os.PathError)
-			if ok {
-				fmt.Println("Nothing to commit")
+		lsOptions := repo.LsTreeOptions{
+			Recursive: options["recursive"] == "true",
+			ShowTrees: options["trees"] == "true",
+			Long:      options["long"] == "true",
+			FullName:  options["full-name"] == "true",
+		}
+		paths := args[1:]
+		if options["full-tree"] != "true" {
+			// Like git, paths and the listing are relative to the current directory
+			prefix, err := RepoPaths(repoStruct, []string{"."})
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			if prefix[0] != "." {
+				lsOptions.Prefix = prefix[0]
+			}
+			paths, err = RepoPaths(repoStruct, args[1:])
+			if err != nil {
+				fmt.Println(err)
 				return 1
 			}
-			fmt.Println(err)
-			return 1
 		}
-
-		err = repoStruct.Commit(args[0])
+		// A trailing slash (or a path that is just dots like "." and "..") limits the listing to
+		// the contents of a directory
+		for i, arg := range args[1:] {
+			if strings.HasSuffix(arg, "/") || path.Base(arg) == "." || path.Base(arg) == ".." {
+				paths[i] += "/"
+			}
+		}
+		abbrev := 0
+		if options["abbrev"] != "" {
+			abbrev, err = strconv.Atoi(options["abbrev"])
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+		}
+		entries, err := repoStruct.LsTree(args[0], paths, lsOptions)
 		if err != nil {
 			fmt.Println(err)
 			return 1
 		}
+		for _, entry := range entries {
+			hash := entry.Hash.String()
+			if options["abbrev"] != "" {
+				hash, err = repoStruct.ShortestAbbrev(entry.Hash, abbrev)
+				if err != nil {
+					fmt.Println(err)
+					return 1
+				}
+			}
+			if options["name-only"] == "true" {
+				fmt.Println(entry.Path)
+			} else if options["long"] == "true" {
+				size := "-"
+				if entry.Size >= 0 {
+					size = fmt.Sprint(entry.Size)
+				}
+				fmt.Printf("%06o %s %s %7s\t%s\n", entry.Mode.Bits(), entry.Type, hash, size, entry.Path)
+			} else {
+				fmt.Printf("%06o %s %s\t%s\n", entry.Mode.Bits(), entry.Type, hash, entry.Path)
+			}
+		}
 		return 0
 	})
-var logCommand = cli.NewCommand("log", "Show commit logs").
+
+// Compute object ID and optionally create an object from a file
+var hashObjectCommandHelp = "Usage: hash-object [-t <type>] [-w] [--path=<file>] [--literally] (--stdin | --stdin-paths | <file>...)"
+var hashObjectCommand = cli.NewCommand("hash-object", "Compute object ID and optionally create an object from a file").
 	WithOption(
-		cli.NewOption("branch", "branch to list").
-			WithChar('b').
+		cli.NewOption("type", "type of object to create (default: blob)").
+			WithChar('t').
 			WithType(cli.TypeString)).
 	WithOption(
-		cli.NewOption("commit-hash", "hash of commit").
-			WithChar('c').
+		cli.NewOption("write", "write the object to the database").
+			WithChar('w').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("stdin", "read object from stdin").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("stdin-paths", "read file names from stdin instead of from the command line").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("path", "hash the object as if it were located at the given path").
 			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("literally", "allow any type and skip checking that the content is valid").
+			WithType(cli.TypeBool)).
 	WithArg(
-		cli.NewArg("distance", "number of commits to show").
-			WithType(cli.TypeInt).
-			AsOptional()).
+		cli.NewArg("path", "paths of files to be hashed").
+			AsOptional().
+			WithType(cli.TypeString)).
 	WithAction(func(args []string, options map[string]string) int {
-		repoStruct, err := FindandOpenRepo()
-		if err != nil {
-			fmt.Println(err)
+		objType := objects.Blob
+		if options["type"] != "" {
+			objType = objects.GitObjectType(options["type"])
+		}
+		fromStdin := options["stdin"] == "true"
+		stdinPaths := options["stdin-paths"] == "true"
+		if (fromStdin && stdinPaths) || (stdinPaths && len(args) > 0) ||
+			(!fromStdin && !stdinPaths && len(args) == 0) {
+			fmt.Println(hashObjectCommandHelp)
 			return 1
 		}
-		specficCommit := options["commit-hash"]
-		branch := options["branch"]
-		// Will look for HEAD if branch and commit-hash aren't specified
-		var startObj string
-		if specficCommit != "" {
-			startObj = specficCommit
-		} else if branch != "" {
-			startObj = branch
+		var repoStruct *repo.Repo
+		var err error
+		if options["write"] == "true" || options["path"] != "" {
+			repoStruct, err = FindandOpenRepo()
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
 		} else {
-			startObj = "HEAD"
+			// Objects are hashed with the format of the repository they would be written to;
+			// outside of a repository that is sha1
+			repoStruct, _ = FindandOpenRepo()
 		}
-		// distance determines how many commits to show
-		distance := 5
-		if len(args) == 1 {
-			distance, err = strconv.Atoi(args[0])
+		algo := objects.SHA1
+		if repoStruct != nil {
+			algo = repoStruct.HashAlgorithm()
+		}
+		if options["path"] != "" {
+			// gitgo doesn't apply any content filters, so the path only has to be valid; the
+			// hash doesn't depend on it
+			_, err = RepoPaths(repoStruct, []string{options["path"]})
 			if err != nil {
 				fmt.Println(err)
 				return 1
 			}
 		}
-		curHash := startObj
-		for i := 0; i < distance; i++ {
-			hash, err := repoStruct.FindObject(curHash)
+		if options["write"] != "true" {
+			repoStruct = nil
+		}
+		literally := options["literally"] == "true"
+
+		if fromStdin {
+			// The size of stdin isn't known until it has all been read
+			data, err := io.ReadAll(os.Stdin)
 			if err != nil {
 				fmt.Println(err)
 				return 1
 			}
-			obj, err := repoStruct.GetObject(hash)
+			hash, err := HashObject(repoStruct, algo, objType, bytes.NewReader(data), int64(len(data)), literally)
 			if err != nil {
 				fmt.Println(err)
 				return 1
 			}
-			commit, ok := obj.(*objects.GitCommit)
-			if !ok {
-				fmt.Println("Unexpected object found when following HEAD")
+			fmt.Println(hash)
+		}
+		paths := args
+		if stdinPaths {
+			paths = make([]string, 0)
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				paths = append(paths, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				fmt.Println(err)
 				return 1
 			}
-			fmt.Println("commit " + objects.Hash(commit))
-			fmt.Println(commit)
-			curHash = commit.ParentHash
-			if len(curHash) == 0 {
-				break
+		}
+		for _, filePath := range paths {
+			// The content is streamed so large files don't have to be read into memory
+			hash, err := hashObjectFile(repoStruct, algo, objType, filePath, literally)
+			if err != nil {
+				fmt.Println(err)
+				return 1
 			}
+			fmt.Println(hash)
 		}
 		return 0
 	})
 
-// IMPROTANT: WILL ONLY REMOVE FILES FROM THE INDEX. THIS PROGRAM WON'T MODIFY THE WORKTREE
-var rmCommand = cli.NewCommand("rm", "Remove files from the index").
-	WithArg(
-		cli.NewArg("path", "path to file to be removed").
-			WithType(cli.TypeString)).
+// Helper for hash-object. Hash the file at filePath as an object of the given type with algo
+func hashObjectFile(repoStruct *repo.Repo, algo *objects.HashAlgorithm, objType objects.GitObjectType,
+	filePath string, literally bool) (objects.ObjectID, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	return HashObject(repoStruct, algo, objType, file, info.Size(), literally)
+}
+
+// Create a tree object from the current index
+var writeTreeCommand = cli.NewCommand("write-tree", "Create a tree object from the current index").
 	WithAction(func(args []string, options map[string]string) int {
 		repoStruct, err := FindandOpenRepo()
 		if err != nil {
 			fmt.Println(err)
 			return 1
 		}
-		// Trim the input string
-		file := strings.Trim(args[0], " ")
-		if strings.HasPrefix(file, ".\\") {
-			file = strings.Trim(file, ".\\")
+		hash, err := repoStruct.WriteTree()
+		if err != nil {
+			fmt.Println(err)
+			return 1
 		}
-		if strings.HasPrefix(file, "./") {
-			file = strings.Trim(file, "./")
+		fmt.Println(hash)
+		return 0
+	})
+
+// Read tree information into the index
+var readTreeCommand = cli.NewCommand("read-tree", "Read tree information into the index").
+	WithOption(
+		cli.NewOption("prefix", "read the tree into the index under the given directory").
+			WithType(cli.TypeString)).
+	WithArg(
+		cli.NewArg("tree-ish", "the tree (or commit, tag, branch...) to read").
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		err = repoStruct.ReadTree(args[0], options["prefix"])
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	})
+
+// Build a tree object from ls-tree formatted text read from stdin
+var mkTreeCommand = cli.NewCommand("mktree", "Build a tree-object from ls-tree formatted text").
+	WithOption(
+		cli.NewOption("missing", "allow missing objects").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("z", "input is NUL terminated").
+			WithChar('z').
+			WithType(cli.TypeBool)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		scanner := bufio.NewScanner(os.Stdin)
+		if options["z"] == "true" {
+			scanner.Split(scanNul)
+		}
+		entries := make([]objects.TreeEntry, 0)
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				continue
+			}
+			entry, err := repo.ParseTreeEntry(scanner.Text())
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			entries = append(entries, entry)
+		}
+		if err := scanner.Err(); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		hash, err := repoStruct.MakeTree(entries, options["missing"] == "true")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Println(hash)
+		return 0
+	})
+
+// Split function for bufio.Scanner that splits input on NUL bytes
+func scanNul(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0x00); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// parentArgs The parents given to commit-tree in args, in order, as '-p <parent>' or
+// '--parent=<parent>'. The message given with -m is skipped so a message starting with a dash
+// isn't taken for an option, and arguments after '--' aren't options
+func parentArgs(args []string) []string {
+	parents := make([]string, 0)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return parents
+		case arg == "-m" || arg == "--message":
+			i++
+		case arg == "-p" || arg == "--parent":
+			if i+1 < len(args) {
+				parents = append(parents, args[i+1])
+			}
+			i++
+		case strings.HasPrefix(arg, "--parent="):
+			parents = append(parents, strings.TrimPrefix(arg, "--parent="))
+		}
+	}
+	return parents
+}
+
+// Create a new commit object from a tree
+var commitTreeCommand = cli.NewCommand("commit-tree", "Create a new commit object").
+	WithOption(
+		cli.NewOption("parent", "a parent commit; repeat -p for several").
+			WithChar('p').
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("message", "commit message; read from stdin if it is not given").
+			WithChar('m').
+			WithType(cli.TypeString)).
+	WithArg(
+		cli.NewArg("tree", "the tree the commit points to").
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		// The cli package only keeps the last value of an option given more than once
+		parents := parentArgs(os.Args[2:])
+		msg, ok := options["message"]
+		if !ok {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			msg = string(data)
+		}
+		hash, warnings, err := repoStruct.CommitTree(args[0], parents, msg)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, "error: "+warning)
+		}
+		fmt.Println(hash)
+		return 0
+	})
+
+// Create a tag object with validation, reading its content from stdin
+var mkTagCommand = cli.NewCommand("mktag", "Creates a tag object with extra validation").
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		hash, err := repoStruct.MakeTag(data)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Println(hash)
+		return 0
+	})
+
+// Check the integrity of the objects and refs in the repo
+var fsckCommand = cli.NewCommand("fsck", "verifies the connectivity and validity of the objects in the database").
+	WithOption(
+		cli.NewOption("unreachable", "show all unreachable objects instead of only dangling ones").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("no-dangling", "don't show dangling objects").
+			WithType(cli.TypeBool)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		unreachable := options["unreachable"] == "true"
+		noDangling := options["no-dangling"] == "true"
+		issues, err := repoStruct.Fsck(repo.FsckOptions{Unreachable: unreachable, NoDangling: noDangling})
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		status := 0
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.Kind == repo.FsckError || issue.Kind == repo.FsckMissing {
+				status = 1
+			}
+		}
+		return status
+	})
+
+// Remove unreachable loose objects
+var pruneCommand = cli.NewCommand("prune", "prune all unreachable objects from the object database").
+	WithOption(
+		cli.NewOption("dry-run", "only report what would be removed").
+			WithChar('n').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("expire", "only prune objects older than this (default: now)").
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		expire := time.Now()
+		if value, ok := options["expire"]; ok {
+			expire, err = repo.ParseExpiry(value, time.Now())
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+		}
+		dryRun := options["dry-run"] == "true"
+		pruned, err := repoStruct.Prune(repo.PruneOptions{Expire: expire, DryRun: dryRun})
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if dryRun {
+			for _, hash := range pruned {
+				fmt.Println(hash)
+			}
+		}
+		return 0
+	})
+
+// Pack refs and objects and prune old unreachable objects
+var gcCommand = cli.NewCommand("gc", "cleanup unnecessary files and optimize the local repository").
+	WithOption(
+		cli.NewOption("prune", "prune unreachable objects older than this (default: gc.pruneExpire or 2.weeks.ago)").
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		var expire time.Time
+		if value, ok := options["prune"]; ok {
+			expire, err = repo.ParseExpiry(value, time.Now())
+		} else {
+			expire, err = repoStruct.PruneExpiry(time.Now())
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		err = repoStruct.Gc(repo.GcOptions{PruneExpire: expire})
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	})
+
+// Move all refs into packed-refs
+var packRefsCommand = cli.NewCommand("pack-refs", "pack heads and tags for efficient repository access").
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		err = repoStruct.PackRefs()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	})
+
+var commitCommand = cli.NewCommand("commit", "record changes to the repository").
+	WithArg(cli.NewArg("message", "commit message")).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		_, err = repoStruct.Index()
+		if err != nil {
+			// If there's a path error, then its likely the index doesn't exist yet and nothing has been added to the directory
+			_, ok := err.(*os.PathError)
+			if ok {
+				fmt.Println("Nothing to commit")
+				return 1
+			}
+			fmt.Println(err)
+			return 1
+		}
+
+		err = repoStruct.Commit(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	})
+var logCommand = cli.NewCommand("log", "Show commit logs").
+	WithOption(
+		cli.NewOption("branch", "branch to list").
+			WithChar('b').
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("commit-hash", "hash of commit").
+			WithChar('c').
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("oneline", "show each commit on one line: its abbreviated hash and its title").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("pretty", "format of each commit: oneline, short, medium, full, fuller or a format string").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("format", "same as --pretty").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("abbrev-commit", "show abbreviated commit hashes").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("date", "date format: default, relative, local, iso, iso-strict, rfc, short, raw or unix").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("graph", "draw the history as a graph next to the commits").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("max-count", "number of commits to show").
+			WithChar('n').
+			WithType(cli.TypeInt)).
+	WithOption(
+		cli.NewOption("author", "show only commits whose author matches a regular expression").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("committer", "show only commits whose committer matches a regular expression").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("grep", "show only commits whose message matches a regular expression").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("since", "show only commits committed after a date, e.g. 2.weeks.ago, yesterday, "+
+			"'2006-01-02 15:04' or a Unix timestamp").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("until", "show only commits committed before a date, in the same forms as --since").
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("follow", "keep showing the history of a single file across renames").
+			WithType(cli.TypeBool)).
+	WithArg(
+		cli.NewArg("paths", "show only commits that changed these paths. A number as the first "+
+			"argument is the number of commits to show").
+			AsOptional().
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		specficCommit := options["commit-hash"]
+		branch := options["branch"]
+		// Will look for HEAD if branch and commit-hash aren't specified
+		var startObj string
+		if specficCommit != "" {
+			startObj = specficCommit
+		} else if branch != "" {
+			startObj = branch
+		} else {
+			startObj = "HEAD"
+		}
+		// distance determines how many commits to show
+		distance := 5
+		if options["max-count"] != "" {
+			distance, err = strconv.Atoi(options["max-count"])
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+		} else if len(args) > 0 {
+			if count, err := strconv.Atoi(args[0]); err == nil {
+				distance, args = count, args[1:]
+			}
+		}
+		logOptions, err := LogOptionsFromFlags(options)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		filter, err := CommitFilterFromFlags(repoStruct, options, args)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		start, err := repoStruct.ResolveCommit(startObj)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		// Graphs need every line of history kept together
+		order := repo.DateOrder
+		if logOptions.Graph {
+			order = repo.TopoOrder
+		}
+		walker, err := repoStruct.FilterCommits(order, filter, start)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		printer := repoStruct.NewLogPrinter(os.Stdout, logOptions)
+		for i := 0; i < distance; i++ {
+			hash, commit, err := walker.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			err = printer.Print(hash, commit)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+		}
+		return 0
+	})
+
+// Remove files from the index and the worktree
+var rmCommandHelp = "Usage: rm [-r] [-f] [--cached] <path>..."
+var rmCommand = cli.NewCommand("rm", "Remove files from the working tree and from the index").
+	WithOption(
+		cli.NewOption("cached", "only remove from the index, keeping the working tree file").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("recursive", "allow recursive removal when a directory is given").
+			WithChar('r').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("force", "override the up-to-date check").
+			WithChar('f').
+			WithType(cli.TypeBool)).
+	WithArg(
+		cli.NewArg("path", "paths of files to be removed").
+			AsOptional().
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		if len(args) == 0 {
+			fmt.Println(rmCommandHelp)
+			return 1
+		}
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		paths, err := RepoPaths(repoStruct, args)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		removed, err := repoStruct.Rm(paths, repo.RmOptions{
+			Cached:    options["cached"] == "true",
+			Recursive: options["recursive"] == "true",
+			Force:     options["force"] == "true",
+		})
+		if err != nil {
+			fmt.Println(err)
+			if _, ok := err.(*repo.ErrRmChanges); ok {
+				fmt.Println("(use --cached to keep the file, or -f to force removal)")
+			}
+			return 1
+		}
+		for _, name := range removed {
+			fmt.Printf("rm '%s'\n", name)
+		}
+		return 0
+	})
+
+var mvCommandHelp = "Usage: mv [-f] [-v] <source>... <destination>"
+var mvCommand = cli.NewCommand("mv", "Move or rename a file or a directory").
+	WithOption(
+		cli.NewOption("force", "force renaming even if the target exists").
+			WithChar('f').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("verbose", "report the names of files as they are moved").
+			WithChar('v').
+			WithType(cli.TypeBool)).
+	WithArg(
+		cli.NewArg("path", "sources followed by the destination").
+			AsOptional().
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		if len(args) < 2 {
+			fmt.Println(mvCommandHelp)
+			return 1
+		}
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		paths, err := RepoPaths(repoStruct, args)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		moves, err := repoStruct.Move(paths[:len(paths)-1], paths[len(paths)-1], options["force"] == "true")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if options["verbose"] == "true" {
+			for _, move := range moves {
+				fmt.Printf("Renaming %s to %s\n", move.From, move.To)
+			}
+		}
+		return 0
+	})
+
+var resetCommandHelp = "Usage: reset [--soft | --mixed | --hard] [<commit>]"
+var resetCommand = cli.NewCommand("reset", "Reset current HEAD to the specified state").
+	WithOption(
+		cli.NewOption("soft", "only move the current branch").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("mixed", "move the current branch and reset the index (default)").
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("hard", "move the current branch and reset the index and working tree").
+			WithType(cli.TypeBool)).
+	WithArg(
+		cli.NewArg("commit", "commit to reset to; defaults to HEAD").
+			AsOptional().
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		modes := 0
+		mode := repo.ResetMixed
+		if options["soft"] == "true" {
+			mode = repo.ResetSoft
+			modes++
+		}
+		if options["mixed"] == "true" {
+			mode = repo.ResetMixed
+			modes++
+		}
+		if options["hard"] == "true" {
+			mode = repo.ResetHard
+			modes++
+		}
+		if modes > 1 || len(args) > 1 {
+			fmt.Println(resetCommandHelp)
+			return 1
+		}
+		target := "HEAD"
+		if len(args) == 1 {
+			target = args[0]
+		}
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		err = repoStruct.Reset(target, mode)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	})
+
+var restoreCommandHelp = "Usage: restore [--source=<tree>] [--staged] [--worktree] <path>..."
+var restoreCommand = cli.NewCommand("restore", "Restore working tree files").
+	WithOption(
+		cli.NewOption("source", "restore from the given tree-ish instead of the index (or HEAD with --staged)").
+			WithChar('s').
+			WithType(cli.TypeString)).
+	WithOption(
+		cli.NewOption("staged", "restore the index").
+			WithChar('S').
+			WithType(cli.TypeBool)).
+	WithOption(
+		cli.NewOption("worktree", "restore the working tree (default)").
+			WithChar('W').
+			WithType(cli.TypeBool)).
+	WithArg(
+		cli.NewArg("path", "paths to restore").
+			AsOptional().
+			WithType(cli.TypeString)).
+	WithAction(func(args []string, options map[string]string) int {
+		if len(args) == 0 {
+			fmt.Println(restoreCommandHelp)
+			return 1
+		}
+		repoStruct, err := FindandOpenRepo()
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		paths, err := RepoPaths(repoStruct, args)
+		if err != nil {
+			fmt.Println(err)
+			return 1
 		}
-		err = repoStruct.Rm(file)
+		_, err = repoStruct.Restore(paths, repo.RestoreOptions{
+			Source:   options["source"],
+			Staged:   options["staged"] == "true",
+			Worktree: options["worktree"] == "true",
+		})
 		if err != nil {
 			fmt.Println(err)
 			return 1
@@ -434,13 +1308,26 @@ var App = cli.New("small subset of git commands").
 	WithCommand(add).
 	WithCommand(catFile).
 	WithCommand(rmCommand).
+	WithCommand(mvCommand).
+	WithCommand(resetCommand).
+	WithCommand(restoreCommand).
 	WithCommand(logCommand).
 	WithCommand(tagCommand).
 	WithCommand(initCommand).
 	WithCommand(commitCommand).
 	WithCommand(showRefCommand).
 	WithCommand(lsFilesCommand).
-	WithCommand(hashObjectCommand)
+	WithCommand(lsTreeCommand).
+	WithCommand(hashObjectCommand).
+	WithCommand(writeTreeCommand).
+	WithCommand(readTreeCommand).
+	WithCommand(mkTreeCommand).
+	WithCommand(commitTreeCommand).
+	WithCommand(mkTagCommand).
+	WithCommand(fsckCommand).
+	WithCommand(pruneCommand).
+	WithCommand(gcCommand).
+	WithCommand(packRefsCommand)
 
 func main() {
 	os.Exit(App.Run(os.Args, os.Stdout))
@@ -1,7 +1,6 @@
 package objects
 
 import (
-	"fmt"
 	"os"
 )
 
@@ -14,11 +13,26 @@ func NewBlob(size int) *GitBlob {
 	return &GitBlob{size: size}
 }
 
+// FileBlob Create a blob from the file at path. Symbolic links are not followed; git stores
+// the link target as the content of the blob instead
 func FileBlob(path string) (*GitBlob, error) {
-	contents, err := os.ReadFile(path)
+	info, err := os.Lstat(path)
 	if err != nil {
 		return nil, err
 	}
+	var contents []byte
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		contents = []byte(target)
+	} else {
+		contents, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
 	blob := &GitBlob{}
 	blob.Deserialize(contents)
 	return blob, nil
@@ -35,10 +49,11 @@ func (obj *GitBlob) String() string {
 	return string(obj.data)
 }
 
-// Deserialize Sets the data field of the GitBlob struct
-func (obj *GitBlob) Deserialize(src []byte) {
+// Deserialize Sets the data field of the GitBlob struct. Any content is a valid blob
+func (obj *GitBlob) Deserialize(src []byte) error {
 	obj.data = src
 	obj.size = len(src)
+	return nil
 }
 
 // Type Returns 'blob'
@@ -46,7 +61,7 @@ func (obj *GitBlob) Type() GitObjectType {
 	return Blob
 }
 
-// Size Returns an ASCII representation of the size of the blob
-func (obj *GitBlob) Size() string {
-	return fmt.Sprint(obj.size)
+// Size Returns the size of the blob
+func (obj *GitBlob) Size() int {
+	return obj.size
 }
@@ -5,8 +5,8 @@ import "testing"
 func TestBasicBlobFunctions(t *testing.T) {
 	blob := NewBlob(10)
 
-	if blob.Size() != "10" {
-		t.Errorf("Expected '10' from Size(), Got: '%s'", blob.Size())
+	if blob.Size() != 10 {
+		t.Errorf("Expected 10 from Size(), Got: %d", blob.Size())
 	}
 
 	if blob.Type() != Blob {
@@ -22,8 +22,8 @@ func TestBlobWithData(t *testing.T) {
 		t.Errorf("blob.Serialize() returned an unexpected value")
 	}
 
-	if blob.Size() != "11" {
-		t.Errorf("Expected blob.Size() to be 11, Got: %s", blob.Size())
+	if blob.Size() != 11 {
+		t.Errorf("Expected blob.Size() to be 11, Got: %d", blob.Size())
 	}
 
 }
@@ -40,3 +40,12 @@ func equalByteSlices(slice1 []byte, slice2 []byte) bool {
 	}
 	return true
 }
+
+// Fuzz parsing blobs; any content is a valid blob
+func FuzzBlob(f *testing.F) {
+	f.Add([]byte("hello world"))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDeserialize(t, func() GitObject { return &GitBlob{} }, data)
+	})
+}
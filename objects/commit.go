@@ -11,7 +11,7 @@ import (
 //Commit object format:
 //Header
 //tree [tree-hash]\n
-//parent [commit-hash]\n (optional)
+//parent [commit-hash]\n (optional, repeated for merge commits)
 //author [Name] [email] [time-stamp] [time-zone] (person who wrote the code)
 //committer (same format as author)\n\n (person who is committing the code)
 //PGP signature (not implemented for now)
@@ -23,11 +23,14 @@ import (
 
 // GitCommit A commit object
 type GitCommit struct {
-	TreeHash   string
-	ParentHash string
-	author     *commitIdentity
-	committer  *commitIdentity
-	Msg        string
+	TreeHash ObjectID
+	// ParentHash The first parent of the commit; see Parents for merge commits. It is the zero
+	// ObjectID for root commits
+	ParentHash   ObjectID
+	extraParents []ObjectID
+	author       *commitIdentity
+	committer    *commitIdentity
+	Msg          string
 }
 
 // Struct that indentifies a committer or author of a commit and when that commit was made
@@ -144,8 +147,8 @@ func idFromString(idString string) (*commitIdentity, error) {
 		}
 	}
 	// emailPos will only be 0 if a word wrapped in '< >' is not found (i.e. the string
-	// has no email in the format stored by git
-	if emailPos == 0 {
+	// has no email in the format stored by git). The timestamp and timezone must follow it
+	if emailPos == 0 || len(words) < emailPos+3 {
 		return nil, errors.New(fmt.Sprintf("String is badly formed: %s", idString))
 	}
 	name := strings.Join(words[0:emailPos], " ")
@@ -175,62 +178,122 @@ func (id *commitIdentity) Size() int {
 	return len(id.String())
 }
 
+// Signature The name and email of a commit's author or committer and when they made it
+type Signature struct {
+	Name  string
+	Email string
+	// When The time of the signature, in the timezone it was made in
+	When time.Time
+}
+
+// Convert a commitIdentity to a Signature
+func (id *commitIdentity) signature() Signature {
+	offset := id.timezone.hours*3600 + id.timezone.mins*60
+	if !id.timezone.postive {
+		offset = -offset
+	}
+	return Signature{
+		Name:  id.name,
+		Email: id.email,
+		When:  time.Unix(id.time, 0).In(time.FixedZone("", offset))}
+}
+
 // Type Return 'commit'
 func (commit *GitCommit) Type() GitObjectType {
 	return Commit
 }
 
 // Size Return size of commit data in bytes
-func (commit *GitCommit) Size() string {
-	return fmt.Sprint(commit.computeSize())
+func (commit *GitCommit) Size() int {
+	return commit.computeSize()
 }
 
 // Deserialize Process a commit string (stored in a commit file) and sets and object field based on
-// the data
-func (commit *GitCommit) Deserialize(src []byte) {
+// the data. Only the lines before the first blank line are treated as headers, so a message
+// can contain lines starting with 'tree' or 'author'
+func (commit *GitCommit) Deserialize(src []byte) error {
+	commit.TreeHash = ObjectID{}
 	commit.Msg = ""
+	commit.ParentHash = ObjectID{}
+	commit.extraParents = nil
+	commit.author = nil
+	commit.committer = nil
 	lines := strings.Split(string(src), "\n")
+	inHeader := true
 	// Loop through all the lines of the commit string
 	for _, line := range lines {
-		// Break the line into words
-		words := strings.Split(line, " ")
+		if line == "" {
+			inHeader = false
+		}
+		if !inHeader {
+			commit.Msg += line + "\n"
+			continue
+		}
 		// The first word determines what information the line holds, process accordingly
-		switch words[0] {
+		key, value, err := splitHeaderLine(Commit, line)
+		if err != nil {
+			return err
+		}
+		switch key {
 		case "tree":
-			commit.TreeHash = words[1]
+			commit.TreeHash, err = ParseObjectID(value)
+			if err != nil {
+				return &ErrBadObject{reason: "commit has a bad tree line: " + err.Error()}
+			}
 		case "parent":
-			commit.ParentHash = words[1]
+			parent, err := ParseObjectID(value)
+			if err != nil {
+				return &ErrBadObject{reason: "commit has a bad parent line: " + err.Error()}
+			}
+			commit.AddParent(parent)
 		case "author":
-			author, err := idFromString(strings.Join(words[1:], " "))
+			author, err := idFromString(value)
 			if err != nil {
-				panic(err)
+				return &ErrBadObject{reason: "commit has a bad author line: " + err.Error()}
 			}
 			commit.author = author
 		case "committer":
-			committer, err := idFromString(strings.Join(words[1:], " "))
+			committer, err := idFromString(value)
 			if err != nil {
-				panic(err)
+				return &ErrBadObject{reason: "commit has a bad committer line: " + err.Error()}
 			}
 			commit.committer = committer
 		default:
-			commit.Msg += strings.Join(words, " ") + "\n"
+			commit.Msg += line + "\n"
 		}
 	}
 	// Remove the Extra new line that will be added on the commit Msg because of how its
 	// parsed
 	commit.Msg = strings.Trim(commit.Msg, "\n")
+	return nil
+}
+
+// Split a header line of a commit or tag into its key (the first word) and value (the rest
+// of the line). Lines for the known keys must have a value
+func splitHeaderLine(objType GitObjectType, line string) (string, string, error) {
+	words := strings.SplitN(line, " ", 2)
+	switch words[0] {
+	case "tree", "parent", "author", "committer", "object", "type", "tag", "tagger":
+		if len(words) < 2 {
+			return "", "", &ErrBadObject{reason: fmt.Sprintf("%s has a '%s' line without a value", objType, words[0])}
+		}
+		return words[0], words[1], nil
+	}
+	return words[0], "", nil
 }
 
 // Serialize Convert commit struct into a []byte (which is really just a string) ready for writing
 // to a file
 func (commit *GitCommit) Serialize() []byte {
 	bytes := make([]byte, 0, commit.computeSize())
-	bytes = append(bytes, "tree "...)
-	bytes = append(bytes, commit.TreeHash...)
-	bytes = append(bytes, '\n')
-	if commit.ParentHash != "" {
+	if !commit.TreeHash.IsZero() {
+		bytes = append(bytes, "tree "...)
+		bytes = append(bytes, commit.TreeHash.String()...)
+		bytes = append(bytes, '\n')
+	}
+	for _, parent := range commit.Parents() {
 		bytes = append(bytes, "parent "...)
-		bytes = append(bytes, commit.ParentHash...)
+		bytes = append(bytes, parent.String()...)
 		bytes = append(bytes, '\n')
 	}
 
@@ -265,14 +328,15 @@ func (commit *GitCommit) String() string {
 // and returns the size
 func (commit *GitCommit) computeSize() int {
 	size := 0
-	if commit.TreeHash != "" {
+	// The tree line is left out until the tree is set
+	if !commit.TreeHash.IsZero() {
 		//(len("tree") = 4) + space + \n = 6
-		size += 6 + len(commit.TreeHash)
+		size += 6 + commit.TreeHash.Size()*2
 	}
 
-	if commit.ParentHash != "" {
+	for _, parent := range commit.Parents() {
 		//(len("parent") = 6) + space + \n =  8
-		size += 8 + len(commit.ParentHash)
+		size += 8 + parent.Size()*2
 	}
 
 	if commit.author != nil {
@@ -290,6 +354,48 @@ func (commit *GitCommit) computeSize() int {
 
 }
 
+// Parents Returns the hashes of all the parents of the commit in order. Root commits have none
+func (commit *GitCommit) Parents() []ObjectID {
+	if commit.ParentHash.IsZero() {
+		return []ObjectID{}
+	}
+	return append([]ObjectID{commit.ParentHash}, commit.extraParents...)
+}
+
+// AddParent Add a parent to the commit. The first parent added is stored in ParentHash
+func (commit *GitCommit) AddParent(hash ObjectID) {
+	if commit.ParentHash.IsZero() {
+		commit.ParentHash = hash
+		return
+	}
+	commit.extraParents = append(commit.extraParents, hash)
+}
+
+// SetParents Replace the parents of the commit. No parents makes it a root commit
+func (commit *GitCommit) SetParents(parents []ObjectID) {
+	commit.ParentHash = ObjectID{}
+	commit.extraParents = nil
+	for _, parent := range parents {
+		commit.AddParent(parent)
+	}
+}
+
+// Author Returns the author of the commit, or an empty Signature if it has none
+func (commit *GitCommit) Author() Signature {
+	if commit.author == nil {
+		return Signature{}
+	}
+	return commit.author.signature()
+}
+
+// Committer Returns the committer of the commit, or an empty Signature if it has none
+func (commit *GitCommit) Committer() Signature {
+	if commit.committer == nil {
+		return Signature{}
+	}
+	return commit.committer.signature()
+}
+
 // SetAuthor Set the commit author (i.e. the original author of the code in the commit)
 // the system time and timezone will be used for those fields
 func (commit *GitCommit) SetAuthor(name string, email string) {
@@ -18,14 +18,14 @@ func TestCommit(t *testing.T) {
 	if err != nil {
 		return
 	}
-	commit.TreeHash = "a6fea408f7673f5ef6fa1d8561ee7bc06fd69d3a"
+	commit.TreeHash = MustParseObjectID("a6fea408f7673f5ef6fa1d8561ee7bc06fd69d3a")
 	commit.Msg = "Initial commit\n\n" +
 		"Created package to parse .ini files and some tests.\n" +
 		"Created basic README"
 	expectedHash := "0a7dc15c18075d667bcf5baebbf8787c73484bc8"
 	hash := Hash(commit)
 
-	if hash != expectedHash {
+	if hash.String() != expectedHash {
 		t.Errorf("Expected hash: %s\nGot: %s", expectedHash, hash)
 		fmt.Printf("Commit data:\n%s", string(commit.Serialize()))
 	}
@@ -49,7 +49,7 @@ func TestCommitDeseralizing(t *testing.T) {
 	if err != nil {
 		return
 	}
-	commit.TreeHash = "a6fea408f7673f5ef6fa1d8561ee7bc06fd69d3a"
+	commit.TreeHash = MustParseObjectID("a6fea408f7673f5ef6fa1d8561ee7bc06fd69d3a")
 	commit.Msg = "Initial commit\n\n" +
 		"Created package to parse .ini files and some tests.\n" +
 		"Created basic README"
@@ -61,7 +61,7 @@ func TestCommitDeseralizing(t *testing.T) {
 
 	hash := Hash(newCommit)
 
-	if hash != expectedHash {
+	if hash.String() != expectedHash {
 		t.Errorf("Expected hash: %s\nGot: %s", expectedHash, hash)
 		fmt.Printf("Commit data:\n%s", string(newCommit.Serialize()))
 	}
@@ -71,3 +71,85 @@ func TestCommitDeseralizing(t *testing.T) {
 		fmt.Printf("Commit data:\n%s", string(newCommit.Serialize()))
 	}
 }
+
+// Test that merge commits keep all their parents in order
+func TestCommitParents(t *testing.T) {
+	commit := &GitCommit{}
+	err := commit.SetAuthorAndTime("A U Thor", "author@example.com", 1625088346, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error when setting author: %s", err)
+	}
+	err = commit.SetCommitterAndTime("A U Thor", "author@example.com", 1625088346, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error when setting committer: %s", err)
+	}
+	commit.TreeHash = MustParseObjectID("4b825dc642cb6eb9a060e54bf8d69288fbe4904b")
+	commit.Msg = "merge"
+	if len(commit.Parents()) != 0 {
+		t.Errorf("Expected a commit without parents, Got: %v", commit.Parents())
+	}
+	parents := []ObjectID{
+		MustParseObjectID("a09d6e1c96c9234b17108777791929a5385eae49"),
+		MustParseObjectID("0a7dc15c18075d667bcf5baebbf8787c73484bc8"),
+	}
+	for _, parent := range parents {
+		commit.AddParent(parent)
+	}
+	// Hash of the same commit created by 'git commit-tree'
+	expectedHash := "652bea09297d2228d023812f36d0b04861c81e6b"
+	if Hash(commit).String() != expectedHash {
+		t.Errorf("Expected hash: %s\nGot: %s\nCommit data:\n%s", expectedHash, Hash(commit), commit)
+	}
+	newCommit := &GitCommit{}
+	newCommit.Deserialize(commit.Serialize())
+	if Hash(newCommit).String() != expectedHash {
+		t.Errorf("Expected deserialized hash: %s\nGot: %s", expectedHash, Hash(newCommit))
+	}
+	if len(newCommit.Parents()) != 2 || newCommit.Parents()[1] != parents[1] {
+		t.Errorf("Expected parents %v, Got: %v", parents, newCommit.Parents())
+	}
+	newCommit.SetParents(parents[1:])
+	if len(newCommit.Parents()) != 1 || newCommit.Parents()[0] != parents[1] {
+		t.Errorf("Expected parents %v, Got: %v", parents[1:], newCommit.Parents())
+	}
+	newCommit.SetParents(nil)
+	if len(newCommit.Parents()) != 0 {
+		t.Errorf("Expected a root commit, Got parents: %v", newCommit.Parents())
+	}
+}
+
+// Test reading the author and committer of a commit, including their timezones
+func TestCommitSignatures(t *testing.T) {
+	commit := &GitCommit{}
+	if commit.Author() != (Signature{}) {
+		t.Errorf("Expected an empty author, Got: %v", commit.Author())
+	}
+	err := commit.Deserialize([]byte("tree a6fea408f7673f5ef6fa1d8561ee7bc06fd69d3a\n" +
+		"author A U Thor <author@example.com> 1700000000 +0530\n" +
+		"committer C O Mitter <committer@example.com> 1700000100 -0800\n\nmessage\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	author := commit.Author()
+	if author.Name != "A U Thor" || author.Email != "author@example.com" ||
+		author.When.Format("2006-01-02 15:04:05 -0700") != "2023-11-15 03:43:20 +0530" {
+		t.Errorf("Unexpected author: %v", author)
+	}
+	committer := commit.Committer()
+	if committer.Name != "C O Mitter" || committer.Email != "committer@example.com" ||
+		committer.When.Format("2006-01-02 15:04:05 -0700") != "2023-11-14 14:15:00 -0800" {
+		t.Errorf("Unexpected committer: %v", committer)
+	}
+}
+
+// Fuzz parsing commits
+func FuzzCommit(f *testing.F) {
+	f.Add([]byte("tree a6fea408f7673f5ef6fa1d8561ee7bc06fd69d3a\n" +
+		"parent 0a7dc15c18075d667bcf5baebbf8787c73484bc8\n" +
+		"author Simon Taye <mulat.simon@gmail.com> 1623004337 +0000\n" +
+		"committer Simon Taye <mulat.simon@gmail.com> 1623004337 -0130\n\nmessage\n"))
+	f.Add([]byte("tree\nauthor <a> 1\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDeserialize(t, func() GitObject { return &GitCommit{} }, data)
+	})
+}
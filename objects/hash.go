@@ -0,0 +1,111 @@
+package objects
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/sha1dc"
+)
+
+// HashAlgorithm The hash function objects are named with. Repositories use SHA1 unless their
+// config sets extensions.objectFormat to sha256; the two can't be mixed in one repository
+type HashAlgorithm struct {
+	name    string
+	size    int
+	newHash func() hash.Hash
+	// newChecksum The hash used for checksums of files (e.g. packs and the index), which don't
+	// name objects and so don't need collision detection
+	newChecksum func() hash.Hash
+}
+
+// SHA1 The hash git has always used; 20 bytes or 40 hex characters. Objects are hashed with
+// collision detection, like git does, since SHA-1 collisions can be made
+var SHA1 = &HashAlgorithm{name: "sha1", size: sha1.Size, newHash: newSHA1DC, newChecksum: sha1.New}
+
+// SHA256 The hash used by repositories created with '--object-format=sha256'; 32 bytes or 64
+// hex characters
+var SHA256 = &HashAlgorithm{name: "sha256", size: sha256.Size, newHash: sha256.New, newChecksum: sha256.New}
+
+func newSHA1DC() hash.Hash {
+	return sha1dc.New()
+}
+
+// collisionDetector A hash that can tell if the data written to it is part of a collision attack
+type collisionDetector interface {
+	CollisionDetected() bool
+}
+
+// ErrCollisionAttack The content of an object is part of a known SHA-1 collision attack, so
+// another object may have the same hash
+type ErrCollisionAttack struct {
+	hash ObjectID
+}
+
+func (e *ErrCollisionAttack) Error() string {
+	return "SHA-1 appears to be part of a collision attack: " + e.hash.String()
+}
+
+// ErrUnknownHashAlgorithm The object format isn't sha1 or sha256
+type ErrUnknownHashAlgorithm struct {
+	name string
+}
+
+func (e *ErrUnknownHashAlgorithm) Error() string {
+	return "unknown object format '" + e.name + "'"
+}
+
+// HashAlgorithmByName Return the algorithm for an object format as it is written in the config
+// (extensions.objectFormat), i.e. 'sha1' or 'sha256'
+func HashAlgorithmByName(name string) (*HashAlgorithm, error) {
+	switch strings.ToLower(name) {
+	case SHA1.name:
+		return SHA1, nil
+	case SHA256.name:
+		return SHA256, nil
+	}
+	return nil, &ErrUnknownHashAlgorithm{name: name}
+}
+
+// Name The name of the object format, as written in the config
+func (algo *HashAlgorithm) Name() string {
+	return algo.name
+}
+
+// Size The length of a hash in bytes
+func (algo *HashAlgorithm) Size() int {
+	return algo.size
+}
+
+// HexSize The length of a hash written in hex
+func (algo *HashAlgorithm) HexSize() int {
+	return algo.size * 2
+}
+
+// New Start a new hash for naming objects. For SHA1 the hash detects collision attacks; see
+// CompressStream
+func (algo *HashAlgorithm) New() hash.Hash {
+	return algo.newHash()
+}
+
+// NewChecksum Start a new hash for the checksum at the end of a file, e.g. a pack or the index
+func (algo *HashAlgorithm) NewChecksum() hash.Hash {
+	return algo.newChecksum()
+}
+
+// Hash The hash of an object and its header. Collision attacks aren't reported here; objects
+// are checked when they are saved or read with CompressStream and HashReader
+func (algo *HashAlgorithm) Hash(obj GitObject) ObjectID {
+	hasher := algo.New()
+	hasher.Write(Header(obj))
+	hasher.Write(obj.Serialize())
+	return algo.sumID(hasher)
+}
+
+// sumID The ID made from the sum of hasher, which must be a hash of this algorithm
+func (algo *HashAlgorithm) sumID(hasher hash.Hash) ObjectID {
+	oid := ObjectID{size: algo.Size()}
+	hasher.Sum(oid.hash[:0])
+	return oid
+}
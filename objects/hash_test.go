@@ -0,0 +1,63 @@
+package objects
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test hashing and parsing objects of a SHA256 repository using hashes from git
+func TestSHA256Objects(t *testing.T) {
+	blob := &GitBlob{}
+	blob.Deserialize([]byte("hello world"))
+	expected := "fee53a18d32820613c0527aa79be5cb30173c823a9b448fa4817767cc84c6f03"
+	if hash := SHA256.Hash(blob); hash.String() != expected {
+		t.Errorf("Expected blob hash %s, Got: %s", expected, hash)
+	}
+	hash, err := SHA256.HashReader(Blob, 11, strings.NewReader("hello world"))
+	if err != nil || hash.String() != expected {
+		t.Errorf("Expected streamed blob hash %s, Got: %s, %v", expected, hash, err)
+	}
+
+	tree := NewTree(SHA256)
+	err = tree.AddEntry(Normal, "file", MustParseObjectID("7c490ebf9db90b84753749c721ef2bedfeb85c1da94160f2619df1249c64bdda"))
+	if err != nil {
+		t.Fatalf("Unexpected error when adding entry: %s", err)
+	}
+	err = tree.AddEntry(Directory, "dir", MustParseObjectID("34ccd66e427e0303fcf0f1b91e69b72ff0a7a899af7746162bcc6a1e978e7f95"))
+	if err != nil {
+		t.Fatalf("Unexpected error when adding entry: %s", err)
+	}
+	expected = "efe77f7a73d2ec0d0d8f78392bfb0e52955333a87f4275c7944c57ee797fe071"
+	if hash := SHA256.Hash(tree); hash.String() != expected {
+		t.Errorf("Expected tree hash %s, Got: %s\nTree:\n%s", expected, hash, tree)
+	}
+	if tree.AddEntry(Normal, "sha1", MustParseObjectID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")) == nil {
+		t.Errorf("Expected a SHA1 hash to be rejected in a SHA256 tree")
+	}
+
+	parsed, err := ParseObject(SHA256, Tree, tree.Serialize())
+	if err != nil {
+		t.Fatalf("Unexpected error when parsing tree: %s", err)
+	}
+	entries := parsed.(*GitTree).Entries()
+	if len(entries) != 2 || entries[1].Hash.String() != "7c490ebf9db90b84753749c721ef2bedfeb85c1da94160f2619df1249c64bdda" {
+		t.Errorf("Expected the entries to be read back, Got: %v", entries)
+	}
+	// A SHA256 tree doesn't split into whole SHA1 entries
+	if _, err := ParseObject(SHA1, Tree, tree.Serialize()); err == nil {
+		t.Errorf("Expected an error when reading a SHA256 tree as SHA1")
+	}
+}
+
+// Test looking up algorithms by name
+func TestHashAlgorithmByName(t *testing.T) {
+	for name, expected := range map[string]*HashAlgorithm{"sha1": SHA1, "sha256": SHA256, "SHA256": SHA256} {
+		algo, err := HashAlgorithmByName(name)
+		if err != nil || algo != expected {
+			t.Errorf("Expected %s for '%s', Got: %v, %v", expected.Name(), name, algo, err)
+		}
+	}
+	if _, err := HashAlgorithmByName("md5"); err == nil {
+		t.Errorf("Expected an error for an unknown object format")
+	}
+}
@@ -0,0 +1,144 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+// maxHashSize The size of the longest hash, SHA256
+const maxHashSize = 32
+
+// ObjectID The name of an object: the hash of its header and content, of either object format.
+// IDs are made by hashing objects or by parsing them with ParseObjectID, so an ObjectID always
+// holds a full hash. The zero ObjectID names no object (e.g. the parent of a root commit).
+// ObjectIDs can be compared with == and used as map keys
+type ObjectID struct {
+	hash [maxHashSize]byte
+	size int
+}
+
+// ErrInvalidObjectID A string or byte slice isn't a full hash of the expected object format
+type ErrInvalidObjectID struct {
+	id string
+}
+
+func (e *ErrInvalidObjectID) Error() string {
+	return "invalid object id '" + e.id + "'"
+}
+
+// hashAlgorithms The object formats an ObjectID can be a hash of
+var hashAlgorithms = []*HashAlgorithm{SHA1, SHA256}
+
+// ParseObjectID Parse a full, lowercase hex hash of any object format
+func ParseObjectID(id string) (ObjectID, error) {
+	for _, algo := range hashAlgorithms {
+		if len(id) == algo.HexSize() {
+			return algo.ParseID(id)
+		}
+	}
+	return ObjectID{}, &ErrInvalidObjectID{id: id}
+}
+
+// MustParseObjectID Like ParseObjectID but panics if id isn't valid. Only meant for hashes that
+// are known to be valid, e.g. constants
+func MustParseObjectID(id string) ObjectID {
+	oid, err := ParseObjectID(id)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+// ParseID Parse a full, lowercase hex hash of this algorithm
+func (algo *HashAlgorithm) ParseID(id string) (ObjectID, error) {
+	if len(id) != algo.HexSize() || !isLowerHex(id) {
+		return ObjectID{}, &ErrInvalidObjectID{id: id}
+	}
+	oid := ObjectID{size: algo.Size()}
+	hex.Decode(oid.hash[:], []byte(id))
+	return oid, nil
+}
+
+// IDFromBytes Make an ObjectID from a raw hash of this algorithm, as stored in trees, the index
+// and packs. The bytes are copied
+func (algo *HashAlgorithm) IDFromBytes(raw []byte) (ObjectID, error) {
+	if len(raw) != algo.Size() {
+		return ObjectID{}, &ErrInvalidObjectID{id: hex.EncodeToString(raw)}
+	}
+	oid := ObjectID{size: algo.Size()}
+	copy(oid.hash[:], raw)
+	return oid, nil
+}
+
+// NullID The ID made of only zeros, used where there is no object (e.g. in reflogs)
+func (algo *HashAlgorithm) NullID() ObjectID {
+	return ObjectID{size: algo.Size()}
+}
+
+// isLowerHex Check that s only has the characters of lowercase hex
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// String The hash as lowercase hex. The zero ObjectID is an empty string
+func (oid ObjectID) String() string {
+	return hex.EncodeToString(oid.Bytes())
+}
+
+// Bytes The raw hash
+func (oid ObjectID) Bytes() []byte {
+	return append([]byte{}, oid.hash[:oid.size]...)
+}
+
+// Size The length of the hash in bytes; 0 for the zero ObjectID
+func (oid ObjectID) Size() int {
+	return oid.size
+}
+
+// IsZero Check if the ID is the zero ObjectID, which names no object
+func (oid ObjectID) IsZero() bool {
+	return oid.size == 0
+}
+
+// IsNull Check if the ID is made of only zeros (see HashAlgorithm.NullID)
+func (oid ObjectID) IsNull() bool {
+	return oid.size != 0 && oid.hash == [maxHashSize]byte{}
+}
+
+// Abbrev The first n characters of the hash in hex, or the whole hash if it is shorter
+func (oid ObjectID) Abbrev(n int) string {
+	id := oid.String()
+	if n < 0 || n >= len(id) {
+		return id
+	}
+	return id[:n]
+}
+
+// HasPrefix Check if the hash in hex starts with prefix
+func (oid ObjectID) HasPrefix(prefix string) bool {
+	if len(prefix) > oid.size*2 {
+		return false
+	}
+	return oid.Abbrev(len(prefix)) == prefix
+}
+
+// Compare Order IDs by their bytes, returning -1, 0 or 1 like bytes.Compare. Hashes sort the
+// same way as their hex
+func (oid ObjectID) Compare(other ObjectID) int {
+	return bytes.Compare(oid.hash[:oid.size], other.hash[:other.size])
+}
+
+// Algorithm The algorithm the hash was made with, or nil for the zero ObjectID
+func (oid ObjectID) Algorithm() *HashAlgorithm {
+	for _, algo := range hashAlgorithms {
+		if oid.size == algo.Size() {
+			return algo
+		}
+	}
+	return nil
+}
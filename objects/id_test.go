@@ -0,0 +1,66 @@
+package objects
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test parsing IDs of both object formats and rejecting anything that isn't a full hash
+func TestParseObjectID(t *testing.T) {
+	for _, id := range []string{"e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"473a0f4c3be8a93681a267e3b1e9a7dcda1185436fe141f7749120a303721813"} {
+		oid, err := ParseObjectID(id)
+		if err != nil || oid.String() != id {
+			t.Errorf("Expected %s to be parsed, Got: %s, %v", id, oid, err)
+		}
+		if oid.Size() != len(id)/2 || oid.Algorithm().HexSize() != len(id) {
+			t.Errorf("Expected %s to be %d bytes, Got: %d", id, len(id)/2, oid.Size())
+		}
+	}
+	invalid := []string{"", "e69de29b", strings.Repeat("g", 40), strings.Repeat("A", 40), strings.Repeat("a", 41)}
+	for _, id := range invalid {
+		if _, err := ParseObjectID(id); err == nil {
+			t.Errorf("Expected an error parsing %q", id)
+		}
+	}
+	if _, err := SHA256.ParseID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"); err == nil {
+		t.Errorf("Expected a SHA1 hash to be rejected as SHA256")
+	}
+	if _, err := SHA1.IDFromBytes(make([]byte, 32)); err == nil {
+		t.Errorf("Expected 32 bytes to be rejected as SHA1")
+	}
+	oid, err := SHA1.IDFromBytes(MustParseObjectID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391").Bytes())
+	if err != nil || oid.String() != "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391" {
+		t.Errorf("Expected the raw hash to be read back, Got: %s, %v", oid, err)
+	}
+}
+
+// Test the zero and null IDs, abbreviations and comparisons
+func TestObjectID(t *testing.T) {
+	var zero ObjectID
+	if !zero.IsZero() || zero.IsNull() || zero.String() != "" || zero.Algorithm() != nil {
+		t.Errorf("Expected the zero ObjectID to be empty")
+	}
+	null := SHA1.NullID()
+	if null.IsZero() || !null.IsNull() || null.String() != strings.Repeat("0", 40) {
+		t.Errorf("Expected the null ID to be 40 zeros, Got: %s", null)
+	}
+	if SHA256.NullID() == null {
+		t.Errorf("Expected the null IDs of the two formats to differ")
+	}
+
+	low := MustParseObjectID("4b825dc642cb6eb9a060e54bf8d69288fbe4904b")
+	high := MustParseObjectID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	if low.Abbrev(7) != "4b825dc" || low.Abbrev(100) != low.String() {
+		t.Errorf("Expected abbreviations of %s, Got: %s, %s", low, low.Abbrev(7), low.Abbrev(100))
+	}
+	if !low.HasPrefix("4b825") || low.HasPrefix("4b826") || !low.HasPrefix("") || low.HasPrefix(low.String()+"0") {
+		t.Errorf("Expected prefixes of %s to be matched", low)
+	}
+	if low.Compare(high) != -1 || high.Compare(low) != 1 || low.Compare(MustParseObjectID(low.String())) != 0 {
+		t.Errorf("Expected %s to sort before %s", low, high)
+	}
+	if low != MustParseObjectID(low.String()) {
+		t.Errorf("Expected equal IDs to compare equal with ==")
+	}
+}
@@ -1,14 +1,14 @@
 package objects
 
 import (
+	"bytes"
 	"compress/zlib"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"strconv"
 )
 
+// ErrBadObject The content of an object is badly formed
 type ErrBadObject struct {
 	reason string
 }
@@ -17,6 +17,52 @@ func (e *ErrBadObject) Error() string {
 	return "Could not read object: " + e.reason
 }
 
+// ErrBadHeader The header of an object isn't in the form '<type> <size>\x00'
+type ErrBadHeader struct {
+	header string
+	reason string
+}
+
+func (e *ErrBadHeader) Error() string {
+	return fmt.Sprintf("Could not read object: bad header %q: %s", e.header, e.reason)
+}
+
+// ErrUnknownType The type in the header of an object isn't one of the four object types
+type ErrUnknownType struct {
+	objType string
+}
+
+func (e *ErrUnknownType) Error() string {
+	return fmt.Sprintf("Could not read object: unknown object type '%s'", e.objType)
+}
+
+// ErrSizeMismatch The content of an object is larger or smaller than the size in its header.
+// Content larger than the header says is reported as one byte larger, since the rest of it
+// isn't read
+type ErrSizeMismatch struct {
+	declared int64
+	actual   int64
+}
+
+func (e *ErrSizeMismatch) Error() string {
+	if e.actual > e.declared {
+		return fmt.Sprintf("Could not read object: object is larger than the %d bytes its header says",
+			e.declared)
+	}
+	return fmt.Sprintf("Could not read object: object has %d bytes but its header says %d",
+		e.actual, e.declared)
+}
+
+// IsBadObject Check if err is one of the errors returned for a badly formed object, as opposed
+// to an object that is missing or couldn't be read
+func IsBadObject(err error) bool {
+	switch err.(type) {
+	case *ErrBadObject, *ErrBadHeader, *ErrUnknownType, *ErrSizeMismatch:
+		return true
+	}
+	return false
+}
+
 // GitObject Interface for all Git objects
 type GitObject interface {
 	ByteDeserializable
@@ -24,8 +70,8 @@ type GitObject interface {
 	fmt.Stringer
 	// Type A string of the object type; using a custom type for clarity
 	Type() GitObjectType
-	// Size An ASCII representation of the size of the object
-	Size() string
+	// Size The size of the serialized object in bytes, excluding the header
+	Size() int
 }
 
 type ByteSerialized interface {
@@ -37,8 +83,8 @@ type ByteSerialized interface {
 type ByteDeserializable interface {
 	ByteSerialized
 	// Deserialize Parse a bunch of bytes into meaningful data
-	// Function will return an error if the object is incorrectly formatted
-	Deserialize(data []byte)
+	// Returns an *ErrBadObject if the object is incorrectly formatted
+	Deserialize(data []byte) error
 }
 
 // GitObjectType Denote the object type
@@ -51,6 +97,19 @@ const (
 	Tag    GitObjectType = "tag"
 )
 
+// maxHeaderSize The longest header that is read: the longest type ('commit'), a space, the
+// digits of any 64-bit size and the null byte fit with room to spare
+const maxHeaderSize = 32
+
+// Valid Check if the type is one of the four object types
+func (objType GitObjectType) Valid() bool {
+	switch objType {
+	case Blob, Commit, Tree, Tag:
+		return true
+	}
+	return false
+}
+
 // CompressAndSave TODO test
 // Compress a git object and writes it to an io.Writer
 func CompressAndSave(dst io.Writer, obj GitObject) error {
@@ -68,81 +127,147 @@ func CompressAndSave(dst io.Writer, obj GitObject) error {
 	return zWriter.Close()
 }
 
-// DecompressAndRead decompress contents in src and parse the resulting data as an object
+// DecompressAndRead decompress contents in src and parse the resulting data as an object of a
+// SHA1 repository
 func DecompressAndRead(src io.Reader) (GitObject, error) {
-	zReader, err := zlib.NewReader(src)
-	defer func(zReader io.ReadCloser) {
-		err := zReader.Close()
-		if err != nil {
-		}
-	}(zReader)
+	objType, content, err := DecompressRaw(src)
 	if err != nil {
 		return nil, err
 	}
-	// Arbitrarily chosen. Most git objects are <200 from experience blobs may be higher.
-	// 300 feels like a good middle ground between too many allocations and too much memory
-	data, err := io.ReadAll(zReader)
+	return ParseObject(SHA1, objType, content)
+}
+
+// DecompressRaw Decompress an object without parsing its content. Returns the type from the
+// header along with the content (i.e. everything after the header)
+func DecompressRaw(src io.Reader) (GitObjectType, []byte, error) {
+	// Hide any Close method so src is left open for the caller
+	reader, err := NewObjectReader(struct{ io.Reader }{src})
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
-	return ObjectFromBytes(data)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil, err
+	}
+	return reader.Type, content, nil
 }
 
-// ObjectFromBytes read a bunch of bytes and return the correct object
+// ObjectFromBytes read a bunch of bytes and return the correct object. Trees are read as trees
+// of a SHA1 repository
 func ObjectFromBytes(src []byte) (GitObject, error) {
-	nulPos := 0
-	// Increment nulPos until it is the index of the null byte at the end of the header
-	l := len(src)
-	for ; src[nulPos] != 0x00; nulPos++ {
-		if nulPos == l-1 {
-			return nil, &ErrBadObject{reason: "object is badly formed"}
+	nulPos := bytes.IndexByte(src, 0x00)
+	if nulPos < 0 || nulPos >= maxHeaderSize {
+		end := len(src)
+		if end > maxHeaderSize {
+			end = maxHeaderSize
 		}
+		return nil, &ErrBadHeader{header: string(src[:end]), reason: "missing the null byte after the size"}
 	}
-	objType, _, err := parseHeader(src[:nulPos+1])
+	objType, size, err := parseHeader(src[:nulPos+1])
 	if err != nil {
 		return nil, err
 	}
-	var obj GitObject
+	content := src[nulPos+1:]
+	if int64(len(content)) != size {
+		return nil, &ErrSizeMismatch{declared: size, actual: int64(len(content))}
+	}
+	return ParseObject(SHA1, objType, content)
+}
+
+// ParseObject Parse content (without a header) as an object of the given type. The entries of
+// trees are read as hashes of algo
+func ParseObject(algo *HashAlgorithm, objType GitObjectType, content []byte) (GitObject, error) {
+	obj, err := emptyObject(algo, objType)
+	if err != nil {
+		return nil, err
+	}
+	err = obj.Deserialize(content)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// ValidateObject Check that content parses as an object of the given type in a repository
+// using algo. Commits must have a tree, an author and a committer and tags must name the object
+// they point to, its type and the tag name
+func ValidateObject(algo *HashAlgorithm, objType GitObjectType, content []byte) error {
+	obj, err := ParseObject(algo, objType, content)
+	if err != nil {
+		return err
+	}
+	switch o := obj.(type) {
+	case *GitTree:
+		for _, entry := range o.Entries() {
+			if entry.Name == "" || entry.Mode.Bits() == 0 {
+				return &ErrBadObject{reason: fmt.Sprintf("tree has a bad entry '%s %s'", entry.Mode, entry.Name)}
+			}
+		}
+	case *GitCommit:
+		if o.TreeHash.IsZero() || o.author == nil || o.committer == nil {
+			return &ErrBadObject{reason: "commit is missing its tree, author or committer"}
+		}
+		for _, hash := range append(o.Parents(), o.TreeHash) {
+			if hash.Size() != algo.Size() {
+				return &ErrBadObject{reason: "commit has a hash that isn't " + algo.Name() + ": " + hash.String()}
+			}
+		}
+	case *GitTag:
+		if o.objectHash.IsZero() || o.tagType == "" || o.tagName == "" {
+			return &ErrBadObject{reason: "tag is missing its object, type or name"}
+		}
+		if o.objectHash.Size() != algo.Size() {
+			return &ErrBadObject{reason: "tag has a hash that isn't " + algo.Name() + ": " + o.objectHash.String()}
+		}
+	}
+	return nil
+}
+
+// Return an empty object of the given type, ready to be deserialized into
+func emptyObject(algo *HashAlgorithm, objType GitObjectType) (GitObject, error) {
 	switch objType {
 	case Commit:
-		obj = &GitCommit{}
+		return &GitCommit{}, nil
 	case Tree:
-		obj = &GitTree{}
+		return NewTree(algo), nil
 	case Tag:
-		obj = &GitTag{}
+		return &GitTag{}, nil
 	case Blob:
-		obj = &GitBlob{}
-	default:
-		return nil, &ErrBadObject{reason: string(objType) + " is not a valid type"}
+		return &GitBlob{}, nil
 	}
-	// TODO Added error checking once it has been added to objects
-	obj.Deserialize(src[nulPos+1:])
-	return obj, nil
+	return nil, &ErrUnknownType{objType: string(objType)}
 }
 
 // Helper function for reading an object's header and returing the relevant information
-func parseHeader(header []byte) (GitObjectType, int, error) {
-
-	var objType GitObjectType
-	spacePos := 0
-
-	if string(header[0:3]) == "tag" {
-		spacePos = 3
-		objType = Tag
-	} else if string(header[0:4]) == "blob" {
-		spacePos = 4
-		objType = Blob
-	} else if string(header[0:4]) == "tree" {
-		spacePos = 4
-		objType = Tree
-	} else if string(header[0:6]) == "commit" {
-		spacePos = 6
-		objType = Commit
+// The header must be exactly '<type> <size>\x00' where the type is one of the object types and
+// the size is written in decimal digits
+func parseHeader(header []byte) (GitObjectType, int64, error) {
+	if len(header) == 0 || header[len(header)-1] != 0x00 {
+		return "", 0, &ErrBadHeader{header: string(header), reason: "missing the null byte after the size"}
+	}
+	header = header[:len(header)-1]
+	spacePos := bytes.IndexByte(header, 0x20)
+	if spacePos < 0 {
+		return "", 0, &ErrBadHeader{header: string(header), reason: "missing the space after the type"}
+	}
+	objType := GitObjectType(header[:spacePos])
+	if !objType.Valid() {
+		return "", 0, &ErrUnknownType{objType: string(objType)}
+	}
+	sizeDigits := header[spacePos+1:]
+	if len(sizeDigits) == 0 {
+		return "", 0, &ErrBadHeader{header: string(header), reason: "missing the size"}
+	}
+	for _, digit := range sizeDigits {
+		if digit < '0' || digit > '9' {
+			return "", 0, &ErrBadHeader{header: string(header), reason: "size is not a number"}
+		}
 	}
 	// Convert the ASCII size representation into an int
-	size, err := strconv.Atoi(string(header[spacePos+1 : len(header)-1]))
+	size, err := strconv.ParseInt(string(sizeDigits), 10, 64)
 	if err != nil {
-		return "", 0, err
+		return "", 0, &ErrBadHeader{header: string(header), reason: "size is too large"}
 	}
 	return objType, size, nil
 }
@@ -152,17 +277,15 @@ func parseHeader(header []byte) (GitObjectType, int, error) {
 //func Decompress(src io.Reader) (GitObject, error) {
 //}
 
-// Hash A SHA1 representation of an object and its header
-func Hash(obj GitObject) string {
-	hashBytes := sha1.Sum(append(Header(obj), obj.Serialize()...))
-	return hex.EncodeToString(hashBytes[:])
+// Hash A SHA1 representation of an object and its header. Use HashAlgorithm.Hash for objects
+// of repositories with other object formats
+func Hash(obj GitObject) ObjectID {
+	return SHA1.Hash(obj)
 }
 
 // Header A git header for the object
 // A header should be in the format:
 // Object Type + Space (0x20) + Object Size + Null Byte (0x00)
 func Header(obj GitObject) []byte {
-	typeAndSpace := append([]byte(obj.Type()), 0x20)
-	typeSize := append(typeAndSpace, []byte(obj.Size())...)
-	return append(typeSize, 0x00)
+	return StreamHeader(obj.Type(), int64(obj.Size()))
 }
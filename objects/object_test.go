@@ -1,6 +1,7 @@
 package objects
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 )
@@ -23,6 +24,36 @@ func TestParseHeader(t *testing.T) {
 	}
 }
 
+// Test that headers with unknown types, bad sizes or sizes that don't match the content are
+// rejected with the matching error
+func TestParseHeaderErrors(t *testing.T) {
+	badHeaders := map[string]string{
+		"treex 0\x00":   "*objects.ErrUnknownType",
+		"Blob 0\x00":    "*objects.ErrUnknownType",
+		" 0\x00":        "*objects.ErrUnknownType",
+		"blob\x00":      "*objects.ErrBadHeader",
+		"blob \x00":     "*objects.ErrBadHeader",
+		"blob +0\x00":   "*objects.ErrBadHeader",
+		"blob 0 \x00":   "*objects.ErrBadHeader",
+		"blob 0x1\x00a": "*objects.ErrBadHeader",
+		"blob 11111111111111111111111111111111\x00": "*objects.ErrBadHeader",
+		"blob 5\x00abc": "*objects.ErrSizeMismatch",
+		"blob 2\x00abc": "*objects.ErrSizeMismatch",
+		"tree 1\x00":    "*objects.ErrSizeMismatch",
+		"blob 3":        "*objects.ErrBadHeader",
+	}
+	for data, expected := range badHeaders {
+		_, err := ObjectFromBytes([]byte(data))
+		if fmt.Sprintf("%T", err) != expected || !IsBadObject(err) {
+			t.Errorf("Expected %s when parsing %q, Got: %T (%v)", expected, data, err, err)
+		}
+	}
+	obj, err := ObjectFromBytes([]byte("blob 3\x00abc"))
+	if err != nil || obj.Size() != 3 {
+		t.Errorf("Expected a 3 byte blob, Got: %v", err)
+	}
+}
+
 func TestHeaderGen(t *testing.T) {
 	sampleHeader := make([]byte, 8, 8)
 	sampleHeader[0] = 'b'
@@ -50,7 +81,7 @@ func TestHash(t *testing.T) {
 	hash := Hash(blob)
 	expectedHash := "95d09f2b10159347eece71399a7e2e907ea3df4f"
 
-	if hash != expectedHash {
+	if hash.String() != expectedHash {
 		t.Errorf("Expected hash to be: \n%s\nGot:\n%s", expectedHash, hash)
 		fmt.Println("Byte contents of blob:")
 		printBytes(blob.Serialize())
@@ -59,7 +90,7 @@ func TestHash(t *testing.T) {
 	blob.Deserialize([]byte("what is up, doc?"))
 	hash = Hash(blob)
 	expectedHash = "bd9dbf5aae1a3862dd1526723246b20206e5fc37"
-	if hash != expectedHash {
+	if hash.String() != expectedHash {
 		t.Errorf("Expected hash to be: \n%s\nGot:\n%s", expectedHash, hash)
 		fmt.Println("Byte contents of blob:")
 		printBytes(blob.Serialize())
@@ -73,3 +104,109 @@ func printBytes(src []byte) {
 	}
 	fmt.Println("")
 }
+
+// Test that content is only accepted when it parses as the given type
+func TestValidateObject(t *testing.T) {
+	commit := "tree 4b825dc642cb6eb9a060e54bf8d69288fbe4904b\n" +
+		"author A U Thor <author@example.com> 1625088346 +0000\n" +
+		"committer A U Thor <author@example.com> 1625088346 +0000\n\nmessage\n"
+	tree := &GitTree{}
+	err := tree.AddEntry(Normal, "file", MustParseObjectID("95d09f2b10159347eece71399a7e2e907ea3df4f"))
+	if err != nil {
+		t.Fatalf("Unexpected error when creating tree: %s", err)
+	}
+	valid := map[GitObjectType]string{
+		Blob:   "anything at all",
+		Commit: commit,
+		Tree:   string(tree.Serialize()),
+	}
+	for objType, content := range valid {
+		err := ValidateObject(SHA1, objType, []byte(content))
+		if err != nil {
+			t.Errorf("Unexpected error when validating %s: %s", objType, err)
+		}
+	}
+	invalid := map[GitObjectType]string{
+		Commit: "not a commit\n",
+		Tree:   "100644 file",
+		Tag:    "object 95d09f2b10159347eece71399a7e2e907ea3df4f\n",
+	}
+	for objType, content := range invalid {
+		err := ValidateObject(SHA1, objType, []byte(content))
+		if _, ok := err.(*ErrBadObject); !ok {
+			t.Errorf("Expected ErrBadObject when validating %q as a %s, Got: %v", content, objType, err)
+		}
+	}
+}
+
+// Test that badly formed objects are reported as errors instead of panicking
+func TestMalformedObjects(t *testing.T) {
+	malformed := []struct {
+		objType GitObjectType
+		content string
+	}{
+		{Commit, "tree"},
+		{Commit, "author A <a@b.c> 1625088346"},
+		{Commit, "committer x y\n"},
+		{Tag, "object\n"},
+		{Tag, "tagger A <a@b.c>\n"},
+		{Tree, "100644 file"},
+		{Tree, "100644 file\x00abcd"},
+		{Tree, "file\x0012345678901234567890"},
+	}
+	for _, bad := range malformed {
+		data := append(StreamHeader(bad.objType, int64(len(bad.content))), bad.content...)
+		_, err := ObjectFromBytes(data)
+		if _, ok := err.(*ErrBadObject); !ok {
+			t.Errorf("Expected ErrBadObject when parsing %q, Got: %v", data, err)
+		}
+	}
+	// Lines in the message of a commit are not parsed as headers
+	commit := &GitCommit{}
+	err := commit.Deserialize([]byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbe4904b\nauthor A <a@b.c> 1 +0000\n\nauthor of the change\ntree\n"))
+	if err != nil || commit.Msg != "author of the change\ntree" {
+		t.Errorf("Expected the message to be kept as is, Got: %q, %v", commit.Msg, err)
+	}
+}
+
+// Check that data either fails to parse as an object with an *ErrBadObject, or parses into an
+// object whose serialized form has the size it reports and parses back into the same object
+func fuzzDeserialize(t *testing.T, newObject func() GitObject, data []byte) {
+	obj := newObject()
+	err := obj.Deserialize(data)
+	if err != nil {
+		if _, ok := err.(*ErrBadObject); !ok {
+			t.Fatalf("Expected an ErrBadObject when parsing %q, Got: %v", data, err)
+		}
+		return
+	}
+	serialized := obj.Serialize()
+	if len(serialized) != obj.Size() {
+		t.Fatalf("Expected size %d for %q, Got: %d", len(serialized), data, obj.Size())
+	}
+	reparsed := newObject()
+	err = reparsed.Deserialize(serialized)
+	if err != nil {
+		t.Fatalf("Could not parse serialized object %q: %s", serialized, err)
+	}
+	if !bytes.Equal(reparsed.Serialize(), serialized) {
+		t.Fatalf("Expected %q to be unchanged after parsing, Got: %q", serialized, reparsed.Serialize())
+	}
+}
+
+// Fuzz parsing whole objects, header included
+func FuzzObjectFromBytes(f *testing.F) {
+	f.Add([]byte("blob 11\x00hello world"))
+	f.Add([]byte("tree 0\x00"))
+	f.Add([]byte("commit 10\x00tree abc\n\n"))
+	f.Add([]byte("tag 13\x00object abc\n\n\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		obj, err := ObjectFromBytes(data)
+		if err == nil && obj == nil {
+			t.Fatalf("Expected an object or an error when parsing %q", data)
+		}
+		if err != nil && !IsBadObject(err) {
+			t.Fatalf("Expected a bad object error when parsing %q, Got: %v", data, err)
+		}
+	})
+}
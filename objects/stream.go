@@ -0,0 +1,232 @@
+package objects
+
+import (
+	"bufio"
+	"compress/zlib"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Streaming versions of the object functions. They work on an io.Reader with a known size so
+// large files never have to be held in memory
+
+// StreamHeader The git header for an object of the given type and size
+func StreamHeader(objType GitObjectType, size int64) []byte {
+	header := append([]byte(objType), 0x20)
+	header = strconv.AppendInt(header, size, 10)
+	return append(header, 0x00)
+}
+
+// HashReader Compute the SHA1 hash of an object of the given type whose content is read from
+// src. Exactly size bytes must be read from src
+func HashReader(objType GitObjectType, size int64, src io.Reader) (ObjectID, error) {
+	return SHA1.HashReader(objType, size, src)
+}
+
+// HashReader Compute the hash of an object of the given type whose content is read from src.
+// Exactly size bytes must be read from src
+func (algo *HashAlgorithm) HashReader(objType GitObjectType, size int64, src io.Reader) (ObjectID, error) {
+	return algo.CompressStream(io.Discard, objType, size, src, false)
+}
+
+// CompressStream Like HashAlgorithm.CompressStream, naming the object with SHA1
+func CompressStream(dst io.Writer, objType GitObjectType, size int64, src io.Reader, compress bool) (ObjectID, error) {
+	return SHA1.CompressStream(dst, objType, size, src, compress)
+}
+
+// CompressStream Write an object whose content is read from src to dst, compressing it if
+// compress is set (otherwise nothing is written). The header is written before the content.
+// Returns the hash of the object. Exactly size bytes must be read from src. Content that is
+// part of a SHA-1 collision attack is rejected with ErrCollisionAttack
+func (algo *HashAlgorithm) CompressStream(dst io.Writer, objType GitObjectType, size int64, src io.Reader,
+	compress bool) (ObjectID, error) {
+	hasher := algo.New()
+	writers := []io.Writer{hasher}
+	var zWriter *zlib.Writer
+	if compress {
+		zWriter = zlib.NewWriter(dst)
+		writers = append(writers, zWriter)
+	}
+	out := io.MultiWriter(writers...)
+	_, err := out.Write(StreamHeader(objType, size))
+	if err != nil {
+		return ObjectID{}, err
+	}
+	// Read one more byte than expected to detect content that is larger than size
+	n, err := io.Copy(out, io.LimitReader(src, size+1))
+	if err != nil {
+		return ObjectID{}, err
+	}
+	if n != size {
+		return ObjectID{}, &ErrSizeMismatch{declared: size, actual: n}
+	}
+	if zWriter != nil {
+		err = zWriter.Close()
+		if err != nil {
+			return ObjectID{}, err
+		}
+	}
+	hash := algo.sumID(hasher)
+	if detector, ok := hasher.(collisionDetector); ok && detector.CollisionDetected() {
+		return ObjectID{}, &ErrCollisionAttack{hash: hash}
+	}
+	return hash, nil
+}
+
+// OpenFile Open the content of a file as it would be stored in a blob, returning its size.
+// Symbolic links are not followed; their content is the target of the link
+func OpenFile(path string) (io.ReadCloser, int64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(strings.NewReader(target)), int64(len(target)), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// HashFile Compute the SHA1 hash of the blob for a file without reading it all into memory
+func HashFile(path string) (ObjectID, error) {
+	return SHA1.HashFile(path)
+}
+
+// HashFile Compute the hash of the blob for a file without reading it all into memory
+func (algo *HashAlgorithm) HashFile(path string) (ObjectID, error) {
+	content, size, err := OpenFile(path)
+	if err != nil {
+		return ObjectID{}, err
+	}
+	defer content.Close()
+	return algo.HashReader(Blob, size, content)
+}
+
+// ObjectReader Reads the content of a compressed object after its header has been parsed
+type ObjectReader struct {
+	// Type The type of the object from its header
+	Type GitObjectType
+	// Size The size of the content from its header
+	Size    int64
+	src     io.Reader
+	zReader io.ReadCloser
+	content io.Reader
+}
+
+// NewObjectReader Start decompressing an object from src and parse its header. The content of
+// the object can then be read from the ObjectReader, which returns an error if there is more
+// or less content than the header says. If src is an io.Closer, it is closed along with the
+// ObjectReader
+func NewObjectReader(src io.Reader) (*ObjectReader, error) {
+	zReader, err := zlib.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(zReader)
+	header, err := readHeader(buffered)
+	if err != nil {
+		zReader.Close()
+		return nil, err
+	}
+	objType, size, err := parseHeader(header)
+	if err != nil {
+		zReader.Close()
+		return nil, err
+	}
+	reader := &ObjectReader{Type: objType, Size: size, src: src, zReader: zReader}
+	reader.content = &sizeCheckedReader{src: buffered, size: size, remaining: size}
+	return reader, nil
+}
+
+// readHeader Read the header of an object up to and including its null byte. Reading stops
+// after maxHeaderSize bytes so a corrupt object isn't read into memory looking for the null byte
+func readHeader(src *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 0, maxHeaderSize)
+	for len(header) < maxHeaderSize {
+		c, err := src.ReadByte()
+		if err == io.EOF {
+			return nil, &ErrBadHeader{header: string(header), reason: "missing the null byte after the size"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, c)
+		if c == 0x00 {
+			return header, nil
+		}
+	}
+	return nil, &ErrBadHeader{header: string(header), reason: "header is too long"}
+}
+
+// NewContentReader Create an ObjectReader for content that isn't compressed (e.g. an object
+// held in memory). Like NewObjectReader, an error is returned if src has more or less than size
+// bytes and src is closed along with the ObjectReader if it is an io.Closer
+func NewContentReader(objType GitObjectType, size int64, src io.Reader) *ObjectReader {
+	reader := &ObjectReader{Type: objType, Size: size, src: src}
+	reader.content = &sizeCheckedReader{src: src, size: size, remaining: size}
+	return reader
+}
+
+// Read Read the content of the object
+func (reader *ObjectReader) Read(p []byte) (int, error) {
+	return reader.content.Read(p)
+}
+
+// Close Stop decompressing the object and close the source
+func (reader *ObjectReader) Close() error {
+	var err error
+	if reader.zReader != nil {
+		err = reader.zReader.Close()
+	}
+	if closer, ok := reader.src.(io.Closer); ok {
+		closeErr := closer.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// sizeCheckedReader Reads exactly remaining bytes from src, returning an error if src ends
+// early or has data left over
+type sizeCheckedReader struct {
+	src       io.Reader
+	size      int64
+	remaining int64
+}
+
+func (reader *sizeCheckedReader) Read(p []byte) (int, error) {
+	if reader.remaining == 0 {
+		// Anything after the content means the header was wrong
+		extra := make([]byte, 1)
+		n, err := reader.src.Read(extra)
+		if n > 0 {
+			return 0, &ErrSizeMismatch{declared: reader.size, actual: reader.size + 1}
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > reader.remaining {
+		p = p[:reader.remaining]
+	}
+	n, err := reader.src.Read(p)
+	reader.remaining -= int64(n)
+	if err == io.EOF && reader.remaining > 0 {
+		return n, &ErrSizeMismatch{declared: reader.size, actual: reader.size - reader.remaining}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,135 @@
+package objects
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Test that hashing a stream gives the same result as hashing a blob
+func TestHashReader(t *testing.T) {
+	data := "what is up, doc?"
+	hash, err := HashReader(Blob, int64(len(data)), strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error when hashing: %s", err)
+	}
+	blob := &GitBlob{}
+	blob.Deserialize([]byte(data))
+	if hash != Hash(blob) {
+		t.Errorf("Expected hash %s, Got: %s", Hash(blob), hash)
+	}
+	// The content must be exactly the size given
+	_, err = HashReader(Blob, int64(len(data))+1, strings.NewReader(data))
+	if err == nil {
+		t.Errorf("Expected an error when the content is smaller than the size")
+	}
+	_, err = HashReader(Blob, int64(len(data))-1, strings.NewReader(data))
+	if err == nil {
+		t.Errorf("Expected an error when the content is larger than the size")
+	}
+}
+
+// Test that a compressed stream can be read back and matches the non-streaming functions
+func TestCompressStreamRoundTrip(t *testing.T) {
+	data := strings.Repeat("large content ", 10000)
+	compressed := &bytes.Buffer{}
+	hash, err := CompressStream(compressed, Blob, int64(len(data)), strings.NewReader(data), true)
+	if err != nil {
+		t.Fatalf("Unexpected error when compressing: %s", err)
+	}
+	reader, err := NewObjectReader(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error when opening object: %s", err)
+	}
+	if reader.Type != Blob || reader.Size != int64(len(data)) {
+		t.Errorf("Expected a blob of size %d, Got: %s of size %d", len(data), reader.Type, reader.Size)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error when reading object: %s", err)
+	}
+	if string(content) != data {
+		t.Errorf("Expected the content read back to match")
+	}
+	obj, err := DecompressAndRead(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error when decompressing: %s", err)
+	}
+	if Hash(obj) != hash {
+		t.Errorf("Expected hash %s, Got: %s", hash, Hash(obj))
+	}
+}
+
+// Test that objects whose header doesn't match their content are rejected
+func TestObjectReaderSizeMismatch(t *testing.T) {
+	for _, raw := range []string{"blob 5\x00abc", "blob 2\x00abc"} {
+		compressed := &bytes.Buffer{}
+		zWriter := zlib.NewWriter(compressed)
+		zWriter.Write([]byte(raw))
+		zWriter.Close()
+		_, _, err := DecompressRaw(compressed)
+		if _, ok := err.(*ErrSizeMismatch); !ok {
+			t.Errorf("Expected ErrSizeMismatch when reading %q, Got: %v", raw, err)
+		}
+	}
+}
+
+// Test that files and symbolic links are hashed like FileBlob does
+func TestHashFile(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "file")
+	err := os.WriteFile(filePath, []byte("file content\n"), 0644)
+	if err != nil {
+		t.Fatalf("Error creating file: %s", err)
+	}
+	linkPath := path.Join(dir, "link")
+	err = os.Symlink("file", linkPath)
+	if err != nil {
+		t.Fatalf("Error creating symlink: %s", err)
+	}
+	for _, p := range []string{filePath, linkPath} {
+		hash, err := HashFile(p)
+		if err != nil {
+			t.Fatalf("Unexpected error when hashing %s: %s", p, err)
+		}
+		blob, err := FileBlob(p)
+		if err != nil {
+			t.Fatalf("Unexpected error when reading %s: %s", p, err)
+		}
+		if hash != Hash(blob) {
+			t.Errorf("Expected hash %s for %s, Got: %s", Hash(blob), p, hash)
+		}
+	}
+}
+
+// collidingHash A SHA-1 hash that reports every input as part of a collision attack
+type collidingHash struct {
+	hash.Hash
+}
+
+func (h collidingHash) CollisionDetected() bool {
+	return true
+}
+
+// Test that objects flagged by the collision detection are rejected
+func TestCompressStreamCollision(t *testing.T) {
+	algo := &HashAlgorithm{name: "sha1", size: sha1.Size, newHash: func() hash.Hash {
+		return collidingHash{sha1.New()}
+	}}
+	data := "shattered"
+	_, err := algo.CompressStream(io.Discard, Blob, int64(len(data)), strings.NewReader(data), true)
+	if _, ok := err.(*ErrCollisionAttack); !ok {
+		t.Errorf("Expected a collision attack to be reported, Got: %v", err)
+	}
+	// Ordinary content passes the real detection
+	_, err = HashReader(Blob, int64(len(data)), strings.NewReader(data))
+	if err != nil {
+		t.Errorf("Expected no collision for ordinary content, Got: %s", err)
+	}
+}
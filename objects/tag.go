@@ -1,7 +1,6 @@
 package objects
 
 import (
-	"fmt"
 	"strings"
 	"time"
 )
@@ -14,16 +13,16 @@ import (
 // The msg is an optional tag message
 // The tag object also supports an optional GPG key which is unsupported
 type GitTag struct {
-	objectHash string
+	objectHash ObjectID
 	tagType    GitObjectType
 	tagName    string
 	tagger     *commitIdentity
 	msg        string
 }
 
-// Size Returns the size of the tag as a string
-func (tag *GitTag) Size() string {
-	return fmt.Sprint(tag.computeSize())
+// Size Returns the size of the tag in bytes
+func (tag *GitTag) Size() int {
+	return tag.computeSize()
 }
 
 // Type Retuns 'tag'
@@ -32,43 +31,64 @@ func (tag *GitTag) Type() GitObjectType {
 }
 
 // Deserialize Set the fields of a tag struct using data in src (i.e. parse a tag object)
-func (tag *GitTag) Deserialize(src []byte) {
+// Like commits, only the lines before the first blank line are treated as headers
+func (tag *GitTag) Deserialize(src []byte) error {
+	tag.objectHash = ObjectID{}
+	tag.tagType = ""
+	tag.tagName = ""
+	tag.tagger = nil
 	tag.msg = ""
 	lines := strings.Split(string(src), "\n")
+	inHeader := true
 	// Loop through all the lines of the tag string
 	for _, line := range lines {
-		// Break the line into words
-		words := strings.Split(line, " ")
+		if line == "" {
+			inHeader = false
+		}
+		if !inHeader {
+			tag.msg += line + "\n"
+			continue
+		}
 		// The first word determines what information the line holds, process accordingly
-		switch words[0] {
+		key, value, err := splitHeaderLine(Tag, line)
+		if err != nil {
+			return err
+		}
+		switch key {
 		case "object":
-			tag.objectHash = words[1]
+			tag.objectHash, err = ParseObjectID(value)
+			if err != nil {
+				return &ErrBadObject{reason: "tag has a bad object line: " + err.Error()}
+			}
 		case "type":
-			tag.tagType = GitObjectType(words[1])
+			tag.tagType = GitObjectType(value)
 		case "tag":
-			tag.tagName = words[1]
+			tag.tagName = value
 		case "tagger":
-			tagger, err := idFromString(strings.Join(words[1:], " "))
+			tagger, err := idFromString(value)
 			if err != nil {
-				panic(err)
+				return &ErrBadObject{reason: "tag has a bad tagger line: " + err.Error()}
 			}
 			tag.tagger = tagger
 		default:
-			tag.msg += strings.Join(words, " ") + "\n"
+			tag.msg += line + "\n"
 		}
 	}
 	// Remove the Extra new line that will be added on the tag msg because of how its
 	// parsed
 	tag.msg = strings.Trim(tag.msg, "\n")
+	return nil
 }
 
 // Serialize Convert tag struct into a []byte (which is really just a string) ready for writing
 // to a file
 func (tag *GitTag) Serialize() []byte {
 	bytes := make([]byte, 0, tag.computeSize())
-	bytes = append(bytes, "object "...)
-	bytes = append(bytes, tag.objectHash...)
-	bytes = append(bytes, '\n')
+	if !tag.objectHash.IsZero() {
+		bytes = append(bytes, "object "...)
+		bytes = append(bytes, tag.objectHash.String()...)
+		bytes = append(bytes, '\n')
+	}
 
 	bytes = append(bytes, "type "...)
 	bytes = append(bytes, tag.tagType...)
@@ -85,7 +105,12 @@ func (tag *GitTag) Serialize() []byte {
 	}
 
 	bytes = append(bytes, '\n')
-	bytes = append(bytes, tag.msg...)
+	// Like commits, the message is followed by a new line. git leaves it out when there is no
+	// message, so the headers just end with the blank line
+	if tag.msg != "" {
+		bytes = append(bytes, tag.msg...)
+		bytes = append(bytes, '\n')
+	}
 	return bytes
 }
 
@@ -97,41 +122,45 @@ func (tag *GitTag) String() string {
 // Compute the overall size of the tag (i.e. the amount of bytes it would take to store
 // the tag as a string
 func (tag *GitTag) computeSize() int {
+	// The type and tag lines are always written, even if they are empty. The object line is
+	// left out until the object is set
 	size := 0
-	if tag.objectHash != "" {
+	if !tag.objectHash.IsZero() {
 		//(len("object") = 6) + space + \n = 8
-		size += 8 + len(tag.objectHash)
-	}
-
-	if tag.tagType != "" {
-		//(len("type") = 4) + space + \n = 6
-		size += 6 + len(tag.tagType)
-	}
-
-	if tag.tagName != "" {
-		//(len("tag") = 3) + space + \n = 5
-		size += 5 + len(tag.tagName)
+		size += 8 + tag.objectHash.Size()*2
 	}
+	//(len("type") = 4) + space + \n = 6
+	size += 6 + len(tag.tagType)
+	//(len("tag") = 3) + space + \n = 5
+	size += 5 + len(tag.tagName)
 
 	if tag.tagger != nil {
 		//(len("tagger") = 6) + space + \n = 8
 		size += tag.tagger.Size() + 8
 	}
 
-	// +2 is for the blank \n character before the tag message
-	// and the \n inserted after the tag message
-	size += len(tag.msg) + 1
+	// +1 is for the blank \n character before the tag message
+	size++
+	if tag.msg != "" {
+		// +1 is for the \n inserted after the tag message
+		size += len(tag.msg) + 1
+	}
 	return size
 }
 
 // SetObject Set the object hash and object type of the tag.
 // Can use struct field assignment, but a function that assigns them together empasizes
 // that they represent information about the same object
-func (tag *GitTag) SetObject(objType GitObjectType, objHash string) {
+func (tag *GitTag) SetObject(objType GitObjectType, objHash ObjectID) {
 	tag.objectHash = objHash
 	tag.tagType = objType
 }
 
+// Target Returns the type and hash of the object being tagged
+func (tag *GitTag) Target() (GitObjectType, ObjectID) {
+	return tag.tagType, tag.objectHash
+}
+
 // SetTagger Set the tagger (i.e. the person creating the tag)
 // the current system time and timezone will be used as a timestamp
 func (tag *GitTag) SetTagger(name string, email string) {
@@ -13,7 +13,7 @@ func TestTag(t *testing.T) {
 	}
 	tag.tagName = "test"
 	tag.msg = "Test tag"
-	tag.SetObject(Commit, "369fb3f5db3baf1b96032979af0cae946d4fd134")
+	tag.SetObject(Commit, MustParseObjectID("369fb3f5db3baf1b96032979af0cae946d4fd134"))
 
 	expectedHash := "83bf8e887fe355870191c95a4ed1dc106db81d29"
 	hash := Hash(tag)
@@ -23,7 +23,7 @@ func TestTag(t *testing.T) {
 		t.Errorf("Expected tag size to be 138, Got: %d\nTag Object:\n%s", size, tag.String())
 	}
 
-	if hash != expectedHash {
+	if hash.String() != expectedHash {
 		t.Errorf("Expected hash to be:\n%s\n Got:\n %s\nTag Object:\n%s ",
 			expectedHash,
 			hash,
@@ -40,7 +40,7 @@ func TestTagDeserialize(t *testing.T) {
 	}
 	sampleTag.tagName = "test"
 	sampleTag.msg = "Test tag"
-	sampleTag.SetObject(Commit, "369fb3f5db3baf1b96032979af0cae946d4fd134")
+	sampleTag.SetObject(Commit, MustParseObjectID("369fb3f5db3baf1b96032979af0cae946d4fd134"))
 
 	tag := &GitTag{}
 	tag.Deserialize(sampleTag.Serialize())
@@ -53,7 +53,7 @@ func TestTagDeserialize(t *testing.T) {
 		t.Errorf("Expected tag size to be 138, Got: %d\nTag Object:\n%s", size, tag.String())
 	}
 
-	if hash != expectedHash {
+	if hash.String() != expectedHash {
 		t.Errorf("Expected hash to be:\n%s\n Got:\n %s\nTag Object:\n%s ",
 			expectedHash,
 			hash,
@@ -61,3 +61,38 @@ func TestTagDeserialize(t *testing.T) {
 	}
 
 }
+
+// Test that tags written by git are serialized back to the same bytes and hash, with and
+// without a message
+func TestTagFromGit(t *testing.T) {
+	// Output of 'git cat-file tag' for tags made with 'git tag -a', and the hashes git gave them
+	tags := map[string]string{
+		"3b9526fd3d3b1251512650bff7c36b6b73880750": "object 5662fd1dac439a2fdf26f716705aa358dcda718b\n" +
+			"type commit\ntag v1\ntagger C <c@x> 1700000000 +0100\n\nRelease 1.0\n\nSecond paragraph\n",
+		"172ed22b904b82a7e6c8995b6a14c244b46ab13c": "object 5662fd1dac439a2fdf26f716705aa358dcda718b\n" +
+			"type commit\ntag v2\ntagger C <c@x> 1700000000 +0100\n\n",
+	}
+	for expectedHash, content := range tags {
+		tag := &GitTag{}
+		err := tag.Deserialize([]byte(content))
+		if err != nil {
+			t.Fatalf("Error parsing tag: %s", err)
+		}
+		if tag.String() != content || tag.Size() != len(content) {
+			t.Errorf("Expected tag:\n%q\nGot (size %d):\n%q", content, tag.Size(), tag.String())
+		}
+		if hash := Hash(tag); hash.String() != expectedHash {
+			t.Errorf("Expected hash to be %s, Got: %s", expectedHash, hash)
+		}
+	}
+}
+
+// Fuzz parsing tags
+func FuzzTag(f *testing.F) {
+	f.Add([]byte("object 369fb3f5db3baf1b96032979af0cae946d4fd134\ntype commit\ntag test\n" +
+		"tagger Simon Taye <mulat.simon@gmail.com> 1625088346 +0000\n\nTest tag\n"))
+	f.Add([]byte("object\ntagger x\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDeserialize(t, func() GitObject { return &GitTag{} }, data)
+	})
+}
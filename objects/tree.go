@@ -1,20 +1,38 @@
 package objects
 
 import (
-	"encoding/hex"
+	"bytes"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Format of tree objects:
 // Header-[Entries]
-// where entry: [mode] [name]0x00[SHA-1 of blob/tree being referenced in BINARY]
+// where entry: [mode] [name]0x00[hash (SHA-1 or SHA-256) of blob/tree being referenced in BINARY]
 // there is no separation between trees
 
 // A GitTree object that fullfils the GitObject interface
 type GitTree struct {
 	size    int
 	entries []*treeEntry
+	// algo The algorithm of the hashes in the entries; nil means SHA1
+	algo *HashAlgorithm
+}
+
+// NewTree Create an empty tree whose entries hold hashes of algo. A GitTree{} holds SHA1 hashes
+func NewTree(algo *HashAlgorithm) *GitTree {
+	return &GitTree{algo: algo}
+}
+
+// HashAlgorithm The algorithm of the hashes in the tree's entries
+func (tree *GitTree) HashAlgorithm() *HashAlgorithm {
+	if tree.algo == nil {
+		return SHA1
+	}
+	return tree.algo
 }
 
 // EntryFileMode The possible file modes for a Tree Entry
@@ -25,30 +43,95 @@ const (
 	Normal       EntryFileMode = "100644"
 	Executable   EntryFileMode = "100755"
 	SymbolicLink EntryFileMode = "120000"
-	Directory    EntryFileMode = "040000"
+	Directory    EntryFileMode = "40000"
+	GitLink      EntryFileMode = "160000"
 )
 
+// Masks for the object type stored in the upper bits of a unix file mode, and the owner's execute
+// bit, which is the only one git looks at
+const (
+	modeTypeMask   uint32 = 0170000
+	modeSymlink    uint32 = 0120000
+	modeGitLink    uint32 = 0160000
+	modeDirectory  uint32 = 0040000
+	modeExecutable uint32 = 0100
+)
+
+// FileModeFromBits Convert the unix mode bits stored in the index (object type + permissions)
+// into the mode used by tree entries. The owner's execute bit marks a regular file as executable
+func FileModeFromBits(bits uint32) EntryFileMode {
+	switch bits & modeTypeMask {
+	case modeSymlink:
+		return SymbolicLink
+	case modeGitLink:
+		return GitLink
+	case modeDirectory:
+		return Directory
+	}
+	if bits&modeExecutable != 0 {
+		return Executable
+	}
+	return Normal
+}
+
+// Bits Return the unix mode bits for a tree entry mode; the inverse of FileModeFromBits
+func (mode EntryFileMode) Bits() uint32 {
+	bits, err := strconv.ParseUint(string(mode), 8, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(bits)
+}
+
+// ObjectType Return the type of object an entry with this mode points to: trees for directories,
+// commits for submodules (git links) and blobs for everything else.
+// Zero-padded directory modes (i.e. "040000"), written by older versions, are also trees
+func (mode EntryFileMode) ObjectType() GitObjectType {
+	switch mode.Bits() & modeTypeMask {
+	case modeDirectory:
+		return Tree
+	case modeGitLink:
+		return Commit
+	}
+	return Blob
+}
+
+// Valid Check if the mode is one of the modes git writes into trees
+func (mode EntryFileMode) Valid() bool {
+	switch mode {
+	case Normal, Executable, SymbolicLink, Directory, GitLink:
+		return true
+	}
+	return false
+}
+
 // Represents a single entry in a GitTree
 type treeEntry struct {
 	mode EntryFileMode
 	name string
-	hash []byte
+	hash ObjectID
 }
 
-// Process a byte slice into a an tree entry
-func byteToEntry(data []byte) treeEntry {
-	spaceByte := 0
-	for ; data[spaceByte] != 0x20; spaceByte++ {
+// Process the start of a byte slice into a tree entry whose hash is made with algo.
+// Returns the entry along with the number of bytes it takes up
+func byteToEntry(data []byte, algo *HashAlgorithm) (*treeEntry, int, error) {
+	nullByte := bytes.IndexByte(data, 0x00)
+	if nullByte < 0 {
+		return nil, 0, &ErrBadObject{reason: "tree entry is missing the null byte after its name"}
 	}
-
-	mode := EntryFileMode(data[0:spaceByte])
-
-	nullByte := spaceByte + 1
-	for ; data[nullByte] != 0x00; nullByte++ {
+	spaceByte := bytes.IndexByte(data[:nullByte], 0x20)
+	if spaceByte < 0 {
+		return nil, 0, &ErrBadObject{reason: "tree entry is missing the space after its mode"}
 	}
+	// The hash comes right after the null byte
+	end := nullByte + 1 + algo.Size()
+	if end > len(data) {
+		return nil, 0, &ErrBadObject{reason: "tree entry has a truncated hash"}
+	}
+	mode := EntryFileMode(data[0:spaceByte])
 	name := string(data[spaceByte+1 : nullByte])
-	hash := data[nullByte+1:]
-	return treeEntry{mode: mode, name: name, hash: hash}
+	hash, _ := algo.IDFromBytes(data[nullByte+1 : end])
+	return &treeEntry{mode: mode, name: name, hash: hash}, end, nil
 }
 
 // Convert an entry into a byte slice for serializing
@@ -58,23 +141,23 @@ func (entry *treeEntry) toBytes() []byte {
 	bytes = append(bytes, 0x20)
 	bytes = append(bytes, []byte(entry.name)...)
 	bytes = append(bytes, 0x00)
-	bytes = append(bytes, entry.hash...)
+	bytes = append(bytes, entry.hash.Bytes()...)
 
 	return bytes
 }
 
 // Size Return the size of the entry
 func (entry *treeEntry) Size() int {
-	return len(entry.mode) + len(entry.name) + len(entry.hash) + 2
+	return len(entry.mode) + len(entry.name) + entry.hash.Size() + 2
 }
 
 func (entry *treeEntry) String() string {
-	return string(entry.mode) + " " + entry.name + " " + hex.EncodeToString(entry.hash)
+	return string(entry.mode) + " " + entry.name + " " + entry.hash.String()
 }
 
-// Size Return the size of the tree data (excluding header) as a string
-func (tree *GitTree) Size() string {
-	return fmt.Sprint(tree.size)
+// Size Return the size of the tree data (excluding header)
+func (tree *GitTree) Size() int {
+	return tree.size
 }
 
 // Type Return 'tree'
@@ -102,46 +185,93 @@ func (tree *GitTree) String() string {
 
 // Deserialize Convert a byte slice into a tree
 // Should not include the header bytes
-func (tree *GitTree) Deserialize(src []byte) {
+func (tree *GitTree) Deserialize(src []byte) error {
 	entries := make([]*treeEntry, 0, 5)
-	curByte, startByte := 0, 0
-	size := len(src)
-	for curByte < size {
-		// Set curByte to the position of the null byte
-		for ; src[curByte] != 0x00; curByte++ {
+	for start := 0; start < len(src); {
+		entry, size, err := byteToEntry(src[start:], tree.HashAlgorithm())
+		if err != nil {
+			return err
 		}
-		// Process from startByte to curByte+20 and append it to the tree
-		// curByte is at the null byte and the SHA1 is 20 bytes long. So the end of the
-		// entry is null byte + 20; we set it to null byte + 21 since the last byte is
-		// ignored when slicing.
-		entry := byteToEntry(src[startByte : curByte+21])
-		entries = append(entries, &entry)
-		// Start the loop again by setting startByte and curByte
-		//to the beginning of the next entry
-		startByte = curByte + 21
-		curByte = startByte
+		entries = append(entries, entry)
+		start += size
 	}
 	tree.entries = entries
-	tree.size = size
+	tree.size = len(src)
+	return nil
 }
 
-// AddEntry Add a entry into the tree object
-func (tree *GitTree) AddEntry(mode EntryFileMode, name string, hash string) {
-	hashBytes, err := hex.DecodeString(hash)
-	if err != nil {
-		panic(err)
+// AddEntry Add an entry into the tree object. Entries are kept in the order git uses, which
+// compares names as if directories ended with a '/'. The name must be a single path component
+// that isn't already in the tree, the mode one of the valid tree modes and the hash a full hash
+// of the tree's algorithm
+func (tree *GitTree) AddEntry(mode EntryFileMode, name string, hash ObjectID) error {
+	if !mode.Valid() {
+		return fmt.Errorf("invalid mode '%s' for tree entry '%s'", mode, name)
 	}
-	entry := treeEntry{mode: mode, name: name, hash: hashBytes}
-	tree.entries = append(tree.entries, &entry)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("invalid name '%s' for tree entry", name)
+	}
+	if hash.Size() != tree.HashAlgorithm().Size() {
+		return fmt.Errorf("invalid hash '%s' for tree entry '%s'", hash, name)
+	}
+	entry := &treeEntry{mode: mode, name: name, hash: hash}
+	pos := sort.Search(len(tree.entries), func(i int) bool {
+		return tree.entries[i].sortKey() >= entry.sortKey()
+	})
+	for _, existing := range tree.entries {
+		if existing.name == name {
+			return fmt.Errorf("duplicate tree entry '%s'", name)
+		}
+	}
+	tree.entries = append(tree.entries, nil)
+	copy(tree.entries[pos+1:], tree.entries[pos:])
+	tree.entries[pos] = entry
 	tree.size += entry.Size()
+	return nil
+}
+
+// The name used when ordering entries; directories are compared as if they end with a '/'
+func (entry *treeEntry) sortKey() string {
+	if entry.mode.ObjectType() == Tree {
+		return entry.name + "/"
+	}
+	return entry.name
+}
+
+// TreeEntry A read-only copy of an entry in a tree
+type TreeEntry struct {
+	Mode EntryFileMode
+	Type GitObjectType
+	Name string
+	Hash ObjectID
+}
+
+// Entries Returns the entries of the tree in the order they are stored
+func (tree *GitTree) Entries() []TreeEntry {
+	entries := make([]TreeEntry, 0, len(tree.entries))
+	for _, entry := range tree.entries {
+		entries = append(entries, TreeEntry{
+			Mode: entry.mode,
+			Type: entry.mode.ObjectType(),
+			Name: entry.name,
+			Hash: entry.hash,
+		})
+	}
+	return entries
 }
 
 // GetEntryHash Returns the hash for the specified file in the tree
-func (tree *GitTree) GetEntryHash(name string) (string, error) {
+func (tree *GitTree) GetEntryHash(name string) (ObjectID, error) {
+	_, hash, err := tree.GetEntry(name)
+	return hash, err
+}
+
+// GetEntry Returns the mode and hash for the specified file in the tree
+func (tree *GitTree) GetEntry(name string) (EntryFileMode, ObjectID, error) {
 	for _, entry := range tree.entries {
 		if entry.name == name {
-			return hex.EncodeToString(entry.hash), nil
+			return entry.mode, entry.hash, nil
 		}
 	}
-	return "", errors.New(fmt.Sprintf("entry '%s' not found", name))
+	return "", ObjectID{}, errors.New(fmt.Sprintf("entry '%s' not found", name))
 }
@@ -1,7 +1,7 @@
 package objects
 
 import (
-	"encoding/hex"
+	"strings"
 	"testing"
 )
 
@@ -9,17 +9,17 @@ import (
 // tests addEntry, Serialize, Size and that the byte layout is correct
 func TestTree(t *testing.T) {
 	tree := &GitTree{}
-	tree.AddEntry(Normal, "fullini_test.go", "cc09a49865b0f9a62039b7155a20f9f29af312b4")
-	tree.AddEntry(Normal, "parseline_test.go", "5b4c1b1891bc002cce7b22c2935257cccec9ef2e")
-	tree.AddEntry(Normal, "read.go", "f08b2de690a4661751f5bfa60f396a3656563654")
-	tree.AddEntry(Normal, "write.go", "ad5ac59ff07840e75684b4700a2eada5086b1308")
+	tree.AddEntry(Normal, "fullini_test.go", MustParseObjectID("cc09a49865b0f9a62039b7155a20f9f29af312b4"))
+	tree.AddEntry(Normal, "parseline_test.go", MustParseObjectID("5b4c1b1891bc002cce7b22c2935257cccec9ef2e"))
+	tree.AddEntry(Normal, "read.go", MustParseObjectID("f08b2de690a4661751f5bfa60f396a3656563654"))
+	tree.AddEntry(Normal, "write.go", MustParseObjectID("ad5ac59ff07840e75684b4700a2eada5086b1308"))
 
 	if tree.size != 159 {
 		t.Errorf("Expected tree size to be '159', Got: %d", tree.size)
 	}
 
 	expectedHash := "1335d19337aa47bb0b0ff5e8444a65cd8d63584c"
-	if Hash(tree) != expectedHash {
+	if Hash(tree).String() != expectedHash {
 		t.Errorf("Expected hash: %s\nGot: %s", expectedHash, Hash(tree))
 	}
 }
@@ -27,13 +27,16 @@ func TestTree(t *testing.T) {
 // Test that an entry object can be correctly constructed from bytes
 func TestEntryConstruction(t *testing.T) {
 	tree := &GitTree{}
-	tree.AddEntry(Normal, "fullini_test.go", "cc09a49865b0f9a62039b7155a20f9f29af312b4")
-	tree.AddEntry(Normal, "parseline_test.go", "5b4c1b1891bc002cce7b22c2935257cccec9ef2e")
-	tree.AddEntry(Normal, "read.go", "f08b2de690a4661751f5bfa60f396a3656563654")
-	tree.AddEntry(Normal, "write.go", "ad5ac59ff07840e75684b4700a2eada5086b1308")
+	tree.AddEntry(Normal, "fullini_test.go", MustParseObjectID("cc09a49865b0f9a62039b7155a20f9f29af312b4"))
+	tree.AddEntry(Normal, "parseline_test.go", MustParseObjectID("5b4c1b1891bc002cce7b22c2935257cccec9ef2e"))
+	tree.AddEntry(Normal, "read.go", MustParseObjectID("f08b2de690a4661751f5bfa60f396a3656563654"))
+	tree.AddEntry(Normal, "write.go", MustParseObjectID("ad5ac59ff07840e75684b4700a2eada5086b1308"))
 	treeBytes := tree.Serialize()
 
-	testEntry := byteToEntry(treeBytes[0:43])
+	testEntry, size, err := byteToEntry(treeBytes, SHA1)
+	if err != nil || size != 43 {
+		t.Fatalf("Expected a 43 byte entry, Got: %d, %v", size, err)
+	}
 
 	if testEntry.mode != Normal {
 		t.Errorf("Expected 'Normal' file mode, Got: %s", testEntry.mode)
@@ -42,8 +45,8 @@ func TestEntryConstruction(t *testing.T) {
 		t.Errorf("Expected the name 'fullini_test.go', Got: %s", testEntry.name)
 	}
 	expectedHash := "cc09a49865b0f9a62039b7155a20f9f29af312b4"
-	if hex.EncodeToString(testEntry.hash) != expectedHash {
-		t.Errorf("Expected hash: %s\nGot: %s", expectedHash, hex.EncodeToString(testEntry.hash))
+	if testEntry.hash.String() != expectedHash {
+		t.Errorf("Expected hash: %s\nGot: %s", expectedHash, testEntry.hash)
 	}
 }
 
@@ -51,10 +54,10 @@ func TestEntryConstruction(t *testing.T) {
 // constructed from the byte slice returned from serialization
 func TestTreeSerializing(t *testing.T) {
 	tree := &GitTree{}
-	tree.AddEntry(Normal, "fullini_test.go", "cc09a49865b0f9a62039b7155a20f9f29af312b4")
-	tree.AddEntry(Normal, "parseline_test.go", "5b4c1b1891bc002cce7b22c2935257cccec9ef2e")
-	tree.AddEntry(Normal, "read.go", "f08b2de690a4661751f5bfa60f396a3656563654")
-	tree.AddEntry(Normal, "write.go", "ad5ac59ff07840e75684b4700a2eada5086b1308")
+	tree.AddEntry(Normal, "fullini_test.go", MustParseObjectID("cc09a49865b0f9a62039b7155a20f9f29af312b4"))
+	tree.AddEntry(Normal, "parseline_test.go", MustParseObjectID("5b4c1b1891bc002cce7b22c2935257cccec9ef2e"))
+	tree.AddEntry(Normal, "read.go", MustParseObjectID("f08b2de690a4661751f5bfa60f396a3656563654"))
+	tree.AddEntry(Normal, "write.go", MustParseObjectID("ad5ac59ff07840e75684b4700a2eada5086b1308"))
 
 	newTree := &GitTree{}
 	newTree.Deserialize(tree.Serialize())
@@ -68,3 +71,130 @@ func TestTreeSerializing(t *testing.T) {
 	}
 
 }
+
+// Test that unix mode bits (as stored in the index) map to the expected tree entry modes and back
+func TestFileModeFromBits(t *testing.T) {
+	cases := map[uint32]EntryFileMode{
+		0100644: Normal,
+		0100755: Executable,
+		0100744: Executable,
+		0100655: Normal,
+		0100700: Executable,
+		0120000: SymbolicLink,
+		0160000: GitLink,
+		0040000: Directory,
+	}
+	for bits, expected := range cases {
+		mode := FileModeFromBits(bits)
+		if mode != expected {
+			t.Errorf("Expected mode %o to be '%s', Got: '%s'", bits, expected, mode)
+		}
+	}
+	if Executable.Bits() != 0100755 {
+		t.Errorf("Expected Executable.Bits() to be %o, Got: %o", 0100755, Executable.Bits())
+	}
+	if SymbolicLink.Bits() != 0120000 {
+		t.Errorf("Expected SymbolicLink.Bits() to be %o, Got: %o", 0120000, SymbolicLink.Bits())
+	}
+}
+
+// Test that entries report the type of object they point to
+func TestTreeEntries(t *testing.T) {
+	tree := &GitTree{}
+	blobHash := MustParseObjectID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	treeHash := MustParseObjectID("4b825dc642cb6eb9a060e54bf8d69288fbe4904b")
+	tree.AddEntry(Normal, "file", blobHash)
+	tree.AddEntry(Directory, "dir", treeHash)
+	tree.AddEntry(GitLink, "module", blobHash)
+	expected := []TreeEntry{
+		{Mode: Directory, Type: Tree, Name: "dir", Hash: treeHash},
+		{Mode: Normal, Type: Blob, Name: "file", Hash: blobHash},
+		{Mode: GitLink, Type: Commit, Name: "module", Hash: blobHash},
+	}
+	entries := tree.Entries()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, Got: %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry != expected[i] {
+			t.Errorf("Expected entry %v, Got: %v", expected[i], entry)
+		}
+	}
+}
+
+// Test that entries are sorted the way git sorts them regardless of the order they are added in.
+// The expected hash is of a tree written by git with the same entries
+func TestTreeOrdering(t *testing.T) {
+	entries := []TreeEntry{
+		{Mode: Normal, Name: "foo-bar", Hash: MustParseObjectID("0cfbf08886fca9a91cb753ec8734c84fcbe52c9f")},
+		{Mode: Normal, Name: "foo.c", Hash: MustParseObjectID("d00491fd7e5bb6fa28c517a0bb32b8b506539d4d")},
+		{Mode: Directory, Name: "foo", Hash: MustParseObjectID("edc566508fc1a91964d1ad1c27574fdab11e3da1")},
+		{Mode: Normal, Name: "foo0", Hash: MustParseObjectID("b8626c4cff2849624fb67f87cd0ad72b163671ad")},
+		{Mode: SymbolicLink, Name: "link", Hash: MustParseObjectID("39628bf003a771d6cb724e8e7214ce11321ccd28")},
+		{Mode: Executable, Name: "run.sh", Hash: MustParseObjectID("7ed6ff82de6bcc2a78243fc9c54d3ef5ac14da69")},
+	}
+	expectedHash := "5fdf8ed98367b5380fcb69df3fd53472ba4ece9a"
+	orders := [][]int{
+		{0, 1, 2, 3, 4, 5},
+		{5, 4, 3, 2, 1, 0},
+		{2, 0, 4, 1, 5, 3},
+	}
+	for _, order := range orders {
+		tree := &GitTree{}
+		for _, i := range order {
+			err := tree.AddEntry(entries[i].Mode, entries[i].Name, entries[i].Hash)
+			if err != nil {
+				t.Fatalf("Unexpected error when adding '%s': %s", entries[i].Name, err)
+			}
+		}
+		if Hash(tree).String() != expectedHash {
+			t.Errorf("Expected hash %s when adding entries in order %v, Got: %s\nTree:\n%s",
+				expectedHash, order, Hash(tree), tree)
+		}
+	}
+}
+
+// Test that entries git considers invalid are rejected
+func TestTreeEntryValidation(t *testing.T) {
+	hash := MustParseObjectID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	tree := &GitTree{}
+	err := tree.AddEntry(Normal, "file", hash)
+	if err != nil {
+		t.Fatalf("Unexpected error when adding entry: %s", err)
+	}
+	invalid := []TreeEntry{
+		{Mode: Normal, Name: "file", Hash: hash},
+		{Mode: Directory, Name: "file", Hash: hash},
+		{Mode: "100664", Name: "other", Hash: hash},
+		{Mode: "040000", Name: "other", Hash: hash},
+		{Mode: Normal, Name: "", Hash: hash},
+		{Mode: Normal, Name: "..", Hash: hash},
+		{Mode: Normal, Name: "dir/file", Hash: hash},
+		{Mode: Normal, Name: "other", Hash: ObjectID{}},
+		{Mode: Normal, Name: "other", Hash: MustParseObjectID(strings.Repeat("ab", 32))},
+	}
+	for _, entry := range invalid {
+		if tree.AddEntry(entry.Mode, entry.Name, entry.Hash) == nil {
+			t.Errorf("Expected an error when adding %+v", entry)
+		}
+	}
+	if len(tree.Entries()) != 1 {
+		t.Errorf("Expected invalid entries not to be added, Got: %v", tree.Entries())
+	}
+	// Trees written with zero-padded directory modes are still read as trees
+	if EntryFileMode("040000").ObjectType() != Tree {
+		t.Errorf("Expected '040000' to be a tree mode")
+	}
+}
+
+// Fuzz parsing trees
+func FuzzTree(f *testing.F) {
+	tree := &GitTree{}
+	tree.AddEntry(Normal, "file", MustParseObjectID("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"))
+	tree.AddEntry(Directory, "dir", MustParseObjectID("4b825dc642cb6eb9a060e54bf8d69288fbee4904"))
+	f.Add(tree.Serialize())
+	f.Add([]byte("100644 file\x00abcd"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDeserialize(t, func() GitObject { return &GitTree{} }, data)
+	})
+}
@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Abbreviating hashes to the shortest prefix that still names a single object, like git does
+// in 'log --oneline' and 'ls-tree --abbrev'
+
+const (
+	// MinAbbrev The shortest abbreviation that is used or accepted when abbreviating hashes
+	MinAbbrev = 4
+	// DefaultAbbrev The abbreviation length used when core.abbrev isn't set, unless the repo has
+	// enough objects to need more
+	DefaultAbbrev = 7
+)
+
+// AmbiguousCandidate An object whose hash starts with an ambiguous prefix
+type AmbiguousCandidate struct {
+	Hash objects.ObjectID
+	// Abbrev The object's hash abbreviated like other output (see Repo.AbbrevLength)
+	Abbrev string
+	// Type The type of the object, or empty if it couldn't be read
+	Type objects.GitObjectType
+}
+
+// ErrAmbiguousObject Indicates that a prefix matches more than one object
+type ErrAmbiguousObject struct {
+	prefix     string
+	candidates []AmbiguousCandidate
+}
+
+func (e *ErrAmbiguousObject) Error() string {
+	var builder strings.Builder
+	builder.WriteString("short object ID " + e.prefix + " is ambiguous\nhint: The candidates are:")
+	for _, candidate := range e.candidates {
+		objType := string(candidate.Type)
+		if objType == "" {
+			objType = "[bad object]"
+		}
+		builder.WriteString("\nhint:   " + candidate.Abbrev + " " + objType)
+	}
+	return builder.String()
+}
+
+// Candidates The objects the prefix matches, sorted by hash
+func (e *ErrAmbiguousObject) Candidates() []AmbiguousCandidate {
+	return e.candidates
+}
+
+// ambiguousObject Build the error for a prefix that matches every object in matches
+func (repo *Repo) ambiguousObject(prefix string, matches []objects.ObjectID) error {
+	candidates := make([]AmbiguousCandidate, 0, len(matches))
+	length := repo.AbbrevLength()
+	for _, hash := range matches {
+		abbrev, err := repo.ShortestAbbrev(hash, length)
+		if err != nil {
+			return err
+		}
+		candidate := AmbiguousCandidate{Hash: hash, Abbrev: abbrev}
+		if reader, err := repo.OpenObject(hash); err == nil {
+			candidate.Type = reader.Type
+			reader.Close()
+		}
+		candidates = append(candidates, candidate)
+	}
+	return &ErrAmbiguousObject{prefix: prefix, candidates: candidates}
+}
+
+// ShortestAbbrev Return the shortest prefix of hash, at least minLength characters long, that
+// no other object in the repo starts with. The object itself doesn't have to be in the repo.
+// Lengths below MinAbbrev are raised to it
+func (repo *Repo) ShortestAbbrev(hash objects.ObjectID, minLength int) (string, error) {
+	full := hash.String()
+	if minLength < MinAbbrev {
+		minLength = MinAbbrev
+	}
+	if minLength >= len(full) {
+		return full, nil
+	}
+	matches, err := repo.objectStore().FindPrefix(full[:minLength])
+	if err != nil {
+		return "", err
+	}
+	length := minLength
+	for _, other := range matches {
+		if other == hash {
+			continue
+		}
+		// One more character than the two hashes share tells them apart
+		if shared := commonPrefixLength(full, other.String()); shared+1 > length {
+			length = shared + 1
+		}
+	}
+	return hash.Abbrev(length), nil
+}
+
+// commonPrefixLength The number of characters a and b start with in common
+func commonPrefixLength(a string, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// AbbrevLength The minimum length of abbreviated hashes, set by core.abbrev. The value may be
+// a number of characters (at least MinAbbrev), "no" to not abbreviate or "auto". When it is
+// "auto", unset or invalid, the length grows with the number of objects in the repo
+func (repo *Repo) AbbrevLength() int {
+	fullLength := repo.HashAlgorithm().HexSize()
+	if ini, err := repo.Config(); err == nil {
+		value, ok := configValue(ini, "core", "abbrev")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if length, err := strconv.Atoi(value); ok && err == nil {
+			if length < MinAbbrev {
+				return MinAbbrev
+			} else if length > fullLength {
+				return fullLength
+			}
+			return length
+		}
+		if enabled, valid := parseConfigBool(value); ok && valid && !enabled {
+			return fullLength
+		}
+	}
+	return repo.autoAbbrevLength()
+}
+
+// autoAbbrevLength The abbreviation length for the number of objects in the repo, computed like
+// git's: there are about as many prefixes of that length as the square of the object count, so
+// one hex character is needed for every 2 bits of the count. Never less than DefaultAbbrev.
+// Objects that are both loose and packed are counted twice, which is close enough
+func (repo *Repo) autoAbbrevLength() int {
+	count := 0
+	err := repo.objectStore().Iterate(func(objects.ObjectID) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return DefaultAbbrev
+	}
+	bits := 0
+	for ; count > 0; count >>= 1 {
+		bits++
+	}
+	if length := (bits + 1) / 2; length > DefaultAbbrev {
+		return length
+	}
+	return DefaultAbbrev
+}
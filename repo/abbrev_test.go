@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// A blob and a tree whose hashes share their first 5 characters
+const (
+	ambiguousBlob = "blob 50580\n"
+	ambiguousTree = "100644 file11\x00\xe6\x9d\xe2\x9b\xb2\xd1\xd6\x43\x4b\x8b\x29\xae\x77\x5a\xd8\xc2\xe4\x8c\x53\x91"
+)
+
+// Set core.abbrev in the config of a repo
+func setTestAbbrev(t *testing.T, repoStruct *Repo, value string) {
+	configPath := path.Join(repoStruct.GitDir, "config")
+	configData, err := repoStruct.FS().ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Error reading config: %s", err)
+	}
+	configData = append(configData, "\n[core]\nabbrev = "+value+"\n"...)
+	err = repoStruct.FS().WriteFile(configPath, configData, NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing config: %s", err)
+	}
+}
+
+// Test that abbreviations are only as long as needed to tell objects apart
+func TestShortestAbbrev(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	blob := saveRawObject(t, repoStruct, objects.Blob, ambiguousBlob)
+	tree := saveRawObject(t, repoStruct, objects.Tree, ambiguousTree)
+	if blob.Abbrev(5) != tree.Abbrev(5) {
+		t.Fatalf("Expected %s and %s to share a prefix", blob, tree)
+	}
+	expected := map[int]string{0: "200144", 4: "200144", 6: "200144", 7: "2001440", 40: blob.String(), 100: blob.String()}
+	for length, abbrev := range expected {
+		found, err := repoStruct.ShortestAbbrev(blob, length)
+		if err != nil || found != abbrev {
+			t.Errorf("Expected %s for a minimum of %d, Got: %s, %v", abbrev, length, found, err)
+		}
+	}
+	// Objects that aren't in the repo are abbreviated too
+	missing := objects.MustParseObjectID("20014f0000000000000000000000000000000000")
+	if found, err := repoStruct.ShortestAbbrev(missing, 4); err != nil || found != "20014f0" {
+		t.Errorf("Expected 20014f0 for a missing object, Got: %s, %v", found, err)
+	}
+}
+
+// Test that prefixes of several objects are reported with every candidate
+func TestAmbiguousObject(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	blob := saveRawObject(t, repoStruct, objects.Blob, ambiguousBlob)
+	tree := saveRawObject(t, repoStruct, objects.Tree, ambiguousTree)
+	_, err = repoStruct.FindObject("20014")
+	ambiguous, ok := err.(*ErrAmbiguousObject)
+	if !ok {
+		t.Fatalf("Expected ErrAmbiguousObject, Got: %v", err)
+	}
+	candidates := ambiguous.Candidates()
+	if len(candidates) != 2 || candidates[0].Hash != blob || candidates[0].Type != objects.Blob ||
+		candidates[1].Hash != tree || candidates[1].Type != objects.Tree {
+		t.Errorf("Expected the blob and the tree as candidates, Got: %v", candidates)
+	}
+	expected := "short object ID 20014 is ambiguous\nhint: The candidates are:\n" +
+		"hint:   2001440 blob\nhint:   20014f8 tree"
+	if err.Error() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, err)
+	}
+	if found, err := repoStruct.FindObject("200144"); err != nil || found != blob {
+		t.Errorf("Expected a longer prefix to find %s, Got: %s, %v", blob, found, err)
+	}
+	if _, err := repoStruct.FindObject("20015"); err == nil || strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected a prefix without objects not to be found, Got: %v", err)
+	}
+}
+
+// Test reading core.abbrev
+func TestAbbrevLength(t *testing.T) {
+	values := map[string]int{"": DefaultAbbrev, "auto": DefaultAbbrev, "12": 12, "2": MinAbbrev,
+		"100": 40, "no": 40, "false": 40, "bad": DefaultAbbrev}
+	for value, expected := range values {
+		repoStruct, err := NewMemoryRepo()
+		if err != nil {
+			t.Fatalf("Error creating repo: %s", err)
+		}
+		if value != "" {
+			setTestAbbrev(t, repoStruct, value)
+		}
+		if length := repoStruct.AbbrevLength(); length != expected {
+			t.Errorf("Expected core.abbrev '%s' to give %d, Got: %d", value, expected, length)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Support for objects/info/alternates. Each line of the file is the path of another objects
+// directory whose objects can be read (but not written) by this repo. Relative paths are
+// relative to the objects directory holding the file, and alternates can have alternates
+// of their own
+
+// ErrNotObjectsDir Returned when an alternate isn't a directory
+type ErrNotObjectsDir struct {
+	dir string
+}
+
+func (e *ErrNotObjectsDir) Error() string {
+	return e.dir + " is not an objects directory"
+}
+
+// maxAlternateDepth How deep alternates of alternates are followed, the same limit git uses
+const maxAlternateDepth = 5
+
+// AlternateStore The objects of another objects directory (its loose objects and packs), which
+// can only be read
+type AlternateStore struct {
+	dir   string
+	store *CompositeStore
+}
+
+// OpenAlternate Open the objects directory dir, holding objects named with algo, for reading.
+// Its own alternates aren't included. Packs that can't be opened are skipped
+func OpenAlternate(fsys vfs.FS, dir string, algo *objects.HashAlgorithm) (*AlternateStore, error) {
+	info, err := fsys.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &ErrNotObjectsDir{dir: dir}
+	}
+	store := NewCompositeStore(NewLooseStore(fsys, dir, algo))
+	packs, skipped := openPacks(fsys, path.Join(dir, "pack"), algo)
+	for _, pack := range packs {
+		store.Add(pack)
+	}
+	for _, err := range skipped {
+		store.Skip(err)
+	}
+	return &AlternateStore{dir: dir, store: store}, nil
+}
+
+// Dir The objects directory of the alternate
+func (alternate *AlternateStore) Dir() string {
+	return alternate.dir
+}
+
+// Has Check if the alternate has the object
+func (alternate *AlternateStore) Has(hash objects.ObjectID) (bool, error) {
+	return alternate.store.Has(hash)
+}
+
+// Get Open an object in the alternate
+func (alternate *AlternateStore) Get(hash objects.ObjectID) (*objects.ObjectReader, error) {
+	return alternate.store.Get(hash)
+}
+
+// Put Always fails; objects are only ever written to the repo's own objects directory
+func (alternate *AlternateStore) Put(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error) {
+	return objects.ObjectID{}, &ErrReadOnlyStore{store: "alternate " + alternate.dir}
+}
+
+// Delete Always fails; the objects of an alternate belong to another repo
+func (alternate *AlternateStore) Delete(hash objects.ObjectID) error {
+	return &ErrReadOnlyStore{store: "alternate " + alternate.dir}
+}
+
+// Iterate Call fn for every object in the alternate
+func (alternate *AlternateStore) Iterate(fn func(hash objects.ObjectID) error) error {
+	return alternate.store.Iterate(fn)
+}
+
+// FindPrefix Return the objects in the alternate starting with prefix
+func (alternate *AlternateStore) FindPrefix(prefix string) ([]objects.ObjectID, error) {
+	return alternate.store.FindPrefix(prefix)
+}
+
+// Close Close the packs of the alternate
+func (alternate *AlternateStore) Close() error {
+	return alternate.store.Close()
+}
+
+// readAlternates Return the objects directories listed in objectsDir/info/alternates, with
+// relative paths resolved. Blank lines and comments are skipped; a missing file has no entries
+func readAlternates(fsys vfs.FS, objectsDir string) ([]string, error) {
+	data, err := fsys.ReadFile(path.Join(objectsDir, "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !isAbsPath(line) {
+			line = path.Join(objectsDir, line)
+		}
+		dirs = append(dirs, path.Clean(line))
+	}
+	return dirs, scanner.Err()
+}
+
+// isAbsPath Check if a path is absolute, either as a slash separated path or for the OS
+// (e.g. 'C:\objects' on Windows)
+func isAbsPath(p string) bool {
+	return path.IsAbs(p) || filepath.IsAbs(p)
+}
+
+// addAlternates Add the alternates of objectsDir to store, followed by their own alternates.
+// Directories that are already in seen, that don't exist or that are nested too deeply are
+// skipped like git does, so a broken alternate doesn't prevent the repo from being used.
+// An alternates file that can't be read is recorded as skipped in store
+func addAlternates(fsys vfs.FS, store *CompositeStore, objectsDir string, algo *objects.HashAlgorithm,
+	depth int, seen map[string]bool) {
+	if depth > maxAlternateDepth {
+		return
+	}
+	dirs, err := readAlternates(fsys, objectsDir)
+	if err != nil {
+		store.Skip(err)
+		return
+	}
+	for _, dir := range dirs {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		alternate, err := OpenAlternate(fsys, dir, algo)
+		if err != nil {
+			continue
+		}
+		store.Add(alternate)
+		addAlternates(fsys, store, dir, algo, depth+1, seen)
+	}
+}
+
+// Alternates Return the objects directories the repo reads objects from besides its own,
+// including alternates of alternates, in the order they are searched
+func (repo *Repo) Alternates() ([]string, error) {
+	composite, ok := repo.objectStore().(*CompositeStore)
+	if !ok {
+		return nil, nil
+	}
+	dirs := make([]string, 0)
+	for _, store := range composite.Stores() {
+		if alternate, ok := store.(*AlternateStore); ok {
+			dirs = append(dirs, alternate.Dir())
+		}
+	}
+	return dirs, nil
+}
+
+// AddAlternate Add an objects directory to objects/info/alternates so the repo can read the
+// objects in it. dir can also be a repository or its '.git' directory, in which case its objects
+// directory is used. Relative paths are relative to the repo's objects directory.
+// If the repo uses the default object store, it is reopened to include the new alternate
+func (repo *Repo) AddAlternate(dir string) error {
+	fsys := repo.FS()
+	objectsDir := path.Join(repo.GitDir, "objects")
+	resolved := dir
+	if !isAbsPath(resolved) {
+		resolved = path.Join(objectsDir, resolved)
+	}
+	suffix, err := FindObjectsDir(fsys, resolved)
+	if err != nil {
+		return err
+	}
+	line := path.Join(dir, suffix)
+
+	altPath := path.Join(objectsDir, "info", "alternates")
+	err = fsys.MkdirAll(path.Dir(altPath), DirFilemode)
+	if err != nil {
+		return err
+	}
+	existing, err := fsys.ReadFile(altPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		existing = append(existing, '\n')
+	}
+	err = fsys.WriteFile(altPath, append(existing, line+"\n"...), NormalFilemode)
+	if err != nil {
+		return err
+	}
+	// Reopen the default store so the new alternate is used; other stores are left alone
+	return repo.reopenObjects()
+}
+
+// FindObjectsDir Find the objects directory of dir, which can be an objects directory, a
+// repository or its '.git' directory. Returns the path of the objects directory relative to dir:
+// "", "objects" or ".git/objects"
+func FindObjectsDir(fsys vfs.FS, dir string) (string, error) {
+	suffix := ""
+	for _, candidate := range []string{".git/objects", "objects"} {
+		if info, err := fsys.Stat(path.Join(dir, candidate)); err == nil && info.IsDir() {
+			suffix = candidate
+			break
+		}
+	}
+	info, err := fsys.Stat(path.Join(dir, suffix))
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", &ErrNotObjectsDir{dir: dir}
+	}
+	return suffix, nil
+}
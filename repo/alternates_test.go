@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Create and open repos in the given directories of a shared in-memory filesystem
+func createMemoryRepos(t *testing.T, fsys vfs.FS, dirs ...string) []*Repo {
+	repos := make([]*Repo, 0, len(dirs))
+	for _, dir := range dirs {
+		err := fsys.MkdirAll(dir, DirFilemode)
+		if err != nil {
+			t.Fatalf("Error creating %s: %s", dir, err)
+		}
+		err = CreateRepoFS(fsys, dir, "", "")
+		if err != nil {
+			t.Fatalf("Error creating repo in %s: %s", dir, err)
+		}
+		repoStruct, err := OpenRepoFS(fsys, dir)
+		if err != nil {
+			t.Fatalf("Error opening repo in %s: %s", dir, err)
+		}
+		repos = append(repos, repoStruct)
+	}
+	return repos
+}
+
+// Test reading objects through absolute and relative alternates, including alternates of
+// alternates
+func TestAlternates(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	repos := createMemoryRepos(t, fsys, "/base", "/shared", "/repo")
+	base, shared, repoStruct := repos[0], repos[1], repos[2]
+	baseHash := saveRawObject(t, base, objects.Blob, "base")
+	sharedHash := saveRawObject(t, shared, objects.Blob, "shared")
+
+	// /shared reads from /base through a relative path, /repo reads from /shared
+	err := shared.AddAlternate("../../../base/.git/objects")
+	if err != nil {
+		t.Fatalf("Error adding alternate: %s", err)
+	}
+	err = repoStruct.AddAlternate("/shared")
+	if err != nil {
+		t.Fatalf("Error adding alternate: %s", err)
+	}
+	data, err := fsys.ReadFile("/repo/.git/objects/info/alternates")
+	if err != nil || string(data) != "/shared/.git/objects\n" {
+		t.Errorf("Expected the alternates file to have /shared/.git/objects, Got: '%s', %v", data, err)
+	}
+	alternates, err := repoStruct.Alternates()
+	if err != nil || len(alternates) != 2 || alternates[0] != "/shared/.git/objects" ||
+		alternates[1] != "/base/.git/objects" {
+		t.Errorf("Expected /shared and /base as alternates, Got: %v, %v", alternates, err)
+	}
+
+	// A fresh repo struct reads the alternates from disk
+	reopened, err := OpenRepoFS(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	for _, hash := range []objects.ObjectID{baseHash, sharedHash} {
+		_, content, err := reopened.ReadRawObject(hash)
+		if err != nil {
+			t.Errorf("Expected to read %s through alternates, Got: %s", hash, err)
+		} else if string(content) != "base" && string(content) != "shared" {
+			t.Errorf("Unexpected content for %s: '%s'", hash, content)
+		}
+	}
+	found, err := reopened.FindObject(baseHash.Abbrev(8))
+	if err != nil || found != baseHash {
+		t.Errorf("Expected prefix lookups to search alternates, Got: %s, %v", found, err)
+	}
+
+	// New objects are only written to the repo itself
+	ownHash := saveRawObject(t, reopened, objects.Blob, "own")
+	if has, _ := shared.HasObject(ownHash); has {
+		t.Errorf("Expected new objects not to be written to an alternate")
+	}
+	err = reopened.DeleteObject(sharedHash)
+	if _, ok := err.(*ErrReadOnlyStore); !ok {
+		t.Errorf("Expected ErrReadOnlyStore deleting an object in an alternate, Got: %v", err)
+	}
+}
+
+// Test that cycles and missing directories in alternates are skipped
+func TestAlternatesCycle(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	repos := createMemoryRepos(t, fsys, "/a", "/b")
+	hash := saveRawObject(t, repos[1], objects.Blob, "b")
+	err := fsys.MkdirAll("/a/.git/objects/info", DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating info dir: %s", err)
+	}
+	err = fsys.WriteFile("/a/.git/objects/info/alternates",
+		[]byte("# comment\n/missing/objects\n\n/b/.git/objects\n"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing alternates: %s", err)
+	}
+	err = fsys.MkdirAll("/b/.git/objects/info", DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating info dir: %s", err)
+	}
+	err = fsys.WriteFile("/b/.git/objects/info/alternates", []byte("/a/.git/objects\n"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing alternates: %s", err)
+	}
+	repoStruct, err := OpenRepoFS(fsys, "/a")
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	alternates, err := repoStruct.Alternates()
+	if err != nil || len(alternates) != 1 || alternates[0] != "/b/.git/objects" {
+		t.Errorf("Expected only /b as an alternate, Got: %v, %v", alternates, err)
+	}
+	if has, err := repoStruct.HasObject(hash); !has || err != nil {
+		t.Errorf("Expected to find the object in /b, Got: %v, %v", has, err)
+	}
+	err = repoStruct.AddAlternate("/missing")
+	if err == nil {
+		t.Errorf("Expected an error adding a missing alternate")
+	}
+}
+
+// Test that adding an alternate closes the packs of the object store it replaces
+func TestAddAlternateClosesStore(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	head, err := repoStruct.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("Error resolving HEAD: %s", err)
+	}
+	err = repoStruct.Gc(GcOptions{PruneExpire: time.Now()})
+	if err != nil {
+		t.Fatalf("Error running gc: %s", err)
+	}
+	old, ok := repoStruct.Objects.(*CompositeStore)
+	if !ok {
+		t.Fatalf("Expected the default object store, Got: %T", repoStruct.Objects)
+	}
+	err = repoStruct.FS().MkdirAll("/shared/objects", DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating objects dir: %s", err)
+	}
+	err = repoStruct.AddAlternate("/shared/objects")
+	if err != nil {
+		t.Fatalf("Error adding alternate: %s", err)
+	}
+	if _, err := old.Get(head); err == nil {
+		t.Errorf("Expected the packs of the old store to be closed")
+	}
+	if has, err := repoStruct.HasObject(head); !has || err != nil {
+		t.Errorf("Expected the reopened store to have HEAD, Got: %v, %v", has, err)
+	}
+}
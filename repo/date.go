@@ -0,0 +1,219 @@
+package repo
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Months by the first three letters of their names
+var monthNames = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// Units of relative dates (without a trailing 's'), as the number of days, months and years or
+// the duration they stand for
+var dateUnits = map[string]struct {
+	duration            time.Duration
+	days, months, years int
+}{
+	"second":    {duration: time.Second},
+	"sec":       {duration: time.Second},
+	"minute":    {duration: time.Minute},
+	"min":       {duration: time.Minute},
+	"hour":      {duration: time.Hour},
+	"day":       {days: 1},
+	"week":      {days: 7},
+	"fortnight": {days: 14},
+	"month":     {months: 1},
+	"year":      {years: 1},
+}
+
+// ParseDate Parse a date the way git's approxidate does for options like 'log --since'. A date
+// is made of words that each change the current time:
+//   - dates ("2006-01-02", "2006/01/02", "01/02/2006", "Jan 2 2006" or "2 Jan 2006"), which keep
+//     the current time of day like git
+//   - times of day ("15:04", "15:04:05", "3pm", "noon" or "midnight") and time zones ("+0200")
+//   - relative times ("yesterday", "last week", "2.weeks.ago" or "1 year 2 months ago")
+//   - "now" and "today", which change nothing
+//
+// RFC 3339 dates and Unix timestamps ("1700000000" or "@1700000000") are accepted on their own.
+// Unlike git, words that aren't understood are an error instead of being ignored
+func ParseDate(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	// git treats numbers this large as timestamps rather than days or years
+	if seconds, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64); err == nil &&
+		(seconds >= 100000000 || strings.HasPrefix(value, "@")) {
+		return time.Unix(seconds, 0), nil
+	}
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ' ' || r == '.' || r == ',' || r == '\t'
+	})
+	if len(words) == 0 {
+		return time.Time{}, &ErrBadDate{value: value}
+	}
+	now = now.In(time.Local)
+	year, month, day := now.Date()
+	hour, minute, second := now.Clock()
+	location := time.Local
+	// Relative times are applied once the date and time are known
+	var duration time.Duration
+	var days, months, years int
+	dateSet := false
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		next := ""
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+		if parsed, ok := parseDateWord(word); ok {
+			year, month, day = parsed.Date()
+			dateSet = true
+			continue
+		}
+		if parsed, ok := parseTimeWord(word); ok {
+			hour, minute, second = parsed.Clock()
+			continue
+		}
+		if len(word) == 5 && (word[0] == '+' || word[0] == '-') {
+			offset, err := strconv.Atoi(word[1:])
+			if err != nil {
+				return time.Time{}, &ErrBadDate{value: value}
+			}
+			seconds := (offset/100)*3600 + (offset%100)*60
+			if word[0] == '-' {
+				seconds = -seconds
+			}
+			location = time.FixedZone(word, seconds)
+			continue
+		}
+		if monthNum, ok := monthNames[prefix(word, 3)]; ok {
+			// The day and year may come before or after the month
+			month = monthNum
+			dateSet = true
+			if n, err := strconv.Atoi(next); err == nil && n >= 1 && n <= 31 {
+				day = n
+				i++
+			} else if i > 0 {
+				if n, err := strconv.Atoi(words[i-1]); err == nil && n >= 1 && n <= 31 {
+					day = n
+				}
+			}
+			if i+1 < len(words) {
+				if n, err := strconv.Atoi(words[i+1]); err == nil && n >= 1970 {
+					year = n
+					i++
+				}
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(word); err == nil {
+			unit, ok := dateUnits[strings.TrimSuffix(next, "s")]
+			if ok {
+				duration += time.Duration(n) * unit.duration
+				days += n * unit.days
+				months += n * unit.months
+				years += n * unit.years
+				i++
+				continue
+			}
+			// The day of a date like "2 Jan 2006" is read with the month
+			if _, ok := monthNames[prefix(next, 3)]; ok {
+				continue
+			}
+			return time.Time{}, &ErrBadDate{value: value}
+		}
+		switch word {
+		case "now", "today", "ago":
+		case "yesterday":
+			days++
+		case "noon", "midnight":
+			hour, minute, second = 0, 0, 0
+			if word == "noon" {
+				hour = 12
+				// Before noon, "noon" is the last one that has passed
+				if !dateSet && now.Hour() < 12 {
+					days++
+				}
+			}
+		case "last":
+			unit, ok := dateUnits[strings.TrimSuffix(next, "s")]
+			if !ok {
+				return time.Time{}, &ErrBadDate{value: value}
+			}
+			duration += unit.duration
+			days += unit.days
+			months += unit.months
+			years += unit.years
+			i++
+		default:
+			parsed, ok := parseHourWord(word)
+			if !ok {
+				return time.Time{}, &ErrBadDate{value: value}
+			}
+			hour, minute, second = parsed, 0, 0
+		}
+	}
+	parsed := time.Date(year, month, day, hour, minute, second, 0, location)
+	return parsed.AddDate(-years, -months, -days).Add(-duration), nil
+}
+
+// ParseExpiry Parse an expiry the way git does for gc and prune: "now" (or "all"), "never" or
+// any date ParseDate accepts. "never" returns the zero time, which nothing is older than
+func ParseExpiry(value string, now time.Time) (time.Time, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "now", "all":
+		return now, nil
+	case "never", "false":
+		return time.Time{}, nil
+	}
+	return ParseDate(value, now)
+}
+
+// parseDateWord Parse a single word that is a whole date
+func parseDateWord(word string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "01/02/2006"} {
+		if parsed, err := time.Parse(layout, word); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimeWord Parse a single word that is a time of day
+func parseTimeWord(word string) (time.Time, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if parsed, err := time.Parse(layout, word); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseHourWord Parse an hour on a 12-hour clock like "3pm" or "11am"
+func parseHourWord(word string) (int, bool) {
+	if !strings.HasSuffix(word, "am") && !strings.HasSuffix(word, "pm") {
+		return 0, false
+	}
+	hour, err := strconv.Atoi(word[:len(word)-2])
+	if err != nil || hour < 1 || hour > 12 {
+		return 0, false
+	}
+	if strings.HasSuffix(word, "pm") {
+		return hour%12 + 12, true
+	}
+	return hour % 12, true
+}
+
+// prefix The first n bytes of a string, or all of it if it is shorter
+func prefix(value string, n int) string {
+	if len(value) < n {
+		return value
+	}
+	return value[:n]
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"testing"
+	"time"
+)
+
+// Test parsing dates for log against what 'git rev-parse --since' gives for the same values
+func TestParseDate(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 18, 7, 0, time.Local)
+	local := func(year int, month time.Month, day, hour, minute, second int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, time.Local)
+	}
+	tests := map[string]time.Time{
+		"now":                    now,
+		"today":                  now,
+		"2023-11-14":             local(2023, 11, 14, 23, 18, 7),
+		"2023-11-14 22:20":       local(2023, 11, 14, 22, 20, 0),
+		"2023-11-14 22:20:05":    local(2023, 11, 14, 22, 20, 5),
+		"2023-11-14 22:20 +0200": time.Date(2023, 11, 14, 20, 20, 0, 0, time.UTC),
+		"2023-11-14T22:20:05Z":   time.Date(2023, 11, 14, 22, 20, 5, 0, time.UTC),
+		"2023/11/14":             local(2023, 11, 14, 23, 18, 7),
+		"11/14/2023":             local(2023, 11, 14, 23, 18, 7),
+		"Nov 14 2023":            local(2023, 11, 14, 23, 18, 7),
+		"14 Nov 2023":            local(2023, 11, 14, 23, 18, 7),
+		"1700000000":             time.Unix(1700000000, 0),
+		"@1700000000":            time.Unix(1700000000, 0),
+		"yesterday":              local(2026, 10, 17, 23, 18, 7),
+		"yesterday noon":         local(2026, 10, 17, 12, 0, 0),
+		"noon":                   local(2026, 10, 18, 12, 0, 0),
+		"midnight":               local(2026, 10, 18, 0, 0, 0),
+		"10:30":                  local(2026, 10, 18, 10, 30, 0),
+		"5pm":                    local(2026, 10, 18, 17, 0, 0),
+		"last week":              local(2026, 10, 11, 23, 18, 7),
+		"2.weeks.ago":            local(2026, 10, 4, 23, 18, 7),
+		"3 days ago":             local(2026, 10, 15, 23, 18, 7),
+		"2 hours ago":            local(2026, 10, 18, 21, 18, 7),
+		"1 year 2 months ago":    local(2025, 8, 18, 23, 18, 7),
+	}
+	for value, expected := range tests {
+		parsed, err := ParseDate(value, now)
+		if err != nil || !parsed.Equal(expected) {
+			t.Errorf("Expected %s to parse as %s, Got: %s, %v", value, expected, parsed, err)
+		}
+	}
+	// Before noon, "noon" is yesterday's
+	morning := local(2026, 10, 18, 10, 0, 0)
+	if parsed, err := ParseDate("noon", morning); err != nil || !parsed.Equal(local(2026, 10, 17, 12, 0, 0)) {
+		t.Errorf("Expected noon to be yesterday's in the morning, Got: %s, %v", parsed, err)
+	}
+	for _, value := range []string{"", "soon", "x.days.ago", "2.fortnights.later", "13pm", "last"} {
+		if _, err := ParseDate(value, now); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
+
+// Test parsing the expiry dates used by gc and prune
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2020, 3, 15, 12, 0, 0, 0, time.Local)
+	tests := map[string]time.Time{
+		"now":            now,
+		"all":            now,
+		"never":          {},
+		"2.weeks.ago":    now.AddDate(0, 0, -14),
+		"3 days ago":     now.AddDate(0, 0, -3),
+		"1.hour.ago":     now.Add(-time.Hour),
+		"@1500000000":    time.Unix(1500000000, 0),
+		"2019-01-02":     time.Date(2019, 1, 2, 12, 0, 0, 0, time.Local),
+		"1.month.ago":    now.AddDate(0, -1, 0),
+		"10.seconds.ago": now.Add(-10 * time.Second),
+		"yesterday":      now.AddDate(0, 0, -1),
+		"last week":      now.AddDate(0, 0, -7),
+		"Jan 2 2019":     time.Date(2019, 1, 2, 12, 0, 0, 0, time.Local),
+	}
+	for value, expected := range tests {
+		parsed, err := ParseExpiry(value, now)
+		if err != nil || !parsed.Equal(expected) {
+			t.Errorf("Expected %s to parse as %s, Got: %s, %v", value, expected, parsed, err)
+		}
+	}
+	for _, value := range []string{"soon", "2.fortnights.later", "x.days.ago"} {
+		if _, err := ParseExpiry(value, now); err == nil {
+			t.Errorf("Expected an error parsing %s", value)
+		}
+	}
+}
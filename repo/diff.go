@@ -0,0 +1,267 @@
+package repo
+
+import (
+	"bytes"
+	"path"
+	"sort"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Comparing trees to find the files a commit changed, like 'git diff-tree -r'
+
+// RenameThreshold How similar (in percent) a deleted and an added file must be for the
+// added file to be treated as a rename of the deleted one. Same as git's default
+const RenameThreshold = 50
+
+// TreeChange A file that differs between two trees. OldHash is the zero ObjectID for files
+// that were added and NewHash for files that were deleted. OldPath is the same as Path unless
+// the file was renamed or copied
+type TreeChange struct {
+	OldPath string
+	Path    string
+	OldMode objects.EntryFileMode
+	NewMode objects.EntryFileMode
+	OldHash objects.ObjectID
+	NewHash objects.ObjectID
+	// Copied Whether the file at OldPath was kept
+	Copied bool
+}
+
+// Status The letter 'git diff-tree' uses for the change: 'A' (added), 'D' (deleted),
+// 'R' (renamed), 'C' (copied) or 'M' (modified)
+func (change TreeChange) Status() byte {
+	switch {
+	case change.OldHash.IsZero():
+		return 'A'
+	case change.NewHash.IsZero():
+		return 'D'
+	case change.Copied:
+		return 'C'
+	case change.OldPath != change.Path:
+		return 'R'
+	}
+	return 'M'
+}
+
+// DiffTrees List the files that differ between two trees, sorted by path. The zero ObjectID
+// stands for an empty tree. If paths isn't empty, only files that are one of the paths or
+// inside one of them are compared; subtrees that can't contain them aren't read
+func (repo *Repo) DiffTrees(oldTree, newTree objects.ObjectID, paths []string) ([]TreeChange, error) {
+	changes := make([]TreeChange, 0)
+	err := repo.diffTrees(oldTree, newTree, "", paths, &changes)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// Helper for DiffTrees. Compares the entries of two trees whose names start with prefix and
+// recurses into the subtrees that differ
+func (repo *Repo) diffTrees(oldTree, newTree objects.ObjectID, prefix string, paths []string,
+	changes *[]TreeChange) error {
+	if oldTree == newTree {
+		return nil
+	}
+	oldEntries, err := repo.treeEntries(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := repo.treeEntries(newTree)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(oldEntries)+len(newEntries))
+	for name := range oldEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := oldEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		fullName := path.Join(prefix, name)
+		matches := len(paths) == 0 || specMatches(fullName, paths)
+		if !matches && !specLeadsTo(fullName, paths) {
+			continue
+		}
+		oldEntry, inOld := oldEntries[name]
+		newEntry, inNew := newEntries[name]
+		if inOld && inNew && oldEntry.Hash == newEntry.Hash && oldEntry.Mode == newEntry.Mode {
+			continue
+		}
+		// A subtree that became a file (or the other way around) is compared with an empty tree
+		var oldSubtree, newSubtree objects.ObjectID
+		if inOld && oldEntry.Type == objects.Tree {
+			oldSubtree = oldEntry.Hash
+		}
+		if inNew && newEntry.Type == objects.Tree {
+			newSubtree = newEntry.Hash
+		}
+		if !oldSubtree.IsZero() || !newSubtree.IsZero() {
+			err = repo.diffTrees(oldSubtree, newSubtree, fullName, paths, changes)
+			if err != nil {
+				return err
+			}
+		}
+		oldFile := inOld && oldEntry.Type != objects.Tree
+		newFile := inNew && newEntry.Type != objects.Tree
+		if !matches || (!oldFile && !newFile) {
+			continue
+		}
+		change := TreeChange{OldPath: fullName, Path: fullName}
+		if oldFile {
+			change.OldMode, change.OldHash = oldEntry.Mode, oldEntry.Hash
+		}
+		if newFile {
+			change.NewMode, change.NewHash = newEntry.Mode, newEntry.Hash
+		}
+		*changes = append(*changes, change)
+	}
+	return nil
+}
+
+// treeEntries The entries of a tree by name. The zero ObjectID has no entries
+func (repo *Repo) treeEntries(treeHash objects.ObjectID) (map[string]objects.TreeEntry, error) {
+	entries := make(map[string]objects.TreeEntry)
+	if treeHash.IsZero() {
+		return entries, nil
+	}
+	tree, err := repo.readTree(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range tree.Entries() {
+		entries[entry.Name] = entry
+	}
+	return entries, nil
+}
+
+// DetectRenames Pair files that were deleted with added files that have the same content or
+// are at least RenameThreshold percent similar, and replace each pair with a single rename.
+// If copiesFrom isn't the zero ObjectID, added files are also paired with the most similar
+// file of that tree (usually the old tree) as copies when they aren't renames, like git's
+// --find-copies-harder. Files with the same content are paired first. Submodules are never
+// paired
+func (repo *Repo) DetectRenames(changes []TreeChange, copiesFrom objects.ObjectID) ([]TreeChange, error) {
+	var sources []TreeChange
+	var added []int
+	// Only files are compared; submodules point to commits that are usually not in the repo
+	for i, change := range changes {
+		switch {
+		case change.Status() == 'D' && change.OldMode.ObjectType() == objects.Blob:
+			sources = append(sources, change)
+		case change.Status() == 'A' && change.NewMode.ObjectType() == objects.Blob:
+			added = append(added, i)
+		}
+	}
+	renames := len(sources)
+	if !copiesFrom.IsZero() {
+		files, err := repo.treeFiles(copiesFrom)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.mode.ObjectType() == objects.Blob {
+				sources = append(sources, TreeChange{OldPath: file.name, OldMode: file.mode, OldHash: file.hash})
+			}
+		}
+	}
+	// pairedWith The source each addition was paired with. Deleted files can only be renamed once
+	pairedWith := make(map[int]int)
+	renamed := make(map[string]bool)
+	usable := func(s int) bool {
+		return s >= renames || !renamed[sources[s].OldPath]
+	}
+	pair := func(a, s int) {
+		pairedWith[a] = s
+		if s < renames {
+			renamed[sources[s].OldPath] = true
+		}
+	}
+	for _, a := range added {
+		for s := range sources {
+			if usable(s) && sources[s].OldHash == changes[a].NewHash {
+				pair(a, s)
+				break
+			}
+		}
+	}
+	contents := make(map[objects.ObjectID][]byte)
+	for _, a := range added {
+		if _, ok := pairedWith[a]; ok {
+			continue
+		}
+		best, bestScore := -1, RenameThreshold-1
+		for s := range sources {
+			if !usable(s) {
+				continue
+			}
+			score, err := repo.similarity(sources[s].OldHash, changes[a].NewHash, contents)
+			if err != nil {
+				return nil, err
+			}
+			if score > bestScore {
+				best, bestScore = s, score
+			}
+		}
+		if best != -1 {
+			pair(a, best)
+		}
+	}
+	detected := make([]TreeChange, 0, len(changes))
+	for i, change := range changes {
+		if change.Status() == 'D' && renamed[change.OldPath] {
+			continue
+		}
+		if s, ok := pairedWith[i]; ok {
+			source := sources[s]
+			change.OldPath, change.OldMode, change.OldHash = source.OldPath, source.OldMode, source.OldHash
+			change.Copied = s >= renames
+		}
+		detected = append(detected, change)
+	}
+	return detected, nil
+}
+
+// similarity How similar (in percent) two blobs are: the size of the lines they share over the
+// size of the larger blob. Empty blobs aren't similar to anything. contents caches the blobs
+// that have been read
+func (repo *Repo) similarity(oldHash, newHash objects.ObjectID, contents map[objects.ObjectID][]byte) (int, error) {
+	var blobs [2][]byte
+	for i, hash := range []objects.ObjectID{oldHash, newHash} {
+		content, ok := contents[hash]
+		if !ok {
+			obj, err := repo.GetObject(hash)
+			if err != nil {
+				return 0, err
+			}
+			content = obj.Serialize()
+			contents[hash] = content
+		}
+		blobs[i] = content
+	}
+	size := len(blobs[0])
+	if len(blobs[1]) > size {
+		size = len(blobs[1])
+	}
+	if len(blobs[0]) == 0 || len(blobs[1]) == 0 {
+		return 0, nil
+	}
+	lines := make(map[string]int)
+	for _, line := range bytes.SplitAfter(blobs[0], []byte("\n")) {
+		lines[string(line)]++
+	}
+	shared := 0
+	for _, line := range bytes.SplitAfter(blobs[1], []byte("\n")) {
+		if lines[string(line)] > 0 {
+			lines[string(line)]--
+			shared += len(line)
+		}
+	}
+	return shared * 100 / size, nil
+}
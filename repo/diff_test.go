@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Save a tree holding files (by path) with the given contents and return its hash
+func saveTestTree(t *testing.T, repoStruct *Repo, files map[string]string) objects.ObjectID {
+	tm := emptyTreeMap(repoStruct.HashAlgorithm())
+	for name, content := range files {
+		tm.addEntryHelper(strings.Split(name, "/"), saveRawObject(t, repoStruct, objects.Blob, content), objects.Normal)
+	}
+	trees, err := tm.allTrees()
+	if err != nil {
+		t.Fatalf("Error creating trees: %s", err)
+	}
+	for _, tree := range trees {
+		err = repoStruct.SaveObject(tree)
+		if err != nil {
+			t.Fatalf("Error saving tree: %s", err)
+		}
+	}
+	return repoStruct.HashAlgorithm().Hash(trees[0])
+}
+
+// Describe changes as "<status> <old path> <path>" lines
+func describeChanges(changes []TreeChange) []string {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, string(change.Status())+" "+change.OldPath+" "+change.Path)
+	}
+	return lines
+}
+
+// Test comparing trees, with and without limiting the comparison to some paths
+func TestDiffTrees(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	oldTree := saveTestTree(t, repoStruct, map[string]string{"a": "1", "d/b": "2", "d/c": "3", "d/e/f": "4"})
+	newTree := saveTestTree(t, repoStruct, map[string]string{"a": "1", "d/b": "2x", "d/e/f": "4", "e": "5"})
+	tests := []struct {
+		old, new objects.ObjectID
+		paths    []string
+		expected []string
+	}{
+		{oldTree, newTree, nil, []string{"M d/b d/b", "D d/c d/c", "A e e"}},
+		{oldTree, newTree, []string{"d"}, []string{"M d/b d/b", "D d/c d/c"}},
+		{oldTree, newTree, []string{"d/e", "e"}, []string{"A e e"}},
+		{oldTree, newTree, []string{"a"}, []string{}},
+		{objects.ObjectID{}, oldTree, []string{"d/e/f"}, []string{"A d/e/f d/e/f"}},
+		{newTree, newTree, nil, []string{}},
+	}
+	for _, test := range tests {
+		changes, err := repoStruct.DiffTrees(test.old, test.new, test.paths)
+		if err != nil {
+			t.Fatalf("Error comparing trees: %s", err)
+		}
+		got := describeChanges(changes)
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("Paths %v: Expected %v, Got: %v", test.paths, test.expected, got)
+		}
+	}
+}
+
+// Test pairing deleted and added files with the same or similar content
+func TestDetectRenames(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	lines := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	oldTree := saveTestTree(t, repoStruct, map[string]string{
+		"exact":   lines,
+		"similar": strings.ToUpper(lines),
+		"kept":    "kept\n",
+	})
+	newTree := saveTestTree(t, repoStruct, map[string]string{
+		"moved":   lines,
+		"edited":  strings.ToUpper(lines) + "eleven\n",
+		"kept":    "kept\n",
+		"copy":    "kept\n",
+		"unknown": "unrelated\n",
+	})
+	changes, err := repoStruct.DiffTrees(oldTree, newTree, nil)
+	if err != nil {
+		t.Fatalf("Error comparing trees: %s", err)
+	}
+	renamed, err := repoStruct.DetectRenames(changes, objects.ObjectID{})
+	if err != nil {
+		t.Fatalf("Error detecting renames: %s", err)
+	}
+	expected := []string{"A copy copy", "R similar edited", "R exact moved", "A unknown unknown"}
+	if got := describeChanges(renamed); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %v, Got: %v", expected, got)
+	}
+	// Unchanged files can be copied
+	copied, err := repoStruct.DetectRenames(changes, oldTree)
+	if err != nil {
+		t.Fatalf("Error detecting copies: %s", err)
+	}
+	expected[0] = "C kept copy"
+	if got := describeChanges(copied); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %v, Got: %v", expected, got)
+	}
+}
+
+// Test that submodules, whose commits aren't in the repo, are left out of rename detection
+func TestDetectRenamesSkipsSubmodules(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	blob := saveRawObject(t, repoStruct, objects.Blob, "one\ntwo\nthree\n")
+	trees := make([]objects.ObjectID, 0, 2)
+	for _, lines := range [][]string{
+		{"100644 blob " + blob.String() + "\tfile", "160000 commit 1111111111111111111111111111111111111111\tsub"},
+		{"100644 blob " + blob.String() + "\tmoved", "160000 commit 2222222222222222222222222222222222222222\tmodule"},
+	} {
+		entries := make([]objects.TreeEntry, 0, len(lines))
+		for _, line := range lines {
+			entry, err := ParseTreeEntry(line)
+			if err != nil {
+				t.Fatalf("Error parsing entry: %s", err)
+			}
+			entries = append(entries, entry)
+		}
+		tree, err := repoStruct.MakeTree(entries, false)
+		if err != nil {
+			t.Fatalf("Error making tree: %s", err)
+		}
+		trees = append(trees, tree)
+	}
+	changes, err := repoStruct.DiffTrees(trees[0], trees[1], nil)
+	if err != nil {
+		t.Fatalf("Error comparing trees: %s", err)
+	}
+	for _, copiesFrom := range []objects.ObjectID{{}, trees[0]} {
+		renamed, err := repoStruct.DetectRenames(changes, copiesFrom)
+		if err != nil {
+			t.Fatalf("Error detecting renames: %s", err)
+		}
+		expected := []string{"A module module", "R file moved", "D sub sub"}
+		if got := describeChanges(renamed); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected %v, Got: %v", expected, got)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Checking the integrity of the objects and refs of a repo, like 'git fsck'
+
+// ErrFsck A problem with the content of an object
+type ErrFsck struct {
+	reason string
+}
+
+func (e *ErrFsck) Error() string {
+	return e.reason
+}
+
+// FsckKind The kind of problem found by Fsck
+type FsckKind string
+
+const (
+	// FsckError A corrupt object or a ref pointing somewhere it shouldn't
+	FsckError FsckKind = "error"
+	// FsckWarning Something git tolerates but doesn't write itself, like zero-padded modes
+	FsckWarning FsckKind = "warning"
+	// FsckMissing An object that is referenced but isn't in the database
+	FsckMissing FsckKind = "missing"
+	// FsckDangling An unreachable object that no other object references
+	FsckDangling FsckKind = "dangling"
+	// FsckUnreachable An object that can't be reached from any ref or the index
+	FsckUnreachable FsckKind = "unreachable"
+)
+
+// FsckIssue A single problem found by Fsck
+type FsckIssue struct {
+	Kind FsckKind
+	// Hash The object the issue is about. Zero for issues with refs
+	Hash objects.ObjectID
+	// Type The type of the object, if it is known
+	Type objects.GitObjectType
+	// Message A description of the problem, for errors and warnings
+	Message string
+}
+
+// String Format the issue the way 'git fsck' prints it
+func (issue FsckIssue) String() string {
+	switch issue.Kind {
+	case FsckError, FsckWarning:
+		if issue.Hash.IsZero() {
+			return string(issue.Kind) + ": " + issue.Message
+		}
+		if issue.Type == "" {
+			return fmt.Sprintf("%s in %s: %s", issue.Kind, issue.Hash, issue.Message)
+		}
+		return fmt.Sprintf("%s in %s %s: %s", issue.Kind, issue.Type, issue.Hash, issue.Message)
+	}
+	return fmt.Sprintf("%s %s %s", issue.Kind, issue.Type, issue.Hash)
+}
+
+// FsckOptions Options for Fsck
+type FsckOptions struct {
+	// Unreachable Report every unreachable object instead of only the dangling ones
+	Unreachable bool
+	// NoDangling Don't report dangling objects
+	NoDangling bool
+}
+
+// objectLink A reference from one object to another along with the type it should have
+type objectLink struct {
+	hash    objects.ObjectID
+	objType objects.GitObjectType
+}
+
+// fsckState The objects read while checking a repo
+type fsckState struct {
+	issues []FsckIssue
+	// types The type of every object that could be read
+	types map[objects.ObjectID]objects.GitObjectType
+	// links The objects referenced by every object that could be parsed
+	links map[objects.ObjectID][]objectLink
+}
+
+func (state *fsckState) report(kind FsckKind, hash objects.ObjectID, objType objects.GitObjectType, msg string) {
+	state.issues = append(state.issues, FsckIssue{Kind: kind, Hash: hash, Type: objType, Message: msg})
+}
+
+// Fsck Check the integrity of the repo. Every object is read to verify that its content
+// matches its hash and header and that commits, trees and tags are well formed. Refs must point
+// to existing objects (and branches to commits). Objects that are referenced but missing, and
+// objects that can't be reached from the refs, reflogs or the index, are reported, as are packs
+// that couldn't be opened.
+// The returned error is only set when the check itself couldn't be completed
+func (repo *Repo) Fsck(opts FsckOptions) ([]FsckIssue, error) {
+	state := &fsckState{
+		issues: make([]FsckIssue, 0),
+		types:  make(map[objects.ObjectID]objects.GitObjectType),
+		links:  make(map[objects.ObjectID][]objectLink),
+	}
+	// Packs and alternates that couldn't be opened were left out of the store
+	if composite, ok := repo.objectStore().(*CompositeStore); ok {
+		for _, err := range composite.Skipped() {
+			state.report(FsckError, objects.ObjectID{}, "", err.Error())
+		}
+	}
+	hashes := make([]objects.ObjectID, 0)
+	err := repo.objectStore().Iterate(func(hash objects.ObjectID) error {
+		hashes = append(hashes, hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range hashes {
+		repo.fsckObject(state, hash)
+	}
+
+	roots, err := repo.fsckRoots(state)
+	if err != nil {
+		return nil, err
+	}
+	reachable := state.walk(roots)
+
+	// Anything referenced by an object is reachable from it, so an unreachable object is
+	// dangling if no other object references it
+	referenced := make(map[objects.ObjectID]bool)
+	for _, links := range state.links {
+		for _, link := range links {
+			referenced[link.hash] = true
+		}
+	}
+	for _, hash := range hashes {
+		objType, ok := state.types[hash]
+		if !ok || reachable[hash] {
+			continue
+		}
+		if opts.Unreachable {
+			state.report(FsckUnreachable, hash, objType, "")
+		} else if !opts.NoDangling && !referenced[hash] {
+			state.report(FsckDangling, hash, objType, "")
+		}
+	}
+	return state.issues, nil
+}
+
+// fsckObject Read an object, verify its hash and header and parse the objects it links to
+func (repo *Repo) fsckObject(state *fsckState, hash objects.ObjectID) {
+	reader, err := repo.objectStore().Get(hash)
+	if err != nil {
+		state.report(FsckError, hash, "", err.Error())
+		return
+	}
+	defer reader.Close()
+	var actual objects.ObjectID
+	var content []byte
+	// Blobs don't link to anything, so they are hashed without being held in memory
+	if reader.Type == objects.Blob {
+		actual, err = repo.HashAlgorithm().HashReader(reader.Type, reader.Size, reader)
+	} else {
+		content, err = io.ReadAll(reader)
+		if err == nil {
+			actual, err = repo.HashAlgorithm().HashReader(reader.Type, int64(len(content)), bytes.NewReader(content))
+		}
+	}
+	if err != nil {
+		state.report(FsckError, hash, reader.Type, err.Error())
+		return
+	}
+	if actual != hash {
+		state.report(FsckError, hash, reader.Type, "hash mismatch, content hashes to "+actual.String())
+		return
+	}
+	state.types[hash] = reader.Type
+	links, warnings, err := parseObjectLinks(repo.HashAlgorithm(), reader.Type, content)
+	for _, warning := range warnings {
+		state.report(FsckWarning, hash, reader.Type, warning)
+	}
+	if err != nil {
+		state.report(FsckError, hash, reader.Type, err.Error())
+		return
+	}
+	state.links[hash] = links
+}
+
+// fsckRoots Check the refs of the repo and return the objects they point to along with the
+// objects in the reflogs and the index (including its cached trees), which are where
+// reachability starts
+func (repo *Repo) fsckRoots(state *fsckState) ([]objectLink, error) {
+	roots := make([]objectLink, 0)
+	refs, err := repo.GetAllRefs()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// A detached HEAD holds a hash; otherwise it points to a branch that is already checked
+	headData, err := repo.FS().ReadFile(path.Join(repo.GitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	head := strings.TrimSpace(string(headData))
+	if !isRef(head) {
+		refs["HEAD"] = head
+		names = append(names, "HEAD")
+	}
+	for _, name := range names {
+		value := strings.TrimSpace(refs[name])
+		hash, err := repo.HashAlgorithm().ParseID(value)
+		objType, ok := state.types[hash]
+		if err != nil || !ok {
+			state.report(FsckError, objects.ObjectID{}, "", name+": invalid sha1 pointer "+value)
+			continue
+		}
+		if (name == "HEAD" || strings.HasPrefix(name, "refs/heads/")) && objType != objects.Commit {
+			state.report(FsckError, objects.ObjectID{}, "", name+": not a commit")
+		}
+		roots = append(roots, objectLink{hash: hash, objType: objType})
+	}
+
+	// Old values of refs are kept alive by the reflogs
+	reflog, err := repo.reflogHashes()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range reflog {
+		if objType, ok := state.types[hash]; ok {
+			roots = append(roots, objectLink{hash: hash, objType: objType})
+		}
+	}
+
+	// Don't create an index just to check it
+	if _, err := repo.FS().Stat(path.Join(repo.GitDir, "index")); err != nil {
+		return roots, nil
+	}
+	idx, err := repo.Index()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range idx.Entries {
+		mode := objects.FileModeFromBits(entry.Metadata.FileMode)
+		// Submodule commits live in another repository
+		if mode == objects.GitLink {
+			continue
+		}
+		roots = append(roots, objectLink{hash: entry.Hash(), objType: mode.ObjectType()})
+	}
+	// The trees git cached in the index must exist too
+	trees, err := idx.CacheTree()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range trees {
+		if _, ok := state.types[hash]; !ok {
+			state.report(FsckError, objects.ObjectID{}, "", hash.String()+": invalid sha1 pointer in cache-tree")
+			continue
+		}
+		roots = append(roots, objectLink{hash: hash, objType: objects.Tree})
+	}
+	return roots, nil
+}
+
+// walk Mark every object reachable from roots, reporting objects that are missing or don't
+// have the type they are referenced as
+func (state *fsckState) walk(roots []objectLink) map[objects.ObjectID]bool {
+	reachable := make(map[objects.ObjectID]bool)
+	missing := make(map[objects.ObjectID]objects.GitObjectType)
+	queue := roots
+	for len(queue) > 0 {
+		link := queue[0]
+		queue = queue[1:]
+		if reachable[link.hash] {
+			continue
+		}
+		objType, ok := state.types[link.hash]
+		if !ok {
+			missing[link.hash] = link.objType
+			continue
+		}
+		reachable[link.hash] = true
+		if objType != link.objType {
+			state.report(FsckError, link.hash, objType, "object is a "+string(objType)+", not a "+string(link.objType))
+		}
+		queue = append(queue, state.links[link.hash]...)
+	}
+	for _, hash := range sortedTypes(missing) {
+		state.report(FsckMissing, hash, missing[hash], "")
+	}
+	return reachable
+}
+
+// sortedTypes Return the keys of a map of hashes to types, sorted
+func sortedTypes(types map[objects.ObjectID]objects.GitObjectType) []objects.ObjectID {
+	hashes := make([]objects.ObjectID, 0, len(types))
+	for hash := range types {
+		hashes = append(hashes, hash)
+	}
+	sortIDs(hashes)
+	return hashes
+}
+
+// parseObjectLinks Check that the content of an object is well formed for its type and return
+// the objects it references, whose hashes are those of algo. Unlike deserializing the object,
+// this never panics on bad content. Problems git tolerates are returned as warnings
+func parseObjectLinks(algo *objects.HashAlgorithm, objType objects.GitObjectType,
+	content []byte) ([]objectLink, []string, error) {
+	switch objType {
+	case objects.Commit:
+		links, err := fsckCommit(algo, content)
+		return links, nil, err
+	case objects.Tree:
+		return fsckTree(algo, content)
+	case objects.Tag:
+		return fsckTag(algo, content)
+	}
+	return nil, nil, nil
+}
+
+// nextHeader Split the next "key value\n" line off content. Returns false if content doesn't
+// start with key
+func nextHeader(content []byte, key string) (string, []byte, bool) {
+	if !bytes.HasPrefix(content, []byte(key+" ")) {
+		return "", content, false
+	}
+	end := bytes.IndexByte(content, '\n')
+	if end < 0 {
+		return "", content, false
+	}
+	return string(content[len(key)+1 : end]), content[end+1:], true
+}
+
+// Check a commit: a tree, any number of parents, an author and a committer
+func fsckCommit(algo *objects.HashAlgorithm, content []byte) ([]objectLink, error) {
+	treeLine, rest, ok := nextHeader(content, "tree")
+	if !ok {
+		return nil, &ErrFsck{reason: "invalid format - expected 'tree' line"}
+	}
+	tree, err := algo.ParseID(treeLine)
+	if err != nil {
+		return nil, &ErrFsck{reason: "invalid 'tree' line format - bad sha1"}
+	}
+	links := []objectLink{{hash: tree, objType: objects.Tree}}
+	for {
+		parentLine, next, ok := nextHeader(rest, "parent")
+		if !ok {
+			break
+		}
+		parent, err := algo.ParseID(parentLine)
+		if err != nil {
+			return nil, &ErrFsck{reason: "invalid 'parent' line format - bad sha1"}
+		}
+		links = append(links, objectLink{hash: parent, objType: objects.Commit})
+		rest = next
+	}
+	for _, key := range []string{"author", "committer"} {
+		ident, next, ok := nextHeader(rest, key)
+		if !ok {
+			return nil, &ErrFsck{reason: "invalid format - expected '" + key + "' line"}
+		}
+		err := fsckIdent(ident)
+		if err != nil {
+			return nil, err
+		}
+		rest = next
+	}
+	return links, nil
+}
+
+// Check a tag: the object it points to, that object's type, the tag name and the tagger
+func fsckTag(algo *objects.HashAlgorithm, content []byte) ([]objectLink, []string, error) {
+	objectLine, rest, ok := nextHeader(content, "object")
+	if !ok {
+		return nil, nil, &ErrFsck{reason: "invalid format - expected 'object' line"}
+	}
+	object, err := algo.ParseID(objectLine)
+	if err != nil {
+		return nil, nil, &ErrFsck{reason: "invalid 'object' line format - bad sha1"}
+	}
+	objType, rest, ok := nextHeader(rest, "type")
+	if !ok {
+		return nil, nil, &ErrFsck{reason: "invalid format - expected 'type' line"}
+	}
+	switch objects.GitObjectType(objType) {
+	case objects.Blob, objects.Tree, objects.Commit, objects.Tag:
+	default:
+		return nil, nil, &ErrFsck{reason: "invalid 'type' value"}
+	}
+	name, rest, ok := nextHeader(rest, "tag")
+	if !ok || name == "" {
+		return nil, nil, &ErrFsck{reason: "invalid format - expected 'tag' line"}
+	}
+	links := []objectLink{{hash: object, objType: objects.GitObjectType(objType)}}
+	tagger, _, ok := nextHeader(rest, "tagger")
+	if !ok {
+		return links, []string{"invalid format - expected 'tagger' line"}, nil
+	}
+	return links, nil, fsckIdent(tagger)
+}
+
+// Check the entries of a tree: their modes, names and order
+func fsckTree(algo *objects.HashAlgorithm, content []byte) ([]objectLink, []string, error) {
+	links := make([]objectLink, 0)
+	warnings := make([]string, 0)
+	hashSize := algo.Size()
+	prevName := ""
+	prevKey := ""
+	for len(content) > 0 {
+		space := bytes.IndexByte(content, ' ')
+		nul := bytes.IndexByte(content, 0x00)
+		if space <= 0 || nul < space || len(content) < nul+1+hashSize {
+			return nil, nil, &ErrFsck{reason: "cannot be parsed as a tree: truncated entry"}
+		}
+		mode := objects.EntryFileMode(content[:space])
+		name := string(content[space+1 : nul])
+		hash, _ := algo.IDFromBytes(content[nul+1 : nul+1+hashSize])
+		content = content[nul+1+hashSize:]
+
+		if mode.Bits() == 0 || strings.Trim(string(mode), "01234567") != "" {
+			return nil, nil, &ErrFsck{reason: "contains bad file modes"}
+		}
+		if !mode.Valid() {
+			switch mode {
+			case "040000":
+				warnings = append(warnings, "contains zero-padded file modes")
+			case "100664":
+				warnings = append(warnings, "contains bad file modes")
+			default:
+				return nil, nil, &ErrFsck{reason: "contains bad file modes"}
+			}
+		}
+		switch {
+		case name == "":
+			return nil, nil, &ErrFsck{reason: "contains empty pathname"}
+		case strings.Contains(name, "/"):
+			return nil, nil, &ErrFsck{reason: "contains full pathnames"}
+		case name == "." || name == "..":
+			return nil, nil, &ErrFsck{reason: "contains '.' or '..'"}
+		case strings.EqualFold(name, ".git"):
+			return nil, nil, &ErrFsck{reason: "contains '.git'"}
+		}
+		// Directories are sorted as if their name ends with a '/'
+		key := name
+		if mode.ObjectType() == objects.Tree {
+			key += "/"
+		}
+		if name == prevName {
+			return nil, nil, &ErrFsck{reason: "contains duplicate file entries"}
+		}
+		if prevKey != "" && key < prevKey {
+			return nil, nil, &ErrFsck{reason: "not properly sorted"}
+		}
+		prevName, prevKey = name, key
+		// Submodule commits live in another repository
+		if mode.ObjectType() != objects.Commit {
+			links = append(links, objectLink{hash: hash, objType: mode.ObjectType()})
+		}
+	}
+	return links, warnings, nil
+}
+
+// fsckIdent Check an author, committer or tagger: "Name <email> timestamp +zone"
+func fsckIdent(ident string) error {
+	bad := func(reason string) error {
+		return &ErrFsck{reason: "invalid author/committer line - " + reason}
+	}
+	open := strings.IndexByte(ident, '<')
+	if open < 0 {
+		return bad("missing email")
+	}
+	if open > 0 && ident[open-1] != ' ' {
+		return bad("missing space before email")
+	}
+	end := strings.IndexByte(ident[open:], '>')
+	if end < 0 || strings.ContainsAny(ident[:open], "<>") {
+		return bad("bad email")
+	}
+	rest := ident[open+end+1:]
+	if !strings.HasPrefix(rest, " ") {
+		return bad("missing space before date")
+	}
+	fields := strings.Split(rest[1:], " ")
+	if len(fields) != 2 {
+		return bad("bad date")
+	}
+	date, zone := fields[0], fields[1]
+	if date == "" || strings.Trim(date, "0123456789") != "" || len(date) > 1 && date[0] == '0' {
+		return bad("bad date")
+	}
+	if len(zone) != 5 || (zone[0] != '+' && zone[0] != '-') || strings.Trim(zone[1:], "0123456789") != "" {
+		return bad("bad time zone")
+	}
+	return nil
+}
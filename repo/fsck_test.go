@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Save an object with the given type and raw content, skipping any validation
+func saveRawObject(t *testing.T, repoStruct *Repo, objType objects.GitObjectType, content string) objects.ObjectID {
+	hash, err := repoStruct.SaveStream(objType, int64(len(content)), strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Error saving %s: %s", objType, err)
+	}
+	return hash
+}
+
+// Run fsck and return the issues formatted as strings
+func fsckLines(t *testing.T, repoStruct *Repo, opts FsckOptions) []string {
+	issues, err := repoStruct.Fsck(opts)
+	if err != nil {
+		t.Fatalf("Error running fsck: %s", err)
+	}
+	lines := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	return lines
+}
+
+// Create a memory repo with one commit of a single file
+func fsckTestRepo(t *testing.T) *Repo {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	setTestIdentity(t, repoStruct)
+	writeRepoFile(t, repoStruct, "file", "content\n")
+	err = repoStruct.AddFile("file")
+	if err != nil {
+		t.Fatalf("Error adding file: %s", err)
+	}
+	err = repoStruct.Commit("first")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	return repoStruct
+}
+
+// Test that a healthy repo has no issues and that unreachable objects are reported
+func TestFsckReachability(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	if lines := fsckLines(t, repoStruct, FsckOptions{}); len(lines) != 0 {
+		t.Errorf("Expected no issues, Got: %v", lines)
+	}
+	blob := saveRawObject(t, repoStruct, objects.Blob, "dangling\n")
+	tree := saveRawObject(t, repoStruct, objects.Tree, "100644 dangling\x00"+string(blob.Bytes()))
+	lines := fsckLines(t, repoStruct, FsckOptions{})
+	if len(lines) != 1 || lines[0] != "dangling tree "+tree.String() {
+		t.Errorf("Expected only the tree to be dangling, Got: %v", lines)
+	}
+	lines = fsckLines(t, repoStruct, FsckOptions{Unreachable: true})
+	if len(lines) != 2 || lines[0] != "unreachable blob "+blob.String() || lines[1] != "unreachable tree "+tree.String() {
+		t.Errorf("Expected the blob and tree to be unreachable, Got: %v", lines)
+	}
+	if lines = fsckLines(t, repoStruct, FsckOptions{NoDangling: true}); len(lines) != 0 {
+		t.Errorf("Expected no issues with NoDangling, Got: %v", lines)
+	}
+
+	// Objects referenced by a ref but missing from the database
+	missingTree := "1111111111111111111111111111111111111111"
+	commit := saveRawObject(t, repoStruct, objects.Commit, "tree "+missingTree+"\n"+
+		"author A <a@b.c> 1 +0000\ncommitter A <a@b.c> 1 +0000\n\nmsg\n")
+	err := repoStruct.FS().WriteFile(path.Join(repoStruct.GitDir, "refs", "heads", "broken"),
+		[]byte(commit.String()+"\n"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing ref: %s", err)
+	}
+	lines = fsckLines(t, repoStruct, FsckOptions{NoDangling: true})
+	if len(lines) != 1 || lines[0] != "missing tree "+missingTree {
+		t.Errorf("Expected the tree to be missing, Got: %v", lines)
+	}
+}
+
+// Test that refs pointing to missing objects or branches not pointing to commits are errors
+func TestFsckRefs(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	blob := saveRawObject(t, repoStruct, objects.Blob, "blob\n")
+	headsDir := path.Join(repoStruct.GitDir, "refs", "heads")
+	repoStruct.FS().WriteFile(path.Join(headsDir, "blob"), []byte(blob.String()), NormalFilemode)
+	repoStruct.FS().WriteFile(path.Join(headsDir, "gone"), []byte("2222222222222222222222222222222222222222\n"), NormalFilemode)
+	lines := fsckLines(t, repoStruct, FsckOptions{})
+	if len(lines) != 2 || lines[0] != "error: refs/heads/blob: not a commit" ||
+		lines[1] != "error: refs/heads/gone: invalid sha1 pointer 2222222222222222222222222222222222222222" {
+		t.Errorf("Expected errors for both refs, Got: %v", lines)
+	}
+}
+
+// Test that trees cached in the index are reachable and must exist
+func TestFsckCacheTree(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	cached := saveTestTree(t, repoStruct, map[string]string{"staged": "staged\n"})
+	missing := objects.MustParseObjectID("3333333333333333333333333333333333333333")
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	data := append([]byte("\x001 1\n"), cached.Bytes()...)
+	data = append(append(data, "dir\x001 0\n"...), missing.Bytes()...)
+	idx.AddExtension([4]byte{'T', 'R', 'E', 'E'}, data)
+	err = repoStruct.WriteIndex(idx)
+	if err != nil {
+		t.Fatalf("Error writing index: %s", err)
+	}
+	lines := fsckLines(t, repoStruct, FsckOptions{})
+	if len(lines) != 1 || lines[0] != "error: "+missing.String()+": invalid sha1 pointer in cache-tree" {
+		t.Errorf("Expected only the missing cached tree to be reported, Got: %v", lines)
+	}
+}
+
+// Test that corrupt and badly formed objects are reported without panicking
+func TestFsckBadObjects(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	blob := saveRawObject(t, repoStruct, objects.Blob, "blob\n")
+	blobBytes := string(blob.Bytes())
+	badObjects := []struct {
+		objType objects.GitObjectType
+		content string
+		message string
+	}{
+		{objects.Commit, "tree abc\n", "invalid 'tree' line format - bad sha1"},
+		{objects.Commit, "tree " + blob.String() + "\nauthor A <a@b.c> 1 +0000\n", "invalid format - expected 'committer' line"},
+		{objects.Commit, "tree " + blob.String() + "\nauthor A a@b.c 1 +0000\ncommitter A <a@b.c> 1 +0000\n",
+			"invalid author/committer line - missing email"},
+		{objects.Commit, "tree " + blob.String() + "\nauthor A <a@b.c> x +0000\ncommitter A <a@b.c> 1 +0000\n",
+			"invalid author/committer line - bad date"},
+		{objects.Tag, "object " + blob.String() + "\ntype blobby\ntag v1\n", "invalid 'type' value"},
+		{objects.Tree, "100644 b\x00" + blobBytes + "100644 a\x00" + blobBytes, "not properly sorted"},
+		{objects.Tree, "100644 a\x00" + blobBytes + "100644 a\x00" + blobBytes, "contains duplicate file entries"},
+		{objects.Tree, "100644 a/b\x00" + blobBytes, "contains full pathnames"},
+		{objects.Tree, "100600 a\x00" + blobBytes, "contains bad file modes"},
+		{objects.Tree, "100644 a\x00" + blobBytes[:5], "cannot be parsed as a tree: truncated entry"},
+	}
+	for _, bad := range badObjects {
+		hash := saveRawObject(t, repoStruct, bad.objType, bad.content)
+		lines := fsckLines(t, repoStruct, FsckOptions{NoDangling: true})
+		expected := "error in " + string(bad.objType) + " " + hash.String() + ": " + bad.message
+		if len(lines) != 1 || lines[0] != expected {
+			t.Errorf("Expected '%s', Got: %v", expected, lines)
+		}
+		repoStruct.DeleteObject(hash)
+	}
+
+	// A tag without a tagger is only a warning
+	tag := saveRawObject(t, repoStruct, objects.Tag, "object "+blob.String()+"\ntype blob\ntag v1\n\nmsg\n")
+	lines := fsckLines(t, repoStruct, FsckOptions{NoDangling: true})
+	if len(lines) != 1 || lines[0] != "warning in tag "+tag.String()+": invalid format - expected 'tagger' line" {
+		t.Errorf("Expected a warning for the tag, Got: %v", lines)
+	}
+	repoStruct.DeleteObject(tag)
+
+	// An object whose content doesn't match its hash
+	fakeHash := "3333333333333333333333333333333333333333"
+	writeRepoFile(t, repoStruct, path.Join(".git", "objects", fakeHash[:2], fakeHash[2:]),
+		string(zlibCompress([]byte("blob 5\x00blob\n"))))
+	lines = fsckLines(t, repoStruct, FsckOptions{})
+	expected := "error in blob " + fakeHash + ": hash mismatch, content hashes to " + blob.String()
+	if len(lines) != 2 || lines[0] != expected || lines[1] != "dangling blob "+blob.String() {
+		t.Errorf("Expected a hash mismatch, Got: %v", lines)
+	}
+}
+
+// Test that objects with a bad header are reported with the reason the header was rejected
+func TestFsckBadHeaders(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	hash := "4444444444444444444444444444444444444444"
+	// Objects whose header can be parsed are reported with their type
+	badHeaders := map[string]string{
+		"treex 3\x00abc": "error in " + hash + ": Could not read object: unknown object type 'treex'",
+		"blob -3\x00abc": "error in " + hash + ": Could not read object: bad header \"blob -3\": size is not a number",
+		"blob 5\x00abc": "error in blob " + hash + ": Could not read object: object has 3 bytes but its " +
+			"header says 5",
+		"blob 2\x00abc": "error in blob " + hash + ": Could not read object: object is larger than the 2 " +
+			"bytes its header says",
+	}
+	for raw, expected := range badHeaders {
+		writeRepoFile(t, repoStruct, path.Join(".git", "objects", hash[:2], hash[2:]), string(zlibCompress([]byte(raw))))
+		lines := fsckLines(t, repoStruct, FsckOptions{})
+		if len(lines) != 1 || lines[0] != expected {
+			t.Errorf("Expected an error for %q, Got: %v", raw, lines)
+		}
+	}
+}
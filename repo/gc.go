@@ -0,0 +1,422 @@
+package repo
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Removing unreachable objects and packing the rest, like 'git prune' and 'git gc'
+
+// DefaultPruneExpire How old unreachable objects must be before gc removes them, unless
+// gc.pruneExpire says otherwise. The grace period keeps objects that are being written by
+// another process (and aren't referenced yet) from being removed
+const DefaultPruneExpire = "2.weeks.ago"
+
+// ErrBadDate Indicates that a date or expiry couldn't be parsed
+type ErrBadDate struct {
+	value string
+}
+
+func (e *ErrBadDate) Error() string {
+	return "Could not parse date: " + e.value
+}
+
+// ErrBadObjectContent Indicates that an object needed to find reachable objects is corrupt
+type ErrBadObjectContent struct {
+	hash   objects.ObjectID
+	reason string
+}
+
+func (e *ErrBadObjectContent) Error() string {
+	return "Bad object " + e.hash.String() + ": " + e.reason
+}
+
+// PruneOptions Options for Prune
+type PruneOptions struct {
+	// Expire Only unreachable objects whose files were modified before this are removed. The
+	// zero time removes nothing
+	Expire time.Time
+	// DryRun Return the objects that would be removed without removing them
+	DryRun bool
+}
+
+// GcOptions Options for Gc
+type GcOptions struct {
+	// PruneExpire Unreachable objects older than this are removed. The zero time keeps them
+	PruneExpire time.Time
+}
+
+// reflogHashes Return every object recorded in the reflogs (the files in .git/logs). Both the
+// old and new value of every entry are included
+func (repo *Repo) reflogHashes() ([]objects.ObjectID, error) {
+	logs, err := recursiveFindFiles(repo.FS(), repo.GitDir, "logs")
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	hashes := make([]objects.ObjectID, 0)
+	for _, log := range logs {
+		data, err := repo.FS().ReadFile(path.Join(repo.GitDir, log))
+		if err != nil {
+			return nil, err
+		}
+		// Each line is: <old hash> <new hash> <identity>\t<message>
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.SplitN(line, " ", 3)
+			if len(fields) < 3 {
+				continue
+			}
+			for _, field := range fields[:2] {
+				hash, err := repo.HashAlgorithm().ParseID(field)
+				if err == nil && !hash.IsNull() {
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// reachabilityRoots Return the objects that everything reachable starts from: the objects refs
+// (and a detached HEAD) point to, the objects in the reflogs and the blobs and cached trees in
+// the index
+func (repo *Repo) reachabilityRoots() ([]objects.ObjectID, error) {
+	roots := make([]objects.ObjectID, 0)
+	refs, err := repo.GetAllRefs()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range refs {
+		if hash, err := repo.HashAlgorithm().ParseID(value); err == nil {
+			roots = append(roots, hash)
+		}
+	}
+	headData, err := repo.FS().ReadFile(path.Join(repo.GitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	if head, err := repo.HashAlgorithm().ParseID(strings.TrimSpace(string(headData))); err == nil {
+		roots = append(roots, head)
+	}
+	reflog, err := repo.reflogHashes()
+	if err != nil {
+		return nil, err
+	}
+	roots = append(roots, reflog...)
+	if _, err := repo.FS().Stat(path.Join(repo.GitDir, "index")); err == nil {
+		idx, err := repo.Index()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range idx.Entries {
+			if objects.FileModeFromBits(entry.Metadata.FileMode) != objects.GitLink {
+				roots = append(roots, entry.Hash())
+			}
+		}
+		// git keeps the trees cached in the index too
+		trees, err := idx.CacheTree()
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, trees...)
+	}
+	return roots, nil
+}
+
+// ReachableObjects Return every object that can be reached from the refs, reflogs and index.
+// Objects that are missing (e.g. old reflog entries) are skipped
+func (repo *Repo) ReachableObjects() (map[objects.ObjectID]bool, error) {
+	queue, err := repo.reachabilityRoots()
+	if err != nil {
+		return nil, err
+	}
+	reachable := make(map[objects.ObjectID]bool)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if reachable[hash] {
+			continue
+		}
+		reader, err := repo.OpenObject(hash)
+		if _, ok := err.(*ErrObjectNotFound); ok {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		reachable[hash] = true
+		// Blobs don't link to anything so they don't need to be read
+		if reader.Type == objects.Blob {
+			reader.Close()
+			continue
+		}
+		objType, content := reader.Type, make([]byte, reader.Size)
+		_, err = io.ReadFull(reader, content)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		links, _, err := parseObjectLinks(repo.HashAlgorithm(), objType, content)
+		if err != nil {
+			return nil, &ErrBadObjectContent{hash: hash, reason: err.Error()}
+		}
+		for _, link := range links {
+			queue = append(queue, link.hash)
+		}
+	}
+	return reachable, nil
+}
+
+// localStores The stores holding the repo's own objects, leaving out alternates
+func (repo *Repo) localStores() []ObjectStore {
+	composite, ok := repo.objectStore().(*CompositeStore)
+	if !ok {
+		return []ObjectStore{repo.objectStore()}
+	}
+	stores := make([]ObjectStore, 0)
+	for _, store := range composite.Stores() {
+		if _, ok := store.(*AlternateStore); !ok {
+			stores = append(stores, store)
+		}
+	}
+	return stores
+}
+
+// Prune Remove the loose objects that can't be reached from the refs, reflogs or index and
+// whose files are older than opts.Expire, along with temporary files left behind by interrupted
+// writes. Packed objects are left alone. Returns the objects removed (or that would be removed),
+// sorted
+func (repo *Repo) Prune(opts PruneOptions) ([]objects.ObjectID, error) {
+	reachable, err := repo.ReachableObjects()
+	if err != nil {
+		return nil, err
+	}
+	return repo.prune(reachable, opts)
+}
+
+// prune Remove the unreachable loose objects older than opts.Expire
+func (repo *Repo) prune(reachable map[objects.ObjectID]bool, opts PruneOptions) ([]objects.ObjectID, error) {
+	pruned := make([]objects.ObjectID, 0)
+	for _, store := range repo.localStores() {
+		loose, ok := store.(*LooseStore)
+		if !ok {
+			continue
+		}
+		candidates := make([]objects.ObjectID, 0)
+		err := loose.Iterate(func(hash objects.ObjectID) error {
+			if !reachable[hash] {
+				candidates = append(candidates, hash)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range candidates {
+			modTime, err := loose.ModTime(hash)
+			if err != nil {
+				return nil, err
+			}
+			if !modTime.Before(opts.Expire) {
+				continue
+			}
+			pruned = append(pruned, hash)
+			if opts.DryRun {
+				continue
+			}
+			err = loose.Delete(hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opts.DryRun {
+			continue
+		}
+		// Temporary files from writes that never finished
+		for _, dir := range []string{loose.Dir(), path.Join(loose.Dir(), "pack")} {
+			err = repo.removeStaleTempFiles(dir, opts.Expire)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sortIDs(pruned)
+	return pruned, nil
+}
+
+// removeStaleTempFiles Remove the temporary files ('tmp_' prefix) in dir older than expire
+func (repo *Repo) removeStaleTempFiles(dir string, expire time.Time) error {
+	entries, err := repo.FS().ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "tmp_") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(expire) {
+			err = repo.FS().Remove(path.Join(dir, entry.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Gc Clean up the repo like 'git gc': refs are packed into packed-refs, every reachable object
+// is written into a single new pack which replaces the old packs and the loose copies of the
+// packed objects are removed. Unreachable objects from old packs are kept as loose objects
+// unless the pack is older than opts.PruneExpire, and finally unreachable loose objects older
+// than opts.PruneExpire are pruned
+func (repo *Repo) Gc(opts GcOptions) error {
+	err := repo.PackRefs()
+	if err != nil {
+		return err
+	}
+	reachable, err := repo.ReachableObjects()
+	if err != nil {
+		return err
+	}
+	var loose *LooseStore
+	oldPacks := make([]*PackStore, 0)
+	toPack := make([]objects.ObjectID, 0)
+	for _, store := range repo.localStores() {
+		switch s := store.(type) {
+		case *LooseStore:
+			if loose == nil {
+				loose = s
+			}
+		case *PackStore:
+			oldPacks = append(oldPacks, s)
+		}
+		err = store.Iterate(func(hash objects.ObjectID) error {
+			if reachable[hash] {
+				toPack = append(toPack, hash)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if loose == nil {
+		return &ErrReadOnlyStore{store: "repository without loose objects"}
+	}
+	packed := make(map[objects.ObjectID]bool)
+	newPack := ""
+	if len(toPack) > 0 {
+		for _, hash := range toPack {
+			packed[hash] = true
+		}
+		newPack, err = WritePack(repo.FS(), path.Join(loose.Dir(), "pack"), repo.objectStore(), sortedIDs(packed), repo.HashAlgorithm())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Replace the old packs, keeping their recent unreachable objects as loose objects
+	for _, pack := range oldPacks {
+		if pack.Path() == newPack {
+			continue
+		}
+		info, err := repo.FS().Stat(pack.Path())
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(opts.PruneExpire) {
+			err = pack.Iterate(func(hash objects.ObjectID) error {
+				if packed[hash] {
+					return nil
+				}
+				return copyObject(pack, loose, hash)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = pack.Close()
+		if err != nil {
+			return err
+		}
+		err = repo.FS().Remove(strings.TrimSuffix(pack.Path(), ".pack") + ".idx")
+		if err != nil {
+			return err
+		}
+		err = repo.FS().Remove(pack.Path())
+		if err != nil {
+			return err
+		}
+	}
+	// Remove the loose copies of packed objects
+	for hash := range packed {
+		has, err := loose.Has(hash)
+		if err != nil {
+			return err
+		}
+		if has {
+			err = loose.Delete(hash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = repo.prune(reachable, PruneOptions{Expire: opts.PruneExpire})
+	if err != nil {
+		return err
+	}
+	return repo.reopenObjects()
+}
+
+// copyObject Copy an object from one store to another unless it is already there
+func copyObject(src ObjectStore, dst ObjectStore, hash objects.ObjectID) error {
+	has, err := dst.Has(hash)
+	if err != nil || has {
+		return err
+	}
+	reader, err := src.Get(hash)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = dst.Put(reader.Type, reader.Size, reader)
+	return err
+}
+
+// reopenObjects Open the default object store again after its packs have changed. Stores set
+// by the user are left alone
+func (repo *Repo) reopenObjects() error {
+	composite, ok := repo.Objects.(*CompositeStore)
+	if !ok && repo.Objects != nil {
+		return nil
+	}
+	if ok {
+		composite.Close()
+	}
+	repo.Objects = defaultObjectStore(repo.FS(), repo.GitDir, repo.HashAlgorithm())
+	return nil
+}
+
+// PruneExpiry The expiry used by gc: gc.pruneExpire from the config or DefaultPruneExpire
+func (repo *Repo) PruneExpiry(now time.Time) (time.Time, error) {
+	expire := DefaultPruneExpire
+	ini, err := repo.Config()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if value, ok := configValue(ini, "gc", "pruneExpire"); ok {
+		expire = value
+	}
+	return ParseExpiry(expire, now)
+}
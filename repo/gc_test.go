@@ -0,0 +1,216 @@
+package repo
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Test moving refs into packed-refs and reading, adding and deleting them afterwards
+func TestPackRefs(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	head, err := repoStruct.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("Error resolving HEAD: %s", err)
+	}
+	tag := &objects.GitTag{}
+	tag.SetObject(objects.Commit, head)
+	tag.SetTagName("v1")
+	tag.SetTagger("Simon Taye", "mulat.simon@gmail.com")
+	err = repoStruct.SaveObject(tag)
+	if err != nil {
+		t.Fatalf("Error saving tag: %s", err)
+	}
+	tagHash := objects.Hash(tag)
+	repoStruct.SaveTag("v1", tagHash)
+	repoStruct.SaveTag("light", head)
+	err = repoStruct.PackRefs()
+	if err != nil {
+		t.Fatalf("Error packing refs: %s", err)
+	}
+	data, err := repoStruct.FS().ReadFile(path.Join(repoStruct.GitDir, "packed-refs"))
+	expected := "# pack-refs with: peeled fully-peeled sorted \n" +
+		head.String() + " refs/heads/main\n" +
+		head.String() + " refs/tags/light\n" +
+		tagHash.String() + " refs/tags/v1\n" +
+		"^" + head.String() + "\n"
+	if err != nil || string(data) != expected {
+		t.Errorf("Expected packed-refs:\n%s\nGot:\n%s (%v)", expected, data, err)
+	}
+	loose, err := recursiveFindFiles(repoStruct.FS(), repoStruct.GitDir, "refs")
+	if err != nil || len(loose) != 0 {
+		t.Errorf("Expected no loose refs to be left, Got: %v, %v", loose, err)
+	}
+
+	// Packed refs can be resolved like loose ones
+	for _, name := range []string{"HEAD", "main", "light"} {
+		hash, err := repoStruct.FindObject(name)
+		if err != nil || hash != head {
+			t.Errorf("Expected %s to resolve to %s, Got: %s, %v", name, head, hash, err)
+		}
+	}
+	if _, ok := repoStruct.SaveTag("light", head).(*ErrTagAlreadyExists); !ok {
+		t.Errorf("Expected packed tags to already exist")
+	}
+	err = repoStruct.DeleteTag("light")
+	if err != nil {
+		t.Fatalf("Error deleting tag: %s", err)
+	}
+	refs, err := repoStruct.GetAllRefs()
+	if _, ok := refs["refs/tags/light"]; ok || err != nil || len(refs) != 2 {
+		t.Errorf("Expected the tag to be removed from packed-refs, Got: %v, %v", refs, err)
+	}
+
+	// A new commit writes a loose ref which overrides the packed one
+	writeRepoFile(t, repoStruct, "file", "changed\n")
+	repoStruct.AddFile("file")
+	err = repoStruct.Commit("second")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	newHead, err := repoStruct.ResolveCommit("main")
+	if err != nil || newHead == head {
+		t.Errorf("Expected main to move to the new commit, Got: %s, %v", newHead, err)
+	}
+}
+
+// Test that only old, unreachable loose objects are pruned
+func TestPrune(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	dangling := saveRawObject(t, repoStruct, objects.Blob, "dangling\n")
+	logged := saveRawObject(t, repoStruct, objects.Blob, "in reflog\n")
+	logsDir := path.Join(repoStruct.GitDir, "logs")
+	repoStruct.FS().MkdirAll(logsDir, DirFilemode)
+	repoStruct.FS().WriteFile(path.Join(logsDir, "HEAD"), []byte(strings.Repeat("0", 40)+" "+logged.String()+
+		" A <a@b.c> 1 +0000\tcommit: msg\n"), NormalFilemode)
+	// A tree cached in the index by git but not committed
+	cached := saveTestTree(t, repoStruct, map[string]string{"staged": "staged\n"})
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	idx.AddExtension([4]byte{'T', 'R', 'E', 'E'}, append([]byte("\x001 0\n"), cached.Bytes()...))
+	err = repoStruct.WriteIndex(idx)
+	if err != nil {
+		t.Fatalf("Error writing index: %s", err)
+	}
+
+	pruned, err := repoStruct.Prune(PruneOptions{Expire: time.Now().Add(-time.Hour)})
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("Expected recent objects to be kept, Got: %v, %v", pruned, err)
+	}
+	pruned, err = repoStruct.Prune(PruneOptions{Expire: time.Now().Add(time.Hour), DryRun: true})
+	if err != nil || len(pruned) != 1 || pruned[0] != dangling {
+		t.Errorf("Expected only %s to be pruned, Got: %v, %v", dangling, pruned, err)
+	}
+	if has, _ := repoStruct.HasObject(dangling); !has {
+		t.Errorf("Expected a dry run not to remove anything")
+	}
+	pruned, err = repoStruct.Prune(PruneOptions{Expire: time.Now().Add(time.Hour)})
+	if err != nil || len(pruned) != 1 {
+		t.Errorf("Expected one object to be pruned, Got: %v, %v", pruned, err)
+	}
+	if has, _ := repoStruct.HasObject(dangling); has {
+		t.Errorf("Expected %s to be removed", dangling)
+	}
+	if has, _ := repoStruct.HasObject(cached); !has {
+		t.Errorf("Expected the tree cached in the index to be kept")
+	}
+	if lines := fsckLines(t, repoStruct, FsckOptions{}); len(lines) != 0 {
+		t.Errorf("Expected no issues after pruning, Got: %v", lines)
+	}
+}
+
+// Test that gc packs every reachable object and removes the loose copies
+func TestGc(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	writeRepoFile(t, repoStruct, "dir/other", "other\n")
+	repoStruct.AddFile("dir/other")
+	err := repoStruct.Commit("second")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	dangling := saveRawObject(t, repoStruct, objects.Blob, "dangling\n")
+	reachable, err := repoStruct.ReachableObjects()
+	if err != nil || len(reachable) != 7 {
+		t.Fatalf("Expected 2 commits, 3 trees and 2 blobs to be reachable, Got: %v, %v", reachable, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = repoStruct.Gc(GcOptions{PruneExpire: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Error running gc: %s", err)
+		}
+		packDir := path.Join(repoStruct.GitDir, "objects", "pack")
+		entries, err := repoStruct.FS().ReadDir(packDir)
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("Expected a single pack and its index, Got: %v, %v", entries, err)
+		}
+		loose := NewLooseStore(repoStruct.FS(), path.Join(repoStruct.GitDir, "objects"), objects.SHA1)
+		looseObjects, _ := loose.FindPrefix("")
+		if len(looseObjects) != 0 {
+			t.Errorf("Expected no loose objects after gc, Got: %v", looseObjects)
+		}
+		pack, err := OpenPack(repoStruct.FS(), path.Join(packDir, strings.TrimSuffix(entries[0].Name(), ".idx")+".pack"), objects.SHA1)
+		if err != nil {
+			t.Fatalf("Error opening pack: %s", err)
+		}
+		packed, _ := pack.FindPrefix("")
+		pack.Close()
+		if len(packed) != len(reachable) {
+			t.Errorf("Expected the pack to hold %d objects, Got: %v", len(reachable), packed)
+		}
+		for hash := range reachable {
+			if _, _, err := repoStruct.ReadRawObject(hash); err != nil {
+				t.Errorf("Expected to read %s after gc, Got: %s", hash, err)
+			}
+		}
+		if has, _ := repoStruct.HasObject(dangling); has {
+			t.Errorf("Expected the dangling blob to be pruned")
+		}
+		if lines := fsckLines(t, repoStruct, FsckOptions{}); len(lines) != 0 {
+			t.Errorf("Expected no issues after gc, Got: %v", lines)
+		}
+	}
+}
+
+// Test deleting an annotated tag whose object gc has packed
+func TestDeleteTagAfterGc(t *testing.T) {
+	repoStruct := fsckTestRepo(t)
+	head, err := repoStruct.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("Error resolving HEAD: %s", err)
+	}
+	tag := &objects.GitTag{}
+	tag.SetObject(objects.Commit, head)
+	tag.SetTagName("v1")
+	tag.SetTagger("Simon Taye", "mulat.simon@gmail.com")
+	err = repoStruct.SaveObject(tag)
+	if err != nil {
+		t.Fatalf("Error saving tag: %s", err)
+	}
+	tagHash := objects.Hash(tag)
+	repoStruct.SaveTag("v1", tagHash)
+	err = repoStruct.Gc(GcOptions{PruneExpire: time.Now()})
+	if err != nil {
+		t.Fatalf("Error running gc: %s", err)
+	}
+	err = repoStruct.DeleteTag("v1")
+	if err != nil {
+		t.Fatalf("Error deleting packed tag: %s", err)
+	}
+	if _, err = repoStruct.FindRef("refs/tags/v1"); err == nil {
+		t.Errorf("Expected the tag to be deleted")
+	}
+	// The unreachable tag object is removed by the next gc
+	err = repoStruct.Gc(GcOptions{PruneExpire: time.Now()})
+	if err != nil {
+		t.Fatalf("Error running gc: %s", err)
+	}
+	if has, _ := repoStruct.HasObject(tagHash); has {
+		t.Errorf("Expected gc to remove the unreachable tag object")
+	}
+}
@@ -0,0 +1,474 @@
+package repo
+
+import (
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Drawing history as an ASCII graph next to a log, like 'git log --graph'. Each line of history
+// is a column: a '*' marks a commit, '|' a line that continues, '\' a branch of a merge and '/'
+// a line that joins another one (e.g. because both lines have the same parent)
+//
+//	*   Merge side
+//	|\
+//	| * Side commit
+//	* | Main commit
+//	|/
+//	* Base
+//
+// The rows are produced by the same state machine as git's graph.c so that the graphs are
+// drawn the same way: a commit may need rows that make room for it (octopus merges), then its
+// own row, then a row that branches out to the parents of a merge and rows that move lines left
+// until every line is in its column
+
+// graphState The kind of row the graph draws next
+type graphState int
+
+const (
+	// graphPadding Rows that keep every line as it is; the current commit is done
+	graphPadding graphState = iota
+	// graphPreCommit Rows that move the lines after an octopus merge right to make room for it
+	graphPreCommit
+	// graphCommit The commit's own row
+	graphCommit
+	// graphPostMerge The row that branches out to the parents of a merge
+	graphPostMerge
+	// graphCollapsing Rows that move lines left until they reach their column
+	graphCollapsing
+)
+
+// commitGraph The state of a graph between two rows
+type commitGraph struct {
+	commit  objects.ObjectID
+	parents []objects.ObjectID
+	// columns The commit each line of history waits for before the current commit, and
+	// newColumns the same after it
+	columns    []objects.ObjectID
+	newColumns []objects.ObjectID
+	// mapping For each character of the next row, the index in newColumns of the line drawn
+	// there or -1. oldMapping is the mapping of the last collapsing row. Both have room for
+	// every column, but only the first mappingSize entries of mapping are used
+	mapping     []int
+	oldMapping  []int
+	mappingSize int
+	// width The width of the rows of the current commit
+	width int
+	// expansionRow The number of rows drawn to make room for an octopus merge
+	expansionRow int
+	state        graphState
+	prevState    graphState
+	// commitIndex The column of the current commit and prevCommitIndex of the last one
+	commitIndex     int
+	prevCommitIndex int
+	// mergeLayout Whether the first parent of a merge is to the left of it (0) or below it (1)
+	mergeLayout int
+	// edgesAdded The number of lines added for the parents of the current and the last commit;
+	// -1 if the second parent joins the line after the commit directly
+	edgesAdded     int
+	prevEdgesAdded int
+	// shownCommit Whether the last row drawn was the commit's own row
+	shownCommit bool
+}
+
+// indexOf The first column of a list that waits for a commit, or -1
+func indexOf(columns []objects.ObjectID, hash objects.ObjectID) int {
+	for i, waiting := range columns {
+		if waiting == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// setState Move to the next kind of row
+func (graph *commitGraph) setState(state graphState) {
+	graph.prevState = graph.state
+	graph.state = state
+}
+
+// add Add a commit to the graph. Its rows are drawn by nextRow
+func (graph *commitGraph) add(hash objects.ObjectID, parents []objects.ObjectID) {
+	graph.commit = hash
+	graph.parents = parents
+	graph.prevCommitIndex = graph.commitIndex
+	graph.updateColumns()
+	graph.expansionRow = 0
+	if graph.needsPreCommitRow() {
+		graph.state = graphPreCommit
+	} else {
+		graph.state = graphCommit
+	}
+}
+
+// updateColumns Work out the lines after the current commit and how each line of the commit's
+// rows leads to them
+func (graph *commitGraph) updateColumns() {
+	graph.columns = graph.newColumns
+	graph.newColumns = make([]objects.ObjectID, 0, len(graph.columns)+len(graph.parents))
+	size := 2 * (len(graph.columns) + len(graph.parents))
+	for len(graph.mapping) < size {
+		graph.mapping = append(graph.mapping, -1)
+		graph.oldMapping = append(graph.oldMapping, -1)
+	}
+	graph.mappingSize = size
+	for i := 0; i < size; i++ {
+		graph.mapping[i] = -1
+	}
+	graph.width = 0
+	graph.prevEdgesAdded = graph.edgesAdded
+	graph.edgesAdded = 0
+	// The commit gets a column after the others if no line waits for it
+	seenCommit := false
+	for i := 0; i <= len(graph.columns); i++ {
+		var hash objects.ObjectID
+		if i == len(graph.columns) {
+			if seenCommit {
+				break
+			}
+			hash = graph.commit
+		} else {
+			hash = graph.columns[i]
+		}
+		if hash != graph.commit {
+			graph.insertColumn(hash, -1)
+			continue
+		}
+		seenCommit = true
+		graph.commitIndex = i
+		graph.mergeLayout = -1
+		for _, parent := range graph.parents {
+			graph.insertColumn(parent, i)
+		}
+		// The commit takes up a column even if its line ends
+		if len(graph.parents) == 0 {
+			graph.width += 2
+		}
+	}
+	for graph.mappingSize > 1 && graph.mapping[graph.mappingSize-1] < 0 {
+		graph.mappingSize--
+	}
+}
+
+// insertColumn Add a line that waits for a commit after the current commit, unless there
+// already is one, and map the next character of the rows to it. index is the column of the
+// current commit when the commit is one of its parents and -1 otherwise
+func (graph *commitGraph) insertColumn(hash objects.ObjectID, index int) {
+	column := indexOf(graph.newColumns, hash)
+	if column < 0 {
+		column = len(graph.newColumns)
+		graph.newColumns = append(graph.newColumns, hash)
+	}
+	var at int
+	if len(graph.parents) > 1 && index > -1 && graph.mergeLayout == -1 {
+		// The first parent of a merge: its edges start to the left of the commit if the parent
+		// is already in a column to its left
+		distance := index - column
+		shift := 1
+		if distance > 1 {
+			shift = 2*distance - 3
+		}
+		graph.mergeLayout = 1
+		if distance > 0 {
+			graph.mergeLayout = 0
+		}
+		graph.edgesAdded = len(graph.parents) + graph.mergeLayout - 2
+		at = graph.width + (graph.mergeLayout-1)*shift
+		graph.width += 2 * graph.mergeLayout
+	} else if graph.edgesAdded > 0 && column == graph.mapping[graph.width-2] {
+		// A parent that the line after the merge waits for joins that line right away
+		at = graph.width - 2
+		graph.edgesAdded = -1
+	} else {
+		at = graph.width
+		graph.width += 2
+	}
+	graph.mapping[at] = column
+}
+
+// dashedParents The number of parents of an octopus merge that are connected with dashes
+func (graph *commitGraph) dashedParents() int {
+	return len(graph.parents) + graph.mergeLayout - 3
+}
+
+// needsPreCommitRow Whether the lines after an octopus merge have to move further right
+func (graph *commitGraph) needsPreCommitRow() bool {
+	return len(graph.parents) >= 3 && graph.commitIndex < len(graph.columns)-1 &&
+		graph.expansionRow < 2*graph.dashedParents()
+}
+
+// mappingDone Whether every line is in its column
+func (graph *commitGraph) mappingDone() bool {
+	for i := 0; i < graph.mappingSize; i++ {
+		if target := graph.mapping[i]; target >= 0 && target != i/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// pad Pad a row to the width of the current commit's rows
+func (graph *commitGraph) pad(row *strings.Builder) string {
+	if row.Len() < graph.width {
+		row.WriteString(strings.Repeat(" ", graph.width-row.Len()))
+	}
+	return row.String()
+}
+
+// finished Whether all the rows of the current commit have been drawn
+func (graph *commitGraph) finished() bool {
+	return graph.state == graphPadding
+}
+
+// nextRow The next row of the current commit, or padding once they have all been drawn
+func (graph *commitGraph) nextRow() string {
+	var row strings.Builder
+	graph.shownCommit = false
+	switch graph.state {
+	case graphPadding:
+		for range graph.newColumns {
+			row.WriteString("| ")
+		}
+	case graphPreCommit:
+		graph.preCommitRow(&row)
+	case graphCommit:
+		graph.commitRow(&row)
+		graph.shownCommit = true
+	case graphPostMerge:
+		graph.postMergeRow(&row)
+	case graphCollapsing:
+		graph.collapsingRow(&row)
+	}
+	return graph.pad(&row)
+}
+
+// paddingBefore The padding drawn before the current commit's rows, e.g. next to the blank line
+// between two commits
+func (graph *commitGraph) paddingBefore() string {
+	if graph.state != graphCommit {
+		return graph.nextRow()
+	}
+	var row strings.Builder
+	for _, hash := range graph.columns {
+		row.WriteString("|")
+		if hash == graph.commit && len(graph.parents) > 2 {
+			row.WriteString(strings.Repeat(" ", 2*(len(graph.parents)-2)))
+		} else {
+			row.WriteString(" ")
+		}
+	}
+	graph.prevState = graphPadding
+	return graph.pad(&row)
+}
+
+// preCommitRow Draw a row that moves the lines after an octopus merge one step right
+func (graph *commitGraph) preCommitRow(row *strings.Builder) {
+	seenCommit := false
+	for i, hash := range graph.columns {
+		switch {
+		case hash == graph.commit:
+			seenCommit = true
+			row.WriteString("|" + strings.Repeat(" ", graph.expansionRow))
+		case seenCommit && graph.expansionRow == 0:
+			// Lines that the last merge drew as '\' keep going right
+			if graph.prevState == graphPostMerge && graph.prevCommitIndex < i {
+				row.WriteString("\\")
+			} else {
+				row.WriteString("|")
+			}
+		case seenCommit:
+			row.WriteString("\\")
+		default:
+			row.WriteString("|")
+		}
+		row.WriteString(" ")
+	}
+	graph.expansionRow++
+	if !graph.needsPreCommitRow() {
+		graph.setState(graphCommit)
+	}
+}
+
+// commitRow Draw the row of the current commit
+func (graph *commitGraph) commitRow(row *strings.Builder) {
+	seenCommit := false
+	for i := 0; i <= len(graph.columns); i++ {
+		var hash objects.ObjectID
+		if i == len(graph.columns) {
+			if seenCommit {
+				break
+			}
+			hash = graph.commit
+		} else {
+			hash = graph.columns[i]
+		}
+		switch {
+		case hash == graph.commit:
+			seenCommit = true
+			row.WriteString("*")
+			// The dashes of an octopus merge lead to its parents after the first two
+			dashed := graph.dashedParents()
+			for p := 0; len(graph.parents) > 2 && p < dashed; p++ {
+				if p == dashed-1 {
+					row.WriteString("-.")
+				} else {
+					row.WriteString("--")
+				}
+			}
+		case seenCommit && graph.edgesAdded > 1:
+			row.WriteString("\\")
+		case seenCommit && graph.edgesAdded == 1:
+			// Lines that the last merge drew as '\' keep going right
+			if graph.prevState == graphPostMerge && graph.prevEdgesAdded > 0 && graph.prevCommitIndex < i {
+				row.WriteString("\\")
+			} else {
+				row.WriteString("|")
+			}
+		case graph.prevState == graphCollapsing && graph.oldMapping[2*i+1] == i && graph.mapping[2*i] < i:
+			// A line that moved into this column and keeps moving left
+			row.WriteString("/")
+		default:
+			row.WriteString("|")
+		}
+		row.WriteString(" ")
+	}
+	if len(graph.parents) > 1 {
+		graph.setState(graphPostMerge)
+	} else if graph.mappingDone() {
+		graph.setState(graphPadding)
+	} else {
+		graph.setState(graphCollapsing)
+	}
+}
+
+// mergeChars The edges to the parents of a merge, starting from the merge's layout
+var mergeChars = []byte{'/', '|', '\\'}
+
+// postMergeRow Draw the row that branches out from a merge to its parents
+func (graph *commitGraph) postMergeRow(row *strings.Builder) {
+	seenCommit := false
+	parentColumn := false
+	for i := 0; i <= len(graph.columns); i++ {
+		var hash objects.ObjectID
+		if i == len(graph.columns) {
+			if seenCommit {
+				break
+			}
+			hash = graph.commit
+		} else {
+			hash = graph.columns[i]
+		}
+		switch {
+		case hash == graph.commit:
+			seenCommit = true
+			edge := graph.mergeLayout
+			for j := range graph.parents {
+				row.WriteByte(mergeChars[edge])
+				if edge == 2 {
+					if graph.edgesAdded > 0 || j < len(graph.parents)-1 {
+						row.WriteString(" ")
+					}
+				} else {
+					edge++
+				}
+			}
+			if graph.edgesAdded == 0 {
+				row.WriteString(" ")
+			}
+		case seenCommit:
+			if graph.edgesAdded > 0 {
+				row.WriteString("\\ ")
+			} else {
+				row.WriteString("| ")
+			}
+		default:
+			row.WriteString("|")
+			// A first parent to the left of the merge is reached with a horizontal edge
+			if graph.mergeLayout != 0 || i != graph.commitIndex-1 {
+				if parentColumn {
+					row.WriteString("_")
+				} else {
+					row.WriteString(" ")
+				}
+			}
+		}
+		if hash == graph.parents[0] {
+			parentColumn = true
+		}
+	}
+	if graph.mappingDone() {
+		graph.setState(graphPadding)
+	} else {
+		graph.setState(graphCollapsing)
+	}
+}
+
+// collapsingRow Draw a row that moves every line that isn't in its column one step left.
+// Lines that wait for the same commit join, and one line at a time can cross the lines in
+// between with a horizontal edge
+func (graph *commitGraph) collapsingRow(row *strings.Builder) {
+	graph.mapping, graph.oldMapping = graph.oldMapping, graph.mapping
+	for i := 0; i < graph.mappingSize; i++ {
+		graph.mapping[i] = -1
+	}
+	horizontal, horizontalTarget := -1, -1
+	for i := 0; i < graph.mappingSize; i++ {
+		target := graph.oldMapping[i]
+		switch {
+		case target < 0:
+			continue
+		case 2*target == i:
+			// Already in its column
+			graph.mapping[i] = target
+		case graph.mapping[i-1] < 0:
+			// Nothing to the left, so move left by one
+			graph.mapping[i-1] = target
+			if horizontal == -1 {
+				horizontal, horizontalTarget = i, target
+				for j := 2*target + 3; j < i-2; j += 2 {
+					graph.mapping[j] = target
+				}
+			}
+		case graph.mapping[i-1] == target:
+			// The line to the left waits for the same commit, so this line joins it
+		default:
+			// Cross the line to the left
+			graph.mapping[i-2] = target
+			if horizontal == -1 {
+				horizontal, horizontalTarget = i-1, target
+				for j := 2*target + 3; j < i-2; j += 2 {
+					graph.mapping[j] = target
+				}
+			}
+		}
+	}
+	copy(graph.oldMapping, graph.mapping[:graph.mappingSize])
+	if graph.mapping[graph.mappingSize-1] < 0 {
+		graph.mappingSize--
+	}
+	usedHorizontal := false
+	for i := 0; i < graph.mappingSize; i++ {
+		target := graph.mapping[i]
+		switch {
+		case target < 0:
+			row.WriteString(" ")
+		case 2*target == i:
+			row.WriteString("|")
+		case target == horizontalTarget && i != horizontal-1:
+			// Only the first segment of the horizontal edge continues into the next row
+			if i != 2*target+3 {
+				graph.mapping[i] = -1
+			}
+			usedHorizontal = true
+			row.WriteString("_")
+		default:
+			if usedHorizontal && i < horizontal {
+				graph.mapping[i] = -1
+			}
+			row.WriteString("/")
+		}
+	}
+	if graph.mappingDone() {
+		graph.setState(graphPadding)
+	}
+}
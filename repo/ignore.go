@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Support for .gitignore files and .git/info/exclude
+
+// ignoreRule A single pattern read from an ignore file
+type ignoreRule struct {
+	// pattern The glob with the leading '!' and '/' and the trailing '/' removed
+	pattern string
+	// base The directory holding the ignore file relative to the worktree ("" for the root).
+	// The rule only applies to paths inside it
+	base string
+	// negate Set for patterns starting with '!'; matching paths are no longer ignored
+	negate bool
+	// dirOnly Set for patterns ending with '/'; they only match directories
+	dirOnly bool
+	// anchored Set for patterns containing a '/' (other than a trailing one). They are matched
+	// against the path relative to base instead of just the file name
+	anchored bool
+}
+
+// IgnoreMatcher Decides whether paths in the worktree are ignored. .gitignore files are read
+// as they are needed; rules in deeper directories take precedence over those above them and
+// all of them take precedence over .git/info/exclude
+type IgnoreMatcher struct {
+	fs       vfs.FS
+	worktree string
+	rules    []ignoreRule
+	loaded   map[string]bool
+}
+
+// IgnoreMatcher Create a matcher for the repo's ignore rules
+func (repo *Repo) IgnoreMatcher() (*IgnoreMatcher, error) {
+	matcher := &IgnoreMatcher{
+		fs:       repo.FS(),
+		worktree: repo.Worktree,
+		rules:    make([]ignoreRule, 0),
+		loaded:   make(map[string]bool),
+	}
+	err := matcher.readIgnoreFile(path.Join(repo.GitDir, "info", "exclude"), "")
+	if err != nil {
+		return nil, err
+	}
+	return matcher, nil
+}
+
+// Ignored Check if a path (relative to the worktree) is ignored. Everything inside an ignored
+// directory is ignored too, even if a later rule would include it again
+func (matcher *IgnoreMatcher) Ignored(name string, isDir bool) (bool, error) {
+	name = cleanRepoPath(name)
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		dir := strings.Join(parts[:i], "/")
+		err := matcher.loadDir(dir)
+		if err != nil {
+			return false, err
+		}
+		if i > 0 && matcher.matches(dir, true) {
+			return true, nil
+		}
+	}
+	return matcher.matches(name, isDir), nil
+}
+
+// Read the .gitignore file in dir if it hasn't been read yet
+func (matcher *IgnoreMatcher) loadDir(dir string) error {
+	if matcher.loaded[dir] {
+		return nil
+	}
+	matcher.loaded[dir] = true
+	return matcher.readIgnoreFile(path.Join(matcher.worktree, dir, ".gitignore"), dir)
+}
+
+// Add the rules in an ignore file. Missing files are skipped
+func (matcher *IgnoreMatcher) readIgnoreFile(filePath string, base string) error {
+	file, err := matcher.fs.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rule, ok := parseIgnoreRule(scanner.Text(), base)
+		if ok {
+			matcher.rules = append(matcher.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseIgnoreRule Parse a line of an ignore file. Returns false for blank lines and comments
+func parseIgnoreRule(line string, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	rule.anchored = strings.Contains(line, "/")
+	rule.pattern = strings.TrimPrefix(line, "/")
+	if rule.pattern == "" {
+		return ignoreRule{}, false
+	}
+	return rule, true
+}
+
+// Check the rules against a path. The last matching rule decides whether it is ignored
+func (matcher *IgnoreMatcher) matches(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range matcher.rules {
+		if rule.matches(name, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Check if a single rule matches a path
+func (rule *ignoreRule) matches(name string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	if !isInDir(name, rule.base) {
+		return false
+	}
+	rel := name
+	if rule.base != "" {
+		rel = name[len(rule.base)+1:]
+	}
+	if !rule.anchored {
+		return globMatch(rule.pattern, path.Base(rel))
+	}
+	return globMatch(rule.pattern, rel)
+}
+
+// globMatch Match a slash separated path against a glob. Each component is matched with
+// path.Match except for "**" which matches any number of components
+func globMatch(pattern string, name string) bool {
+	return globMatchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func globMatchParts(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try every possible number of components for "**" to match
+			for i := 0; i <= len(name); i++ {
+				if globMatchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
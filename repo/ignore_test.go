@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+// Test the matching rules of ignore files: globs, anchoring, directory-only patterns, negation,
+// precedence of nested .gitignore files and .git/info/exclude
+func TestIgnoreMatcher(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	ignoreFiles := map[string]string{
+		".gitignore":          "# comment\n*.log\n!keep.log\n/root-only\nbuild/\ndocs/**/*.tmp\n",
+		"sub/.gitignore":      "!*.log\nlocal\n",
+		".git/info/exclude":   "excluded\n*.log\n",
+		"sub/deep/.gitignore": "*.txt\n",
+	}
+	for name, content := range ignoreFiles {
+		filePath := path.Join(repoStruct.Worktree, name)
+		err = os.MkdirAll(path.Dir(filePath), DirFilemode)
+		if err != nil {
+			t.Fatalf("Error creating directory: %s", err)
+		}
+		err = os.WriteFile(filePath, []byte(content), NormalFilemode)
+		if err != nil {
+			t.Fatalf("Error writing ignore file: %s", err)
+		}
+	}
+	matcher, err := repoStruct.IgnoreMatcher()
+	if err != nil {
+		t.Fatalf("Error creating matcher: %s", err)
+	}
+	cases := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"debug.log", false, true},
+		{"dir/debug.log", false, true},
+		{"keep.log", false, false},
+		{"sub/debug.log", false, false},
+		{"root-only", false, true},
+		{"dir/root-only", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"build/output", false, true},
+		{"dir/build/output", false, true},
+		{"docs/a.tmp", false, true},
+		{"docs/a/b/c.tmp", false, true},
+		{"other/a.tmp", false, false},
+		{"excluded", false, true},
+		{"sub/local", false, true},
+		{"local", false, false},
+		{"sub/deep/notes.txt", false, true},
+		{"notes.txt", false, false},
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		ignored, err := matcher.Ignored(c.name, c.isDir)
+		if err != nil {
+			t.Fatalf("Error checking %s: %s", c.name, err)
+		}
+		if ignored != c.ignored {
+			t.Errorf("Expected Ignored(%s, %t) to be %t", c.name, c.isDir, c.ignored)
+		}
+	}
+}
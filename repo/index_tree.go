@@ -1,7 +1,6 @@
 package repo
 
 import (
-	"encoding/hex"
 	"github.com/SimonMTaye/gitgo/index"
 	"github.com/SimonMTaye/gitgo/objects"
 	"strings"
@@ -10,28 +9,29 @@ import (
 type treeEntry struct {
 	mode objects.EntryFileMode
 	name string
-	hash string
+	hash objects.ObjectID
 }
 
 // Represents the overall tree structure that will be created
 type treeMap struct {
 	subTrees map[string]*treeMap
 	files    []*treeEntry
+	// algo The algorithm the trees are hashed with
+	algo *objects.HashAlgorithm
 }
 
 //addEntry add an entry to the treemap
 func (tm *treeMap) addEntry(entry *index.Entry) {
-	mode := parseFileModeBits(entry.Metadata.FileMode)
-	hash := hex.EncodeToString(entry.Metadata.ObjHash[:])
+	mode := objects.FileModeFromBits(entry.Metadata.FileMode)
 	pathlist := strings.Split(entry.Name, "/")
-	tm.addEntryHelper(pathlist, hash, mode)
+	tm.addEntryHelper(pathlist, entry.Hash(), mode)
 }
 
-func (tm *treeMap) addEntryHelper(pathlist []string, hash string, mode objects.EntryFileMode) {
+func (tm *treeMap) addEntryHelper(pathlist []string, hash objects.ObjectID, mode objects.EntryFileMode) {
 	if len(pathlist) > 1 {
 		subtree, ok := tm.subTrees[pathlist[0]]
 		if !ok {
-			tm.subTrees[pathlist[0]] = &treeMap{}
+			tm.subTrees[pathlist[0]] = emptyTreeMap(tm.algo)
 			subtree = tm.subTrees[pathlist[0]]
 		}
 		subtree.addEntryHelper(pathlist[1:], hash, mode)
@@ -40,70 +40,58 @@ func (tm *treeMap) addEntryHelper(pathlist []string, hash string, mode objects.E
 	}
 }
 
-// Convert a treeMap into a regular GitTree
-func (tm *treeMap) toTree() *objects.GitTree {
-	tree := &objects.GitTree{}
+// Convert a treeMap into a regular GitTree. The GitTree takes care of ordering the entries
+func (tm *treeMap) toTree() (*objects.GitTree, error) {
+	tree := objects.NewTree(tm.algo)
 	// Add all the regular files
 	for _, file := range tm.files {
-		tree.AddEntry(file.mode, file.name, file.hash)
+		err := tree.AddEntry(file.mode, file.name, file.hash)
+		if err != nil {
+			return nil, err
+		}
 	}
 	for name, subtree := range tm.subTrees {
-		subGitTree := subtree.toTree()
-		tree.AddEntry(objects.Directory, name, objects.Hash(subGitTree))
+		subGitTree, err := subtree.toTree()
+		if err != nil {
+			return nil, err
+		}
+		err = tree.AddEntry(objects.Directory, name, tm.algo.Hash(subGitTree))
+		if err != nil {
+			return nil, err
+		}
 	}
-	return tree
+	return tree, nil
 }
 
 // allTrees Creates all the GitTree objects that represent the root dir as well as all the subdirs
 // Necessary for saving them to Disk
-func (tm *treeMap) allTrees() []*objects.GitTree {
-	trees := make([]*objects.GitTree, 0)
-	trees = append(trees, tm.toTree())
-	for _, subtree := range tm.subTrees {
-		trees = append(trees, subtree.allTrees()...)
+func (tm *treeMap) allTrees() ([]*objects.GitTree, error) {
+	tree, err := tm.toTree()
+	if err != nil {
+		return nil, err
 	}
-	return trees
-}
-
-// Convert the file mode bits stored in the uint32 into a string required by tree
-// objects
-// BREAKS ENCAPSULATION, this should be handled by trees themselves
-func parseFileModeBits(FileMode uint32) objects.EntryFileMode {
-	// bit 0-15 are empty
-	offset := 15
-	str := ""
-	for i := 0; i < 4; i++ {
-		if index.BitSet32(FileMode, i+offset) {
-			str += "1"
-		} else {
-			str += "0"
+	trees := []*objects.GitTree{tree}
+	for _, subtree := range tm.subTrees {
+		subTrees, err := subtree.allTrees()
+		if err != nil {
+			return nil, err
 		}
+		trees = append(trees, subTrees...)
 	}
-	if str == "1000" {
-		// The last 9 bits of FileMode are permission. We are checking the
-		// last bit (which corresponds to 'everyone' execution permission) and
-		// 2 bits behind the last bit (which corresponds to 'user' execution permission)
-		if index.BitSet32(FileMode, 31) || index.BitSet32(FileMode, 29) {
-			return objects.Executable
-		} else {
-			return objects.Normal
-		}
-	} else {
-		return objects.SymbolicLink
-	}
-
+	return trees, nil
 }
 
-func emptyTreeMap() *treeMap {
+func emptyTreeMap(algo *objects.HashAlgorithm) *treeMap {
 	return &treeMap{
 		subTrees: make(map[string]*treeMap),
 		files:    make([]*treeEntry, 0),
+		algo:     algo,
 	}
 }
 
 // indexToTreeMap converts the index into a treeMap, which is  more convenient format for saving to disk
 func indexToTreeMap(idx *index.Index) *treeMap {
-	treeMap := emptyTreeMap()
+	treeMap := emptyTreeMap(idx.HashAlgorithm())
 	for _, entry := range idx.Entries {
 		treeMap.addEntry(entry)
 	}
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Test that the trees created from the index always have the same hash as the tree git
+// creates for the same files. Subtrees are stored in a map so this is repeated a few times
+func TestIndexTreeHash(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	files := map[string]string{
+		"foo.c":   "1\n",
+		"foo-bar": "2\n",
+		"foo/x":   "3\n",
+		"foo0":    "4\n",
+		"run.sh":  "5\n",
+	}
+	err = os.Mkdir(path.Join(repoStruct.Worktree, "foo"), DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	for name, content := range files {
+		err = os.WriteFile(path.Join(repoStruct.Worktree, name), []byte(content), NormalFilemode)
+		if err != nil {
+			t.Fatalf("Error creating file: %s", err)
+		}
+	}
+	err = os.Chmod(path.Join(repoStruct.Worktree, "run.sh"), ExecutableFilemode)
+	if err != nil {
+		t.Fatalf("Error changing mode: %s", err)
+	}
+	err = os.Symlink("foo.c", path.Join(repoStruct.Worktree, "link"))
+	if err != nil {
+		t.Fatalf("Error creating symlink: %s", err)
+	}
+	for _, name := range []string{"link", "run.sh", "foo/x", "foo0", "foo-bar", "foo.c"} {
+		err = repoStruct.AddFile(name)
+		if err != nil {
+			t.Fatalf("Error adding file: %s", err)
+		}
+	}
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	// Hash of the tree written by 'git write-tree'
+	expectedHash := "5fdf8ed98367b5380fcb69df3fd53472ba4ece9a"
+	for i := 0; i < 10; i++ {
+		tree, err := indexToTreeMap(idx).toTree()
+		if err != nil {
+			t.Fatalf("Error creating tree: %s", err)
+		}
+		if objects.Hash(tree).String() != expectedHash {
+			t.Fatalf("Expected tree hash %s, Got: %s\nTree:\n%s", expectedHash, objects.Hash(tree), tree)
+		}
+	}
+}
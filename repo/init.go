@@ -1,11 +1,13 @@
 package repo
 
 import (
-	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/SimonMTaye/gitgo/config"
 	"github.com/SimonMTaye/gitgo/iniparse"
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
 )
 
 const EmptyDescription = "Unnamed repository; edit this file 'description' to name the repository."
@@ -18,6 +20,10 @@ const DirFilemode = 1023
 // NormalFilemode Represents 000 - 110 - 110 - 100
 //Or:          d - rwx - rwx - rwx
 const NormalFilemode = 436
+
+// ExecutableFilemode Represents 000 - 111 - 101 - 101, the mode git checks executables out with
+//Or:          d - rwx - rwx - rwx
+const ExecutableFilemode = 0755
 const DefaultBranchName = "main"
 
 // CreateRepo Create the ".git" directory and the necessary files and dirs
@@ -26,76 +32,103 @@ const DefaultBranchName = "main"
 // description: repo description
 // worktree: location for worktree
 func CreateRepo(cwd string, description string, worktree string) error {
-	gitDir, err := filepath.Abs(filepath.Join(cwd, ".git"))
+	return CreateRepoWithHash(cwd, description, worktree, objects.SHA1)
+}
+
+// CreateRepoWithHash Create a repository like CreateRepo whose objects are named with algo.
+// Repositories using anything but SHA1 record it in extensions.objectFormat
+func CreateRepoWithHash(cwd string, description string, worktree string, algo *objects.HashAlgorithm) error {
+	absCwd, err := filepath.Abs(cwd)
+	if err != nil {
+		return err
+	}
+	// Get default branch name; use hard coded value if not available
+	configData, err := config.LoadGlobalConfig()
 	if err != nil {
-		return nil
+		return err
 	}
-	err = os.Mkdir(gitDir, DirFilemode)
+	initBranchName := DefaultBranchName
+	initSection, ok := (*configData.All)["init"]
+	if ok {
+		branchName, ok := initSection["defaultBranch"]
+		if ok {
+			initBranchName = branchName
+		}
+	}
+	return createRepoFS(vfs.OS, filepath.ToSlash(absCwd), description, worktree, initBranchName, algo)
+}
+
+// CreateRepoFS Create a repository in cwd on the given filesystem. Works like CreateRepo except
+// that the global config isn't read, so the initial branch is always DefaultBranchName
+func CreateRepoFS(fsys vfs.FS, cwd string, description string, worktree string) error {
+	return CreateRepoFSWithHash(fsys, cwd, description, worktree, objects.SHA1)
+}
+
+// CreateRepoFSWithHash Create a repository like CreateRepoFS whose objects are named with algo
+func CreateRepoFSWithHash(fsys vfs.FS, cwd string, description string, worktree string,
+	algo *objects.HashAlgorithm) error {
+	return createRepoFS(fsys, cwd, description, worktree, DefaultBranchName, algo)
+}
+
+func createRepoFS(fsys vfs.FS, cwd string, description string, worktree string, initBranchName string,
+	algo *objects.HashAlgorithm) error {
+	gitDir := path.Join(cwd, ".git")
+	err := fsys.Mkdir(gitDir, DirFilemode)
 	if err != nil {
 		return err
 	}
 
-	err = os.Mkdir(filepath.Join(gitDir, "objects"), DirFilemode)
+	err = fsys.Mkdir(path.Join(gitDir, "objects"), DirFilemode)
 	if err != nil {
 		return err
 	}
 
-	err = os.Mkdir(filepath.Join(gitDir, "branches"), DirFilemode)
+	err = fsys.Mkdir(path.Join(gitDir, "branches"), DirFilemode)
 	if err != nil {
 		return err
 	}
 
-	refsDir := filepath.Join(gitDir, "refs")
-	err = os.Mkdir(refsDir, DirFilemode)
+	refsDir := path.Join(gitDir, "refs")
+	err = fsys.Mkdir(refsDir, DirFilemode)
 	if err != nil {
 		return err
 	}
 
-	err = os.Mkdir(filepath.Join(refsDir, "tags"), DirFilemode)
+	err = fsys.Mkdir(path.Join(refsDir, "tags"), DirFilemode)
 	if err != nil {
 		return err
 	}
 
-	err = os.Mkdir(filepath.Join(refsDir, "heads"), DirFilemode)
+	err = fsys.Mkdir(path.Join(refsDir, "heads"), DirFilemode)
 	if err != nil {
 		return err
 	}
 	// Create config file
-	configFile, err := os.Create(filepath.Join(gitDir, "config"))
+	configFile, err := vfs.Create(fsys, path.Join(gitDir, "config"))
 	if err != nil {
 		return err
 	}
-	defer func(configFile *os.File) {
+	defer func(configFile vfs.File) {
 		err := configFile.Close()
 		if err != nil {
 		}
 	}(configFile)
 
-	_, err = configFile.Write([]byte(defaultConfig(worktree)))
+	_, err = configFile.Write([]byte(defaultConfig(worktree, algo)))
 	if err != nil {
 		return err
 	}
-	// Get default branch name; use hard coded value if not available
-	configData, err := config.LoadGlobalConfig()
+	// Create main branch ref
+	headFile, err := vfs.Create(fsys, path.Join(gitDir, "HEAD"))
 	if err != nil {
 		return err
 	}
-	initBranchName := DefaultBranchName
-	initSection, ok := (*configData.All)["init"]
-	if ok {
-		branchName, ok := initSection["defaultBranch"]
-		if ok {
-			initBranchName = branchName
-		}
-	}
-	// Create main branch ref
-	headFile, err := os.Create(filepath.Join(gitDir, "HEAD"))
 	// Set new branch as head
-	_, err = headFile.WriteString("ref: refs/heads/" + initBranchName + "\n")
+	_, err = headFile.Write([]byte("ref: refs/heads/" + initBranchName + "\n"))
 	if err != nil {
 		return err
 	}
-	defer func(headFile *os.File) {
+	defer func(headFile vfs.File) {
 		err := headFile.Close()
 		if err != nil {
 		}
@@ -103,11 +136,11 @@ func CreateRepo(cwd string, description string, worktree string) error {
 	if err != nil {
 		return err
 	}
-	descriptionFile, err := os.Create(filepath.Join(gitDir, "description"))
+	descriptionFile, err := vfs.Create(fsys, path.Join(gitDir, "description"))
 	if err != nil {
 		return err
 	}
-	defer func(descriptionFile *os.File) {
+	defer func(descriptionFile vfs.File) {
 		err := descriptionFile.Close()
 		if err != nil {
 		}
@@ -123,10 +156,17 @@ func CreateRepo(cwd string, description string, worktree string) error {
 }
 
 //Returns a string representation of the default config file used for .git directories
-func defaultConfig(worktree string) string {
+func defaultConfig(worktree string, algo *objects.HashAlgorithm) string {
 	configIni := make(iniparse.IniFile)
-	configIni.SetProperty("core", "repositoryformatversion", "0")
-	configIni.SetProperty("core", "filemode", "false")
+	if algo == objects.SHA1 {
+		configIni.SetProperty("core", "repositoryformatversion", "0")
+	} else {
+		// Older versions of git must not open repositories with extensions they don't know
+		configIni.SetProperty("core", "repositoryformatversion", "1")
+		configIni.SetProperty("extensions", "objectformat", algo.Name())
+	}
+	// Trust the executable bit of files in the worktree, like git does on filesystems that keep it
+	configIni.SetProperty("core", "filemode", "true")
 	configIni.SetProperty("core", "bare", "false")
 	if worktree != ".." && worktree != "" {
 		configIni.SetProperty("core", "worktree", worktree)
@@ -7,15 +7,16 @@ import (
 	"testing"
 
 	"github.com/SimonMTaye/gitgo/iniparse"
+	"github.com/SimonMTaye/gitgo/objects"
 )
 
 func TestDefaultConfig(t *testing.T) {
 	expectedIni := make(iniparse.IniFile)
 	expectedIni.SetProperty("core", "repositoryformatversion", "0")
-	expectedIni.SetProperty("core", "filemode", "false")
+	expectedIni.SetProperty("core", "filemode", "true")
 	expectedIni.SetProperty("core", "bare", "false")
 
-	defaultIni, err := iniparse.ParseIni(strings.NewReader(defaultConfig("")))
+	defaultIni, err := iniparse.ParseIni(strings.NewReader(defaultConfig("", objects.SHA1)))
 	if err != nil {
 		t.Errorf("Unexpected Error when parsing ini:\n%s", err.Error())
 	}
@@ -25,7 +26,7 @@ func TestDefaultConfig(t *testing.T) {
 	}
 
 	expectedIni.SetProperty("core", "worktree", "random")
-	defaultIni, err = iniparse.ParseIni(strings.NewReader(defaultConfig("random")))
+	defaultIni, err = iniparse.ParseIni(strings.NewReader(defaultConfig("random", objects.SHA1)))
 	if err != nil {
 		t.Errorf("Unexpected Error when parsing ini:\n%s", err.Error())
 	}
@@ -107,7 +108,7 @@ func TestCreateRepoWithDefaultParams(t *testing.T) {
 		t.Errorf("Unexpected Error when parsing 'config' file as ini:\n%s", err.Error())
 	}
 
-	expectedIni, err := iniparse.ParseIni(strings.NewReader(defaultConfig("")))
+	expectedIni, err := iniparse.ParseIni(strings.NewReader(defaultConfig("", objects.SHA1)))
 	if err != nil {
 		t.Errorf("Unexpected Error when parsing default config as ini:\n%s", err.Error())
 	}
@@ -123,7 +124,7 @@ func TestCreateRepoWorktreeAndDescription(t *testing.T) {
 
 	sampleDescription := "hello world"
 	worktreeDir := path.Join(tmpDir, "random")
-	sampleConfig := defaultConfig(worktreeDir)
+	sampleConfig := defaultConfig(worktreeDir, objects.SHA1)
 	err := CreateRepo(tmpDir, sampleDescription, worktreeDir)
 	if err != nil {
 		t.Errorf("CreateRepo returned unexpected Error:\n%s", err.Error())
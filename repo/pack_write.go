@@ -0,0 +1,198 @@
+package repo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"path"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Writing pack files along with their version 2 index. Objects are stored whole (compressed
+// but without deltas), which every reader of packs understands
+
+// packTypes The pack entry type of each object type
+var packTypes = map[objects.GitObjectType]int{
+	objects.Commit: packCommit,
+	objects.Tree:   packTree,
+	objects.Blob:   packBlob,
+	objects.Tag:    packTag,
+}
+
+// countingWriter Counts the bytes written through it
+type countingWriter struct {
+	count int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	writer.count += int64(len(p))
+	return len(p), nil
+}
+
+// WritePack Write the objects with the given hashes, read from store, into a new pack in
+// packDir. The pack is named after its checksum (pack-<checksum>.pack) and its index is written
+// next to it. Both checksums are made with algo, which must be the algorithm of the hashes.
+// Returns the path of the pack
+func WritePack(fsys vfs.FS, packDir string, store ObjectStore, hashes []objects.ObjectID,
+	algo *objects.HashAlgorithm) (string, error) {
+	err := fsys.MkdirAll(packDir, DirFilemode)
+	if err != nil {
+		return "", err
+	}
+	sorted := append([]objects.ObjectID{}, hashes...)
+	sortIDs(sorted)
+
+	tmpPack, err := vfs.CreateTemp(fsys, packDir, "tmp_pack_")
+	if err != nil {
+		return "", err
+	}
+	checksum, offsets, crcs, err := writePackData(tmpPack, store, sorted, algo)
+	closeErr := tmpPack.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fsys.Remove(tmpPack.Name())
+		return "", err
+	}
+
+	name := "pack-" + hex.EncodeToString(checksum)
+	idxData, err := packIndex(sorted, offsets, crcs, checksum, algo)
+	if err != nil {
+		fsys.Remove(tmpPack.Name())
+		return "", err
+	}
+	// The index is put in place first; a pack is only read once its index exists
+	idxPath := path.Join(packDir, name+".idx")
+	tmpIdx := path.Join(packDir, "tmp_idx_"+name)
+	err = fsys.WriteFile(tmpIdx, idxData, 0444)
+	if err == nil {
+		err = fsys.Rename(tmpIdx, idxPath)
+	}
+	if err != nil {
+		fsys.Remove(tmpIdx)
+		fsys.Remove(tmpPack.Name())
+		return "", err
+	}
+	packPath := path.Join(packDir, name+".pack")
+	err = fsys.Rename(tmpPack.Name(), packPath)
+	if err != nil {
+		fsys.Remove(idxPath)
+		fsys.Remove(tmpPack.Name())
+		return "", err
+	}
+	return packPath, nil
+}
+
+// writePackData Write the pack header, every object and the trailing checksum to dst. Returns
+// the checksum along with the offset and the CRC32 of the entry of every object
+func writePackData(dst io.Writer, store ObjectStore, hashes []objects.ObjectID,
+	algo *objects.HashAlgorithm) ([]byte, []int64, []uint32, error) {
+	packHash := algo.NewChecksum()
+	counter := &countingWriter{}
+	out := io.MultiWriter(dst, packHash, counter)
+	header := make([]byte, 12)
+	copy(header, "PACK")
+	binary.BigEndian.PutUint32(header[4:8], 2)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(hashes)))
+	_, err := out.Write(header)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	offsets := make([]int64, len(hashes))
+	crcs := make([]uint32, len(hashes))
+	for i, objHash := range hashes {
+		offsets[i] = counter.count
+		crc := crc32.NewIEEE()
+		err = writePackEntry(io.MultiWriter(out, crc), store, objHash)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		crcs[i] = crc.Sum32()
+	}
+	checksum := packHash.Sum(nil)
+	_, err = dst.Write(checksum)
+	return checksum, offsets, crcs, err
+}
+
+// writePackEntry Write a single object: its type and size followed by its compressed content
+func writePackEntry(dst io.Writer, store ObjectStore, objHash objects.ObjectID) error {
+	reader, err := store.Get(objHash)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	entryType, ok := packTypes[reader.Type]
+	if !ok {
+		return &ErrBadPack{pack: "new pack", reason: "cannot store object of type " + string(reader.Type)}
+	}
+	// The size is stored 4 bits in the first byte and 7 bits in each byte after it
+	size := reader.Size
+	entryHeader := []byte{byte(entryType<<4) | byte(size&0x0f)}
+	size >>= 4
+	for size > 0 {
+		entryHeader[len(entryHeader)-1] |= 0x80
+		entryHeader = append(entryHeader, byte(size&0x7f))
+		size >>= 7
+	}
+	_, err = dst.Write(entryHeader)
+	if err != nil {
+		return err
+	}
+	zWriter := zlib.NewWriter(dst)
+	_, err = io.Copy(zWriter, reader)
+	if err != nil {
+		return err
+	}
+	return zWriter.Close()
+}
+
+// packIndex Build a version 2 index for a pack holding the objects in hashes (sorted) at the
+// given offsets
+func packIndex(hashes []objects.ObjectID, offsets []int64, crcs []uint32, packChecksum []byte,
+	algo *objects.HashAlgorithm) ([]byte, error) {
+	idx := &bytes.Buffer{}
+	idxHash := algo.NewChecksum()
+	out := io.MultiWriter(idx, idxHash)
+	write := func(data interface{}) {
+		binary.Write(out, binary.BigEndian, data)
+	}
+	out.Write(packIdxSignature)
+	write(uint32(2))
+	// The fan-out table holds the number of objects whose first byte is less than or equal to
+	// each value
+	fanout := make([]uint32, 256)
+	for _, objHash := range hashes {
+		if objHash.Size() != algo.Size() {
+			return nil, &ErrBadPack{pack: "new pack", reason: "object " + objHash.String() + " isn't a " + algo.Name() + " hash"}
+		}
+		fanout[objHash.Bytes()[0]]++
+	}
+	for i := 1; i < 256; i++ {
+		fanout[i] += fanout[i-1]
+	}
+	write(fanout)
+	for _, objHash := range hashes {
+		out.Write(objHash.Bytes())
+	}
+	write(crcs)
+	// Offsets that don't fit in 31 bits are stored in a table of 8-byte offsets
+	largeOffsets := make([]uint64, 0)
+	for _, offset := range offsets {
+		if offset < 0x80000000 {
+			write(uint32(offset))
+			continue
+		}
+		write(uint32(0x80000000 | len(largeOffsets)))
+		largeOffsets = append(largeOffsets, uint64(offset))
+	}
+	write(largeOffsets)
+	out.Write(packChecksum)
+	idx.Write(idxHash.Sum(nil))
+	return idx.Bytes(), nil
+}
@@ -0,0 +1,529 @@
+package repo
+
+import (
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Formatting commits for 'log', like git's --pretty, --format and --date
+
+// DateMode How dates are shown in logs
+type DateMode string
+
+const (
+	// DateDefault e.g. "Wed Nov 15 03:43:20 2023 +0530", in the timezone the date was made in
+	DateDefault DateMode = "default"
+	// DateRelative e.g. "2 hours ago"
+	DateRelative DateMode = "relative"
+	// DateLocal Like DateDefault, but in the local timezone
+	DateLocal DateMode = "local"
+	// DateISO e.g. "2023-11-15 03:43:20 +0530"
+	DateISO DateMode = "iso"
+	// DateISOStrict e.g. "2023-11-15T03:43:20+05:30"
+	DateISOStrict DateMode = "iso-strict"
+	// DateRFC e.g. "Wed, 15 Nov 2023 03:43:20 +0530"
+	DateRFC DateMode = "rfc"
+	// DateShort e.g. "2023-11-15"
+	DateShort DateMode = "short"
+	// DateRaw The Unix timestamp and the timezone, e.g. "1700000000 +0530"
+	DateRaw DateMode = "raw"
+	// DateUnix The Unix timestamp, e.g. "1700000000"
+	DateUnix DateMode = "unix"
+)
+
+// ErrUnknownDateMode Indicates that a date mode isn't supported
+type ErrUnknownDateMode struct {
+	mode string
+}
+
+func (e *ErrUnknownDateMode) Error() string {
+	return "unknown date format " + e.mode
+}
+
+// ErrUnknownFormat Indicates that a pretty format isn't one of the named formats or a format
+// string
+type ErrUnknownFormat struct {
+	name string
+}
+
+func (e *ErrUnknownFormat) Error() string {
+	return "invalid --pretty format: " + e.name
+}
+
+// ParseDateMode Parse the name of a date mode. git's other names for the modes ("iso8601",
+// "iso8601-strict" and "rfc2822") are accepted too
+func ParseDateMode(mode string) (DateMode, error) {
+	switch mode {
+	case "iso8601":
+		return DateISO, nil
+	case "iso8601-strict":
+		return DateISOStrict, nil
+	case "rfc2822":
+		return DateRFC, nil
+	}
+	switch DateMode(mode) {
+	case DateDefault, DateRelative, DateLocal, DateISO, DateISOStrict, DateRFC, DateShort, DateRaw, DateUnix:
+		return DateMode(mode), nil
+	}
+	return "", &ErrUnknownDateMode{mode: mode}
+}
+
+// FormatDate Show a date in the given mode. now is only used by DateRelative
+func FormatDate(when time.Time, mode DateMode, now time.Time) string {
+	switch mode {
+	case DateRelative:
+		return relativeDate(when, now)
+	case DateLocal:
+		return when.Local().Format("Mon Jan 2 15:04:05 2006")
+	case DateISO:
+		return when.Format("2006-01-02 15:04:05 -0700")
+	case DateISOStrict:
+		return when.Format("2006-01-02T15:04:05-07:00")
+	case DateRFC:
+		return when.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+	case DateShort:
+		return when.Format("2006-01-02")
+	case DateRaw:
+		return strconv.FormatInt(when.Unix(), 10) + when.Format(" -0700")
+	case DateUnix:
+		return strconv.FormatInt(when.Unix(), 10)
+	}
+	return when.Format("Mon Jan 2 15:04:05 2006 -0700")
+}
+
+// plural Show a count of a unit, e.g. "1 day" or "2 days"
+func plural(count int64, unit string) string {
+	if count == 1 {
+		return "1 " + unit
+	}
+	return strconv.FormatInt(count, 10) + " " + unit + "s"
+}
+
+// relativeDate Show how long before now a date is, rounded the same way as git
+func relativeDate(when time.Time, now time.Time) string {
+	diff := now.Unix() - when.Unix()
+	if diff < 0 {
+		return "in the future"
+	}
+	if diff < 90 {
+		return plural(diff, "second") + " ago"
+	}
+	// Minutes
+	diff = (diff + 30) / 60
+	if diff < 90 {
+		return plural(diff, "minute") + " ago"
+	}
+	// Hours
+	diff = (diff + 30) / 60
+	if diff < 36 {
+		return plural(diff, "hour") + " ago"
+	}
+	// Days
+	diff = (diff + 12) / 24
+	if diff < 14 {
+		return plural(diff, "day") + " ago"
+	}
+	if diff < 70 {
+		return plural((diff+3)/7, "week") + " ago"
+	}
+	if diff < 365 {
+		return plural((diff+15)/30, "month") + " ago"
+	}
+	// Years and months for about 5 years
+	if diff < 1825 {
+		totalMonths := (diff*12*2 + 365) / (365 * 2)
+		years, months := totalMonths/12, totalMonths%12
+		if months != 0 {
+			return plural(years, "year") + ", " + plural(months, "month") + " ago"
+		}
+		return plural(years, "year") + " ago"
+	}
+	return plural((diff+183)/365, "year") + " ago"
+}
+
+// LogFormat How a log shows each commit: one of git's named formats ("oneline", "short",
+// "medium", "full" and "fuller") or a format string with placeholders
+type LogFormat struct {
+	// name The named format, or empty for format strings
+	name   string
+	format string
+	// separate Whether entries are separated by newlines ('format:') instead of ending with one
+	// ('tformat:')
+	separate bool
+}
+
+// ParseLogFormat Parse a --pretty or --format value: a named format, "format:<string>",
+// "tformat:<string>" or a string with placeholders, which is treated like "tformat:". An empty
+// value is the default, "medium".
+//
+// Format strings may use these placeholders:
+//
+//	%H %h    commit hash, abbreviated hash
+//	%T %t    tree hash, abbreviated tree hash
+//	%P %p    parent hashes, abbreviated parent hashes
+//	%an %ae %al
+//	         author name, email and the part of the email before '@' (%c.. for the committer)
+//	%ad      author date in the log's date mode (%cd for the committer)
+//	%ar %at %ai %aI %as
+//	         author date: relative, Unix, ISO, strict ISO and short (%c.. for the committer)
+//	%s %b %B subject (the first paragraph of the message on one line), body, whole message
+//	%n %%    newline, '%'
+//	%xNN     the byte with the hex value NN
+//
+// Unknown placeholders are shown as they are
+func ParseLogFormat(pretty string) (*LogFormat, error) {
+	switch pretty {
+	case "":
+		return &LogFormat{name: "medium"}, nil
+	case "oneline", "short", "medium", "full", "fuller":
+		return &LogFormat{name: pretty}, nil
+	}
+	if strings.HasPrefix(pretty, "format:") {
+		return &LogFormat{format: strings.TrimPrefix(pretty, "format:"), separate: true}, nil
+	} else if strings.HasPrefix(pretty, "tformat:") {
+		return &LogFormat{format: strings.TrimPrefix(pretty, "tformat:")}, nil
+	} else if strings.Contains(pretty, "%") {
+		return &LogFormat{format: pretty}, nil
+	}
+	return nil, &ErrUnknownFormat{name: pretty}
+}
+
+// OneLine Whether the format shows commits on a single line
+func (format *LogFormat) OneLine() bool {
+	return format.name == "oneline"
+}
+
+// terminated Whether each entry ends with a newline, like oneline and 'tformat:', instead of
+// entries being separated by one
+func (format *LogFormat) terminated() bool {
+	if format.name != "" {
+		return format.name == "oneline"
+	}
+	return !format.separate
+}
+
+// LogOptions How LogPrinter shows commits
+type LogOptions struct {
+	// Format The format of each commit. The default is "medium"
+	Format *LogFormat
+	// Dates The date mode of %ad, %cd and the dates of named formats
+	Dates DateMode
+	// AbbrevCommit Abbreviate the commit hash in named formats, like --abbrev-commit
+	AbbrevCommit bool
+	// Graph Draw the history as a graph next to the commits. The commits should be listed in
+	// TopoOrder
+	Graph bool
+	// Now The time relative dates are relative to. The default is the current time
+	Now time.Time
+}
+
+// LogPrinter Writes commits to a log. Create one with Repo.NewLogPrinter
+type LogPrinter struct {
+	repo         *Repo
+	out          io.Writer
+	options      LogOptions
+	abbrevLength int
+	graph        *commitGraph
+	printed      int
+	// endedWithNewline Whether the last entry ended with a newline
+	endedWithNewline bool
+}
+
+// NewLogPrinter Create a LogPrinter that writes commits to out
+func (repo *Repo) NewLogPrinter(out io.Writer, options LogOptions) *LogPrinter {
+	if options.Format == nil {
+		options.Format = &LogFormat{name: "medium"}
+	}
+	if options.Dates == "" {
+		options.Dates = DateDefault
+	}
+	if options.Now.IsZero() {
+		options.Now = time.Now()
+	}
+	printer := &LogPrinter{repo: repo, out: out, options: options, abbrevLength: repo.AbbrevLength()}
+	if options.Graph {
+		printer.graph = &commitGraph{}
+	}
+	return printer
+}
+
+// Print Write a commit to the log. With a graph, a row of the graph is drawn before every line
+// except the empty line after a newline that ends an entry (like git)
+func (printer *LogPrinter) Print(hash objects.ObjectID, commit *objects.GitCommit) error {
+	entry, err := printer.FormatCommit(hash, commit)
+	if err != nil {
+		return err
+	}
+	terminated := printer.options.Format.terminated()
+	endsWithNewline := strings.HasSuffix(entry, "\n")
+	graph := printer.graph
+	if graph != nil {
+		graph.add(hash, commit.Parents())
+	}
+	var output strings.Builder
+	if printer.printed > 0 && !terminated {
+		// Without padding, the separator after an entry that ends with a newline would leave a
+		// gap in the graph
+		if graph != nil && printer.endedWithNewline {
+			output.WriteString(graph.paddingBefore())
+		}
+		output.WriteString("\n")
+	}
+	if graph == nil {
+		output.WriteString(entry)
+	} else {
+		// Rows that make room for the commit go on their own lines before the commit's row
+		row := graph.nextRow()
+		for !graph.shownCommit {
+			output.WriteString(row + "\n")
+			row = graph.nextRow()
+		}
+		output.WriteString(row)
+		for i, line := range strings.SplitAfter(entry, "\n") {
+			if i > 0 && line != "" {
+				output.WriteString(graph.nextRow())
+			}
+			output.WriteString(line)
+		}
+		// Draw the rows that connect the commit to its parents on their own lines
+		if !graph.finished() {
+			if !endsWithNewline {
+				output.WriteString("\n")
+			}
+			rows := make([]string, 0, 1)
+			for !graph.finished() {
+				rows = append(rows, graph.nextRow())
+			}
+			output.WriteString(strings.Join(rows, "\n"))
+			if endsWithNewline {
+				output.WriteString("\n")
+			}
+		}
+	}
+	if terminated {
+		if graph != nil && endsWithNewline {
+			output.WriteString(graph.nextRow())
+		}
+		output.WriteString("\n")
+	}
+	printer.printed++
+	printer.endedWithNewline = endsWithNewline
+	_, err = io.WriteString(printer.out, output.String())
+	return err
+}
+
+// abbrev Abbreviate a hash to the repo's abbreviation length
+func (printer *LogPrinter) abbrev(hash objects.ObjectID) (string, error) {
+	return printer.repo.ShortestAbbrev(hash, printer.abbrevLength)
+}
+
+// FormatCommit Show a commit in the printer's format, without the newline that ends or separates
+// entries
+func (printer *LogPrinter) FormatCommit(hash objects.ObjectID, commit *objects.GitCommit) (string, error) {
+	if printer.options.Format.name == "" {
+		return printer.expandFormat(printer.options.Format.format, hash, commit)
+	}
+	return printer.namedFormat(hash, commit)
+}
+
+// namedFormat Show a commit in one of the named formats
+func (printer *LogPrinter) namedFormat(hash objects.ObjectID, commit *objects.GitCommit) (string, error) {
+	name := printer.options.Format.name
+	hashString := hash.String()
+	if printer.options.AbbrevCommit {
+		var err error
+		hashString, err = printer.abbrev(hash)
+		if err != nil {
+			return "", err
+		}
+	}
+	if name == "oneline" {
+		return hashString + " " + commitSubject(commit.Msg), nil
+	}
+	var builder strings.Builder
+	builder.WriteString("commit " + hashString + "\n")
+	if parents := commit.Parents(); len(parents) > 1 {
+		abbrevs := make([]string, 0, len(parents))
+		for _, parent := range parents {
+			abbrev, err := printer.abbrev(parent)
+			if err != nil {
+				return "", err
+			}
+			abbrevs = append(abbrevs, abbrev)
+		}
+		builder.WriteString("Merge: " + strings.Join(abbrevs, " ") + "\n")
+	}
+	author, committer := commit.Author(), commit.Committer()
+	dates := printer.options.Dates
+	now := printer.options.Now
+	switch name {
+	case "short":
+		builder.WriteString("Author: " + signatureName(author) + "\n")
+	case "medium":
+		builder.WriteString("Author: " + signatureName(author) + "\n")
+		builder.WriteString("Date:   " + FormatDate(author.When, dates, now) + "\n")
+	case "full":
+		builder.WriteString("Author: " + signatureName(author) + "\n")
+		builder.WriteString("Commit: " + signatureName(committer) + "\n")
+	case "fuller":
+		builder.WriteString("Author:     " + signatureName(author) + "\n")
+		builder.WriteString("AuthorDate: " + FormatDate(author.When, dates, now) + "\n")
+		builder.WriteString("Commit:     " + signatureName(committer) + "\n")
+		builder.WriteString("CommitDate: " + FormatDate(committer.When, dates, now) + "\n")
+	}
+	msg := commit.Msg
+	if name == "short" {
+		msg = commitSubject(msg)
+	}
+	if msg != "" {
+		builder.WriteString("\n    " + strings.ReplaceAll(msg, "\n", "\n    ") + "\n")
+	}
+	return builder.String(), nil
+}
+
+// signatureName Show the name and email of a signature like git: "name <email>"
+func signatureName(signature objects.Signature) string {
+	return signature.Name + " <" + signature.Email + ">"
+}
+
+// commitSubject The first paragraph of a commit message, joined into one line
+func commitSubject(msg string) string {
+	lines := make([]string, 0, 1)
+	for _, line := range strings.Split(strings.TrimLeft(msg, "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, " ")
+}
+
+// commitBody The message after the first paragraph, ending with a newline, or empty if there
+// is none
+func commitBody(msg string) string {
+	msg = strings.TrimLeft(msg, "\n")
+	end := strings.Index(msg, "\n\n")
+	if end == -1 {
+		return ""
+	}
+	body := strings.Trim(msg[end:], "\n")
+	if body == "" {
+		return ""
+	}
+	return body + "\n"
+}
+
+// expandFormat Replace the placeholders of a format string with the details of a commit
+func (printer *LogPrinter) expandFormat(format string, hash objects.ObjectID, commit *objects.GitCommit) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			builder.WriteByte(format[i])
+			continue
+		}
+		value, length, err := printer.placeholder(format[i+1:], hash, commit)
+		if err != nil {
+			return "", err
+		}
+		if length == 0 {
+			// Unknown placeholders are kept
+			builder.WriteByte('%')
+			continue
+		}
+		builder.WriteString(value)
+		i += length
+	}
+	return builder.String(), nil
+}
+
+// placeholder Expand the placeholder at the start of spec (the text after a '%'). Returns the
+// value and the length of the placeholder, which is 0 if it is unknown
+func (printer *LogPrinter) placeholder(spec string, hash objects.ObjectID, commit *objects.GitCommit) (string, int, error) {
+	switch spec[0] {
+	case 'H':
+		return hash.String(), 1, nil
+	case 'h':
+		abbrev, err := printer.abbrev(hash)
+		return abbrev, 1, err
+	case 'T':
+		return commit.TreeHash.String(), 1, nil
+	case 't':
+		abbrev, err := printer.abbrev(commit.TreeHash)
+		return abbrev, 1, err
+	case 'P', 'p':
+		parents := make([]string, 0, len(commit.Parents()))
+		for _, parent := range commit.Parents() {
+			value := parent.String()
+			if spec[0] == 'p' {
+				var err error
+				value, err = printer.abbrev(parent)
+				if err != nil {
+					return "", 0, err
+				}
+			}
+			parents = append(parents, value)
+		}
+		return strings.Join(parents, " "), 1, nil
+	case 's':
+		return commitSubject(commit.Msg), 1, nil
+	case 'b':
+		return commitBody(commit.Msg), 1, nil
+	case 'B':
+		return commit.Msg + "\n", 1, nil
+	case 'n':
+		return "\n", 1, nil
+	case '%':
+		return "%", 1, nil
+	case 'x':
+		if len(spec) >= 3 {
+			if value, err := hex.DecodeString(spec[1:3]); err == nil {
+				return string(value), 3, nil
+			}
+		}
+	case 'a', 'c':
+		if len(spec) < 2 {
+			return "", 0, nil
+		}
+		signature := commit.Author()
+		if spec[0] == 'c' {
+			signature = commit.Committer()
+		}
+		value, ok := printer.signaturePlaceholder(spec[1], signature)
+		if ok {
+			return value, 2, nil
+		}
+	}
+	return "", 0, nil
+}
+
+// signaturePlaceholder Expand the part of an author (%a) or committer (%c) placeholder after
+// its first letter
+func (printer *LogPrinter) signaturePlaceholder(field byte, signature objects.Signature) (string, bool) {
+	switch field {
+	case 'n':
+		return signature.Name, true
+	case 'e':
+		return signature.Email, true
+	case 'l':
+		return strings.SplitN(signature.Email, "@", 2)[0], true
+	case 'd':
+		return FormatDate(signature.When, printer.options.Dates, printer.options.Now), true
+	case 'r':
+		return FormatDate(signature.When, DateRelative, printer.options.Now), true
+	case 't':
+		return FormatDate(signature.When, DateUnix, printer.options.Now), true
+	case 'i':
+		return FormatDate(signature.When, DateISO, printer.options.Now), true
+	case 'I':
+		return FormatDate(signature.When, DateISOStrict, printer.options.Now), true
+	case 's':
+		return FormatDate(signature.When, DateShort, printer.options.Now), true
+	}
+	return "", false
+}
@@ -0,0 +1,330 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Show the first limit commits of a walk (or all of them if limit is 0) with a LogPrinter
+func printLog(t *testing.T, repoStruct *Repo, options LogOptions, order WalkOrder, start objects.ObjectID, limit int) string {
+	var output strings.Builder
+	printer := repoStruct.NewLogPrinter(&output, options)
+	walker, err := repoStruct.WalkCommits(order, start)
+	if err != nil {
+		t.Fatalf("Error starting walk: %s", err)
+	}
+	for i := 0; limit == 0 || i < limit; i++ {
+		hash, commit, err := walker.Next()
+		if err != nil {
+			break
+		}
+		err = printer.Print(hash, commit)
+		if err != nil {
+			t.Fatalf("Error printing %s: %s", hash, err)
+		}
+	}
+	return output.String()
+}
+
+// Parse a log format, failing the test if it is invalid
+func mustParseLogFormat(t *testing.T, pretty string) *LogFormat {
+	format, err := ParseLogFormat(pretty)
+	if err != nil {
+		t.Fatalf("Error parsing format '%s': %s", pretty, err)
+	}
+	return format
+}
+
+// Test each date mode, using output from git
+func TestFormatDate(t *testing.T) {
+	when := time.Unix(1700000000, 0).In(time.FixedZone("", 5*3600+30*60))
+	expected := map[DateMode]string{
+		DateDefault:   "Wed Nov 15 03:43:20 2023 +0530",
+		DateISO:       "2023-11-15 03:43:20 +0530",
+		DateISOStrict: "2023-11-15T03:43:20+05:30",
+		DateRFC:       "Wed, 15 Nov 2023 03:43:20 +0530",
+		DateShort:     "2023-11-15",
+		DateRaw:       "1700000000 +0530",
+		DateUnix:      "1700000000",
+		DateLocal:     when.Local().Format("Mon Jan 2 15:04:05 2006"),
+	}
+	for mode, date := range expected {
+		if found := FormatDate(when, mode, when); found != date {
+			t.Errorf("Expected %s date %s, Got: %s", mode, date, found)
+		}
+	}
+	if mode, err := ParseDateMode("iso8601"); err != nil || mode != DateISO {
+		t.Errorf("Expected iso8601 to be DateISO, Got: %s, %v", mode, err)
+	}
+	if _, err := ParseDateMode("bogus"); err == nil {
+		t.Errorf("Expected an error for an unknown date mode")
+	}
+}
+
+// Test that relative dates are rounded like git's
+func TestRelativeDate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expected := map[time.Duration]string{
+		-time.Second:                  "in the future",
+		0:                             "0 seconds ago",
+		time.Second:                   "1 second ago",
+		89 * time.Second:              "89 seconds ago",
+		90 * time.Second:              "2 minutes ago",
+		89 * time.Minute:              "89 minutes ago",
+		90 * time.Minute:              "2 hours ago",
+		35 * time.Hour:                "35 hours ago",
+		36 * time.Hour:                "2 days ago",
+		13 * 24 * time.Hour:           "13 days ago",
+		14 * 24 * time.Hour:           "2 weeks ago",
+		70 * 24 * time.Hour:           "2 months ago",
+		364 * 24 * time.Hour:          "12 months ago",
+		365 * 24 * time.Hour:          "1 year ago",
+		(365 + 45) * 24 * time.Hour:   "1 year, 1 month ago",
+		(3*365 + 90) * 24 * time.Hour: "3 years, 3 months ago",
+		10 * 365 * 24 * time.Hour:     "10 years ago",
+	}
+	for ago, date := range expected {
+		if found := FormatDate(now.Add(-ago), DateRelative, now); found != date {
+			t.Errorf("Expected %s for %s, Got: %s", date, ago, found)
+		}
+	}
+}
+
+// Test the named formats, using output from git for the same commits
+func TestNamedFormats(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	history := saveMergeHistory(t, repoStruct)
+	sideAbbrev, mainAbbrev := history.side.Abbrev(7), history.main.Abbrev(7)
+	expected := map[string]string{
+		"oneline": history.merge.String() + " merge\n" + history.main.String() + " main\n",
+		"short": "commit " + history.merge.String() + "\n" +
+			"Merge: " + mainAbbrev + " " + sideAbbrev + "\n" +
+			"Author: A U Thor <author@example.com>\n\n    merge\n\n" +
+			"commit " + history.main.String() + "\n" +
+			"Author: A U Thor <author@example.com>\n\n    main\n",
+		"medium": "commit " + history.merge.String() + "\n" +
+			"Merge: " + mainAbbrev + " " + sideAbbrev + "\n" +
+			"Author: A U Thor <author@example.com>\n" +
+			"Date:   Tue Nov 14 22:18:20 2023 +0000\n\n    merge\n\n" +
+			"commit " + history.main.String() + "\n" +
+			"Author: A U Thor <author@example.com>\n" +
+			"Date:   Tue Nov 14 22:16:40 2023 +0000\n\n    main\n",
+		"full": "commit " + history.merge.String() + "\n" +
+			"Merge: " + mainAbbrev + " " + sideAbbrev + "\n" +
+			"Author: A U Thor <author@example.com>\n" +
+			"Commit: C O Mitter <committer@example.com>\n\n    merge\n\n" +
+			"commit " + history.main.String() + "\n" +
+			"Author: A U Thor <author@example.com>\n" +
+			"Commit: C O Mitter <committer@example.com>\n\n    main\n",
+		"fuller": "commit " + history.merge.String() + "\n" +
+			"Merge: " + mainAbbrev + " " + sideAbbrev + "\n" +
+			"Author:     A U Thor <author@example.com>\n" +
+			"AuthorDate: Tue Nov 14 22:18:20 2023 +0000\n" +
+			"Commit:     C O Mitter <committer@example.com>\n" +
+			"CommitDate: Tue Nov 14 22:18:20 2023 +0000\n\n    merge\n\n" +
+			"commit " + history.main.String() + "\n" +
+			"Author:     A U Thor <author@example.com>\n" +
+			"AuthorDate: Tue Nov 14 22:16:40 2023 +0000\n" +
+			"Commit:     C O Mitter <committer@example.com>\n" +
+			"CommitDate: Tue Nov 14 22:16:40 2023 +0000\n\n    main\n",
+	}
+	for name, log := range expected {
+		options := LogOptions{Format: mustParseLogFormat(t, name)}
+		// Only show the merge and the main commit
+		if found := printLog(t, repoStruct, options, DateOrder, history.merge, 2); found != log {
+			t.Errorf("Expected %s log:\n%s\nGot:\n%s", name, log, found)
+		}
+	}
+	options := LogOptions{Format: mustParseLogFormat(t, "oneline"), AbbrevCommit: true}
+	expectedLog := history.merge.Abbrev(7) + " merge\n" + mainAbbrev + " main\n" +
+		sideAbbrev + " side\n" + history.base.Abbrev(7) + " base\n"
+	if found := printLog(t, repoStruct, options, DateOrder, history.merge, 0); found != expectedLog {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expectedLog, found)
+	}
+	if _, err := ParseLogFormat("bogus"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+// Test the placeholders of format strings
+func TestFormatString(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	base := saveTestCommit(t, repoStruct, "base", 1700000000)
+	hash := saveTestCommit(t, repoStruct, "side one\ncontinued\n\nbody para\n  indented", 1700000100, base)
+	commit, err := repoStruct.readCommit(hash)
+	if err != nil {
+		t.Fatalf("Error reading commit: %s", err)
+	}
+	now := time.Unix(1700000100+7200, 0)
+	expected := map[string]string{
+		"%H":               hash.String(),
+		"%h %p":            hash.Abbrev(7) + " " + base.Abbrev(7),
+		"%T %t":            commit.TreeHash.String() + " 4b825dc",
+		"%P":               base.String(),
+		"%an <%ae> %al":    "A U Thor <author@example.com> author",
+		"%cn <%ce> %cl":    "C O Mitter <committer@example.com> committer",
+		"%ad|%ar|%at":      "Tue Nov 14 22:15:00 2023 +0000|2 hours ago|1700000100",
+		"%ci|%cI|%cs":      "2023-11-14 22:15:00 +0000|2023-11-14T22:15:00+00:00|2023-11-14",
+		"[%s]":             "[side one continued]",
+		"[%b]":             "[body para\n  indented\n]",
+		"[%B]":             "[side one\ncontinued\n\nbody para\n  indented\n]",
+		"%n%%%x41":         "\n%A",
+		"%q %a %x4 %az %":  "%q %a %x4 %az %",
+		"format:no fields": "no fields",
+	}
+	for format, value := range expected {
+		printer := repoStruct.NewLogPrinter(nil, LogOptions{Format: mustParseLogFormat(t, format), Now: now})
+		found, err := printer.FormatCommit(hash, commit)
+		if err != nil || found != value {
+			t.Errorf("Expected '%s' to give '%s', Got: '%s', %v", format, value, found, err)
+		}
+	}
+	// 'format:' separates entries and 'tformat:' ends them with a newline
+	options := LogOptions{Format: mustParseLogFormat(t, "format:%s")}
+	if found := printLog(t, repoStruct, options, DateOrder, hash, 0); found != "side one continued\nbase" {
+		t.Errorf("Expected entries to be separated, Got: %q", found)
+	}
+	options = LogOptions{Format: mustParseLogFormat(t, "tformat:%s")}
+	if found := printLog(t, repoStruct, options, DateOrder, hash, 0); found != "side one continued\nbase\n" {
+		t.Errorf("Expected entries to be terminated, Got: %q", found)
+	}
+}
+
+// Test drawing graphs, using output from git for the same histories
+func TestLogGraph(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	history := saveMergeHistory(t, repoStruct)
+	options := LogOptions{Format: mustParseLogFormat(t, "%s"), Graph: true}
+	expected := "*   merge\n" +
+		"|\\  \n" +
+		"| * side\n" +
+		"* | main\n" +
+		"|/  \n" +
+		"* base\n"
+	if found := printLog(t, repoStruct, options, TopoOrder, history.merge, 0); found != expected {
+		t.Errorf("Expected graph:\n%s\nGot:\n%s", expected, found)
+	}
+	// Lines after the first are drawn with the rows that connect the commit to its parents,
+	// and the blank lines between commits are padded
+	options = LogOptions{Format: mustParseLogFormat(t, "short"), AbbrevCommit: true, Graph: true}
+	mainAbbrev, sideAbbrev := history.main.Abbrev(7), history.side.Abbrev(7)
+	expected = "*   commit " + history.merge.Abbrev(7) + "\n" +
+		"|\\  Merge: " + mainAbbrev + " " + sideAbbrev + "\n" +
+		"| | Author: A U Thor <author@example.com>\n" +
+		"| | \n" +
+		"| |     merge\n" +
+		"| | \n" +
+		"| * commit " + sideAbbrev + "\n" +
+		"| | Author: A U Thor <author@example.com>\n" +
+		"| | \n" +
+		"| |     side\n" +
+		"| | \n" +
+		"* | commit " + mainAbbrev + "\n" +
+		"|/  Author: A U Thor <author@example.com>\n" +
+		"|   \n" +
+		"|       main\n" +
+		"| \n" +
+		"* commit " + history.base.Abbrev(7) + "\n" +
+		"  Author: A U Thor <author@example.com>\n" +
+		"  \n" +
+		"      base\n"
+	if found := printLog(t, repoStruct, options, TopoOrder, history.merge, 0); found != expected {
+		t.Errorf("Expected graph:\n%s\nGot:\n%s", expected, found)
+	}
+}
+
+// Test joining lines that cross other lines
+func TestGraphJoin(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	// Two branches off a base are merged, then the base is merged into the merge's side branch
+	base := saveTestCommit(t, repoStruct, "base", 1700000000)
+	a1 := saveTestCommit(t, repoStruct, "a1", 1700000100, base)
+	b1 := saveTestCommit(t, repoStruct, "b1", 1700000200, base)
+	m1 := saveTestCommit(t, repoStruct, "m1", 1700000300, base)
+	b2 := saveTestCommit(t, repoStruct, "b2", 1700000400, b1, m1)
+	top := saveTestCommit(t, repoStruct, "top", 1700000500, a1, b2)
+	options := LogOptions{Format: mustParseLogFormat(t, "%s"), Graph: true}
+	// Output of 'git log --graph --format=%s' for the same history
+	expected := "*   top\n" +
+		"|\\  \n" +
+		"| *   b2\n" +
+		"| |\\  \n" +
+		"| | * m1\n" +
+		"| * | b1\n" +
+		"| |/  \n" +
+		"* / a1\n" +
+		"|/  \n" +
+		"* base\n"
+	if found := printLog(t, repoStruct, options, TopoOrder, top, 0); found != expected {
+		t.Errorf("Expected graph:\n%s\nGot:\n%s", expected, found)
+	}
+}
+
+func TestGraphMergeIntoOpenColumn(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	// m2's first parent is also waiting in the column to its right, which stays open past m1
+	base := saveTestCommit(t, repoStruct, "base", 1700000000)
+	b1 := saveTestCommit(t, repoStruct, "b1", 1700000100, base)
+	m1 := saveTestCommit(t, repoStruct, "m1", 1700000200, b1, base)
+	m2 := saveTestCommit(t, repoStruct, "m2", 1700000300, m1, b1)
+	top := saveTestCommit(t, repoStruct, "top", 1700000400, m1, m2)
+	options := LogOptions{Format: mustParseLogFormat(t, "%s"), Graph: true}
+	// Output of 'git log --graph --format=%s' for the same history
+	expected := "*   top\n" +
+		"|\\  \n" +
+		"| * m2\n" +
+		"|/| \n" +
+		"* |   m1\n" +
+		"|\\ \\  \n" +
+		"| |/  \n" +
+		"|/|   \n" +
+		"* | b1\n" +
+		"|/  \n" +
+		"* base\n"
+	if found := printLog(t, repoStruct, options, TopoOrder, top, 0); found != expected {
+		t.Errorf("Expected graph:\n%s\nGot:\n%s", expected, found)
+	}
+}
+
+func TestGraphOctopus(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	base := saveTestCommit(t, repoStruct, "base", 1700000000)
+	c1 := saveTestCommit(t, repoStruct, "c1", 1700000100, base)
+	c2 := saveTestCommit(t, repoStruct, "c2", 1700000200, base)
+	c3 := saveTestCommit(t, repoStruct, "c3", 1700000300, base)
+	octopus := saveTestCommit(t, repoStruct, "octopus", 1700000400, c1, c2, c3)
+	options := LogOptions{Format: mustParseLogFormat(t, "%s"), Graph: true}
+	// Output of 'git log --graph --format=%s' for the same history
+	expected := "*-.   octopus\n" +
+		"|\\ \\  \n" +
+		"| | * c3\n" +
+		"| * | c2\n" +
+		"| |/  \n" +
+		"* / c1\n" +
+		"|/  \n" +
+		"* base\n"
+	if found := printLog(t, repoStruct, options, TopoOrder, octopus, 0); found != expected {
+		t.Errorf("Expected graph:\n%s\nGot:\n%s", expected, found)
+	}
+}
@@ -4,11 +4,22 @@ package repo
 import (
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
 )
 
+// ErrBadPackedRefs Indicates that a line of the packed-refs file couldn't be parsed
+type ErrBadPackedRefs struct {
+	line string
+}
+
+func (e *ErrBadPackedRefs) Error() string {
+	return "Bad line in packed-refs: " + e.line
+}
+
 type ErrTagAlreadyExists struct {
 	name string
 }
@@ -17,21 +28,40 @@ func (e *ErrTagAlreadyExists) Error() string {
 	return "refs/tags/" + e.name + " already exists"
 }
 
+// ErrBadRef Indicates that a ref doesn't hold a valid hash
+type ErrBadRef struct {
+	ref   string
+	value string
+}
+
+func (e *ErrBadRef) Error() string {
+	return e.ref + " does not point to a valid object: " + e.value
+}
+
 // Plumbing function; find the hash a 'ref' refers too;
 // usually this is simply finding the ref file and reading the hash
 // The function may also recursively call itself in the case where refs point to other
-// refs
-func readRef(gitDir string, refPath string) (string, error) {
-	refData, err := os.ReadFile(path.Join(gitDir, refPath))
-	if err != nil {
+// refs. Refs without a file are looked up in the packed-refs file
+func readRef(fsys vfs.FS, gitDir string, refPath string) (string, error) {
+	refData, err := fsys.ReadFile(path.Join(gitDir, refPath))
+	if os.IsNotExist(err) {
+		packed, _, packedErr := readPackedRefs(fsys, gitDir)
+		if packedErr != nil {
+			return "", packedErr
+		}
+		if hash, ok := packed[refPath]; ok {
+			return hash, nil
+		}
+		return "", err
+	} else if err != nil {
 		return "", err
 	}
-	ref := string(refData)
+	ref := strings.TrimSpace(string(refData))
 
 	// This ref is a ref to another ref
 	if isRef(ref) {
 		newRefPath := strings.TrimPrefix(ref, "ref: ")
-		return readRef(gitDir, newRefPath)
+		return readRef(fsys, gitDir, newRefPath)
 	}
 	return ref, nil
 }
@@ -42,15 +72,19 @@ func isRef(unknown string) bool {
 	return strings.HasPrefix(unknown, "ref: ")
 }
 
-// Find all the refs in a repo and the hashes of what they are pointing to
-func findAllRefs(gitDir string) (map[string]string, error) {
-	refs, err := recursiveFindFiles(gitDir, "refs")
+// Find all the refs in a repo and the hashes of what they are pointing to. Loose refs take
+// precedence over those in packed-refs
+func findAllRefs(fsys vfs.FS, gitDir string) (map[string]string, error) {
+	refMap, _, err := readPackedRefs(fsys, gitDir)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := recursiveFindFiles(fsys, gitDir, "refs")
 	if err != nil {
 		return nil, err
 	}
-	refMap := make(map[string]string)
 	for _, refPath := range refs {
-		ref, err := readRef(gitDir, refPath)
+		ref, err := readRef(fsys, gitDir, refPath)
 		if err != nil {
 			refMap[refPath] = "Error reading ref"
 		} else {
@@ -60,11 +94,51 @@ func findAllRefs(gitDir string) (map[string]string, error) {
 	return refMap, nil
 }
 
+// readPackedRefs Read the packed-refs file, which holds refs that were packed into a single
+// file (by 'git pack-refs' or gc). Returns the refs and, for annotated tags, the object the tag
+// points to ('peeled'). A missing file has no refs
+func readPackedRefs(fsys vfs.FS, gitDir string) (map[string]string, map[string]string, error) {
+	refs := make(map[string]string)
+	peeled := make(map[string]string)
+	data, err := fsys.ReadFile(path.Join(gitDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return refs, peeled, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	lastRef := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		// The first line is a comment listing the traits of the file
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// A '^' line holds the peeled value of the tag on the line before it
+		if strings.HasPrefix(line, "^") {
+			if lastRef == "" {
+				return nil, nil, &ErrBadPackedRefs{line: line}
+			}
+			peeled[lastRef] = line[1:]
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, nil, &ErrBadPackedRefs{line: line}
+		}
+		if _, err := objects.ParseObjectID(fields[0]); err != nil {
+			return nil, nil, &ErrBadPackedRefs{line: line}
+		}
+		refs[fields[1]] = fields[0]
+		lastRef = fields[1]
+	}
+	return refs, peeled, nil
+}
+
 // Returns the name of all files in root/subpath and nested directories prefixed with subpath
 // for example, the dir /root/refs/heads which contains main and /branch/feature and where
 // the root is /root would return refs/heads/main and refs/head/feature
-func recursiveFindFiles(root string, subpath string) ([]string, error) {
-	entries, err := os.ReadDir(path.Join(root, subpath))
+func recursiveFindFiles(fsys vfs.FS, root string, subpath string) ([]string, error) {
+	entries, err := fsys.ReadDir(path.Join(root, subpath))
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +148,7 @@ func recursiveFindFiles(root string, subpath string) ([]string, error) {
 		// If Error is found in the recursive calls, the list so far and the Error is returned
 		if entry.IsDir() {
 			newSubpath := path.Join(subpath, entry.Name())
-			recursiveRefs, err := recursiveFindFiles(root, newSubpath)
+			recursiveRefs, err := recursiveFindFiles(fsys, root, newSubpath)
 			files = append(files, recursiveRefs...)
 			// Return results so far if Error is nil
 			if err != nil {
@@ -87,39 +161,51 @@ func recursiveFindFiles(root string, subpath string) ([]string, error) {
 	return files, nil
 }
 
-// FindRef Find a ref in a repo. Simply calls the readRef function defined in refs.go
-func (repo *Repo) FindRef(refPath string) (string, error) {
-	if isRef(refPath) {
-		return readRef(repo.GitDir, strings.TrimPrefix(refPath, "ref: "))
+// FindRef Find a ref in a repo and the object it points to. Calls the readRef function defined
+// in refs.go; a ref that doesn't hold a valid hash is an ErrBadRef
+func (repo *Repo) FindRef(refPath string) (objects.ObjectID, error) {
+	refPath = strings.TrimPrefix(refPath, "ref: ")
+	value, err := readRef(repo.FS(), repo.GitDir, refPath)
+	if err != nil {
+		return objects.ObjectID{}, err
 	}
-	return readRef(repo.GitDir, refPath)
+	return repo.parseRefValue(refPath, value)
+}
+
+// parseRefValue Parse the hash held by a ref
+func (repo *Repo) parseRefValue(refPath string, value string) (objects.ObjectID, error) {
+	hash, err := repo.HashAlgorithm().ParseID(value)
+	if err != nil {
+		return objects.ObjectID{}, &ErrBadRef{ref: refPath, value: value}
+	}
+	return hash, nil
 }
 
 // GetAllRefs Return a map off all refs and what they point to as a key-value pair, respectively.
+// The values are what the ref files hold, which may not be valid hashes if a ref is broken.
 // Calls findAllRefs defined in refs.go
 func (repo *Repo) GetAllRefs() (map[string]string, error) {
-	return findAllRefs(repo.GitDir)
+	return findAllRefs(repo.FS(), repo.GitDir)
 }
 
 // SaveTag Saves a tag with the given 'name' that points the object of the corresponding hash
 // This function does not verify that the hash is valid, that is the caller's responsibility
 // An Error is thrown if the tag already exists
-func (repo *Repo) SaveTag(name string, hash string) error {
-	tagsDir := path.Join(repo.GitDir, "refs", "tags")
-	// Check that the tag doesn't already exist
-	entries, err := os.ReadDir(tagsDir)
-	if err != nil {
-		return err
-	}
-	if exists(entries, name) {
+func (repo *Repo) SaveTag(name string, hash objects.ObjectID) error {
+	tagRef := path.Join("refs", "tags", name)
+	// Check that the tag doesn't already exist, either as a file or in packed-refs
+	_, err := readRef(repo.FS(), repo.GitDir, tagRef)
+	if err == nil {
 		return &ErrTagAlreadyExists{name: name}
+	} else if !os.IsNotExist(err) {
+		return err
 	}
-	file, err := os.Create(path.Join(tagsDir, name))
+	file, err := vfs.Create(repo.FS(), path.Join(repo.GitDir, tagRef))
 	if err != nil {
 		return err
 	}
-	_, err = file.WriteString(hash)
-	defer func(file *os.File) {
+	_, err = file.Write([]byte(hash.String()))
+	defer func(file vfs.File) {
 		err := file.Close()
 		if err != nil {
 		}
@@ -127,40 +213,151 @@ func (repo *Repo) SaveTag(name string, hash string) error {
 	return err
 }
 
-// DeleteTag Deletes a tag from the list of tags.
-// If the tag points to a tag object, delete that too
+// DeleteTag Deletes a tag from the list of tags. Like git, a tag object the tag pointed to is
+// left for prune and gc to remove once nothing else reaches it
 func (repo *Repo) DeleteTag(name string) error {
-	tagsDir := path.Join(repo.GitDir, "refs", "tags")
-	entries, err := os.ReadDir(tagsDir)
+	tagRef := path.Join("refs", "tags", name)
+	_, err := repo.FindRef(tagRef)
+	if os.IsNotExist(err) {
+		// Tag doesn't exist
+		return &ErrObjectNotFound{query: name}
+	} else if err != nil {
+		return err
+	}
+	return repo.deleteRef(tagRef)
+}
+
+// deleteRef Remove a ref, both its file and its entry in packed-refs
+func (repo *Repo) deleteRef(refPath string) error {
+	err := repo.FS().Remove(path.Join(repo.GitDir, refPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	packed, peeled, err := readPackedRefs(repo.FS(), repo.GitDir)
+	if err != nil {
+		return err
+	}
+	if _, ok := packed[refPath]; !ok {
+		return nil
+	}
+	delete(packed, refPath)
+	delete(peeled, refPath)
+	return repo.writePackedRefs(packed, peeled)
+}
+
+// writePackedRefs Write the packed-refs file. peeled holds the objects annotated tags point to
+func (repo *Repo) writePackedRefs(refs map[string]string, peeled map[string]string) error {
+	var builder strings.Builder
+	builder.WriteString("# pack-refs with: peeled fully-peeled sorted \n")
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		builder.WriteString(refs[name] + " " + name + "\n")
+		if target, ok := peeled[name]; ok {
+			builder.WriteString("^" + target + "\n")
+		}
+	}
+	// Write to a temporary file first so readers never see a partially written file
+	packedPath := path.Join(repo.GitDir, "packed-refs")
+	tmpPath := packedPath + ".lock"
+	err := repo.FS().WriteFile(tmpPath, []byte(builder.String()), NormalFilemode)
+	if err != nil {
+		return err
+	}
+	return repo.FS().Rename(tmpPath, packedPath)
+}
+
+// PackRefs Move every ref under refs/ into the packed-refs file and remove their files, like
+// 'git pack-refs --all --prune'. Annotated tags are stored with the object they point to
+func (repo *Repo) PackRefs() error {
+	refs, err := repo.GetAllRefs()
 	if err != nil {
 		return err
 	}
-	if exists(entries, name) {
-		tagPath := path.Join(tagsDir, name)
-		contents, err := os.ReadFile(tagPath)
+	looseRefs, err := recursiveFindFiles(repo.FS(), repo.GitDir, "refs")
+	if err != nil {
+		return err
+	}
+	peeled := make(map[string]string)
+	for name, value := range refs {
+		hash, err := repo.parseRefValue(name, value)
 		if err != nil {
 			return err
 		}
-		obj, err := repo.GetObject(string(contents))
+		if !strings.HasPrefix(name, "refs/tags/") {
+			continue
+		}
+		objType, _, err := repo.ReadRawObject(hash)
 		if err != nil {
 			return err
 		}
-		// If the tag reference points to a tag object, delete the object too
-		if obj.Type() == objects.Tag {
-			err = repo.DeleteObject(objects.Hash(obj))
+		if objType == objects.Tag {
+			target, err := repo.peelTag(hash)
 			if err != nil {
 				return err
 			}
+			peeled[name] = target.String()
 		}
-		return os.Remove(tagPath)
-	} else {
-		// Tag doesn't exist
-		return &ErrObjectNotFound{query: name}
 	}
+	err = repo.writePackedRefs(refs, peeled)
+	if err != nil {
+		return err
+	}
+	for _, refPath := range looseRefs {
+		err = repo.FS().Remove(path.Join(repo.GitDir, refPath))
+		if err != nil {
+			return err
+		}
+	}
+	return repo.removeEmptyRefDirs("refs", true)
+}
+
+// peelTag Follow tag objects until an object that isn't a tag is found
+func (repo *Repo) peelTag(hash objects.ObjectID) (objects.ObjectID, error) {
+	for {
+		obj, err := repo.GetObject(hash)
+		if err != nil {
+			return objects.ObjectID{}, err
+		}
+		tag, ok := obj.(*objects.GitTag)
+		if !ok {
+			return hash, nil
+		}
+		_, hash = tag.Target()
+	}
+}
+
+// removeEmptyRefDirs Remove the empty directories inside dir (relative to the git dir).
+// refs/heads and refs/tags are always kept; top is set for the first call
+func (repo *Repo) removeEmptyRefDirs(dir string, top bool) error {
+	entries, err := repo.FS().ReadDir(path.Join(repo.GitDir, dir))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		err = repo.removeEmptyRefDirs(path.Join(dir, entry.Name()), false)
+		if err != nil {
+			return err
+		}
+	}
+	if top || dir == "refs/heads" || dir == "refs/tags" {
+		return nil
+	}
+	entries, err = repo.FS().ReadDir(path.Join(repo.GitDir, dir))
+	if err != nil || len(entries) > 0 {
+		return err
+	}
+	return repo.FS().Remove(path.Join(repo.GitDir, dir))
 }
 
 // Update a branch ref to a new hash
-func (repo *Repo) updateBranchRef(branch string, hash string) error {
+func (repo *Repo) updateBranchRef(branch string, hash objects.ObjectID) error {
 	branchRef := path.Join(repo.GitDir, "refs", "heads", branch)
-	return os.WriteFile(branchRef, []byte(hash), NormalFilemode)
+	return repo.FS().WriteFile(branchRef, []byte(hash.String()), NormalFilemode)
 }
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
 	"os"
 	"path"
 	"testing"
@@ -94,7 +95,7 @@ func TestFindAllRefs(t *testing.T) {
 		t.Fatalf("Error creating sample ref directories:\n%s", err.Error())
 	}
 
-	refMap, err := findAllRefs(gitDir)
+	refMap, err := findAllRefs(vfs.OS, gitDir)
 	if err != nil {
 		t.Fatalf("Unexpected Error scanning for refs:\n%s", err.Error())
 	}
@@ -134,28 +135,28 @@ func TestReadRef(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error creating sample directory and references:\n%s", err.Error())
 	}
-	firstRef, err := readRef(tmpDir, firstRefPath)
+	firstRef, err := readRef(vfs.OS, tmpDir, firstRefPath)
 	if err != nil {
 		t.Errorf("Unexpected Error when reading %s:\n%s", firstRefPath, err.Error())
 	} else if firstRef != "firstref" {
 		t.Errorf("Expected %s to contain %s. Got: %s", firstRefPath, "firstref", firstRef)
 	}
 
-	secondRef, err := readRef(tmpDir, secondRefPath)
+	secondRef, err := readRef(vfs.OS, tmpDir, secondRefPath)
 	if err != nil {
 		t.Errorf("Unexpected Error when reading %s:\n%s", secondRefPath, err.Error())
 	} else if secondRef != "firstref" {
 		t.Errorf("Expected %s to contain %s. Got: %s", secondRefPath, "firstref", secondRef)
 	}
 
-	thirdRef, err := readRef(tmpDir, thirdRefPath)
+	thirdRef, err := readRef(vfs.OS, tmpDir, thirdRefPath)
 	if err != nil {
 		t.Errorf("Unexpected Error when reading %s:\n%s", thirdRefPath, err.Error())
 	} else if thirdRef != "fourthref" {
 		t.Errorf("Expected %s to contain %s. Got: %s", thirdRefPath, "fourthref", thirdRef)
 	}
 
-	fourthRef, err := readRef(tmpDir, fourthRefPath)
+	fourthRef, err := readRef(vfs.OS, tmpDir, fourthRefPath)
 	if err != nil {
 		t.Errorf("Unexpected Error when reading %s:\n%s", fourthRefPath, err.Error())
 	} else if thirdRef != "fourthref" {
@@ -177,7 +178,8 @@ func TestSaveTag(t *testing.T) {
 			err.Error())
 	}
 
-	err = repo.SaveTag("test", "hello world")
+	hash := objects.MustParseObjectID("95d09f2b10159347eece71399a7e2e907ea3df4f")
+	err = repo.SaveTag("test", hash)
 	if err != nil {
 		t.Fatalf("Unexpected Error when saving tag:\n%s", err.Error())
 	}
@@ -187,8 +189,8 @@ func TestSaveTag(t *testing.T) {
 		t.Fatalf("Unexpected Error when reading tag:\n%s", err.Error())
 	}
 
-	if tagContent != "hello world" {
-		t.Errorf("Expected tag to contain %s, Got: %s", "hello world", tagContent)
+	if tagContent != hash {
+		t.Errorf("Expected tag to contain %s, Got: %s", hash, tagContent)
 	}
 }
 
@@ -251,8 +253,8 @@ func TestDeleteTag(t *testing.T) {
 	if exists(entries, "test2") {
 		t.Errorf("Expected 'tags/test' to not exist")
 	}
-	_, err = repo.GetObject(tagHash)
-	if _, ok := err.(*ErrObjectNotFound); !ok {
-		t.Errorf("Expected ObjectNotFound Error when searching for deleted tag object")
+	// The tag object is left for prune
+	if has, err := repo.HasObject(tagHash); !has || err != nil {
+		t.Errorf("Expected the tag object to be kept, Got: %v, %v", has, err)
 	}
 }
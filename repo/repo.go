@@ -8,13 +8,24 @@ import (
 	"strings"
 
 	"github.com/SimonMTaye/gitgo/iniparse"
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
 )
 
 type Repo struct {
 	GitDir   string
 	Worktree string
 	Branches []Branch
+	// Objects The database objects are read from and written to. OpenRepo uses the loose
+	// objects and packs in GitDir; it can be replaced to store objects elsewhere
+	Objects  ObjectStore
 	detached bool
+	// fs The filesystem holding GitDir and Worktree; nil means the OS filesystem
+	fs vfs.FS
+	// localConfig Whether only the repo's own config is used, without the system and global ones
+	localConfig bool
+	// hashAlgo The algorithm objects are named with, from extensions.objectFormat; nil means SHA1
+	hashAlgo *objects.HashAlgorithm
 }
 
 type Branch struct {
@@ -44,8 +55,19 @@ func (e *ErrNoRepositoryFound) Error() string {
 // Reads the "config" file in the ".git" directory and returns a Repo struct with the
 // current repos properties
 func OpenRepo(dir string) (*Repo, error) {
+	repo, err := OpenRepoFS(vfs.OS, dir)
+	if err != nil {
+		return nil, err
+	}
+	repo.localConfig = false
+	return repo, nil
+}
+
+// OpenRepoFS Open the repository in dir on the given filesystem. Unlike OpenRepo, only the
+// repo's own config is used; the system and global configs are never read
+func OpenRepoFS(fsys vfs.FS, dir string) (*Repo, error) {
 	// Look for the ".git" directory
-	dirs, err := os.ReadDir(dir)
+	dirs, err := fsys.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -56,12 +78,13 @@ func OpenRepo(dir string) (*Repo, error) {
 
 	// Open the "config" file
 	gitDir := path.Join(dir, ".git")
-	configFile, err := os.Open(path.Join(gitDir, "config"))
+	configFile, err := fsys.Open(path.Join(gitDir, "config"))
 	if err != nil {
 		return nil, err
 	}
 	// Parse the config file
 	configIni, err := iniparse.ParseIni(configFile)
+	configFile.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +94,17 @@ func OpenRepo(dir string) (*Repo, error) {
 		return nil, err
 	}
 
-	repo := Repo{GitDir: gitDir, Branches: branches}
+	algo := objects.SHA1
+	if format, ok := configValue(&configIni, "extensions", "objectformat"); ok {
+		algo, err = objects.HashAlgorithmByName(format)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	store := defaultObjectStore(fsys, gitDir, algo)
+	repo := Repo{GitDir: gitDir, Branches: branches, Objects: store, fs: fsys, localConfig: true,
+		hashAlgo: algo}
 
 	worktree, ok := configIni["core"]["worktree"]
 	if ok {
@@ -83,6 +116,37 @@ func OpenRepo(dir string) (*Repo, error) {
 
 }
 
+// NewMemoryRepo Create an empty repository that is held entirely in memory. Its worktree is
+// "/repo" on an in-memory filesystem, which can be reached through FS
+func NewMemoryRepo() (*Repo, error) {
+	fsys := vfs.NewMemFS()
+	err := fsys.MkdirAll("/repo", DirFilemode)
+	if err != nil {
+		return nil, err
+	}
+	err = CreateRepoFS(fsys, "/repo", "", "")
+	if err != nil {
+		return nil, err
+	}
+	return OpenRepoFS(fsys, "/repo")
+}
+
+// HashAlgorithm The algorithm the objects of the repo are named with
+func (repo *Repo) HashAlgorithm() *objects.HashAlgorithm {
+	if repo.hashAlgo == nil {
+		return objects.SHA1
+	}
+	return repo.hashAlgo
+}
+
+// FS The filesystem holding the repo
+func (repo *Repo) FS() vfs.FS {
+	if repo.fs == nil {
+		return vfs.OS
+	}
+	return repo.fs
+}
+
 // FindRepo Recursively checks parent directory until a ".git" is found or "/" is reached
 func FindRepo(cwd string) (string, error) {
 	curDir := cwd
@@ -129,16 +193,8 @@ func exists(entries []os.DirEntry, name string) bool {
 }
 
 func (repo *Repo) initIndex() (*index.Index, error) {
-	f, err := os.Create(path.Join(repo.GitDir, "index"))
-	err = f.Close()
-	if err != nil {
-		return nil, err
-	}
-	if err != nil {
-		return nil, err
-	}
-	idx := index.EmptyIndex()
-	err = repo.WriteIndex(idx)
+	idx := index.EmptyIndex(repo.HashAlgorithm())
+	err := repo.WriteIndex(idx)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +203,7 @@ func (repo *Repo) initIndex() (*index.Index, error) {
 
 // Index Parse the index file of repo and return a struct representing the staging area. If the index doesn't already, create a new one
 func (repo *Repo) Index() (*index.Index, error) {
-	indexFile, err := os.Open(path.Join(repo.GitDir, "index"))
+	indexFile, err := repo.FS().Open(path.Join(repo.GitDir, "index"))
 	if err != nil {
 		_, ok := err.(*os.PathError)
 		if ok {
@@ -155,19 +211,30 @@ func (repo *Repo) Index() (*index.Index, error) {
 		}
 		return nil, err
 	}
-	return index.ParseIndex(indexFile)
+	defer indexFile.Close()
+	idx, err := index.ParseIndex(indexFile, repo.HashAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	// Record when the index was written so racily clean entries can be detected
+	info, err := indexFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	idx.Timestamp = index.TimePairFromTime(info.ModTime())
+	return idx, nil
 }
 
 // WriteIndex Write an Index struct to the index file of the repo
 func (repo *Repo) WriteIndex(index *index.Index) error {
-	return os.WriteFile(path.Join(repo.GitDir, "index"), index.Serialize(), 0644)
+	return repo.FS().WriteFile(path.Join(repo.GitDir, "index"), index.Serialize(), 0644)
 }
 
 // UpdateCurrentBranch Updates the current branch to point to the new hash. If there is no branch (i.e. HEAD
 // is detached) then HEAD will now point to the new hash.
-func (repo *Repo) UpdateCurrentBranch(hash string) error {
+func (repo *Repo) UpdateCurrentBranch(hash objects.ObjectID) error {
 	headPath := path.Join(repo.GitDir, "HEAD")
-	data, err := os.ReadFile(headPath)
+	data, err := repo.FS().ReadFile(headPath)
 	if err != nil {
 		return err
 	}
@@ -180,7 +247,7 @@ func (repo *Repo) UpdateCurrentBranch(hash string) error {
 		return repo.updateBranchRef(branchName, hash)
 
 	} else {
-		return os.WriteFile(headPath, []byte(hash), NormalFilemode)
+		return repo.FS().WriteFile(headPath, []byte(hash.String()), NormalFilemode)
 	}
 
 }
@@ -2,9 +2,8 @@ package repo
 
 import (
 	"errors"
-	"github.com/SimonMTaye/gitgo/config"
+	"github.com/SimonMTaye/gitgo/index"
 	"github.com/SimonMTaye/gitgo/objects"
-	"os"
 	"path"
 )
 
@@ -19,79 +18,57 @@ func (repo *Repo) Commit(msg string) error {
 	if idx.IsEmpty() {
 		return errors.New("index is empty, there is nothing to commit")
 	}
-	treeMap := indexToTreeMap(idx)
-	trees := treeMap.allTrees()
-	for _, tree := range trees {
-		// Save all the tree objects that will be referenced in our commit
-		err := repo.SaveObject(tree)
-		if err != nil {
-			return err
-		}
-	}
-	configs, err := config.LoadConfig(path.Join(repo.GitDir, "config"))
+	treeHash, err := repo.WriteTree()
 	if err != nil {
 		return err
 	}
-	headHash, err := repo.FindObject("HEAD")
-	// If the head doesn't exist (likely, this is the first commit), then set the headHash to be empty
+	headHash, err := repo.headCommit()
 	if err != nil {
-		_, ok := err.(*os.PathError)
-		if !ok {
-			return err
-		}
-		headHash = ""
-	}
-
-	user, ok := (*configs)["user"]["name"]
-	if !ok {
-		return errors.New("no user name set; please set git user name")
+		return err
 	}
-	email, ok := (*configs)["user"]["email"]
-	if !ok {
-		return errors.New("no user email set; please set git user email")
+	parents := make([]string, 0, 1)
+	if !headHash.IsZero() {
+		parents = append(parents, headHash.String())
 	}
-
-	commit := &objects.GitCommit{}
-	// Create commit object
-	// Root tree should be at the beginning, needs to be checked
-	commit.TreeHash = objects.Hash(trees[0])
-	commit.Msg = msg
-	commit.SetAuthor(user, email)
-	// TODO write function that takes committer information from user
-	commit.SetCommitter(user, email)
-	commit.ParentHash = headHash
-	err = repo.SaveObject(commit)
+	commitHash, _, err := repo.CommitTree(treeHash.String(), parents, msg)
 	if err != nil {
 		return err
 	}
 	// Update the current branch/head to point to our commit
-	return repo.UpdateCurrentBranch(objects.Hash(commit))
+	return repo.UpdateCurrentBranch(commitHash)
 }
 
-// AddFile adds a file entry to the index
+// AddFile adds a file entry to the index. The file is only read once; it is hashed while it
+// is being saved to the database
 func (repo *Repo) AddFile(filepath string) error {
-	blob, err := objects.FileBlob(path.Join(repo.Worktree, filepath))
+	fullPath := path.Join(repo.Worktree, filepath)
+	// Stat the file before reading it so changes made while it is read are noticed later
+	info, err := repo.FS().Lstat(fullPath)
 	if err != nil {
 		return err
 	}
-	// Save Object handles duplicates so we don't need to check for it
-	err = repo.SaveObject(blob)
+	content, size, err := repo.openFile(fullPath)
 	if err != nil {
 		return err
 	}
-	// Update the staging area with the new file
-	idx, err := repo.Index()
+	// SaveStream handles duplicates so we don't need to check for it
+	hash, err := repo.SaveStream(objects.Blob, size, content)
+	content.Close()
 	if err != nil {
 		return err
 	}
-
-	err = idx.AddFile(repo.Worktree, filepath)
+	entry, err := index.NewFileEntry(filepath, info, hash)
+	if err != nil {
+		return err
+	}
+	// Update the staging area with the new file
+	idx, err := repo.Index()
 	if err != nil {
 		return err
 	}
-	err = repo.WriteIndex(idx)
+	err = idx.AddEntry(entry)
 	if err != nil {
 		return err
 	}
-	return nil
+	return repo.WriteIndex(idx)
 }
@@ -18,6 +18,21 @@ func createNewRepo(t *testing.T) (*Repo, error) {
 	return OpenRepo(dir)
 }
 
+// Set the user name and email in the config of the repo so commits can be made regardless
+// of the global config of the machine running the tests
+func setTestIdentity(t *testing.T, repoStruct *Repo) {
+	configPath := path.Join(repoStruct.GitDir, "config")
+	configData, err := repoStruct.FS().ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Error reading config: %s", err)
+	}
+	configData = append(configData, "\n[user]\nname = Simon Taye\nemail = mulat.simon@gmail.com\n"...)
+	err = repoStruct.FS().WriteFile(configPath, configData, NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing config: %s", err)
+	}
+}
+
 func TestAdd(t *testing.T) {
 	repoStruct, err := createNewRepo(t)
 	if err != nil {
@@ -79,6 +94,7 @@ func TestCommit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error opening repo: %s", err)
 	}
+	setTestIdentity(t, repoStruct)
 	_, err = os.Create(path.Join(repoStruct.Worktree, "test.txt"))
 	if err != nil {
 		t.Fatalf("Error creating file: %s", err)
@@ -112,6 +128,6 @@ func TestCommit(t *testing.T) {
 		if commit.Msg != expectedMessage {
 			t.Errorf("Error: commit message is not correct.\n Expected: %s\n Got: %s", expectedMessage, commit.Msg)
 		}
-		parent = commit.ParentHash
+		parent = commit.ParentHash.String()
 	}
 }
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"path"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/config"
+	"github.com/SimonMTaye/gitgo/iniparse"
+)
+
+// Config Load the configuration of the repo merged with the system and global configs.
+// Repos opened with OpenRepoFS only use their own config
+func (repo *Repo) Config() (*iniparse.IniFile, error) {
+	configFile, err := repo.FS().Open(path.Join(repo.GitDir, "config"))
+	if err != nil {
+		return nil, err
+	}
+	defer configFile.Close()
+	localIni, err := iniparse.ParseIni(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if repo.localConfig {
+		return &localIni, nil
+	}
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	return iniparse.MergeInis(globalConfig.All, &localIni), nil
+}
+
+// configValue Look up a value in a config. Section and key names are case-insensitive in git
+// (e.g. 'core.fileMode' and 'core.filemode' are the same setting) so they are matched as such
+func configValue(ini *iniparse.IniFile, section string, key string) (string, bool) {
+	for sectionName, entries := range *ini {
+		if !strings.EqualFold(sectionName, section) {
+			continue
+		}
+		for k, v := range entries {
+			if strings.EqualFold(k, key) {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseConfigBool Parse a boolean config value the way git does. Returns false for ok if the
+// value is not a valid boolean
+func parseConfigBool(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	// A key without a value is treated as true by git
+	case "true", "yes", "on", "1", "":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// configBool Read a boolean setting from the repo config, returning def if it isn't set or
+// can't be parsed
+func (repo *Repo) configBool(section string, key string, def bool) bool {
+	ini, err := repo.Config()
+	if err != nil {
+		return def
+	}
+	value, ok := configValue(ini, section, key)
+	if !ok {
+		return def
+	}
+	parsed, ok := parseConfigBool(value)
+	if !ok {
+		return def
+	}
+	return parsed
+}
+
+// TrustExecutableBit Whether the executable bit of files in the worktree should be trusted
+// when comparing them to the index (core.filemode). Defaults to true like git
+func (repo *Repo) TrustExecutableBit() bool {
+	return repo.configBool("core", "filemode", true)
+}
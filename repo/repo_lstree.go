@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"path"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// LsTreeOptions Options for Repo.LsTree; they mirror the flags of 'git ls-tree'
+type LsTreeOptions struct {
+	// Recursive List the contents of subtrees instead of the subtrees themselves
+	Recursive bool
+	// ShowTrees List subtrees even when recursing into them
+	ShowTrees bool
+	// Long Look up the size of blobs
+	Long bool
+	// Prefix The directory (relative to the root) the listing is made from, like git's prefix
+	// when ls-tree runs in a subdirectory. Without paths only its contents are listed, and
+	// unless FullName is set the paths of entries are relative to it
+	Prefix string
+	// FullName List paths relative to the root even when there is a prefix
+	FullName bool
+}
+
+// LsTreeEntry An entry listed by Repo.LsTree. Path is relative to the prefix (the root tree if
+// there is none) and Size is only set (i.e. not -1) for blobs when the Long option is used
+type LsTreeEntry struct {
+	Mode objects.EntryFileMode
+	Type objects.GitObjectType
+	Hash objects.ObjectID
+	Path string
+	Size int
+}
+
+// LsTree List the entries of a tree-ish (a tree or anything that resolves to one, like HEAD).
+// If paths are given, only entries matching them are listed. A path matches the entry with the
+// same name and everything inside it; with a trailing slash it only matches the contents of a
+// directory, so "src/" lists the files in src rather than src itself. paths are relative to the
+// root even if there is a prefix
+func (repo *Repo) LsTree(name string, paths []string, opts LsTreeOptions) ([]LsTreeEntry, error) {
+	treeHash, err := repo.ResolveTree(name)
+	if err != nil {
+		return nil, err
+	}
+	specs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		spec := cleanRepoPath(p)
+		if spec != "" && strings.HasSuffix(p, "/") {
+			spec += "/"
+		}
+		specs = append(specs, spec)
+	}
+	prefix := cleanRepoPath(opts.Prefix)
+	if len(specs) == 0 && prefix != "" {
+		specs = append(specs, prefix+"/")
+	}
+	entries := make([]LsTreeEntry, 0)
+	err = repo.lsTreeHelper(treeHash, "", specs, opts, &entries)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !opts.FullName {
+		for i := range entries {
+			entries[i].Path = relativePath(prefix, entries[i].Path)
+		}
+	}
+	return entries, nil
+}
+
+// relativePath The path of name as seen from the directory dir, both relative to the root. Like
+// git, dir itself is "./" and paths outside of it go up with "../"; its parent is "../"
+func relativePath(dir string, name string) string {
+	if name == dir {
+		return "./"
+	}
+	up := ""
+	for dir != "" && dir != name && !isInDir(name, dir) {
+		up += "../"
+		dir = path.Dir(dir)
+		if dir == "." {
+			dir = ""
+		}
+	}
+	// An ancestor of dir is just the way up to it
+	if dir == name {
+		return up
+	}
+	if dir == "" {
+		return up + name
+	}
+	return up + strings.TrimPrefix(name, dir+"/")
+}
+
+// Helper for LsTree. Adds the matching entries of the tree, with prefix added to their name
+func (repo *Repo) lsTreeHelper(treeHash objects.ObjectID, prefix string, specs []string, opts LsTreeOptions,
+	entries *[]LsTreeEntry) error {
+	tree, err := repo.readTree(treeHash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries() {
+		name := path.Join(prefix, entry.Name)
+		matched := len(specs) == 0 || specMatches(name, specs)
+		// Trees leading to a path have to be entered even if they don't match
+		leading := !matched && specLeadsTo(name, specs)
+		if !matched && !leading {
+			continue
+		}
+		recurse := entry.Type == objects.Tree && (leading || opts.Recursive)
+		if !recurse || opts.ShowTrees {
+			lsEntry := LsTreeEntry{Mode: entry.Mode, Type: entry.Type, Hash: entry.Hash, Path: name, Size: -1}
+			if opts.Long && entry.Type == objects.Blob {
+				lsEntry.Size, err = repo.objectSize(entry.Hash)
+				if err != nil {
+					return err
+				}
+			}
+			*entries = append(*entries, lsEntry)
+		}
+		if recurse {
+			err = repo.lsTreeHelper(entry.Hash, name, specs, opts, entries)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// specMatches Check if the name matches one of the path specs
+func specMatches(name string, specs []string) bool {
+	for _, spec := range specs {
+		if name == spec || isInDir(name, strings.TrimSuffix(spec, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// specLeadsTo Check if the name is a directory containing one of the path specs
+func specLeadsTo(name string, specs []string) bool {
+	for _, spec := range specs {
+		if strings.HasPrefix(spec, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// objectSize Return the size of an object's content
+func (repo *Repo) objectSize(hash objects.ObjectID) (int, error) {
+	obj, err := repo.GetObject(hash)
+	if err != nil {
+		return 0, err
+	}
+	return obj.Size(), nil
+}
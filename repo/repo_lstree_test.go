@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Return the paths of entries listed by LsTree
+func lsTreePaths(t *testing.T, repoStruct *Repo, paths []string, opts LsTreeOptions) []string {
+	entries, err := repoStruct.LsTree("HEAD", paths, opts)
+	if err != nil {
+		t.Fatalf("Error listing tree: %s", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Path)
+	}
+	return names
+}
+
+// Check that two lists of paths are the same
+func samePaths(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLsTree(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	setTestIdentity(t, repoStruct)
+	addTestFiles(t, repoStruct, "a.txt", "src/b.txt", "src/sub/c.txt")
+	err = repoStruct.Commit("ls-tree test")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+
+	cases := []struct {
+		paths    []string
+		opts     LsTreeOptions
+		expected []string
+	}{
+		{nil, LsTreeOptions{}, []string{"a.txt", "src"}},
+		{nil, LsTreeOptions{Recursive: true}, []string{"a.txt", "src/b.txt", "src/sub/c.txt"}},
+		{nil, LsTreeOptions{Recursive: true, ShowTrees: true}, []string{"a.txt", "src", "src/b.txt", "src/sub", "src/sub/c.txt"}},
+		{[]string{"src"}, LsTreeOptions{}, []string{"src"}},
+		{[]string{"src/"}, LsTreeOptions{}, []string{"src/b.txt", "src/sub"}},
+		{[]string{"src/sub/c.txt"}, LsTreeOptions{}, []string{"src/sub/c.txt"}},
+		{[]string{"src/sub/c.txt"}, LsTreeOptions{ShowTrees: true}, []string{"src", "src/sub", "src/sub/c.txt"}},
+		{[]string{"src", "a.txt"}, LsTreeOptions{Recursive: true}, []string{"a.txt", "src/b.txt", "src/sub/c.txt"}},
+		{[]string{"missing"}, LsTreeOptions{}, []string{}},
+	}
+	for _, c := range cases {
+		names := lsTreePaths(t, repoStruct, c.paths, c.opts)
+		if !samePaths(names, c.expected) {
+			t.Errorf("Expected ls-tree %v %+v to list %v, Got: %v", c.paths, c.opts, c.expected, names)
+		}
+	}
+
+	entries, err := repoStruct.LsTree("HEAD", nil, LsTreeOptions{Long: true})
+	if err != nil {
+		t.Fatalf("Error listing tree: %s", err)
+	}
+	if entries[0].Type != objects.Blob || entries[0].Mode != objects.Normal || entries[0].Size != len("a.txt\n") {
+		t.Errorf("Expected a.txt to be a normal blob of size %d, Got: %+v", len("a.txt\n"), entries[0])
+	}
+	if entries[1].Type != objects.Tree || entries[1].Size != -1 {
+		t.Errorf("Expected src to be a tree without a size, Got: %+v", entries[1])
+	}
+}
+
+// Test listing from a subdirectory: without paths only its contents are listed, and paths are
+// shown relative to it like 'git ls-tree' does when run from src/sub
+func TestLsTreeFromSubdirectory(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	setTestIdentity(t, repoStruct)
+	addTestFiles(t, repoStruct, "a.txt", "src/b.txt", "src/sub/c.txt")
+	err = repoStruct.Commit("ls-tree test")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+
+	cases := []struct {
+		paths    []string
+		opts     LsTreeOptions
+		expected []string
+	}{
+		{nil, LsTreeOptions{Prefix: "src"}, []string{"b.txt", "sub"}},
+		{nil, LsTreeOptions{Prefix: "src", Recursive: true, ShowTrees: true}, []string{"./", "b.txt", "sub", "sub/c.txt"}},
+		{nil, LsTreeOptions{Prefix: "src", FullName: true}, []string{"src/b.txt", "src/sub"}},
+		{nil, LsTreeOptions{Prefix: "src/sub", ShowTrees: true}, []string{"../", "./", "c.txt"}},
+		{[]string{"src/b.txt"}, LsTreeOptions{Prefix: "src"}, []string{"b.txt"}},
+		{[]string{"a.txt", "src/b.txt"}, LsTreeOptions{Prefix: "src/sub"}, []string{"../../a.txt", "../b.txt"}},
+		{[]string{""}, LsTreeOptions{Prefix: "src"}, []string{"../a.txt", "./"}},
+	}
+	for _, c := range cases {
+		names := lsTreePaths(t, repoStruct, c.paths, c.opts)
+		if !samePaths(names, c.expected) {
+			t.Errorf("Expected ls-tree %v %+v to list %v, Got: %v", c.paths, c.opts, c.expected, names)
+		}
+	}
+}
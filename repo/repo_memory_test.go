@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Test that files can be added, committed, changed and reset without touching the disk
+func TestMemoryRepo(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	if _, err := os.Stat(repoStruct.GitDir); !os.IsNotExist(err) {
+		t.Fatalf("Expected the repo not to be on disk, Got: %v", err)
+	}
+	setTestIdentity(t, repoStruct)
+	writeRepoFile(t, repoStruct, "README", "hello\n")
+	writeRepoFile(t, repoStruct, "src/main.go", "package main\n")
+	err = repoStruct.FS().Symlink("README", path.Join(repoStruct.Worktree, "link"))
+	if err != nil {
+		t.Fatalf("Error creating link: %s", err)
+	}
+	for _, name := range []string{"README", "src/main.go", "link"} {
+		err = repoStruct.AddFile(name)
+		if err != nil {
+			t.Fatalf("Error adding %s: %s", name, err)
+		}
+	}
+	// Same tree as 'git write-tree' for these files
+	tree, err := repoStruct.WriteTree()
+	if err != nil || tree.String() != "6182f547c31472db4f8c003f1c8984083d38bf60" {
+		t.Errorf("Expected tree 6182f547c31472db4f8c003f1c8984083d38bf60, Got: %s, %v", tree, err)
+	}
+	err = repoStruct.Commit("first")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	first, err := repoStruct.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("Error resolving HEAD: %s", err)
+	}
+
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	modified, _, err := repoStruct.ChangedFiles(idx)
+	if err != nil || len(modified) != 0 {
+		t.Errorf("Expected no changes after committing, Got: %v, %v", modified, err)
+	}
+	writeRepoFile(t, repoStruct, "README", "changed\n")
+	writeRepoFile(t, repoStruct, "notes", "untracked\n")
+	modified, _, err = repoStruct.ChangedFiles(idx)
+	if err != nil || len(modified) != 1 || modified[0] != "README" {
+		t.Errorf("Expected README to be modified, Got: %v, %v", modified, err)
+	}
+	untracked, err := repoStruct.UntrackedFiles(idx)
+	if err != nil || len(untracked) != 1 || untracked[0] != "notes" {
+		t.Errorf("Expected notes to be untracked, Got: %v, %v", untracked, err)
+	}
+
+	err = repoStruct.AddFile("README")
+	if err != nil {
+		t.Fatalf("Error adding README: %s", err)
+	}
+	err = repoStruct.Commit("second")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	second, err := repoStruct.ResolveCommit("HEAD")
+	if err != nil {
+		t.Fatalf("Error resolving HEAD: %s", err)
+	}
+	obj, err := repoStruct.GetObject(second)
+	if err != nil {
+		t.Fatalf("Error reading commit: %s", err)
+	}
+	if parents := obj.(*objects.GitCommit).Parents(); len(parents) != 1 || parents[0] != first {
+		t.Errorf("Expected the second commit to have %s as parent, Got: %v", first, parents)
+	}
+
+	err = repoStruct.Reset(first.String(), ResetHard)
+	if err != nil {
+		t.Fatalf("Error resetting: %s", err)
+	}
+	content, err := repoStruct.FS().ReadFile(path.Join(repoStruct.Worktree, "README"))
+	if err != nil || string(content) != "hello\n" {
+		t.Errorf("Expected README to be reset to 'hello', Got: '%s', %v", content, err)
+	}
+}
+
+// Test a SHA256 repository from adding files to packing its objects. The hashes are those
+// of git for the same files
+func TestSHA256Repo(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	err := fsys.MkdirAll("/repo", DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating dir: %s", err)
+	}
+	err = CreateRepoFSWithHash(fsys, "/repo", "", "", objects.SHA256)
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	repoStruct, err := OpenRepoFS(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	if repoStruct.HashAlgorithm() != objects.SHA256 {
+		t.Fatalf("Expected the repo to use sha256, Got: %s", repoStruct.HashAlgorithm().Name())
+	}
+	setTestIdentity(t, repoStruct)
+	writeRepoFile(t, repoStruct, "file", "content\n")
+	writeRepoFile(t, repoStruct, "dir/other", "other\n")
+	for _, name := range []string{"file", "dir/other"} {
+		err = repoStruct.AddFile(name)
+		if err != nil {
+			t.Fatalf("Error adding %s: %s", name, err)
+		}
+	}
+	blob, err := repoStruct.FindObject("7c490ebf9d")
+	if err != nil || blob.String() != "7c490ebf9db90b84753749c721ef2bedfeb85c1da94160f2619df1249c64bdda" {
+		t.Errorf("Expected the blob of 'file' to be found by its prefix, Got: %s, %v", blob, err)
+	}
+	tree, err := repoStruct.WriteTree()
+	if err != nil || tree.String() != "efe77f7a73d2ec0d0d8f78392bfb0e52955333a87f4275c7944c57ee797fe071" {
+		t.Errorf("Expected tree efe77f7a73d2ec0d0d8f78392bfb0e52955333a87f4275c7944c57ee797fe071, Got: %s, %v", tree, err)
+	}
+	err = repoStruct.Commit("first")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	head, err := repoStruct.ResolveCommit("HEAD")
+	if err != nil || head.Algorithm() != objects.SHA256 {
+		t.Fatalf("Expected HEAD to be a sha256 commit, Got: %s, %v", head, err)
+	}
+	files, err := repoStruct.treeFiles(tree)
+	if err != nil || len(files) != 2 {
+		t.Errorf("Expected the commit to hold 2 files, Got: %v, %v", files, err)
+	}
+
+	err = repoStruct.Gc(GcOptions{PruneExpire: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Error running gc: %s", err)
+	}
+	reopened, err := OpenRepoFS(fsys, "/repo")
+	if err != nil {
+		t.Fatalf("Error reopening repo: %s", err)
+	}
+	if packedTree, err := reopened.ResolveTree(head.String()); err != nil || packedTree != tree {
+		t.Errorf("Expected the commit to be read from the pack, Got: %s, %v", packedTree, err)
+	}
+	issues, err := reopened.Fsck(FsckOptions{})
+	if err != nil || len(issues) != 0 {
+		t.Errorf("Expected no fsck issues, Got: %v, %v", issues, err)
+	}
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// Rename A file or directory moved by Repo.Move
+type Rename struct {
+	From string
+	To   string
+}
+
+// Move Move or rename files and directories in both the worktree and the index. Paths are relative
+// to the worktree. If dest is an existing directory the sources are moved inside it, otherwise
+// the single source is renamed to dest. Sources must be tracked and, unless force is set,
+// destinations must not exist. Every move is checked before anything is changed and worktree
+// renames are undone if a later step fails. Returns the moves that were made
+func (repo *Repo) Move(sources []string, dest string, force bool) ([]Rename, error) {
+	idx, err := repo.Index()
+	if err != nil {
+		return nil, err
+	}
+	dest = cleanRepoPath(dest)
+	destInfo, err := repo.FS().Lstat(path.Join(repo.Worktree, dest))
+	destIsDir := err == nil && destInfo.IsDir()
+	if len(sources) > 1 && !destIsDir {
+		return nil, fmt.Errorf("destination '%s' is not a directory", dest)
+	}
+
+	moves := make([]Rename, 0, len(sources))
+	// Index entries that will be renamed and those that will be overwritten
+	entryRenames := make([]Rename, 0)
+	overwritten := make([]string, 0)
+	targets := make(map[string]bool)
+	for _, src := range sources {
+		src = cleanRepoPath(src)
+		target := dest
+		if destIsDir {
+			target = path.Join(dest, path.Base(src))
+		}
+		badMove := func(reason string) error {
+			return fmt.Errorf("%s, source=%s, destination=%s", reason, src, target)
+		}
+		if src == "" {
+			return nil, badMove("can not move the worktree")
+		}
+		srcInfo, err := repo.FS().Lstat(path.Join(repo.Worktree, src))
+		if os.IsNotExist(err) {
+			return nil, badMove("bad source")
+		} else if err != nil {
+			return nil, err
+		}
+		if src == target || isInDir(target, src) {
+			return nil, badMove("can not move directory into itself")
+		}
+		if targets[target] {
+			return nil, badMove("multiple sources for the same target")
+		}
+		targets[target] = true
+
+		if srcInfo.IsDir() {
+			inDir := 0
+			for _, entry := range idx.Entries {
+				if isInDir(entry.Name, src) {
+					entryRenames = append(entryRenames, Rename{entry.Name, target + entry.Name[len(src):]})
+					inDir++
+				}
+			}
+			if inDir == 0 {
+				return nil, badMove("source directory is empty")
+			}
+		} else {
+			if exists, _ := idx.EntryExists(src); !exists {
+				return nil, badMove("not under version control")
+			}
+			entryRenames = append(entryRenames, Rename{src, target})
+		}
+
+		parentInfo, err := repo.FS().Stat(path.Join(repo.Worktree, path.Dir(target)))
+		if err != nil || !parentInfo.IsDir() {
+			return nil, badMove("destination directory does not exist")
+		}
+		targetInfo, err := repo.FS().Lstat(path.Join(repo.Worktree, target))
+		targetExists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		targetTracked, _ := idx.EntryExists(target)
+		if targetExists || targetTracked {
+			// Only a file can be overwritten by another file
+			if !force || srcInfo.IsDir() || (targetExists && targetInfo.IsDir()) {
+				return nil, badMove("destination exists")
+			}
+			if targetTracked {
+				overwritten = append(overwritten, target)
+			}
+		}
+		for _, entry := range idx.Entries {
+			if isInDir(entry.Name, target) {
+				return nil, badMove("destination exists")
+			}
+		}
+		moves = append(moves, Rename{src, target})
+	}
+
+	for _, name := range overwritten {
+		_, pos := idx.EntryExists(name)
+		err = idx.DeleteEntry(pos)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, rename := range entryRenames {
+		err = idx.RenameEntry(rename.From, rename.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, move := range moves {
+		err = repo.FS().Rename(path.Join(repo.Worktree, move.From), path.Join(repo.Worktree, move.To))
+		if err != nil {
+			repo.undoMoves(moves[:i])
+			return nil, err
+		}
+	}
+	err = repo.WriteIndex(idx)
+	if err != nil {
+		repo.undoMoves(moves)
+		return nil, err
+	}
+	return moves, nil
+}
+
+// Move files in the worktree back to where they were, in reverse order. Errors are ignored since
+// this is only used when already failing
+func (repo *Repo) undoMoves(moves []Rename) {
+	for i := len(moves) - 1; i >= 0; i-- {
+		repo.FS().Rename(path.Join(repo.Worktree, moves[i].To), path.Join(repo.Worktree, moves[i].From))
+	}
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"testing"
+)
+
+// Test that files and directories are renamed in both the worktree and the index
+func TestMove(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	addTestFiles(t, repoStruct, "a.txt", "b.txt", "dir/c.txt", "dir/sub/d.txt", "other/e.txt")
+
+	moves, err := repoStruct.Move([]string{"a.txt"}, "renamed.txt", false)
+	if err != nil {
+		t.Fatalf("Error moving file: %s", err)
+	}
+	if len(moves) != 1 || moves[0] != (Rename{"a.txt", "renamed.txt"}) {
+		t.Errorf("Expected a.txt to be renamed to renamed.txt, Got: %v", moves)
+	}
+	if worktreeFileExists(repoStruct, "a.txt") || indexHasEntry(t, repoStruct, "a.txt") {
+		t.Errorf("Expected a.txt to be gone after the move")
+	}
+	if !worktreeFileExists(repoStruct, "renamed.txt") || !indexHasEntry(t, repoStruct, "renamed.txt") {
+		t.Errorf("Expected renamed.txt to exist after the move")
+	}
+	if readWorktreeFile(t, repoStruct, "renamed.txt") != "a.txt\n" {
+		t.Errorf("Expected the content of the file to be kept")
+	}
+
+	// Moving into an existing directory
+	_, err = repoStruct.Move([]string{"renamed.txt", "dir"}, "other", false)
+	if err != nil {
+		t.Fatalf("Error moving files: %s", err)
+	}
+	for _, name := range []string{"other/renamed.txt", "other/dir/c.txt", "other/dir/sub/d.txt", "other/e.txt"} {
+		if !worktreeFileExists(repoStruct, name) || !indexHasEntry(t, repoStruct, name) {
+			t.Errorf("Expected %s to exist after the move", name)
+		}
+	}
+	if worktreeFileExists(repoStruct, "dir") || indexHasEntry(t, repoStruct, "dir/c.txt") {
+		t.Errorf("Expected dir to be gone after the move")
+	}
+
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	for _, entry := range idx.Entries {
+		modified, err := repoStruct.IsModified(idx, entry)
+		if err != nil {
+			t.Fatalf("Error checking file: %s", err)
+		}
+		if modified {
+			t.Errorf("Did not expect %s to be modified after moving it", entry.Name)
+		}
+	}
+}
+
+// Test that moves which would overwrite files or are otherwise invalid fail without changes
+func TestMoveChecks(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	addTestFiles(t, repoStruct, "a.txt", "b.txt", "dir/c.txt")
+	writeRepoFile(t, repoStruct, "untracked.txt", "untracked")
+
+	invalid := []struct {
+		sources []string
+		dest    string
+	}{
+		{[]string{"a.txt"}, "b.txt"},
+		{[]string{"missing.txt"}, "new.txt"},
+		{[]string{"untracked.txt"}, "new.txt"},
+		{[]string{"dir"}, "dir/inside"},
+		{[]string{"a.txt"}, "missing/new.txt"},
+		{[]string{"a.txt", "b.txt"}, "new.txt"},
+		// The second move is invalid so the first shouldn't happen either
+		{[]string{"a.txt", "missing.txt"}, "dir"},
+	}
+	for _, move := range invalid {
+		_, err = repoStruct.Move(move.sources, move.dest, false)
+		if err == nil {
+			t.Errorf("Expected an error when moving %v to %s", move.sources, move.dest)
+		}
+	}
+	if !indexHasEntry(t, repoStruct, "a.txt") || !worktreeFileExists(repoStruct, "a.txt") {
+		t.Errorf("Expected a.txt to be untouched by failed moves")
+	}
+
+	// Force allows overwriting a file
+	_, err = repoStruct.Move([]string{"a.txt"}, "b.txt", true)
+	if err != nil {
+		t.Fatalf("Error forcing move: %s", err)
+	}
+	if indexHasEntry(t, repoStruct, "a.txt") || readWorktreeFile(t, repoStruct, "b.txt") != "a.txt\n" {
+		t.Errorf("Expected b.txt to be overwritten by a.txt")
+	}
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	if len(idx.Entries) != int(idx.Header.NumEntry) || len(idx.Entries) != 2 {
+		t.Errorf("Expected 2 entries in the index, Got: %d", len(idx.Entries))
+	}
+}
@@ -2,8 +2,10 @@
 package repo
 
 import (
+	"bytes"
 	"github.com/SimonMTaye/gitgo/objects"
-	"os"
+	"github.com/SimonMTaye/gitgo/vfs"
+	"io"
 	"path"
 	"strings"
 )
@@ -21,129 +23,128 @@ func (e *ErrObjectNotFound) Error() string {
 //     Check branch heads
 //     Look for tags
 //     Check for object-refs (treat name as the first few chars of a hash id)
-// The last step requires that the hash id be at least 3 chars
-func (repo *Repo) FindObject(name string) (string, error) {
+// The last step requires that the hash id be at least 3 chars. A prefix matching more than one
+// object is an ErrAmbiguousObject
+func (repo *Repo) FindObject(name string) (objects.ObjectID, error) {
 	if name == "HEAD" {
-		bytes, err := os.ReadFile(path.Join(repo.GitDir, "HEAD"))
+		bytes, err := repo.FS().ReadFile(path.Join(repo.GitDir, "HEAD"))
 		if err != nil {
-			return "", err
+			return objects.ObjectID{}, err
 		}
 		read := string(bytes)
 		read = strings.Trim(read, " \n")
 		if isRef(read) {
 			obj, err := repo.FindRef(read)
 			if err != nil {
-				return "", err
+				return objects.ObjectID{}, err
 			}
 			return obj, nil
 		}
-		return read, nil
+		return repo.HashAlgorithm().ParseID(read)
 	}
 	refs, err := repo.GetAllRefs()
 	if err != nil {
-		return "", err
+		return objects.ObjectID{}, err
 	}
-	// Check branch heads
-	obj, ok := refs["refs/heads/"+name]
-	if ok {
-		return obj, nil
-	}
-	// Check tags
-	obj, ok = refs["refs/tags/"+name]
-	if ok {
-		return obj, nil
+	// Check branch heads, then tags
+	for _, refPath := range []string{"refs/heads/" + name, "refs/tags/" + name} {
+		if value, ok := refs[refPath]; ok {
+			return repo.parseRefValue(refPath, value)
+		}
 	}
 
 	if len(name) < 3 {
-		return "", &ErrObjectNotFound{query: name}
+		return objects.ObjectID{}, &ErrObjectNotFound{query: name}
 	}
-
-	objectDirs, err := os.ReadDir(path.Join(repo.GitDir, "objects"))
+	matches, err := repo.objectStore().FindPrefix(name)
 	if err != nil {
-		return "", nil
+		return objects.ObjectID{}, err
 	}
-	present := exists(objectDirs, name[:2])
-	if !present {
-		return "", &ErrObjectNotFound{query: name}
-	}
-
-	objs, err := os.ReadDir(path.Join(repo.GitDir, "objects", name[:2]))
-	if err != nil {
-		return "", err
-	}
-
-	matches := make([]string, 0, len(objs))
-	for _, obj := range objs {
-		if strings.HasPrefix(obj.Name(), name[2:]) {
-			matches = append(matches, name[:2]+obj.Name())
-		}
-	}
-	// We only want one match
-	if len(matches) != 1 {
-		return "", &ErrObjectNotFound{query: name}
+	if len(matches) == 0 {
+		return objects.ObjectID{}, &ErrObjectNotFound{query: name}
+	} else if len(matches) > 1 {
+		return objects.ObjectID{}, repo.ambiguousObject(name, matches)
 	}
 	return matches[0], nil
 }
 
 // GetObject Return a GitObject from a valid hash
 // returns an Error if the object is not found
-func (repo *Repo) GetObject(hash string) (objects.GitObject, error) {
-	objectHash := strings.Trim(hash, " \n")
-	dir := path.Join(repo.GitDir, "objects", objectHash[:2])
-	objs, err := os.ReadDir(dir)
+func (repo *Repo) GetObject(hash objects.ObjectID) (objects.GitObject, error) {
+	objType, content, err := repo.ReadRawObject(hash)
 	if err != nil {
 		return nil, err
 	}
-	for _, obj := range objs {
-		if obj.Name() == objectHash[2:] {
-			file, err := os.Open(path.Join(dir, obj.Name()))
-			if err != nil {
-				return nil, err
-			}
-			return objects.DecompressAndRead(file)
-		}
+	return objects.ParseObject(repo.HashAlgorithm(), objType, content)
+}
+
+// OpenObject Open the object with the given hash for reading. Loose objects are decompressed as
+// they are read so large blobs don't have to fit in memory. The reader must be closed
+func (repo *Repo) OpenObject(hash objects.ObjectID) (*objects.ObjectReader, error) {
+	return repo.objectStore().Get(hash)
+}
+
+// ReadRawObject Return the type and the unparsed content of the object with the given hash.
+// Unlike GetObject, the content is exactly what is stored in the database
+func (repo *Repo) ReadRawObject(hash objects.ObjectID) (objects.GitObjectType, []byte, error) {
+	reader, err := repo.OpenObject(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil, err
 	}
-	return nil, &ErrObjectNotFound{query: objectHash}
+	return reader.Type, content, nil
 }
 
 // DeleteObject Delete an object from the database
-func (repo *Repo) DeleteObject(hash string) error {
-	objPath := path.Join(repo.GitDir, "objects", hash[:2], hash[2:])
-	return os.Remove(objPath)
+func (repo *Repo) DeleteObject(hash objects.ObjectID) error {
+	return repo.objectStore().Delete(hash)
+}
+
+// HasObject Check if the object with the given hash is in the database
+func (repo *Repo) HasObject(hash objects.ObjectID) (bool, error) {
+	return repo.objectStore().Has(hash)
 }
 
 // SaveObject Save a git object to the repo
 func (repo *Repo) SaveObject(obj objects.GitObject) error {
-	hash := objects.Hash(obj)
-	// Check if the dir with the first two letters of the hash exists
-	// (eg. objects/0a where the hash is 0a32e1...)
-	objectsDir := path.Join(repo.GitDir, "objects")
-	entries, err := os.ReadDir(objectsDir)
-	if err != nil {
-		return err
-	}
+	content := obj.Serialize()
+	_, err := repo.SaveStream(obj.Type(), int64(len(content)), bytes.NewReader(content))
+	return err
+}
 
-	hashDir := path.Join(objectsDir, hash[:2])
-	// If the dir where the object will be stored doesn't exist, create it
-	if !exists(entries, hash[:2]) {
-		err = os.Mkdir(hashDir, DirFilemode)
-		if err != nil {
-			return err
-		}
-	}
+// SaveStream Save an object whose content is read from src without holding it all in memory.
+// Exactly size bytes must be read from src. Returns the hash of the object
+func (repo *Repo) SaveStream(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error) {
+	return repo.objectStore().Put(objType, size, src)
+}
 
-	// Check if the object already exists
-	entries, err = os.ReadDir(hashDir)
-	if err != nil {
-		return err
+// objectStore Return the store holding the repo's objects, opening the default one for repos
+// that weren't created by OpenRepo
+func (repo *Repo) objectStore() ObjectStore {
+	if repo.Objects == nil {
+		repo.Objects = defaultObjectStore(repo.FS(), repo.GitDir, repo.HashAlgorithm())
 	}
-	if !exists(entries, hash[2:]) {
-		file, err := os.Create(path.Join(hashDir, hash[2:]))
-		if err != nil {
-			return err
-		}
-		return objects.CompressAndSave(file, obj)
+	return repo.Objects
+}
 
-	}
-	return nil
+// defaultObjectStore The store git uses: loose objects followed by the packs in objects/pack
+// and then the alternates listed in objects/info/alternates. All of them hold objects named
+// with algo. Packs and alternates that can't be opened are skipped so the rest of the objects
+// can still be read; the store's Skipped says why
+func defaultObjectStore(fsys vfs.FS, gitDir string, algo *objects.HashAlgorithm) *CompositeStore {
+	objectsDir := path.Join(gitDir, "objects")
+	store := NewCompositeStore(NewLooseStore(fsys, objectsDir, algo))
+	packs, skipped := openPacks(fsys, path.Join(objectsDir, "pack"), algo)
+	for _, pack := range packs {
+		store.Add(pack)
+	}
+	for _, err := range skipped {
+		store.Skip(err)
+	}
+	addAlternates(fsys, store, objectsDir, algo, 1, map[string]bool{path.Clean(objectsDir): true})
+	return store
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/SimonMTaye/gitgo/objects"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -27,7 +28,7 @@ func TestSaveObjects(t *testing.T) {
 		t.Fatalf("Unexpected Error when saving object:\n%s", err.Error())
 	}
 
-	file, err := os.Open(path.Join(tmpDir, ".git", "objects", blobHash[:2], blobHash[2:]))
+	file, err := os.Open(path.Join(tmpDir, ".git", "objects", blobHash.String()[:2], blobHash.String()[2:]))
 	if err != nil {
 		t.Fatalf("Unexpected Error when opening object file:\n%s", err.Error())
 	}
@@ -116,7 +117,7 @@ func TestFindObject(t *testing.T) {
 	}
 
 	// Check that objects can be found with the first few letters of the hash
-	objSearchHash, err := repo.FindObject(blobHash[:4])
+	objSearchHash, err := repo.FindObject(blobHash.Abbrev(4))
 	if err != nil {
 		t.Fatalf("Unexpected Error when searching for object:\n%s", err.Error())
 	}
@@ -132,7 +133,7 @@ func TestFindObject(t *testing.T) {
 		t.Fatalf("Unexpected Error when creating file 'test' in refs/heads:\n%s", err.Error())
 	}
 
-	randomString := "hello world"
+	randomString := "95d09f2b10159347eece71399a7e2e907ea3df4f"
 	_, err = file.WriteString(randomString)
 	if err != nil {
 		t.Fatalf("Unexpected Error when writing to file refs/heads/test:\n%s", err.Error())
@@ -147,7 +148,100 @@ func TestFindObject(t *testing.T) {
 		t.Fatalf("Unexpected Error when searching for head object:\n%s", err.Error())
 	}
 
-	if headSearch != randomString {
+	if headSearch.String() != randomString {
 		t.Errorf("Expected returned head hash to be: %s\nGot: %s", randomString, headSearch)
 	}
+
+	// Refs that don't hold a hash can't be resolved
+	err = os.WriteFile(path.Join(headsDir, "broken"), []byte("hello world"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Unexpected Error when writing refs/heads/broken:\n%s", err.Error())
+	}
+	_, err = repo.FindObject("broken")
+	if _, ok := err.(*ErrBadRef); !ok {
+		t.Errorf("Expected ErrBadRef when resolving a ref without a hash, Got: %v", err)
+	}
+}
+
+// Test that raw objects are read exactly as they are stored
+func TestReadRawObject(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := CreateRepo(tmpDir, "", "")
+	if err != nil {
+		t.Fatalf("Unexpected Error when creating repo:\n%s", err.Error())
+	}
+	repo, err := OpenRepo(tmpDir)
+	if err != nil {
+		t.Fatalf("Unexpected Error when opening repo:\n%s", err.Error())
+	}
+	tree := &objects.GitTree{}
+	err = tree.AddEntry(objects.Normal, "file", objects.MustParseObjectID("95d09f2b10159347eece71399a7e2e907ea3df4f"))
+	if err != nil {
+		t.Fatalf("Unexpected Error when creating tree:\n%s", err.Error())
+	}
+	err = repo.SaveObject(tree)
+	if err != nil {
+		t.Fatalf("Unexpected Error when saving object:\n%s", err.Error())
+	}
+	objType, content, err := repo.ReadRawObject(objects.Hash(tree))
+	if err != nil {
+		t.Fatalf("Unexpected Error when reading object:\n%s", err.Error())
+	}
+	if objType != objects.Tree {
+		t.Errorf("Expected type 'tree', Got: %s", objType)
+	}
+	if string(content) != string(tree.Serialize()) {
+		t.Errorf("Expected content to match the serialized tree, Got: %v", content)
+	}
+	_, _, err = repo.ReadRawObject(objects.MustParseObjectID("95d09f2b10159347eece71399a7e2e907ea3df4f"))
+	if _, ok := err.(*ErrObjectNotFound); !ok {
+		t.Errorf("Expected ErrObjectNotFound for a missing object, Got: %v", err)
+	}
+}
+
+// Test that streamed objects are saved where SaveObject would put them
+func TestSaveStream(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := CreateRepo(tmpDir, "", "")
+	if err != nil {
+		t.Fatalf("Unexpected Error when creating repo:\n%s", err.Error())
+	}
+	repo, err := OpenRepo(tmpDir)
+	if err != nil {
+		t.Fatalf("Unexpected Error when opening repo:\n%s", err.Error())
+	}
+	data := strings.Repeat("streamed ", 1000)
+	blob := &objects.GitBlob{}
+	blob.Deserialize([]byte(data))
+	for i := 0; i < 2; i++ {
+		// Saving the same object twice is allowed
+		hash, err := repo.SaveStream(objects.Blob, int64(len(data)), strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("Unexpected Error when saving stream:\n%s", err.Error())
+		}
+		if hash != objects.Hash(blob) {
+			t.Errorf("Expected hash %s, Got: %s", objects.Hash(blob), hash)
+		}
+	}
+	readBlob, err := repo.GetObject(objects.Hash(blob))
+	if err != nil {
+		t.Fatalf("Unexpected Error when reading object:\n%s", err.Error())
+	}
+	if readBlob.String() != data {
+		t.Errorf("Expected the saved object to have the streamed content")
+	}
+	// No temporary files should be left behind, even when saving fails
+	_, err = repo.SaveStream(objects.Blob, int64(len(data))+1, strings.NewReader(data))
+	if err == nil {
+		t.Errorf("Expected an error when the stream is shorter than its size")
+	}
+	entries, err := os.ReadDir(path.Join(repo.GitDir, "objects"))
+	if err != nil {
+		t.Fatalf("Unexpected Error when reading objects dir:\n%s", err.Error())
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "tmp_obj_") {
+			t.Errorf("Expected temporary object %s to be removed", entry.Name())
+		}
+	}
 }
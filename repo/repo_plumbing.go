@@ -0,0 +1,302 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Plumbing commands for writing trees, commits and tags directly
+
+// WriteTree Create tree objects from the index and return the hash of the root tree. Fails if
+// the index has unmerged entries
+func (repo *Repo) WriteTree() (objects.ObjectID, error) {
+	idx, err := repo.Index()
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	for _, entry := range idx.Entries {
+		if entry.Stage() != 0 {
+			return objects.ObjectID{}, fmt.Errorf("%s: unmerged (%s)", entry.Name, entry.Hash())
+		}
+	}
+	trees, err := indexToTreeMap(idx).allTrees()
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	for _, tree := range trees {
+		err := repo.SaveObject(tree)
+		if err != nil {
+			return objects.ObjectID{}, err
+		}
+	}
+	// The root tree is always the first one
+	return repo.HashAlgorithm().Hash(trees[0]), nil
+}
+
+// ReadTree Read the tree treeish resolves to into the index. Without a prefix the index is
+// replaced by the tree (keeping the 'stat' data of unchanged entries). With a prefix the files
+// of the tree are added to the index under that directory, which must not already be in the
+// index. The worktree is not changed
+func (repo *Repo) ReadTree(treeish string, prefix string) error {
+	treeHash, err := repo.ResolveTree(treeish)
+	if err != nil {
+		return err
+	}
+	idx, err := repo.Index()
+	if err != nil {
+		return err
+	}
+	prefix = cleanRepoPath(prefix)
+	if prefix == "" {
+		newIdx, err := repo.treeToIndex(treeHash, idx)
+		if err != nil {
+			return err
+		}
+		return repo.WriteIndex(newIdx)
+	}
+	for _, entry := range idx.Entries {
+		if isInDir(entry.Name, prefix) || isInDir(prefix, entry.Name) {
+			return fmt.Errorf("entry '%s' overlaps with '%s'; cannot bind", entry.Name, prefix)
+		}
+	}
+	files, err := repo.treeFiles(treeHash)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		file.name = path.Join(prefix, file.name)
+		err := idx.AddEntry(indexEntryFromTree(nil, file))
+		if err != nil {
+			return err
+		}
+	}
+	return repo.WriteIndex(idx)
+}
+
+// ParseTreeEntry Parse a line in the format printed by 'ls-tree' (mode, type, hash, a tab and
+// then the name) into a tree entry. Zero-padded directory modes (i.e. "040000") are accepted
+func ParseTreeEntry(line string) (objects.TreeEntry, error) {
+	tab := strings.IndexByte(line, '\t')
+	if tab < 0 {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+	fields := strings.Split(line[:tab], " ")
+	if len(fields) != 3 {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+	hash, err := objects.ParseObjectID(fields[2])
+	if err != nil {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+	mode := objects.EntryFileMode(fields[0])
+	if mode.ObjectType() == objects.Tree {
+		mode = objects.Directory
+	}
+	return objects.TreeEntry{
+		Mode: mode,
+		Type: objects.GitObjectType(fields[1]),
+		Hash: hash,
+		Name: line[tab+1:],
+	}, nil
+}
+
+// MakeTree Create a tree object from a list of entries and return its hash. The type of each
+// entry must match its mode and, unless allowMissing is set, the object it points to must be
+// in the database. Submodule commits are never checked since they live in another repository
+func (repo *Repo) MakeTree(entries []objects.TreeEntry, allowMissing bool) (objects.ObjectID, error) {
+	tree := objects.NewTree(repo.HashAlgorithm())
+	for _, entry := range entries {
+		if entry.Type != entry.Mode.ObjectType() {
+			return objects.ObjectID{}, fmt.Errorf("entry '%s' object type (%s) doesn't match mode type (%s)",
+				entry.Name, entry.Type, entry.Mode.ObjectType())
+		}
+		if !allowMissing && entry.Type != objects.Commit {
+			reader, err := repo.OpenObject(entry.Hash)
+			if err != nil {
+				return objects.ObjectID{}, fmt.Errorf("entry '%s': %w", entry.Name, err)
+			}
+			objType := reader.Type
+			reader.Close()
+			if objType != entry.Type {
+				return objects.ObjectID{}, fmt.Errorf("entry '%s' object %s is a %s but specified type was (%s)",
+					entry.Name, entry.Hash, objType, entry.Type)
+			}
+		}
+		err := tree.AddEntry(entry.Mode, entry.Name, entry.Hash)
+		if err != nil {
+			return objects.ObjectID{}, err
+		}
+	}
+	err := repo.SaveObject(tree)
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	return repo.HashAlgorithm().Hash(tree), nil
+}
+
+// CommitTree Create a commit object for a tree with the given parents and message and return
+// its hash. The author and committer are read from the config. The message always ends with a
+// single newline like git's, whether or not msg has one. No refs are updated. Like git, a parent
+// given more than once is only added once; the duplicates are returned as warnings
+func (repo *Repo) CommitTree(tree string, parents []string, msg string) (objects.ObjectID, []string, error) {
+	treeHash, err := repo.ResolveTree(tree)
+	if err != nil {
+		return objects.ObjectID{}, nil, err
+	}
+	user, email, err := repo.identity()
+	if err != nil {
+		return objects.ObjectID{}, nil, err
+	}
+	commit := &objects.GitCommit{}
+	commit.TreeHash = treeHash
+	warnings := make([]string, 0)
+	for _, parent := range parents {
+		parentHash, err := repo.ResolveCommit(parent)
+		if err != nil {
+			return objects.ObjectID{}, nil, err
+		}
+		if indexOf(commit.Parents(), parentHash) != -1 {
+			warnings = append(warnings, fmt.Sprintf("duplicate parent %s ignored", parentHash))
+			continue
+		}
+		commit.AddParent(parentHash)
+	}
+	// Serialize adds the final newline
+	commit.Msg = strings.TrimSuffix(msg, "\n")
+	commit.SetAuthor(user, email)
+	commit.SetCommitter(user, email)
+	err = repo.SaveObject(commit)
+	if err != nil {
+		return objects.ObjectID{}, nil, err
+	}
+	return repo.HashAlgorithm().Hash(commit), warnings, nil
+}
+
+// MakeTag Create a tag object from its raw content and return its hash. The content must be a
+// valid tag and the object it points to must be in the database with the type the tag says
+func (repo *Repo) MakeTag(content []byte) (objects.ObjectID, error) {
+	err := objects.ValidateObject(repo.HashAlgorithm(), objects.Tag, content)
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	obj, err := objects.ParseObject(repo.HashAlgorithm(), objects.Tag, content)
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	tagType, target := obj.(*objects.GitTag).Target()
+	reader, err := repo.OpenObject(target)
+	if err != nil {
+		return objects.ObjectID{}, fmt.Errorf("could not read tagged object '%s': %w", target, err)
+	}
+	objType := reader.Type
+	reader.Close()
+	if objType != tagType {
+		return objects.ObjectID{}, fmt.Errorf("object '%s' tagged as '%s', but is a '%s'", target, tagType, objType)
+	}
+	// Save the content as is so the hash matches what was given
+	return repo.SaveStream(objects.Tag, int64(len(content)), bytes.NewReader(content))
+}
+
+// PrettyObject Format the content of an object for 'cat-file -p'. Trees, whose entries are
+// hashes of algo, are listed one entry per line with the type of each entry; everything else is
+// printed as it is stored
+func PrettyObject(algo *objects.HashAlgorithm, objType objects.GitObjectType, content []byte) ([]byte, error) {
+	if objType != objects.Tree {
+		return content, nil
+	}
+	tree := objects.NewTree(algo)
+	err := tree.Deserialize(content)
+	if err != nil {
+		return nil, err
+	}
+	pretty := &bytes.Buffer{}
+	for _, entry := range tree.Entries() {
+		fmt.Fprintf(pretty, "%06o %s %s\t%s\n", entry.Mode.Bits(), entry.Type, entry.Hash, entry.Name)
+	}
+	return pretty.Bytes(), nil
+}
+
+// CatFileBatch Read object names from src, one per line, and write '<hash> <type> <size>' for
+// each of them to dst, followed by the content of the object and a newline if withContent is set.
+// Names that can't be resolved are written as '<name> missing' (or '<name> ambiguous' for
+// prefixes of several objects); a corrupt object is an error. Content is streamed, so only the
+// header of each object is read unless withContent is set
+func (repo *Repo) CatFileBatch(src io.Reader, dst io.Writer, withContent bool) error {
+	scanner := bufio.NewScanner(src)
+	writer := bufio.NewWriter(dst)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		hash, err := repo.FindObject(name)
+		var reader *objects.ObjectReader
+		if err == nil {
+			reader, err = repo.OpenObject(hash)
+		}
+		// Corrupt objects stop the batch; only objects that can't be found are missing
+		if objects.IsBadObject(err) {
+			writer.Flush()
+			return fmt.Errorf("%s: %w", hash, err)
+		}
+		if _, ok := err.(*ErrAmbiguousObject); ok {
+			fmt.Fprintf(writer, "%s ambiguous\n", name)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(writer, "%s missing\n", name)
+			continue
+		}
+		fmt.Fprintf(writer, "%s %s %d\n", hash, reader.Type, reader.Size)
+		if withContent {
+			_, err = io.Copy(writer, reader)
+			if err == nil {
+				err = writer.WriteByte('\n')
+			}
+		}
+		reader.Close()
+		if err != nil {
+			writer.Flush()
+			return fmt.Errorf("%s: %w", hash, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// identity The user name and email from the config, used as the author and committer
+func (repo *Repo) identity() (string, string, error) {
+	configs, err := repo.Config()
+	if err != nil {
+		return "", "", err
+	}
+	user, ok := configValue(configs, "user", "name")
+	if !ok {
+		return "", "", errors.New("no user name set; please set git user name")
+	}
+	email, ok := configValue(configs, "user", "email")
+	if !ok {
+		return "", "", errors.New("no user email set; please set git user email")
+	}
+	return user, email, nil
+}
+
+// headCommit The hash of the commit HEAD points to, or the zero ObjectID if there are no commits
+func (repo *Repo) headCommit() (objects.ObjectID, error) {
+	headHash, err := repo.FindObject("HEAD")
+	if err != nil {
+		// The branch HEAD points to doesn't exist until the first commit is made
+		if _, ok := err.(*os.PathError); ok {
+			return objects.ObjectID{}, nil
+		}
+		return objects.ObjectID{}, err
+	}
+	return headHash, nil
+}
@@ -0,0 +1,298 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Test that the index is written to the same tree git creates and that it can be read back,
+// both in place of the index and under a prefix
+func TestWriteAndReadTree(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	addTestFiles(t, repoStruct, "a.txt", "dir/b.txt")
+	treeHash, err := repoStruct.WriteTree()
+	if err != nil {
+		t.Fatalf("Error writing tree: %s", err)
+	}
+	// Hash of the same files written by 'git write-tree'
+	expectedHash := "4ef45bde9b5dbba5815062bc5978e49dec53de1a"
+	if treeHash.String() != expectedHash {
+		t.Fatalf("Expected tree: %s\nGot: %s", expectedHash, treeHash)
+	}
+	err = repoStruct.ReadTree(treeHash.String(), "sub/")
+	if err != nil {
+		t.Fatalf("Error reading tree with prefix: %s", err)
+	}
+	for _, name := range []string{"a.txt", "dir/b.txt", "sub/a.txt", "sub/dir/b.txt"} {
+		if !indexHasEntry(t, repoStruct, name) {
+			t.Errorf("Expected %s to be in the index", name)
+		}
+	}
+	err = repoStruct.ReadTree(treeHash.String(), "sub")
+	if err == nil {
+		t.Errorf("Expected an error when reading a tree into a prefix that is in the index")
+	}
+	err = repoStruct.ReadTree(treeHash.String(), "a.txt/x")
+	if err == nil {
+		t.Errorf("Expected an error when reading a tree under a file in the index")
+	}
+	err = repoStruct.ReadTree(treeHash.String(), "")
+	if err != nil {
+		t.Fatalf("Error reading tree: %s", err)
+	}
+	if indexHasEntry(t, repoStruct, "sub/a.txt") {
+		t.Errorf("Expected sub/a.txt to be removed when replacing the index")
+	}
+	newHash, err := repoStruct.WriteTree()
+	if err != nil {
+		t.Fatalf("Error writing tree: %s", err)
+	}
+	if newHash != treeHash {
+		t.Errorf("Expected tree: %s after reading it back\nGot: %s", treeHash, newHash)
+	}
+}
+
+// Test that trees can be made from 'ls-tree' lines and that bad entries are rejected
+func TestMakeTree(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	addTestFiles(t, repoStruct, "a.txt", "dir/b.txt")
+	lines := []string{
+		"100644 blob eaa5fa8755fc20f08d0b3da347a5d1868404e462\ta.txt",
+		"040000 tree 0a7dc15c18075d667bcf5baebbf8787c73484bc8\tdir",
+	}
+	subtree := "100644 blob 23d3550c6477d90cbce6073c88169bc6ac555f08\tb.txt"
+	entry, err := ParseTreeEntry(subtree)
+	if err != nil {
+		t.Fatalf("Error parsing entry: %s", err)
+	}
+	subHash, err := repoStruct.MakeTree([]objects.TreeEntry{entry}, false)
+	if err != nil {
+		t.Fatalf("Error making tree: %s", err)
+	}
+	lines[1] = "040000 tree " + subHash.String() + "\tdir"
+	entries := make([]objects.TreeEntry, 0, len(lines))
+	// Entries don't have to be given in order
+	for i := len(lines) - 1; i >= 0; i-- {
+		entry, err := ParseTreeEntry(lines[i])
+		if err != nil {
+			t.Fatalf("Error parsing entry: %s", err)
+		}
+		entries = append(entries, entry)
+	}
+	treeHash, err := repoStruct.MakeTree(entries, false)
+	if err != nil {
+		t.Fatalf("Error making tree: %s", err)
+	}
+	expectedHash := "4ef45bde9b5dbba5815062bc5978e49dec53de1a"
+	if treeHash.String() != expectedHash {
+		t.Errorf("Expected tree: %s\nGot: %s", expectedHash, treeHash)
+	}
+
+	missing := "100644 blob 1111111111111111111111111111111111111111\tmissing"
+	badLines := []string{
+		// Object isn't in the database
+		missing,
+		// Type doesn't match the mode
+		"100644 tree eaa5fa8755fc20f08d0b3da347a5d1868404e462\ta.txt",
+		// Type doesn't match the object
+		"040000 tree eaa5fa8755fc20f08d0b3da347a5d1868404e462\ta.txt",
+	}
+	for _, line := range badLines {
+		entry, err := ParseTreeEntry(line)
+		if err != nil {
+			t.Fatalf("Error parsing entry: %s", err)
+		}
+		_, err = repoStruct.MakeTree([]objects.TreeEntry{entry}, false)
+		if err == nil {
+			t.Errorf("Expected an error making a tree from '%s'", line)
+		}
+	}
+	entry, _ = ParseTreeEntry(missing)
+	_, err = repoStruct.MakeTree([]objects.TreeEntry{entry}, true)
+	if err != nil {
+		t.Errorf("Unexpected error making a tree with a missing object: %s", err)
+	}
+	_, err = ParseTreeEntry("100644 blob eaa5fa8755fc20f08d0b3da347a5d1868404e462 a.txt")
+	if err == nil {
+		t.Errorf("Expected an error parsing a line without a tab")
+	}
+}
+
+// Test that commits are created with all their parents and that the refs are left alone
+func TestCommitTree(t *testing.T) {
+	repoStruct, first := twoCommitRepo(t)
+	second, err := repoStruct.FindObject("HEAD")
+	if err != nil {
+		t.Fatalf("Error finding HEAD: %s", err)
+	}
+	commitHash, warnings, err := repoStruct.CommitTree("HEAD", []string{first, "HEAD"}, "merge\n")
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Error creating commit: %v, %v", err, warnings)
+	}
+	obj, err := repoStruct.GetObject(commitHash)
+	if err != nil {
+		t.Fatalf("Error reading commit: %s", err)
+	}
+	commit := obj.(*objects.GitCommit)
+	parents := commit.Parents()
+	if len(parents) != 2 || parents[0].String() != first || parents[1] != second {
+		t.Errorf("Expected parents %s and %s, Got: %v", first, second, parents)
+	}
+	if commit.Msg != "merge" {
+		t.Errorf("Expected message 'merge', Got: '%s'", commit.Msg)
+	}
+	head, err := repoStruct.FindObject("HEAD")
+	if err != nil {
+		t.Fatalf("Error finding HEAD: %s", err)
+	}
+	if head != second {
+		t.Errorf("Expected HEAD to stay at %s, Got: %s", second, head)
+	}
+	// Like git, duplicate parents are left out with a warning
+	commitHash, warnings, err = repoStruct.CommitTree("HEAD", []string{first, "HEAD", first}, "dup")
+	if err != nil {
+		t.Fatalf("Error creating commit: %s", err)
+	}
+	expected := "duplicate parent " + first + " ignored"
+	if len(warnings) != 1 || warnings[0] != expected {
+		t.Errorf("Expected the warning '%s', Got: %v", expected, warnings)
+	}
+	obj, err = repoStruct.GetObject(commitHash)
+	if err != nil {
+		t.Fatalf("Error reading commit: %s", err)
+	}
+	parents = obj.(*objects.GitCommit).Parents()
+	if len(parents) != 2 || parents[0].String() != first || parents[1] != second {
+		t.Errorf("Expected parents %s and %s, Got: %v", first, second, parents)
+	}
+}
+
+// Test that valid tags are saved unchanged and invalid ones are rejected
+func TestMakeTag(t *testing.T) {
+	repoStruct, first := twoCommitRepo(t)
+	tagger := "tagger Simon Taye <mulat.simon@gmail.com> 1625088346 +0000\n"
+	content := fmt.Sprintf("object %s\ntype commit\ntag v1\n%s\nfirst release\n", first, tagger)
+	tagHash, err := repoStruct.MakeTag([]byte(content))
+	if err != nil {
+		t.Fatalf("Error making tag: %s", err)
+	}
+	expectedHash, err := objects.HashReader(objects.Tag, int64(len(content)), strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Error hashing tag: %s", err)
+	}
+	if tagHash != expectedHash {
+		t.Errorf("Expected tag: %s\nGot: %s", expectedHash, tagHash)
+	}
+	objType, saved, err := repoStruct.ReadRawObject(tagHash)
+	if err != nil {
+		t.Fatalf("Error reading tag: %s", err)
+	}
+	if objType != objects.Tag || string(saved) != content {
+		t.Errorf("Expected tag content:\n%s\nGot %s:\n%s", content, objType, saved)
+	}
+	badTags := []string{
+		// Missing the tag name
+		fmt.Sprintf("object %s\ntype commit\n%s\nmsg\n", first, tagger),
+		// Wrong type for the object
+		fmt.Sprintf("object %s\ntype tree\ntag v1\n%s\nmsg\n", first, tagger),
+		// Object isn't in the database
+		fmt.Sprintf("object %s\ntype commit\ntag v1\n%s\nmsg\n", "1111111111111111111111111111111111111111", tagger),
+	}
+	for _, tag := range badTags {
+		_, err = repoStruct.MakeTag([]byte(tag))
+		if err == nil {
+			t.Errorf("Expected an error making tag:\n%s", tag)
+		}
+	}
+}
+
+// Test the '--batch' and '--batch-check' output of cat-file against git's
+func TestCatFileBatch(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	saveRawObject(t, repoStruct, objects.Blob, "hello\n")
+	saveRawObject(t, repoStruct, objects.Tree, "")
+	saveRawObject(t, repoStruct, objects.Blob, ambiguousBlob)
+	saveRawObject(t, repoStruct, objects.Tree, ambiguousTree)
+	tree := catFileTestTree(t, repoStruct)
+	input := "ce013625030ba8dba906f756967f9e9ca394464a\nhello.txt\n" + tree.String() + "\n20014\n"
+	// Output of 'git cat-file --batch-check' for the same objects
+	expected := "ce013625030ba8dba906f756967f9e9ca394464a blob 6\n" +
+		"hello.txt missing\n" +
+		"b7545114dee84ef66374b774a7fb0e09f06042bf tree 101\n" +
+		"20014 ambiguous\n"
+	output := &strings.Builder{}
+	err = repoStruct.CatFileBatch(strings.NewReader(input), output, false)
+	if err != nil || output.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s (%v)", expected, output, err)
+	}
+	// Each object's content follows its line and is terminated by a newline
+	expected = "ce013625030ba8dba906f756967f9e9ca394464a blob 6\nhello\n\n" +
+		"nope missing\n" +
+		"4b825dc642cb6eb9a060e54bf8d69288fbee4904 tree 0\n\n"
+	output.Reset()
+	err = repoStruct.CatFileBatch(strings.NewReader("ce0136\nnope\n4b825dc\n"), output, true)
+	if err != nil || output.String() != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q (%v)", expected, output, err)
+	}
+}
+
+// Test that trees are listed like 'git cat-file -p' and other objects are printed as they are
+func TestPrettyObject(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	saveRawObject(t, repoStruct, objects.Blob, "hello\n")
+	saveRawObject(t, repoStruct, objects.Tree, "")
+	tree := catFileTestTree(t, repoStruct)
+	objType, content, err := repoStruct.ReadRawObject(tree)
+	if err != nil {
+		t.Fatalf("Error reading tree: %s", err)
+	}
+	pretty, err := PrettyObject(repoStruct.HashAlgorithm(), objType, content)
+	// Output of 'git cat-file -p' for the same tree
+	expected := "100644 blob ce013625030ba8dba906f756967f9e9ca394464a\thello.txt\n" +
+		"100755 blob ce013625030ba8dba906f756967f9e9ca394464a\trun.sh\n" +
+		"040000 tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\tsub\n"
+	if err != nil || string(pretty) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s (%v)", expected, pretty, err)
+	}
+	pretty, err = PrettyObject(repoStruct.HashAlgorithm(), objects.Blob, []byte("hello\n"))
+	if err != nil || string(pretty) != "hello\n" {
+		t.Errorf("Expected a blob to be printed as it is, Got: %q (%v)", pretty, err)
+	}
+}
+
+// Save a tree with a file, an executable and an empty directory. The blob and the empty tree
+// must already be saved
+func catFileTestTree(t *testing.T, repoStruct *Repo) objects.ObjectID {
+	entries := make([]objects.TreeEntry, 0)
+	for _, line := range []string{
+		"100644 blob ce013625030ba8dba906f756967f9e9ca394464a\thello.txt",
+		"100755 blob ce013625030ba8dba906f756967f9e9ca394464a\trun.sh",
+		"040000 tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\tsub",
+	} {
+		entry, err := ParseTreeEntry(line)
+		if err != nil {
+			t.Fatalf("Error parsing entry: %s", err)
+		}
+		entries = append(entries, entry)
+	}
+	tree, err := repoStruct.MakeTree(entries, false)
+	if err != nil {
+		t.Fatalf("Error making tree: %s", err)
+	}
+	return tree
+}
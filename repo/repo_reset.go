@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"github.com/SimonMTaye/gitgo/index"
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// ResetMode Controls how much of the repository Reset changes
+type ResetMode int
+
+const (
+	// ResetSoft Only move the current branch
+	ResetSoft ResetMode = iota
+	// ResetMixed Move the current branch and reset the index to the target commit
+	ResetMixed
+	// ResetHard Move the current branch and reset both the index and the worktree
+	ResetHard
+)
+
+// Reset Point the current branch (or HEAD if it is detached) at the commit target resolves to.
+// Depending on the mode, the index and the worktree are also reset to match the commit.
+// Untracked files in the worktree are never touched
+func (repo *Repo) Reset(target string, mode ResetMode) error {
+	commitHash, err := repo.ResolveCommit(target)
+	if err != nil {
+		return err
+	}
+	if mode != ResetSoft {
+		treeHash, err := repo.peelObject(commitHash, objects.Tree)
+		if err != nil {
+			return err
+		}
+		oldIdx, err := repo.Index()
+		if err != nil {
+			return err
+		}
+		newIdx, err := repo.treeToIndex(treeHash, oldIdx)
+		if err != nil {
+			return err
+		}
+		if mode == ResetHard {
+			err = repo.checkoutIndex(oldIdx, newIdx)
+			if err != nil {
+				return err
+			}
+		}
+		err = repo.WriteIndex(newIdx)
+		if err != nil {
+			return err
+		}
+	}
+	return repo.UpdateCurrentBranch(commitHash)
+}
+
+// checkoutIndex Make the worktree match newIdx. Files tracked in oldIdx but not in newIdx are
+// deleted, and files that are unchanged in both the index and the worktree are left alone
+func (repo *Repo) checkoutIndex(oldIdx *index.Index, newIdx *index.Index) error {
+	for _, entry := range oldIdx.Entries {
+		if exists, _ := newIdx.EntryExists(entry.Name); !exists {
+			err := repo.removeWorktreeFile(entry.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, entry := range newIdx.Entries {
+		// treeToIndex reuses entries of the old index that didn't change
+		if exists, pos := oldIdx.EntryExists(entry.Name); exists && oldIdx.Entries[pos] == entry {
+			modified, err := repo.IsModified(oldIdx, entry)
+			if err != nil {
+				return err
+			}
+			if !modified {
+				continue
+			}
+		}
+		err := repo.checkoutEntry(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
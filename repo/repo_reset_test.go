@@ -0,0 +1,200 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+// Write content to a file in the worktree through the repo's filesystem, creating its directory
+func writeRepoFile(t *testing.T, repoStruct *Repo, name string, content string) {
+	filePath := path.Join(repoStruct.Worktree, name)
+	err := repoStruct.FS().MkdirAll(path.Dir(filePath), DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating dir for %s: %s", name, err)
+	}
+	err = repoStruct.FS().WriteFile(filePath, []byte(content), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing %s: %s", name, err)
+	}
+}
+
+// Read a file in the worktree
+func readWorktreeFile(t *testing.T, repoStruct *Repo, file string) string {
+	data, err := os.ReadFile(path.Join(repoStruct.Worktree, file))
+	if err != nil {
+		t.Fatalf("Error reading file: %s", err)
+	}
+	return string(data)
+}
+
+// Create a repo with two commits. The first has a.txt containing "first", the second changes
+// a.txt to "second" and adds b.txt. Returns the repo and the hash of the first commit
+func twoCommitRepo(t *testing.T) (*Repo, string) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	setTestIdentity(t, repoStruct)
+	writeRepoFile(t, repoStruct, "a.txt", "first")
+	err = repoStruct.AddFile("a.txt")
+	if err != nil {
+		t.Fatalf("Error adding file: %s", err)
+	}
+	err = repoStruct.Commit("first")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	first, err := repoStruct.FindObject("HEAD")
+	if err != nil {
+		t.Fatalf("Error finding HEAD: %s", err)
+	}
+	writeRepoFile(t, repoStruct, "a.txt", "second")
+	writeRepoFile(t, repoStruct, "b.txt", "new")
+	for _, file := range []string{"a.txt", "b.txt"} {
+		err = repoStruct.AddFile(file)
+		if err != nil {
+			t.Fatalf("Error adding file: %s", err)
+		}
+	}
+	err = repoStruct.Commit("second")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	return repoStruct, first.String()
+}
+
+// Check that the hash of the staged version of a file matches the one in a commit's tree
+func stagedMatchesCommit(t *testing.T, repoStruct *Repo, file string, commit string) bool {
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	exists, pos := idx.EntryExists(file)
+	if !exists {
+		return false
+	}
+	treeHash, err := repoStruct.ResolveTree(commit)
+	if err != nil {
+		t.Fatalf("Error resolving tree: %s", err)
+	}
+	_, hash, err := repoStruct.treeEntryAtPath(treeHash, file)
+	if err != nil {
+		return false
+	}
+	return hash == idx.Entries[pos].Hash()
+}
+
+func TestResetModes(t *testing.T) {
+	repoStruct, first := twoCommitRepo(t)
+	second, err := repoStruct.FindObject("HEAD")
+	if err != nil {
+		t.Fatalf("Error finding HEAD: %s", err)
+	}
+
+	// Soft only moves the branch
+	err = repoStruct.Reset(first, ResetSoft)
+	if err != nil {
+		t.Fatalf("Error resetting: %s", err)
+	}
+	head, _ := repoStruct.FindObject("HEAD")
+	if head.String() != first {
+		t.Errorf("Expected HEAD to be %s after soft reset, Got: %s", first, head)
+	}
+	if !stagedMatchesCommit(t, repoStruct, "a.txt", second.String()) || !indexHasEntry(t, repoStruct, "b.txt") {
+		t.Errorf("Expected the index to be untouched by a soft reset")
+	}
+
+	// Mixed resets the index but not the worktree
+	err = repoStruct.Reset(first, ResetMixed)
+	if err != nil {
+		t.Fatalf("Error resetting: %s", err)
+	}
+	if !stagedMatchesCommit(t, repoStruct, "a.txt", first) || indexHasEntry(t, repoStruct, "b.txt") {
+		t.Errorf("Expected the index to match the first commit after a mixed reset")
+	}
+	if readWorktreeFile(t, repoStruct, "a.txt") != "second" || !worktreeFileExists(repoStruct, "b.txt") {
+		t.Errorf("Expected the worktree to be untouched by a mixed reset")
+	}
+
+	// Moving back to the second commit and then resetting hard changes everything
+	err = repoStruct.Reset(second.String(), ResetMixed)
+	if err != nil {
+		t.Fatalf("Error resetting: %s", err)
+	}
+	err = repoStruct.Reset(first, ResetHard)
+	if err != nil {
+		t.Fatalf("Error resetting: %s", err)
+	}
+	head, _ = repoStruct.FindObject("HEAD")
+	if head.String() != first {
+		t.Errorf("Expected HEAD to be %s after hard reset, Got: %s", first, head)
+	}
+	if readWorktreeFile(t, repoStruct, "a.txt") != "first" {
+		t.Errorf("Expected a.txt to be restored to the first commit after a hard reset")
+	}
+	if worktreeFileExists(repoStruct, "b.txt") {
+		t.Errorf("Expected b.txt to be deleted by a hard reset")
+	}
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	for _, entry := range idx.Entries {
+		modified, err := repoStruct.IsModified(idx, entry)
+		if err != nil {
+			t.Fatalf("Error checking file: %s", err)
+		}
+		if modified {
+			t.Errorf("Did not expect %s to be modified after a hard reset", entry.Name)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	repoStruct, first := twoCommitRepo(t)
+
+	// Restoring the worktree uses the index by default
+	writeRepoFile(t, repoStruct, "a.txt", "local change")
+	restored, err := repoStruct.Restore([]string{"a.txt"}, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("Error restoring: %s", err)
+	}
+	if len(restored) != 1 || readWorktreeFile(t, repoStruct, "a.txt") != "second" {
+		t.Errorf("Expected a.txt to be restored from the index, Got: %v", restored)
+	}
+
+	// Unstage a change
+	writeRepoFile(t, repoStruct, "a.txt", "staged change")
+	err = repoStruct.AddFile("a.txt")
+	if err != nil {
+		t.Fatalf("Error adding file: %s", err)
+	}
+	_, err = repoStruct.Restore([]string{"a.txt"}, RestoreOptions{Staged: true})
+	if err != nil {
+		t.Fatalf("Error restoring: %s", err)
+	}
+	if !stagedMatchesCommit(t, repoStruct, "a.txt", "HEAD") {
+		t.Errorf("Expected the staged a.txt to match HEAD")
+	}
+	if readWorktreeFile(t, repoStruct, "a.txt") != "staged change" {
+		t.Errorf("Expected the worktree to be untouched when restoring the index")
+	}
+
+	// Restore both from an older commit; b.txt doesn't exist there so it is removed
+	_, err = repoStruct.Restore([]string{"."}, RestoreOptions{Source: first, Staged: true, Worktree: true})
+	if err != nil {
+		t.Fatalf("Error restoring: %s", err)
+	}
+	if !stagedMatchesCommit(t, repoStruct, "a.txt", first) || readWorktreeFile(t, repoStruct, "a.txt") != "first" {
+		t.Errorf("Expected a.txt to be restored from the first commit")
+	}
+	if indexHasEntry(t, repoStruct, "b.txt") || worktreeFileExists(repoStruct, "b.txt") {
+		t.Errorf("Expected b.txt to be removed since it isn't in the source")
+	}
+
+	_, err = repoStruct.Restore([]string{"missing.txt"}, RestoreOptions{})
+	if err == nil {
+		t.Errorf("Expected an error when restoring a path that doesn't exist")
+	}
+}
@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// RestoreOptions Options for Repo.Restore; they mirror the flags of 'git restore'
+type RestoreOptions struct {
+	// Source Name of the tree-ish (commit, tag, tree...) to restore from. When empty, the
+	// index is restored from HEAD and the worktree from the index
+	Source string
+	// Staged Restore the index
+	Staged bool
+	// Worktree Restore the worktree. This is the default if neither Staged nor Worktree is set
+	Worktree bool
+}
+
+// Restore Restore the given paths (files or directories relative to the worktree) in the index
+// and/or the worktree from a source. Files matching a path that don't exist in the source are
+// removed from whatever is being restored. Returns the names of the restored files
+func (repo *Repo) Restore(paths []string, opts RestoreOptions) ([]string, error) {
+	if !opts.Staged && !opts.Worktree {
+		opts.Worktree = true
+	}
+	idx, err := repo.Index()
+	if err != nil {
+		return nil, err
+	}
+	// Restoring only the worktree without a source uses the index as the source
+	fromIndex := opts.Source == "" && !opts.Staged
+	sourceFiles := make([]treeFile, 0)
+	if !fromIndex {
+		var treeHash objects.ObjectID
+		if opts.Source != "" {
+			treeHash, err = repo.ResolveTree(opts.Source)
+		} else {
+			treeHash, err = repo.headTreeHash()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !treeHash.IsZero() {
+			sourceFiles, err = repo.treeFiles(treeHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		cleaned = append(cleaned, cleanRepoPath(p))
+	}
+	matchedSource := make(map[string]treeFile)
+	for _, file := range sourceFiles {
+		if matchesAnyPath(file.name, cleaned) {
+			matchedSource[file.name] = file
+		}
+	}
+	matchedIndex := make([]string, 0)
+	for _, entry := range idx.Entries {
+		if matchesAnyPath(entry.Name, cleaned) {
+			matchedIndex = append(matchedIndex, entry.Name)
+		}
+	}
+	known := append([]string{}, matchedIndex...)
+	for name := range matchedSource {
+		known = append(known, name)
+	}
+	for _, p := range cleaned {
+		if !pathMatchesAny(p, known) {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", p)
+		}
+	}
+
+	restored := make(map[string]bool)
+	if fromIndex {
+		for _, name := range matchedIndex {
+			_, pos := idx.EntryExists(name)
+			err = repo.checkoutEntry(idx.Entries[pos])
+			if err != nil {
+				return nil, err
+			}
+			restored[name] = true
+		}
+		return sortedNames(restored), repo.WriteIndex(idx)
+	}
+
+	// Files that are tracked but missing from the source are removed
+	for _, name := range matchedIndex {
+		if _, ok := matchedSource[name]; ok {
+			continue
+		}
+		if opts.Staged {
+			_, pos := idx.EntryExists(name)
+			err = idx.DeleteEntry(pos)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opts.Worktree {
+			err = repo.removeWorktreeFile(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		restored[name] = true
+	}
+	for name, file := range matchedSource {
+		if opts.Staged {
+			entry := indexEntryFromTree(idx, file)
+			err = idx.AddEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			if opts.Worktree {
+				err = repo.checkoutEntry(entry)
+			}
+		} else {
+			// The index is left alone so its 'stat' information must not be refreshed
+			err = repo.CheckoutFile(file.name, file.hash, file.mode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		restored[name] = true
+	}
+	return sortedNames(restored), repo.WriteIndex(idx)
+}
+
+// matchesAnyPath Check if a file name is one of paths or is inside one of them
+func matchesAnyPath(name string, paths []string) bool {
+	for _, p := range paths {
+		if name == p || isInDir(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathMatchesAny Check if any of the names is p or is inside it
+func pathMatchesAny(p string, names []string) bool {
+	for _, name := range names {
+		if name == p || isInDir(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
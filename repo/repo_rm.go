@@ -1,83 +1,197 @@
 package repo
 
 import (
-	"encoding/hex"
-	"errors"
 	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/index"
 	"github.com/SimonMTaye/gitgo/objects"
 )
 
-func (repo *Repo) Rm(file string) error {
+// RmOptions Options for Repo.Rm; they mirror the flags of 'git rm'
+type RmOptions struct {
+	// Cached Only remove the files from the index, leaving the worktree untouched
+	Cached bool
+	// Recursive Allow directories to be removed along with all the files inside them
+	Recursive bool
+	// Force Remove files even if they have staged or unstaged changes
+	Force bool
+}
+
+// ErrRmChanges Returned by Rm when files can't be removed without losing changes
+type ErrRmChanges struct {
+	reason string
+	files  []string
+}
 
+func (e *ErrRmChanges) Error() string {
+	return fmt.Sprintf("the following file(s) have %s:\n    %s\n", e.reason, strings.Join(e.files, "\n    "))
+}
+
+// Rm Remove files from the index and (unless opts.Cached is set) from the worktree.
+// Paths are relative to the worktree; directories are only removed when opts.Recursive is set.
+// Like git, files whose staged content differs from HEAD or from the worktree won't be
+// removed unless opts.Force is set. Nothing is removed if any path fails these checks.
+// Returns the names of the files that were removed
+func (repo *Repo) Rm(paths []string, opts RmOptions) ([]string, error) {
 	idx, err := repo.Index()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	exists, pos := idx.EntryExists(file)
-	if exists {
-		hash, err := repo.getFileLastHash(file)
-		// If the error is not real, we assume the file does not exist in the previous commits tree, thus the rm
-		// file deletes the file from the index
+	matched, err := matchIndexPaths(idx, paths, opts.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Force {
+		err = repo.checkRmChanges(idx, matched, opts.Cached)
 		if err != nil {
-			fmt.Println(err)
-			err = idx.DeleteEntry(pos)
-			if err != nil {
-				return err
-			}
-			err = repo.WriteIndex(idx)
-			if err != nil {
-				return err
-			}
-			return nil
+			return nil, err
 		}
-		// If the file does exist, revert the hash
-		hashBytes, err := hashToBytes(hash)
+	}
+	for _, name := range matched {
+		_, pos := idx.EntryExists(name)
+		err = idx.DeleteEntry(pos)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = idx.ModifyFileHash(file, hashBytes)
-		return err
+	}
+	err = repo.WriteIndex(idx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Cached {
+		return matched, nil
+	}
+	for _, name := range matched {
+		err = repo.removeWorktreeFile(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matched, nil
+}
 
-	} else {
-		return errors.New(fmt.Sprintf("File %s not found in index", file))
+// matchIndexPaths Find the names of all index entries matching paths. A path matches an entry
+// with the same name or, if recursive is set, every entry inside it when it is a directory
+func matchIndexPaths(idx *index.Index, paths []string, recursive bool) ([]string, error) {
+	seen := make(map[string]bool)
+	matched := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = cleanRepoPath(p)
+		if exists, _ := idx.EntryExists(p); exists {
+			if !seen[p] {
+				seen[p] = true
+				matched = append(matched, p)
+			}
+			continue
+		}
+		inDir := make([]string, 0)
+		for _, entry := range idx.Entries {
+			if isInDir(entry.Name, p) {
+				inDir = append(inDir, entry.Name)
+			}
+		}
+		if len(inDir) == 0 {
+			return nil, fmt.Errorf("pathspec '%s' did not match any files", p)
+		}
+		if !recursive {
+			return nil, fmt.Errorf("not removing '%s' recursively without -r", p)
+		}
+		for _, name := range inDir {
+			if !seen[name] {
+				seen[name] = true
+				matched = append(matched, name)
+			}
+		}
 	}
+	sort.Strings(matched)
+	return matched, nil
 }
 
-func (repo *Repo) getFileLastHash(file string) (string, error) {
-	headHash, err := repo.FindObject("HEAD")
+// Check that removing the named entries won't lose any changes. The checks are the same as
+// git's: a file whose staged content matches neither HEAD nor the worktree is never removed.
+// Otherwise, unless only the index is being modified, staged and unstaged changes both block
+// removal. Files missing from the worktree are always safe to remove
+func (repo *Repo) checkRmChanges(idx *index.Index, names []string, cached bool) error {
+	headTree, err := repo.headTreeHash()
 	if err != nil {
-		return "", err
+		return err
 	}
-	obj, err := repo.GetObject(headHash)
-	if err != nil {
-		fmt.Println("Could not open head")
-		return "", err
+	stagedAndLocal := make([]string, 0)
+	staged := make([]string, 0)
+	local := make([]string, 0)
+	for _, name := range names {
+		_, pos := idx.EntryExists(name)
+		entry := idx.Entries[pos]
+		_, err := repo.FS().Lstat(path.Join(repo.Worktree, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		localChanges, err := repo.IsModified(idx, entry)
+		if err != nil {
+			return err
+		}
+		stagedChanges := true
+		if !headTree.IsZero() {
+			mode, hash, err := repo.treeEntryAtPath(headTree, name)
+			if err == nil {
+				stagedChanges = mode != objects.FileModeFromBits(entry.Metadata.FileMode) ||
+					hash != entry.Hash()
+			}
+		}
+		if localChanges && stagedChanges {
+			stagedAndLocal = append(stagedAndLocal, name)
+		} else if !cached {
+			if stagedChanges {
+				staged = append(staged, name)
+			}
+			if localChanges {
+				local = append(local, name)
+			}
+		}
 	}
-	commit, ok := obj.(*objects.GitCommit)
-	if !ok {
-		return "", errors.New("HEAD is not a commit")
+	if len(stagedAndLocal) > 0 {
+		return &ErrRmChanges{reason: "staged content different from both the file and the HEAD", files: stagedAndLocal}
 	}
-	treeObj, err := repo.GetObject(commit.TreeHash)
-	if err != nil {
-		fmt.Println("Could not open head commit")
-		return "", err
+	if len(staged) > 0 {
+		return &ErrRmChanges{reason: "changes staged in the index", files: staged}
 	}
-	tree, ok := treeObj.(*objects.GitTree)
-	if !ok {
-		return "", errors.New(fmt.Sprintf("commit tree hash does not point to tree\nHash: %s", commit.TreeHash))
+	if len(local) > 0 {
+		return &ErrRmChanges{reason: "local modifications", files: local}
 	}
-	return tree.GetEntryHash(file)
+	return nil
 }
 
-func hashToBytes(hash string) (*[20]byte, error) {
-	hashBytes := new([20]byte)
-	n, err := hex.Decode(hashBytes[:], []byte(hash))
-	if err != nil {
-		return nil, err
+// Delete a file from the worktree along with any parent directories left empty by the removal
+func (repo *Repo) removeWorktreeFile(name string) error {
+	err := repo.FS().Remove(path.Join(repo.Worktree, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	if n != 20 {
-		return nil, fmt.Errorf("%d characters were read, but 20 were expected", n)
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		// Remove fails on directories that aren't empty, which is when we stop
+		if repo.FS().Remove(path.Join(repo.Worktree, dir)) != nil {
+			break
+		}
 	}
-	return hashBytes, nil
+	return nil
+}
+
+// cleanRepoPath Normalize a path relative to the worktree. The worktree itself is ""
+func cleanRepoPath(p string) string {
+	p = path.Clean(strings.ReplaceAll(p, "\\", "/"))
+	if p == "." || p == "/" {
+		return ""
+	}
+	return strings.TrimPrefix(p, "./")
+}
+
+// isInDir Check if the file name is inside the directory dir. Every file is inside ""
+func isInDir(name string, dir string) bool {
+	return dir == "" || strings.HasPrefix(name, dir+"/")
 }
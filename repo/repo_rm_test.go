@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+// Create the given files (relative to the worktree) with their name as content and add them
+func addTestFiles(t *testing.T, repoStruct *Repo, files ...string) {
+	for _, file := range files {
+		filePath := path.Join(repoStruct.Worktree, file)
+		err := os.MkdirAll(path.Dir(filePath), DirFilemode)
+		if err != nil {
+			t.Fatalf("Error creating directory: %s", err)
+		}
+		err = os.WriteFile(filePath, []byte(file+"\n"), NormalFilemode)
+		if err != nil {
+			t.Fatalf("Error creating file: %s", err)
+		}
+		err = repoStruct.AddFile(file)
+		if err != nil {
+			t.Fatalf("Error adding file: %s", err)
+		}
+	}
+}
+
+// Check whether a file exists in the worktree
+func worktreeFileExists(repoStruct *Repo, file string) bool {
+	_, err := os.Lstat(path.Join(repoStruct.Worktree, file))
+	return err == nil
+}
+
+// Check whether an entry exists in the index of the repo
+func indexHasEntry(t *testing.T, repoStruct *Repo, name string) bool {
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	exists, _ := idx.EntryExists(name)
+	return exists
+}
+
+// Test that committed files are removed from both the index and the worktree and that
+// directories are only removed with the recursive option
+func TestRm(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	setTestIdentity(t, repoStruct)
+	addTestFiles(t, repoStruct, "a.txt", "dir/b.txt", "dir/sub/c.txt")
+	err = repoStruct.Commit("initial commit")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+
+	removed, err := repoStruct.Rm([]string{"a.txt"}, RmOptions{})
+	if err != nil {
+		t.Fatalf("Error removing file: %s", err)
+	}
+	if len(removed) != 1 || removed[0] != "a.txt" {
+		t.Errorf("Expected only 'a.txt' to be removed, Got: %v", removed)
+	}
+	if indexHasEntry(t, repoStruct, "a.txt") || worktreeFileExists(repoStruct, "a.txt") {
+		t.Errorf("Expected 'a.txt' to be removed from the index and the worktree")
+	}
+
+	_, err = repoStruct.Rm([]string{"dir"}, RmOptions{})
+	if err == nil {
+		t.Errorf("Expected an error when removing a directory without the recursive option")
+	}
+	removed, err = repoStruct.Rm([]string{"./dir/"}, RmOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("Error removing directory: %s", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 files to be removed, Got: %v", removed)
+	}
+	if worktreeFileExists(repoStruct, "dir") {
+		t.Errorf("Expected the emptied directory 'dir' to be removed")
+	}
+
+	_, err = repoStruct.Rm([]string{"missing.txt"}, RmOptions{})
+	if err == nil {
+		t.Errorf("Expected an error when removing a file that isn't in the index")
+	}
+}
+
+// Test that files with staged or local changes are only removed when it is safe
+func TestRmChecks(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	setTestIdentity(t, repoStruct)
+	addTestFiles(t, repoStruct, "committed.txt")
+	err = repoStruct.Commit("initial commit")
+	if err != nil {
+		t.Fatalf("Error committing: %s", err)
+	}
+	addTestFiles(t, repoStruct, "new.txt")
+
+	// A newly added file has changes staged in the index
+	_, err = repoStruct.Rm([]string{"new.txt"}, RmOptions{})
+	if _, ok := err.(*ErrRmChanges); !ok {
+		t.Errorf("Expected ErrRmChanges when removing a newly added file, Got: %v", err)
+	}
+	_, err = repoStruct.Rm([]string{"new.txt"}, RmOptions{Cached: true})
+	if err != nil {
+		t.Fatalf("Unexpected error when removing a new file from the index: %s", err)
+	}
+	if indexHasEntry(t, repoStruct, "new.txt") || !worktreeFileExists(repoStruct, "new.txt") {
+		t.Errorf("Expected 'new.txt' to be removed from the index but kept in the worktree")
+	}
+
+	// Local modifications only block removal from the worktree
+	err = os.WriteFile(path.Join(repoStruct.Worktree, "committed.txt"), []byte("changed"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error modifying file: %s", err)
+	}
+	_, err = repoStruct.Rm([]string{"committed.txt"}, RmOptions{})
+	if _, ok := err.(*ErrRmChanges); !ok {
+		t.Errorf("Expected ErrRmChanges when removing a modified file, Got: %v", err)
+	}
+	if !indexHasEntry(t, repoStruct, "committed.txt") {
+		t.Errorf("Expected 'committed.txt' to still be in the index after a failed removal")
+	}
+
+	// Staged content that matches neither HEAD nor the worktree is never removed without force
+	err = repoStruct.AddFile("committed.txt")
+	if err != nil {
+		t.Fatalf("Error adding file: %s", err)
+	}
+	err = os.WriteFile(path.Join(repoStruct.Worktree, "committed.txt"), []byte("changed again"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error modifying file: %s", err)
+	}
+	_, err = repoStruct.Rm([]string{"committed.txt"}, RmOptions{Cached: true})
+	if _, ok := err.(*ErrRmChanges); !ok {
+		t.Errorf("Expected ErrRmChanges when removing a file with staged and local changes, Got: %v", err)
+	}
+	_, err = repoStruct.Rm([]string{"committed.txt"}, RmOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Unexpected error when forcing removal: %s", err)
+	}
+	if indexHasEntry(t, repoStruct, "committed.txt") || worktreeFileExists(repoStruct, "committed.txt") {
+		t.Errorf("Expected 'committed.txt' to be removed when forced")
+	}
+}
@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/index"
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Functions for reading trees out of the object database and converting them into index entries
+
+// treeFile A file (i.e. anything other than a subtree) found when walking a tree.
+// The name is the full path of the file relative to the root tree
+type treeFile struct {
+	name string
+	mode objects.EntryFileMode
+	hash objects.ObjectID
+}
+
+// headTreeHash Returns the hash of the tree the HEAD commit points to. The zero ObjectID is
+// returned if there are no commits yet
+func (repo *Repo) headTreeHash() (objects.ObjectID, error) {
+	treeHash, err := repo.ResolveTree("HEAD")
+	if err != nil {
+		// The branch HEAD points to doesn't exist until the first commit is made
+		if _, ok := err.(*os.PathError); ok {
+			return objects.ObjectID{}, nil
+		}
+		return objects.ObjectID{}, err
+	}
+	return treeHash, nil
+}
+
+// treeEntryAtPath Find the mode and hash of the entry at filePath (which may be nested in
+// subdirectories) inside the tree with the given hash
+func (repo *Repo) treeEntryAtPath(treeHash objects.ObjectID, filePath string) (objects.EntryFileMode, objects.ObjectID, error) {
+	mode, hash := objects.Directory, treeHash
+	for _, name := range strings.Split(filePath, "/") {
+		if mode.ObjectType() != objects.Tree {
+			return "", objects.ObjectID{}, fmt.Errorf("entry '%s' not found", filePath)
+		}
+		tree, err := repo.readTree(hash)
+		if err != nil {
+			return "", objects.ObjectID{}, err
+		}
+		mode, hash, err = tree.GetEntry(name)
+		if err != nil {
+			return "", objects.ObjectID{}, fmt.Errorf("entry '%s' not found", filePath)
+		}
+	}
+	return mode, hash, nil
+}
+
+// readTree Return the tree object with the given hash
+func (repo *Repo) readTree(treeHash objects.ObjectID) (*objects.GitTree, error) {
+	obj, err := repo.GetObject(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	tree, ok := obj.(*objects.GitTree)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a tree", treeHash)
+	}
+	return tree, nil
+}
+
+// treeFiles Recursively list all the files in a tree, sorted by name like index entries
+func (repo *Repo) treeFiles(treeHash objects.ObjectID) ([]treeFile, error) {
+	files := make([]treeFile, 0)
+	err := repo.walkTreeFiles(treeHash, "", func(file treeFile) {
+		files = append(files, file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	return files, nil
+}
+
+// Helper for treeFiles. Calls fn for every file in the tree with prefix added to its name
+func (repo *Repo) walkTreeFiles(treeHash objects.ObjectID, prefix string, fn func(file treeFile)) error {
+	tree, err := repo.readTree(treeHash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries() {
+		name := path.Join(prefix, entry.Name)
+		if entry.Type == objects.Tree {
+			err = repo.walkTreeFiles(entry.Hash, name, fn)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		fn(treeFile{name: name, mode: entry.Mode, hash: entry.Hash})
+	}
+	return nil
+}
+
+// peelObject Follow tags (and commits, when a tree is wanted) from the object with the given
+// hash until an object of type want is found. Returns the hash of that object
+func (repo *Repo) peelObject(hash objects.ObjectID, want objects.GitObjectType) (objects.ObjectID, error) {
+	for {
+		obj, err := repo.GetObject(hash)
+		if err != nil {
+			return objects.ObjectID{}, err
+		}
+		if obj.Type() == want {
+			return hash, nil
+		}
+		switch o := obj.(type) {
+		case *objects.GitTag:
+			_, hash = o.Target()
+		case *objects.GitCommit:
+			if want != objects.Tree {
+				return objects.ObjectID{}, fmt.Errorf("%s is a commit, not a %s", hash, want)
+			}
+			hash = o.TreeHash
+		default:
+			return objects.ObjectID{}, fmt.Errorf("%s is a %s, not a %s", hash, obj.Type(), want)
+		}
+	}
+}
+
+// ResolveTree Resolve a name (branch, tag, HEAD or hash) to a tree, following commits and tags
+func (repo *Repo) ResolveTree(name string) (objects.ObjectID, error) {
+	hash, err := repo.FindObject(name)
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	return repo.peelObject(hash, objects.Tree)
+}
+
+// ResolveCommit Resolve a name (branch, tag, HEAD or hash) to a commit, following tags
+func (repo *Repo) ResolveCommit(name string) (objects.ObjectID, error) {
+	hash, err := repo.FindObject(name)
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	return repo.peelObject(hash, objects.Commit)
+}
+
+// indexEntryFromTree Create an index entry for a file found in a tree. The entry has no 'stat'
+// information so it is treated as modified until it is refreshed from the worktree. If the
+// old index already has an identical entry, that entry is reused to keep its 'stat' data
+func indexEntryFromTree(oldIdx *index.Index, file treeFile) *index.Entry {
+	if oldIdx != nil {
+		if exists, pos := oldIdx.EntryExists(file.name); exists {
+			old := oldIdx.Entries[pos]
+			if old.Hash() == file.hash && objects.FileModeFromBits(old.Metadata.FileMode) == file.mode {
+				return old
+			}
+		}
+	}
+	return index.NewEntry(file.name, file.mode.Bits(), file.hash)
+}
+
+// treeToIndex Create a new index holding all the files in a tree. oldIdx is used to keep
+// the 'stat' data of unchanged entries and may be nil
+func (repo *Repo) treeToIndex(treeHash objects.ObjectID, oldIdx *index.Index) (*index.Index, error) {
+	files, err := repo.treeFiles(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	idx := index.EmptyIndex(repo.HashAlgorithm())
+	for _, file := range files {
+		err := idx.AddEntry(indexEntryFromTree(oldIdx, file))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
@@ -0,0 +1,444 @@
+package repo
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// WalkOrder The order a CommitWalker lists commits in
+type WalkOrder int
+
+const (
+	// DateOrder List the newest commit (by committer date) first, like 'git log'
+	DateOrder WalkOrder = iota
+	// TopoOrder List no commit before all of its children and keep the commits of each line of
+	// history together, like 'git log --topo-order'. Graphs need this order
+	TopoOrder
+)
+
+// ErrFollowPaths Indicates that renames were to be followed for more or less than one path
+type ErrFollowPaths struct{}
+
+func (e *ErrFollowPaths) Error() string {
+	return "--follow requires exactly one path"
+}
+
+// CommitFilter Limits the commits a CommitWalker lists, like the options of 'git log' that do.
+// The zero value lists every commit
+type CommitFilter struct {
+	// Paths Only list commits that changed one of these files or directories. History is
+	// simplified like git does by default: a merge that left the paths the same as one of its
+	// parents is not listed and only that parent's history is walked
+	Paths []string
+	// Follow Keep listing the history of the only path across renames. The whole history is
+	// walked, without simplifying it
+	Follow bool
+	// Author and Committer Only list commits whose author or committer ("Name <email>")
+	// matches. Grep Only list commits whose message matches
+	Author    *regexp.Regexp
+	Committer *regexp.Regexp
+	Grep      *regexp.Regexp
+	// Since and Until Only list commits committed in this period. The zero time leaves out a
+	// bound
+	Since time.Time
+	Until time.Time
+}
+
+// limitsPaths Check if the filter lists only the commits that changed some paths
+func (filter *CommitFilter) limitsPaths() bool {
+	return len(filter.Paths) > 0
+}
+
+// inPeriod Check if a commit was committed between Since and Until
+func (filter *CommitFilter) inPeriod(commit *objects.GitCommit) bool {
+	when := commit.Committer().When
+	if !filter.Since.IsZero() && when.Before(filter.Since) {
+		return false
+	}
+	return filter.Until.IsZero() || !when.After(filter.Until)
+}
+
+// matches Check if a commit passes the parts of the filter that don't depend on its changes
+func (filter *CommitFilter) matches(commit *objects.GitCommit) bool {
+	if !filter.inPeriod(commit) {
+		return false
+	}
+	committer, author := commit.Committer(), commit.Author()
+	if filter.Author != nil && !filter.Author.MatchString(author.Name+" <"+author.Email+">") {
+		return false
+	}
+	if filter.Committer != nil && !filter.Committer.MatchString(committer.Name+" <"+committer.Email+">") {
+		return false
+	}
+	return filter.Grep == nil || filter.Grep.MatchString(commit.Msg)
+}
+
+// CommitWalker Lists every commit reachable from a set of starting commits once. Create one with
+// Repo.WalkCommits and call Next until it returns io.EOF
+type CommitWalker struct {
+	repo    *Repo
+	order   WalkOrder
+	filter  CommitFilter
+	seen    map[objects.ObjectID]bool
+	queue   commitQueue
+	counter int
+	// paths The paths commits are checked for changes to. They change when a followed file
+	// was renamed. read The commits that were read to compare with their children and haven't
+	// been queued yet
+	paths []string
+	read  map[objects.ObjectID]*objects.GitCommit
+	// Only used in TopoOrder: the commits that are ready to be listed and, for every commit,
+	// the number of its children that haven't been listed
+	stack    []*walkCommit
+	children map[objects.ObjectID]int
+	commits  map[objects.ObjectID]*walkCommit
+	// changedAncestors The nearest ancestors that changed the paths of the commits that didn't
+	changedAncestors map[objects.ObjectID][]objects.ObjectID
+}
+
+// walkCommit A commit waiting to be listed by a CommitWalker
+type walkCommit struct {
+	hash   objects.ObjectID
+	commit *objects.GitCommit
+	// order The position the commit was found in, used to keep the walk stable
+	order int
+	// parents The parents whose history is walked: all of them unless history was simplified
+	parents []objects.ObjectID
+	// changed Whether the commit changed the walker's paths. shown Whether it passes the
+	// whole filter
+	changed bool
+	shown   bool
+}
+
+// commitQueue A heap of commits with the newest commit on top
+type commitQueue []*walkCommit
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	iTime, jTime := q[i].commit.Committer().When, q[j].commit.Committer().When
+	if !iTime.Equal(jTime) {
+		return iTime.After(jTime)
+	}
+	return q[i].order < q[j].order
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*walkCommit)) }
+
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// WalkCommits Walk the history of the commits in starts, in the given order
+func (repo *Repo) WalkCommits(order WalkOrder, starts ...objects.ObjectID) (*CommitWalker, error) {
+	return repo.FilterCommits(order, CommitFilter{}, starts...)
+}
+
+// FilterCommits Walk the history of the commits in starts, in the given order, listing only the
+// commits that pass the filter. In TopoOrder, the parents of the listed commits are rewritten
+// like git does for graphs: commits that didn't change the paths are skipped over to their
+// nearest ancestors that did, and parents that aren't listed for other reasons are left out
+func (repo *Repo) FilterCommits(order WalkOrder, filter CommitFilter, starts ...objects.ObjectID) (*CommitWalker, error) {
+	if filter.Follow && len(filter.Paths) != 1 {
+		return nil, &ErrFollowPaths{}
+	}
+	walker := &CommitWalker{
+		repo:   repo,
+		order:  order,
+		filter: filter,
+		seen:   make(map[objects.ObjectID]bool),
+		paths:  filter.Paths,
+		read:   make(map[objects.ObjectID]*objects.GitCommit),
+	}
+	for _, start := range starts {
+		err := walker.push(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if order == TopoOrder {
+		err := walker.sortTopologically()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return walker, nil
+}
+
+// readCommit Read the commit with the given hash
+func (repo *Repo) readCommit(hash objects.ObjectID) (*objects.GitCommit, error) {
+	obj, err := repo.GetObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	commit, ok := obj.(*objects.GitCommit)
+	if !ok {
+		return nil, fmt.Errorf("%s is a %s, not a commit", hash, obj.Type())
+	}
+	return commit, nil
+}
+
+// load Read a commit, or take it from the commits that were read to compare with their children
+func (walker *CommitWalker) load(hash objects.ObjectID) (*objects.GitCommit, error) {
+	if commit, ok := walker.read[hash]; ok {
+		return commit, nil
+	}
+	commit, err := walker.repo.readCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	walker.read[hash] = commit
+	return commit, nil
+}
+
+// push Read a commit and queue it, unless it has been queued before
+func (walker *CommitWalker) push(hash objects.ObjectID) error {
+	if walker.seen[hash] {
+		return nil
+	}
+	commit, err := walker.load(hash)
+	if err != nil {
+		return err
+	}
+	delete(walker.read, hash)
+	walker.seen[hash] = true
+	heap.Push(&walker.queue, &walkCommit{hash: hash, commit: commit, order: walker.counter})
+	walker.counter++
+	return nil
+}
+
+// nextByDate Take the newest queued commit, check if it passes the filter and queue the parents
+// whose history is walked
+func (walker *CommitWalker) nextByDate() (*walkCommit, error) {
+	if walker.queue.Len() == 0 {
+		return nil, io.EOF
+	}
+	next := heap.Pop(&walker.queue).(*walkCommit)
+	next.parents = next.commit.Parents()
+	changed := true
+	var err error
+	if walker.filter.Follow {
+		changed, err = walker.followRenames(next)
+	} else if walker.filter.limitsPaths() {
+		changed, err = walker.simplify(next)
+	}
+	if err != nil {
+		return nil, err
+	}
+	next.changed = changed
+	next.shown = changed && walker.filter.matches(next.commit)
+	for _, parent := range next.parents {
+		err = walker.push(parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// changes The changes to the walker's paths between a commit's parent and the commit. The zero
+// ObjectID compares a root commit with an empty tree
+func (walker *CommitWalker) changes(parent objects.ObjectID, commit *objects.GitCommit) ([]TreeChange, error) {
+	var parentTree objects.ObjectID
+	if !parent.IsZero() {
+		parentCommit, err := walker.load(parent)
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parentCommit.TreeHash
+	}
+	return walker.repo.DiffTrees(parentTree, commit.TreeHash, walker.paths)
+}
+
+// simplify Check if a commit changed the walker's paths. A commit that left them the same as
+// one of its parents didn't, and only the history of the first such parent is walked
+func (walker *CommitWalker) simplify(next *walkCommit) (bool, error) {
+	if len(next.parents) == 0 {
+		changes, err := walker.changes(objects.ObjectID{}, next.commit)
+		return len(changes) > 0, err
+	}
+	for _, parent := range next.parents {
+		changes, err := walker.changes(parent, next.commit)
+		if err != nil {
+			return false, err
+		}
+		if len(changes) == 0 {
+			next.parents = []objects.ObjectID{parent}
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// followRenames Check if a commit changed the followed path compared to its parent. If it added
+// the path by renaming or copying another file, that file is followed from then on. Like git,
+// merges are never listed and renames aren't looked for in them
+func (walker *CommitWalker) followRenames(next *walkCommit) (bool, error) {
+	if len(next.parents) > 1 {
+		return false, nil
+	}
+	var parent objects.ObjectID
+	if len(next.parents) > 0 {
+		parent = next.parents[0]
+	}
+	changes, err := walker.changes(parent, next.commit)
+	if err != nil || len(changes) == 0 {
+		return false, err
+	}
+	if len(changes) != 1 || changes[0].Status() != 'A' || parent.IsZero() {
+		return true, nil
+	}
+	parentCommit, err := walker.load(parent)
+	if err != nil {
+		return false, err
+	}
+	allChanges, err := walker.repo.DiffTrees(parentCommit.TreeHash, next.commit.TreeHash, nil)
+	if err != nil {
+		return false, err
+	}
+	allChanges, err = walker.repo.DetectRenames(allChanges, parentCommit.TreeHash)
+	if err != nil {
+		return false, err
+	}
+	for _, change := range allChanges {
+		if change.OldPath != change.Path && change.Path == walker.paths[0] {
+			walker.paths = []string{change.OldPath}
+		}
+	}
+	return true, nil
+}
+
+// sortTopologically Read the whole history and count the children of every commit, so that
+// commits can be listed once all their children have been. Like git, commits committed outside
+// the filter's period are left out of the order, so their parents may be listed first
+func (walker *CommitWalker) sortTopologically() error {
+	walker.children = make(map[objects.ObjectID]int)
+	walker.commits = make(map[objects.ObjectID]*walkCommit)
+	walker.changedAncestors = make(map[objects.ObjectID][]objects.ObjectID)
+	var sorted []*walkCommit
+	for {
+		next, err := walker.nextByDate()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		walker.commits[next.hash] = next
+		if !walker.filter.inPeriod(next.commit) {
+			continue
+		}
+		sorted = append(sorted, next)
+		for _, parent := range next.parents {
+			walker.children[parent]++
+		}
+	}
+	// The commits without children are listed newest first
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if walker.children[sorted[i].hash] == 0 {
+			walker.stack = append(walker.stack, sorted[i])
+		}
+	}
+	return nil
+}
+
+// nearestChanged The commits that changed the walker's paths in place of the given ones: each
+// commit itself if it did, otherwise the nearest ancestors that did, without duplicates
+func (walker *CommitWalker) nearestChanged(hashes []objects.ObjectID) []objects.ObjectID {
+	ancestors := make([]objects.ObjectID, 0, len(hashes))
+	for _, hash := range hashes {
+		next := walker.commits[hash]
+		nearest := []objects.ObjectID{hash}
+		if !next.changed {
+			var ok bool
+			nearest, ok = walker.changedAncestors[hash]
+			if !ok {
+				nearest = walker.nearestChanged(next.parents)
+				walker.changedAncestors[hash] = nearest
+			}
+		}
+		for _, ancestor := range nearest {
+			if indexOf(ancestors, ancestor) == -1 {
+				ancestors = append(ancestors, ancestor)
+			}
+		}
+	}
+	return ancestors
+}
+
+// nextByTopology List the most recently readied commit. Parents are readied once all of their
+// children are listed; the last parent is listed first so that side branches are shown
+// before the rest of the history they were merged into
+func (walker *CommitWalker) nextByTopology() (*walkCommit, error) {
+	if len(walker.stack) == 0 {
+		return nil, io.EOF
+	}
+	next := walker.stack[len(walker.stack)-1]
+	walker.stack = walker.stack[:len(walker.stack)-1]
+	for _, parent := range next.parents {
+		walker.children[parent]--
+		ready := walker.commits[parent]
+		if walker.children[parent] == 0 && walker.filter.inPeriod(ready.commit) {
+			walker.stack = append(walker.stack, ready)
+		}
+	}
+	return next, nil
+}
+
+// sameParents Check if two lists of parents are the same, in the same order
+func sameParents(a, b []objects.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Next Return the next commit of the walk and its hash. Returns io.EOF when every commit has
+// been listed. In TopoOrder, the parents of the commit are the ones it is listed with
+func (walker *CommitWalker) Next() (objects.ObjectID, *objects.GitCommit, error) {
+	if walker.order == TopoOrder {
+		next, err := walker.nextByTopology()
+		for err == nil && !next.shown {
+			next, err = walker.nextByTopology()
+		}
+		if err != nil {
+			return objects.ObjectID{}, nil, err
+		}
+		commit := next.commit
+		parents := make([]objects.ObjectID, 0, len(next.parents))
+		for _, parent := range walker.nearestChanged(next.parents) {
+			if walker.commits[parent].shown {
+				parents = append(parents, parent)
+			}
+		}
+		if !sameParents(parents, commit.Parents()) {
+			rewritten := *commit
+			rewritten.SetParents(parents)
+			commit = &rewritten
+		}
+		return next.hash, commit, nil
+	}
+	for {
+		next, err := walker.nextByDate()
+		if err != nil {
+			return objects.ObjectID{}, nil, err
+		}
+		if next.shown {
+			return next.hash, next.commit, nil
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package repo
+
+import (
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Save a commit of the empty tree made at the given time (in seconds after the Unix epoch)
+func saveTestCommit(t *testing.T, repoStruct *Repo, msg string, when int64, parents ...objects.ObjectID) objects.ObjectID {
+	emptyTree := objects.MustParseObjectID("4b825dc642cb6eb9a060e54bf8d69288fbe4904b")
+	return saveTreeCommit(t, repoStruct, msg, when, emptyTree, parents...)
+}
+
+// Save a commit of a tree made at the given time (in seconds after the Unix epoch)
+func saveTreeCommit(t *testing.T, repoStruct *Repo, msg string, when int64, tree objects.ObjectID,
+	parents ...objects.ObjectID) objects.ObjectID {
+	commit := &objects.GitCommit{}
+	commit.TreeHash = tree
+	for _, parent := range parents {
+		commit.AddParent(parent)
+	}
+	err := commit.SetAuthorAndTime("A U Thor", "author@example.com", when, 0)
+	if err != nil {
+		t.Fatalf("Error setting author: %s", err)
+	}
+	err = commit.SetCommitterAndTime("C O Mitter", "committer@example.com", when, 0)
+	if err != nil {
+		t.Fatalf("Error setting committer: %s", err)
+	}
+	commit.Msg = msg
+	err = repoStruct.SaveObject(commit)
+	if err != nil {
+		t.Fatalf("Error saving commit: %s", err)
+	}
+	return objects.Hash(commit)
+}
+
+// A history with a side branch that was merged back: side and main are both children of base
+// and merge has main as its first parent and side as its second
+type mergeHistory struct {
+	base, side, main, merge objects.ObjectID
+}
+
+// Save the commits of a mergeHistory. The side commit is older than the main commit
+func saveMergeHistory(t *testing.T, repoStruct *Repo) mergeHistory {
+	var history mergeHistory
+	history.base = saveTestCommit(t, repoStruct, "base", 1700000000)
+	history.side = saveTestCommit(t, repoStruct, "side", 1700000100, history.base)
+	history.main = saveTestCommit(t, repoStruct, "main", 1700000200, history.base)
+	history.merge = saveTestCommit(t, repoStruct, "merge", 1700000300, history.main, history.side)
+	return history
+}
+
+// List every commit of a walk
+func walkAll(t *testing.T, repoStruct *Repo, order WalkOrder, starts ...objects.ObjectID) []objects.ObjectID {
+	hashes, _ := filterAll(t, repoStruct, order, CommitFilter{}, starts...)
+	return hashes
+}
+
+// List every commit of a filtered walk, along with the parents each was listed with
+func filterAll(t *testing.T, repoStruct *Repo, order WalkOrder, filter CommitFilter,
+	starts ...objects.ObjectID) ([]objects.ObjectID, map[objects.ObjectID][]objects.ObjectID) {
+	walker, err := repoStruct.FilterCommits(order, filter, starts...)
+	if err != nil {
+		t.Fatalf("Error starting walk: %s", err)
+	}
+	var hashes []objects.ObjectID
+	parents := make(map[objects.ObjectID][]objects.ObjectID)
+	for {
+		hash, commit, err := walker.Next()
+		if err == io.EOF {
+			return hashes, parents
+		} else if err != nil {
+			t.Fatalf("Error walking commits: %s", err)
+		}
+		hashes = append(hashes, hash)
+		parents[hash] = commit.Parents()
+	}
+}
+
+// Test that walks list every commit once in the expected order
+func TestWalkCommits(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	history := saveMergeHistory(t, repoStruct)
+	byDate := walkAll(t, repoStruct, DateOrder, history.merge)
+	expected := []objects.ObjectID{history.merge, history.main, history.side, history.base}
+	if !sameIDs(byDate, expected) {
+		t.Errorf("Expected date order %v, Got: %v", expected, byDate)
+	}
+	// The side branch is kept together and listed before the line it was merged into
+	byTopology := walkAll(t, repoStruct, TopoOrder, history.merge)
+	expected = []objects.ObjectID{history.merge, history.side, history.main, history.base}
+	if !sameIDs(byTopology, expected) {
+		t.Errorf("Expected topological order %v, Got: %v", expected, byTopology)
+	}
+	// Starting commits that are reachable from other ones are only listed once
+	for _, order := range []WalkOrder{DateOrder, TopoOrder} {
+		hashes := walkAll(t, repoStruct, order, history.side, history.merge)
+		if len(hashes) != 4 || hashes[0] != history.merge {
+			t.Errorf("Expected 4 commits starting with the merge, Got: %v", hashes)
+		}
+	}
+	_, err = repoStruct.WalkCommits(DateOrder, repoStruct.HashAlgorithm().NullID())
+	if err == nil {
+		t.Errorf("Expected an error when starting from a missing commit")
+	}
+}
+
+// Save a mergeHistory whose commits change files: side changes f, main changes g and the merge
+// takes both changes
+func saveFileMergeHistory(t *testing.T, repoStruct *Repo) mergeHistory {
+	var history mergeHistory
+	tree := saveTestTree(t, repoStruct, map[string]string{"f": "1", "g": "1"})
+	history.base = saveTreeCommit(t, repoStruct, "base", 1700000000, tree)
+	tree = saveTestTree(t, repoStruct, map[string]string{"f": "2", "g": "1"})
+	history.side = saveTreeCommit(t, repoStruct, "side", 1700000100, tree, history.base)
+	tree = saveTestTree(t, repoStruct, map[string]string{"f": "1", "g": "2"})
+	history.main = saveTreeCommit(t, repoStruct, "main", 1700000200, tree, history.base)
+	tree = saveTestTree(t, repoStruct, map[string]string{"f": "2", "g": "2"})
+	history.merge = saveTreeCommit(t, repoStruct, "merge", 1700000300, tree, history.main, history.side)
+	return history
+}
+
+// Test listing only the commits that changed some paths, with history simplification
+func TestFilterCommitsByPath(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	history := saveFileMergeHistory(t, repoStruct)
+	tests := []struct {
+		paths      []string
+		byDate     []objects.ObjectID
+		byTopology []objects.ObjectID
+	}{
+		// The merge has the same f as side, so only side's history is walked
+		{[]string{"f"}, []objects.ObjectID{history.side, history.base}, []objects.ObjectID{history.side, history.base}},
+		{[]string{"g"}, []objects.ObjectID{history.main, history.base}, []objects.ObjectID{history.main, history.base}},
+		{
+			[]string{"f", "g"},
+			[]objects.ObjectID{history.merge, history.main, history.side, history.base},
+			[]objects.ObjectID{history.merge, history.side, history.main, history.base},
+		},
+		{[]string{"missing"}, nil, nil},
+	}
+	for _, test := range tests {
+		hashes, _ := filterAll(t, repoStruct, DateOrder, CommitFilter{Paths: test.paths}, history.merge)
+		if !sameIDs(hashes, test.byDate) {
+			t.Errorf("Paths %v: Expected date order %v, Got: %v", test.paths, test.byDate, hashes)
+		}
+		hashes, _ = filterAll(t, repoStruct, TopoOrder, CommitFilter{Paths: test.paths}, history.merge)
+		if !sameIDs(hashes, test.byTopology) {
+			t.Errorf("Paths %v: Expected topological order %v, Got: %v", test.paths, test.byTopology, hashes)
+		}
+	}
+}
+
+// Test listing commits by author, committer, message and date
+func TestFilterCommitsByCommit(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	history := saveMergeHistory(t, repoStruct)
+	all := []objects.ObjectID{history.merge, history.main, history.side, history.base}
+	tests := []struct {
+		filter   CommitFilter
+		expected []objects.ObjectID
+	}{
+		{CommitFilter{Author: regexp.MustCompile("^A U Thor <author@")}, all},
+		{CommitFilter{Committer: regexp.MustCompile("Thor")}, nil},
+		{CommitFilter{Grep: regexp.MustCompile("^(main|base)$")}, []objects.ObjectID{history.main, history.base}},
+		{CommitFilter{Since: time.Unix(1700000100, 0)}, all[:3]},
+		{CommitFilter{Until: time.Unix(1700000200, 0)}, all[1:]},
+		{CommitFilter{Since: time.Unix(1700000100, 0), Until: time.Unix(1700000200, 0)}, all[1:3]},
+	}
+	for _, test := range tests {
+		hashes, _ := filterAll(t, repoStruct, DateOrder, test.filter, history.merge)
+		if !sameIDs(hashes, test.expected) {
+			t.Errorf("Filter %+v: Expected %v, Got: %v", test.filter, test.expected, hashes)
+		}
+	}
+}
+
+// Test rewriting the parents of the listed commits in TopoOrder
+func TestFilterCommitsParents(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	history := saveFileMergeHistory(t, repoStruct)
+	top := saveTreeCommit(t, repoStruct, "top", 1700000400,
+		saveTestTree(t, repoStruct, map[string]string{"f": "3", "g": "2"}), history.merge)
+	// Commits that didn't change the paths are skipped over
+	hashes, parents := filterAll(t, repoStruct, TopoOrder, CommitFilter{Paths: []string{"f"}}, top)
+	expected := []objects.ObjectID{top, history.side, history.base}
+	if !sameIDs(hashes, expected) {
+		t.Errorf("Expected %v, Got: %v", expected, hashes)
+	}
+	if len(parents[top]) != 1 || parents[top][0] != history.side {
+		t.Errorf("Expected the top commit's parent to be %s, Got: %v", history.side, parents[top])
+	}
+	// Parents left out for other reasons are dropped
+	filter := CommitFilter{Grep: regexp.MustCompile("^(top|merge|base)$")}
+	hashes, parents = filterAll(t, repoStruct, TopoOrder, filter, top)
+	expected = []objects.ObjectID{top, history.merge, history.base}
+	if !sameIDs(hashes, expected) {
+		t.Errorf("Expected %v, Got: %v", expected, hashes)
+	}
+	if len(parents[history.merge]) != 0 {
+		t.Errorf("Expected the merge to have no parents, Got: %v", parents[history.merge])
+	}
+}
+
+// Test following a file across renames
+func TestFilterCommitsFollow(t *testing.T) {
+	repoStruct, err := NewMemoryRepo()
+	if err != nil {
+		t.Fatalf("Error creating repo: %s", err)
+	}
+	lines := "one\ntwo\nthree\nfour\nfive\n"
+	tree := saveTestTree(t, repoStruct, map[string]string{"old": lines, "other": "other\n"})
+	added := saveTreeCommit(t, repoStruct, "add", 1700000000, tree)
+	tree = saveTestTree(t, repoStruct, map[string]string{"old": lines + "six\n", "other": "other\n"})
+	edited := saveTreeCommit(t, repoStruct, "edit", 1700000100, tree, added)
+	tree = saveTestTree(t, repoStruct, map[string]string{"new": lines + "six\n", "other": "other\n"})
+	renamed := saveTreeCommit(t, repoStruct, "rename", 1700000200, tree, edited)
+	tree = saveTestTree(t, repoStruct, map[string]string{"new": lines + "seven\n", "other": "other\n"})
+	last := saveTreeCommit(t, repoStruct, "edit again", 1700000300, tree, renamed)
+
+	hashes, _ := filterAll(t, repoStruct, DateOrder, CommitFilter{Paths: []string{"new"}}, last)
+	expected := []objects.ObjectID{last, renamed}
+	if !sameIDs(hashes, expected) {
+		t.Errorf("Expected %v, Got: %v", expected, hashes)
+	}
+	hashes, _ = filterAll(t, repoStruct, DateOrder, CommitFilter{Paths: []string{"new"}, Follow: true}, last)
+	expected = []objects.ObjectID{last, renamed, edited, added}
+	if !sameIDs(hashes, expected) {
+		t.Errorf("Expected %v, Got: %v", expected, hashes)
+	}
+	_, err = repoStruct.FilterCommits(DateOrder, CommitFilter{Follow: true}, last)
+	if _, ok := err.(*ErrFollowPaths); !ok {
+		t.Errorf("Expected ErrFollowPaths, Got: %v", err)
+	}
+}
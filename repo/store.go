@@ -0,0 +1,193 @@
+package repo
+
+import (
+	"io"
+	"sort"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// ObjectStore A database of git objects. Repo reads and writes all objects through one, so
+// objects can be kept somewhere other than the usual '.git/objects' directory.
+// Lookups for objects that aren't in the store return an *ErrObjectNotFound
+type ObjectStore interface {
+	// Has Check if the object with the given hash is in the store
+	Has(hash objects.ObjectID) (bool, error)
+	// Get Open an object for reading. The reader must be closed
+	Get(hash objects.ObjectID) (*objects.ObjectReader, error)
+	// Put Add an object whose content is read from src. Exactly size bytes must be read from
+	// src. Adding an object that already exists is not an error. Returns the hash of the object
+	Put(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error)
+	// Delete Remove an object from the store
+	Delete(hash objects.ObjectID) error
+	// Iterate Call fn with the hash of every object in the store, stopping at the first error
+	Iterate(fn func(hash objects.ObjectID) error) error
+	// FindPrefix Return the hashes of all objects whose hex starts with prefix
+	FindPrefix(prefix string) ([]objects.ObjectID, error)
+}
+
+// ErrReadOnlyStore Returned when writing to a store that can't be modified, such as a pack
+type ErrReadOnlyStore struct {
+	store string
+}
+
+func (e *ErrReadOnlyStore) Error() string {
+	return e.store + " is read-only"
+}
+
+// CompositeStore Combines several stores into one. Objects are read from the first store that
+// has them and written to the first store, so the first store should be the writable one
+// (e.g. loose objects followed by packs and alternates)
+type CompositeStore struct {
+	stores []ObjectStore
+	// skipped Why the stores that couldn't be opened were left out
+	skipped []error
+}
+
+// NewCompositeStore Create a store that combines stores, in order of preference
+func NewCompositeStore(stores ...ObjectStore) *CompositeStore {
+	return &CompositeStore{stores: stores}
+}
+
+// Stores Return the stores that are combined, in order of preference
+func (composite *CompositeStore) Stores() []ObjectStore {
+	return composite.stores
+}
+
+// Add Add a store to be checked after the existing ones
+func (composite *CompositeStore) Add(store ObjectStore) {
+	composite.stores = append(composite.stores, store)
+}
+
+// Skip Record that a store was left out because it couldn't be opened
+func (composite *CompositeStore) Skip(err error) {
+	composite.skipped = append(composite.skipped, err)
+}
+
+// Skipped Return why stores were left out, including those left out of its alternates
+func (composite *CompositeStore) Skipped() []error {
+	skipped := append([]error{}, composite.skipped...)
+	for _, store := range composite.stores {
+		if alternate, ok := store.(*AlternateStore); ok {
+			skipped = append(skipped, alternate.store.Skipped()...)
+		}
+	}
+	return skipped
+}
+
+// Has Check if any of the stores have the object
+func (composite *CompositeStore) Has(hash objects.ObjectID) (bool, error) {
+	for _, store := range composite.stores {
+		found, err := store.Has(hash)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// Get Open the object from the first store that has it
+func (composite *CompositeStore) Get(hash objects.ObjectID) (*objects.ObjectReader, error) {
+	for _, store := range composite.stores {
+		reader, err := store.Get(hash)
+		if _, ok := err.(*ErrObjectNotFound); ok {
+			continue
+		}
+		return reader, err
+	}
+	return nil, &ErrObjectNotFound{query: hash.String()}
+}
+
+// Put Add the object to the first store
+func (composite *CompositeStore) Put(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error) {
+	if len(composite.stores) == 0 {
+		return objects.ObjectID{}, &ErrReadOnlyStore{store: "empty composite store"}
+	}
+	return composite.stores[0].Put(objType, size, src)
+}
+
+// Delete Remove the object from every store that has it
+func (composite *CompositeStore) Delete(hash objects.ObjectID) error {
+	found := false
+	for _, store := range composite.stores {
+		has, err := store.Has(hash)
+		if err != nil {
+			return err
+		}
+		if !has {
+			continue
+		}
+		found = true
+		err = store.Delete(hash)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return &ErrObjectNotFound{query: hash.String()}
+	}
+	return nil
+}
+
+// Iterate Call fn once for every object in any of the stores
+func (composite *CompositeStore) Iterate(fn func(hash objects.ObjectID) error) error {
+	seen := make(map[objects.ObjectID]bool)
+	for _, store := range composite.stores {
+		err := store.Iterate(func(hash objects.ObjectID) error {
+			if seen[hash] {
+				return nil
+			}
+			seen[hash] = true
+			return fn(hash)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindPrefix Return the objects starting with prefix in any of the stores, sorted
+func (composite *CompositeStore) FindPrefix(prefix string) ([]objects.ObjectID, error) {
+	matches := make(map[objects.ObjectID]bool)
+	for _, store := range composite.stores {
+		found, err := store.FindPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range found {
+			matches[hash] = true
+		}
+	}
+	return sortedIDs(matches), nil
+}
+
+// Close Close every store that holds open files, such as packs
+func (composite *CompositeStore) Close() error {
+	var err error
+	for _, store := range composite.stores {
+		if closer, ok := store.(io.Closer); ok {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return err
+}
+
+// sortIDs Sort hashes in place, which is also the order of their hex
+func sortIDs(hashes []objects.ObjectID) {
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].Compare(hashes[j]) < 0
+	})
+}
+
+// sortedIDs The hashes in a set, sorted
+func sortedIDs(set map[objects.ObjectID]bool) []objects.ObjectID {
+	hashes := make([]objects.ObjectID, 0, len(set))
+	for hash := range set {
+		hashes = append(hashes, hash)
+	}
+	sortIDs(hashes)
+	return hashes
+}
@@ -0,0 +1,208 @@
+package repo
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// LooseStore Stores each object in its own compressed file, named after its hash, in a
+// directory named after the first two characters of the hash (e.g. objects/0a/32e1...).
+// This is the layout of '.git/objects'
+type LooseStore struct {
+	fs   vfs.FS
+	dir  string
+	algo *objects.HashAlgorithm
+}
+
+// NewLooseStore Create a store for the loose objects in dir on the given filesystem, named
+// with algo
+func NewLooseStore(fsys vfs.FS, dir string, algo *objects.HashAlgorithm) *LooseStore {
+	return &LooseStore{fs: fsys, dir: dir, algo: algo}
+}
+
+// Dir The directory holding the objects
+func (store *LooseStore) Dir() string {
+	return store.dir
+}
+
+// Return the path of the file holding an object
+func (store *LooseStore) objectPath(hash objects.ObjectID) string {
+	hex := hash.String()
+	return path.Join(store.dir, hex[:2], hex[2:])
+}
+
+// holds Check if the store can hold an object with the given hash, i.e. it is of the store's
+// algorithm
+func (store *LooseStore) holds(hash objects.ObjectID) bool {
+	return hash.Size() == store.algo.Size()
+}
+
+// Has Check if the file for the object exists
+func (store *LooseStore) Has(hash objects.ObjectID) (bool, error) {
+	if !store.holds(hash) {
+		return false, nil
+	}
+	_, err := store.fs.Stat(store.objectPath(hash))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get Open the object file for reading. Its content is decompressed as it is read
+func (store *LooseStore) Get(hash objects.ObjectID) (*objects.ObjectReader, error) {
+	if !store.holds(hash) {
+		return nil, &ErrObjectNotFound{query: hash.String()}
+	}
+	file, err := store.fs.Open(store.objectPath(hash))
+	if os.IsNotExist(err) {
+		return nil, &ErrObjectNotFound{query: hash.String()}
+	} else if err != nil {
+		return nil, err
+	}
+	reader, err := objects.NewObjectReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Put Compress an object into its file
+func (store *LooseStore) Put(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error) {
+	// The hash isn't known until everything has been read, so the object is written to a
+	// temporary file first and then moved to where it belongs
+	tmpFile, err := vfs.CreateTemp(store.fs, store.dir, "tmp_obj_")
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	hash, err := store.algo.CompressStream(tmpFile, objType, size, src, true)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		store.fs.Remove(tmpFile.Name())
+		return objects.ObjectID{}, err
+	}
+	err = store.fs.MkdirAll(path.Dir(store.objectPath(hash)), DirFilemode)
+	if err != nil {
+		store.fs.Remove(tmpFile.Name())
+		return objects.ObjectID{}, err
+	}
+	objPath := store.objectPath(hash)
+	if _, err := store.fs.Stat(objPath); err == nil {
+		// The object already exists
+		return hash, store.fs.Remove(tmpFile.Name())
+	}
+	err = store.fs.Rename(tmpFile.Name(), objPath)
+	if err != nil {
+		store.fs.Remove(tmpFile.Name())
+		return objects.ObjectID{}, err
+	}
+	return hash, nil
+}
+
+// ModTime Return when the object's file was last modified
+func (store *LooseStore) ModTime(hash objects.ObjectID) (time.Time, error) {
+	if !store.holds(hash) {
+		return time.Time{}, &ErrObjectNotFound{query: hash.String()}
+	}
+	info, err := store.fs.Stat(store.objectPath(hash))
+	if os.IsNotExist(err) {
+		return time.Time{}, &ErrObjectNotFound{query: hash.String()}
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Delete Remove the object's file
+func (store *LooseStore) Delete(hash objects.ObjectID) error {
+	if !store.holds(hash) {
+		return &ErrObjectNotFound{query: hash.String()}
+	}
+	err := store.fs.Remove(store.objectPath(hash))
+	if os.IsNotExist(err) {
+		return &ErrObjectNotFound{query: hash.String()}
+	} else if err != nil {
+		return err
+	}
+	// Remove the directory once its last object is gone; this fails while it isn't empty
+	store.fs.Remove(path.Dir(store.objectPath(hash)))
+	return nil
+}
+
+// Iterate Call fn for every object file, in order of their hashes
+func (store *LooseStore) Iterate(fn func(hash objects.ObjectID) error) error {
+	return store.iterateDirs("", fn)
+}
+
+// FindPrefix Return the objects whose hash starts with prefix. Prefixes shorter than two
+// characters have to check every directory
+func (store *LooseStore) FindPrefix(prefix string) ([]objects.ObjectID, error) {
+	prefix = strings.ToLower(prefix)
+	matches := make([]objects.ObjectID, 0)
+	err := store.iterateDirs(prefix, func(hash objects.ObjectID) error {
+		if hash.HasPrefix(prefix) {
+			matches = append(matches, hash)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// Call fn for every object in the directories that could hold objects starting with prefix
+func (store *LooseStore) iterateDirs(prefix string, fn func(hash objects.ObjectID) error) error {
+	dirs, err := store.fs.ReadDir(store.dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// ReadDir returns the entries sorted by name
+	for _, dir := range dirs {
+		name := dir.Name()
+		if !dir.IsDir() || len(name) != 2 || !isHex(name) {
+			continue
+		}
+		if len(prefix) >= 2 && name != prefix[:2] || len(prefix) == 1 && name[0] != prefix[0] {
+			continue
+		}
+		files, err := store.fs.ReadDir(path.Join(store.dir, name))
+		if err != nil {
+			return err
+		}
+		hashes := make([]objects.ObjectID, 0, len(files))
+		for _, file := range files {
+			// Anything that isn't named like an object (e.g. temporary files) is skipped
+			if hash, err := store.algo.ParseID(name + file.Name()); err == nil {
+				hashes = append(hashes, hash)
+			}
+		}
+		sortIDs(hashes)
+		for _, hash := range hashes {
+			err = fn(hash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isHex Check that s only has lowercase hex digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
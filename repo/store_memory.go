@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// MemoryStore Keeps objects uncompressed in memory. Useful for tests and for building objects
+// that never need to be written to disk. It is safe for concurrent use
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[objects.ObjectID]memoryObject
+	algo    *objects.HashAlgorithm
+}
+
+type memoryObject struct {
+	objType objects.GitObjectType
+	content []byte
+}
+
+// NewMemoryStore Create an empty in-memory store for objects named with algo
+func NewMemoryStore(algo *objects.HashAlgorithm) *MemoryStore {
+	return &MemoryStore{objects: make(map[objects.ObjectID]memoryObject), algo: algo}
+}
+
+// Has Check if the object is in memory
+func (store *MemoryStore) Has(hash objects.ObjectID) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	_, ok := store.objects[hash]
+	return ok, nil
+}
+
+// Get Return a reader for the object's content
+func (store *MemoryStore) Get(hash objects.ObjectID) (*objects.ObjectReader, error) {
+	store.mu.RLock()
+	obj, ok := store.objects[hash]
+	store.mu.RUnlock()
+	if !ok {
+		return nil, &ErrObjectNotFound{query: hash.String()}
+	}
+	return objects.NewContentReader(obj.objType, int64(len(obj.content)), bytes.NewReader(obj.content)), nil
+}
+
+// Put Read the object into memory
+func (store *MemoryStore) Put(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error) {
+	buf := &bytes.Buffer{}
+	hash, err := store.algo.HashReader(objType, size, io.TeeReader(src, buf))
+	if err != nil {
+		return objects.ObjectID{}, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.objects[hash]; !ok {
+		store.objects[hash] = memoryObject{objType: objType, content: buf.Bytes()}
+	}
+	return hash, nil
+}
+
+// Delete Remove the object from memory
+func (store *MemoryStore) Delete(hash objects.ObjectID) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.objects[hash]; !ok {
+		return &ErrObjectNotFound{query: hash.String()}
+	}
+	delete(store.objects, hash)
+	return nil
+}
+
+// Iterate Call fn for every object, in order of their hashes
+func (store *MemoryStore) Iterate(fn func(hash objects.ObjectID) error) error {
+	for _, hash := range store.hashes("") {
+		err := fn(hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindPrefix Return the objects whose hash starts with prefix
+func (store *MemoryStore) FindPrefix(prefix string) ([]objects.ObjectID, error) {
+	return store.hashes(strings.ToLower(prefix)), nil
+}
+
+// The sorted hashes of all the objects starting with prefix
+func (store *MemoryStore) hashes(prefix string) []objects.ObjectID {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	hashes := make([]objects.ObjectID, 0, len(store.objects))
+	for hash := range store.objects {
+		if hash.HasPrefix(prefix) {
+			hashes = append(hashes, hash)
+		}
+	}
+	sortIDs(hashes)
+	return hashes
+}
@@ -0,0 +1,478 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Reading objects from pack files (objects/pack/pack-*.pack) through their version 2 index
+// (the matching .idx file)
+
+// Types of the entries in a pack file
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// Limit on how many deltas are followed to find an object, to catch packs with cycles
+const maxDeltaDepth = 4096
+
+// maxInflateRatio How many times larger than its compressed form deflate can make data. Entries
+// that claim to be larger than the rest of the pack could inflate to are corrupt
+const maxInflateRatio = 1032
+
+// maxDeltaCopy The most a single delta instruction can add to the result
+const maxDeltaCopy = 0x10000
+
+// packIdxSignature The magic number at the start of a version 2 pack index
+var packIdxSignature = []byte{0xff, 't', 'O', 'c'}
+
+// ErrBadPack Returned when a pack or its index is badly formed
+type ErrBadPack struct {
+	pack   string
+	reason string
+}
+
+func (e *ErrBadPack) Error() string {
+	return "Could not read pack " + e.pack + ": " + e.reason
+}
+
+// PackStore Reads the objects in a single pack file. Packs can't be changed, so Put and
+// Delete always fail
+type PackStore struct {
+	packPath string
+	pack     vfs.File
+	// size The length of the pack file
+	size int64
+	// hashes The hashes of the objects in the pack, sorted (as stored in the index)
+	hashes []objects.ObjectID
+	// offsets The offset of each object in the pack file, in the same order as hashes
+	offsets []int64
+	// algo The algorithm of the hashes in the index and of its checksums
+	algo *objects.HashAlgorithm
+}
+
+// OpenPack Read the index of a pack and open the pack for reading. packPath is the path of
+// the .pack file; the index is expected next to it with a .idx extension. The hashes in both
+// are those of algo
+func OpenPack(fsys vfs.FS, packPath string, algo *objects.HashAlgorithm) (*PackStore, error) {
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+	idxData, err := fsys.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	store := &PackStore{packPath: packPath, algo: algo}
+	err = store.parseIndex(idxData)
+	if err != nil {
+		return nil, err
+	}
+	store.pack, err = fsys.OpenFile(packPath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 12)
+	_, err = store.pack.ReadAt(header, 0)
+	if err != nil || string(header[:4]) != "PACK" {
+		store.pack.Close()
+		return nil, &ErrBadPack{pack: packPath, reason: "bad pack header"}
+	}
+	version := binary.BigEndian.Uint32(header[4:8])
+	if version != 2 && version != 3 {
+		store.pack.Close()
+		return nil, &ErrBadPack{pack: packPath, reason: fmt.Sprintf("unsupported version %d", version)}
+	}
+	if int(binary.BigEndian.Uint32(header[8:12])) != len(store.hashes) {
+		store.pack.Close()
+		return nil, &ErrBadPack{pack: packPath, reason: "pack and index have a different number of objects"}
+	}
+	info, err := store.pack.Stat()
+	if err != nil {
+		store.pack.Close()
+		return nil, err
+	}
+	store.size = info.Size()
+	return store, nil
+}
+
+// Parse a version 2 pack index:
+// signature, version, a fan-out table of 256 object counts, the sorted hashes, a CRC32 for each
+// object, 4-byte offsets and then 8-byte offsets for objects past 2GB, followed by checksums
+func (store *PackStore) parseIndex(data []byte) error {
+	bad := func(reason string) error {
+		return &ErrBadPack{pack: store.packPath, reason: reason}
+	}
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], packIdxSignature) {
+		return bad("index is not a version 2 index")
+	}
+	if binary.BigEndian.Uint32(data[4:8]) != 2 {
+		return bad("index is not a version 2 index")
+	}
+	hashSize := store.algo.Size()
+	count := int(binary.BigEndian.Uint32(data[8+255*4 : 8+256*4]))
+	hashStart := 8 + 256*4
+	crcStart := hashStart + count*hashSize
+	offsetStart := crcStart + count*4
+	largeStart := offsetStart + count*4
+	// The index ends with the checksums of the pack and of the index itself
+	checksumsSize := 2 * hashSize
+	if len(data) < largeStart+checksumsSize {
+		return bad("index is truncated")
+	}
+	store.hashes = make([]objects.ObjectID, count)
+	store.offsets = make([]int64, count)
+	for i := 0; i < count; i++ {
+		store.hashes[i], _ = store.algo.IDFromBytes(data[hashStart+i*hashSize : hashStart+(i+1)*hashSize])
+		offset := binary.BigEndian.Uint32(data[offsetStart+i*4:])
+		if offset&0x80000000 == 0 {
+			store.offsets[i] = int64(offset)
+			continue
+		}
+		// The offset is an index into the table of 8-byte offsets
+		pos := largeStart + int(offset&0x7fffffff)*8
+		if pos+8 > len(data)-checksumsSize {
+			return bad("index is truncated")
+		}
+		store.offsets[i] = int64(binary.BigEndian.Uint64(data[pos:]))
+	}
+	for i := 1; i < count; i++ {
+		if store.hashes[i-1].Compare(store.hashes[i]) > 0 {
+			return bad("index is not sorted")
+		}
+	}
+	return nil
+}
+
+// Path The path of the .pack file
+func (store *PackStore) Path() string {
+	return store.packPath
+}
+
+// Close Close the pack file
+func (store *PackStore) Close() error {
+	return store.pack.Close()
+}
+
+// Find the position of hash in the index, or -1 if the pack doesn't have it
+func (store *PackStore) find(hash objects.ObjectID) int {
+	pos := sort.Search(len(store.hashes), func(i int) bool {
+		return store.hashes[i].Compare(hash) >= 0
+	})
+	if pos < len(store.hashes) && store.hashes[pos] == hash {
+		return pos
+	}
+	return -1
+}
+
+// search The position of the first hash in the index whose hex isn't before prefix
+func (store *PackStore) search(prefix string) int {
+	return sort.Search(len(store.hashes), func(i int) bool {
+		return store.hashes[i].String() >= prefix
+	})
+}
+
+// Has Check if the index lists the object
+func (store *PackStore) Has(hash objects.ObjectID) (bool, error) {
+	return store.find(hash) >= 0, nil
+}
+
+// Get Read the object from the pack. Deltas are resolved, so the whole object is read into
+// memory
+func (store *PackStore) Get(hash objects.ObjectID) (*objects.ObjectReader, error) {
+	pos := store.find(hash)
+	if pos < 0 {
+		return nil, &ErrObjectNotFound{query: hash.String()}
+	}
+	objType, content, err := store.readAt(store.offsets[pos], 0)
+	if err != nil {
+		return nil, err
+	}
+	return objects.NewContentReader(objType, int64(len(content)), bytes.NewReader(content)), nil
+}
+
+// Put Packs are read-only
+func (store *PackStore) Put(objType objects.GitObjectType, size int64, src io.Reader) (objects.ObjectID, error) {
+	return objects.ObjectID{}, &ErrReadOnlyStore{store: store.packPath}
+}
+
+// Delete Packs are read-only
+func (store *PackStore) Delete(hash objects.ObjectID) error {
+	return &ErrReadOnlyStore{store: store.packPath}
+}
+
+// Iterate Call fn for every object in the pack, in order of their hashes
+func (store *PackStore) Iterate(fn func(hash objects.ObjectID) error) error {
+	for _, hash := range store.hashes {
+		err := fn(hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindPrefix Return the objects in the pack whose hash starts with prefix
+func (store *PackStore) FindPrefix(prefix string) ([]objects.ObjectID, error) {
+	prefix = strings.ToLower(prefix)
+	matches := make([]objects.ObjectID, 0)
+	for pos := store.search(prefix); pos < len(store.hashes); pos++ {
+		if !store.hashes[pos].HasPrefix(prefix) {
+			break
+		}
+		matches = append(matches, store.hashes[pos])
+	}
+	return matches, nil
+}
+
+// Read the object at offset in the pack, resolving deltas. depth is the number of deltas
+// followed so far
+func (store *PackStore) readAt(offset int64, depth int) (objects.GitObjectType, []byte, error) {
+	if depth > maxDeltaDepth {
+		return "", nil, &ErrBadPack{pack: store.packPath, reason: "delta chain is too long"}
+	}
+	if offset < 12 || offset >= store.size {
+		return "", nil, &ErrBadPack{pack: store.packPath, reason: fmt.Sprintf("offset %d is outside the pack", offset)}
+	}
+	reader := bufio.NewReader(io.NewSectionReader(store.pack, offset, store.size-offset))
+	entryType, size, err := readPackEntryHeader(reader)
+	if err != nil {
+		return "", nil, &ErrBadPack{pack: store.packPath, reason: err.Error()}
+	}
+	if size > (store.size-offset)*maxInflateRatio {
+		return "", nil, &ErrBadPack{pack: store.packPath, reason: fmt.Sprintf("entry at %d is larger than the pack can hold", offset)}
+	}
+	var baseType objects.GitObjectType
+	var base []byte
+	switch entryType {
+	case packOfsDelta:
+		distance, err := readOffsetDistance(reader)
+		if err != nil || distance <= 0 || distance > offset {
+			return "", nil, &ErrBadPack{pack: store.packPath, reason: "bad delta base offset"}
+		}
+		baseType, base, err = store.readAt(offset-distance, depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+	case packRefDelta:
+		raw := make([]byte, store.algo.Size())
+		_, err := io.ReadFull(reader, raw)
+		if err != nil {
+			return "", nil, &ErrBadPack{pack: store.packPath, reason: "truncated delta base"}
+		}
+		baseHash, _ := store.algo.IDFromBytes(raw)
+		pos := store.find(baseHash)
+		if pos < 0 {
+			return "", nil, &ErrBadPack{pack: store.packPath, reason: "delta base " + baseHash.String() + " is not in the pack"}
+		}
+		baseType, base, err = store.readAt(store.offsets[pos], depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	data, err := inflateExactly(reader, size)
+	if err != nil {
+		return "", nil, &ErrBadPack{pack: store.packPath, reason: err.Error()}
+	}
+	switch entryType {
+	case packCommit:
+		return objects.Commit, data, nil
+	case packTree:
+		return objects.Tree, data, nil
+	case packBlob:
+		return objects.Blob, data, nil
+	case packTag:
+		return objects.Tag, data, nil
+	case packOfsDelta, packRefDelta:
+		content, err := applyDelta(base, data)
+		if err != nil {
+			return "", nil, &ErrBadPack{pack: store.packPath, reason: err.Error()}
+		}
+		return baseType, content, nil
+	}
+	return "", nil, &ErrBadPack{pack: store.packPath, reason: fmt.Sprintf("unknown entry type %d", entryType)}
+}
+
+// Read the type and the uncompressed size at the start of a pack entry. The first byte holds
+// the type in bits 4-6 and the lowest 4 bits of the size; the remaining bits of the size
+// follow 7 bits per byte while the top bit is set
+func readPackEntryHeader(reader io.ByteReader) (int, int64, error) {
+	c, err := reader.ReadByte()
+	if err != nil {
+		return 0, 0, errors.New("truncated entry header")
+	}
+	entryType := int(c>>4) & 0x7
+	size := int64(c & 0x0f)
+	shift := uint(4)
+	for c&0x80 != 0 {
+		c, err = reader.ReadByte()
+		if err != nil || shift > 56 {
+			return 0, 0, errors.New("bad entry header")
+		}
+		size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+	return entryType, size, nil
+}
+
+// Read the distance back to the base of an offset delta. Each continuation byte adds one
+// before shifting so that no distance has more than one encoding
+func readOffsetDistance(reader io.ByteReader) (int64, error) {
+	c, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	distance := int64(c & 0x7f)
+	for c&0x80 != 0 {
+		c, err = reader.ReadByte()
+		if err != nil || distance > 1<<55 {
+			return 0, errors.New("bad offset")
+		}
+		distance = (distance+1)<<7 | int64(c&0x7f)
+	}
+	return distance, nil
+}
+
+// Decompress a zlib stream that must hold exactly size bytes
+func inflateExactly(src io.Reader, size int64) ([]byte, error) {
+	zReader, err := zlib.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	defer zReader.Close()
+	data := make([]byte, size)
+	_, err = io.ReadFull(zReader, data)
+	if err != nil {
+		return nil, errors.New("entry is smaller than its header says")
+	}
+	if n, _ := zReader.Read(make([]byte, 1)); n > 0 {
+		return nil, errors.New("entry is larger than its header says")
+	}
+	return data, nil
+}
+
+// Read a size in a delta: 7 bits per byte, least significant first, while the top bit is set
+func readDeltaSize(delta []byte) (int, []byte, error) {
+	size, shift := 0, uint(0)
+	for i, c := range delta {
+		if shift > 56 {
+			break
+		}
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+	}
+	return 0, nil, errors.New("bad delta size")
+}
+
+// applyDelta Create an object from its base and a delta. The delta starts with the size of the
+// base and of the result, followed by instructions that either copy a range of the base or
+// insert new data
+func applyDelta(base []byte, delta []byte) ([]byte, error) {
+	baseSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, errors.New("delta base has the wrong size")
+	}
+	resultSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	// Every instruction takes at least a byte, so the size can be checked before allocating
+	if resultSize > len(delta)*maxDeltaCopy {
+		return nil, errors.New("delta result is larger than the delta can produce")
+	}
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// Copy: the lower 4 bits say which bytes of the offset follow and the next 3 bits
+			// which bytes of the size follow
+			var offset, size int
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errors.New("truncated delta")
+				}
+				if i < 4 {
+					offset |= int(delta[0]) << (8 * i)
+				} else {
+					size |= int(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = maxDeltaCopy
+			}
+			if offset+size > len(base) {
+				return nil, errors.New("delta copies past the end of its base")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			// Insert the next op bytes
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("delta has a reserved instruction")
+		}
+	}
+	if len(result) != resultSize {
+		return nil, errors.New("delta result has the wrong size")
+	}
+	return result, nil
+}
+
+// openPacks Open every pack in a pack directory (e.g. objects/pack) whose hashes are those of
+// algo. A missing directory has no packs. Packs that can't be opened (e.g. corrupt ones or
+// packs without an index) are skipped, like git does, and returned along with the reason
+func openPacks(fsys vfs.FS, packDir string, algo *objects.HashAlgorithm) ([]*PackStore, []error) {
+	entries, err := fsys.ReadDir(packDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, []error{err}
+	}
+	packs := make([]*PackStore, 0)
+	skipped := make([]error, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pack") {
+			continue
+		}
+		packPath := path.Join(packDir, entry.Name())
+		pack, err := OpenPack(fsys, packPath, algo)
+		if os.IsNotExist(err) {
+			err = &ErrBadPack{pack: packPath, reason: "its index is missing"}
+		}
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		packs = append(packs, pack)
+	}
+	return packs, skipped
+}
@@ -0,0 +1,450 @@
+package repo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+	"github.com/SimonMTaye/gitgo/vfs"
+)
+
+// Read the content of an object from a store
+func readStoreObject(t *testing.T, store ObjectStore, hash objects.ObjectID) (objects.GitObjectType, string) {
+	reader, err := store.Get(hash)
+	if err != nil {
+		t.Fatalf("Error getting %s: %s", hash, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Error reading %s: %s", hash, err)
+	}
+	return reader.Type, string(content)
+}
+
+// Check that two lists of hashes are the same
+func sameIDs(a []objects.ObjectID, b []objects.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Run the same checks against any writable store
+func testWritableStore(t *testing.T, store ObjectStore) {
+	contents := []string{"first\n", "second\n", "third\n"}
+	hashes := make([]objects.ObjectID, 0, len(contents))
+	for _, content := range contents {
+		hash, err := store.Put(objects.Blob, int64(len(content)), strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("Error putting object: %s", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	// Adding an object twice is allowed
+	_, err := store.Put(objects.Blob, int64(len(contents[0])), strings.NewReader(contents[0]))
+	if err != nil {
+		t.Fatalf("Error putting object again: %s", err)
+	}
+	_, err = store.Put(objects.Blob, 100, strings.NewReader(contents[0]))
+	if err == nil {
+		t.Errorf("Expected an error when the content is shorter than its size")
+	}
+	for i, hash := range hashes {
+		found, err := store.Has(hash)
+		if err != nil || !found {
+			t.Errorf("Expected store to have %s, Got: %v, %v", hash, found, err)
+		}
+		objType, content := readStoreObject(t, store, hash)
+		if objType != objects.Blob || content != contents[i] {
+			t.Errorf("Expected blob '%s', Got %s '%s'", contents[i], objType, content)
+		}
+	}
+	iterated := make([]objects.ObjectID, 0)
+	err = store.Iterate(func(hash objects.ObjectID) error {
+		iterated = append(iterated, hash)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error iterating: %s", err)
+	}
+	sorted := append([]objects.ObjectID{}, hashes...)
+	sortIDs(sorted)
+	if !sameIDs(iterated, sorted) {
+		t.Errorf("Expected to iterate over %v, Got: %v", sorted, iterated)
+	}
+	matches, err := store.FindPrefix(hashes[1].Abbrev(6))
+	if err != nil || len(matches) != 1 || matches[0] != hashes[1] {
+		t.Errorf("Expected prefix to match %s, Got: %v, %v", hashes[1], matches, err)
+	}
+	matches, err = store.FindPrefix("")
+	if err != nil || len(matches) != len(hashes) {
+		t.Errorf("Expected an empty prefix to match every object, Got: %v, %v", matches, err)
+	}
+
+	err = store.Delete(hashes[0])
+	if err != nil {
+		t.Fatalf("Error deleting object: %s", err)
+	}
+	if found, _ := store.Has(hashes[0]); found {
+		t.Errorf("Expected %s to be deleted", hashes[0])
+	}
+	_, err = store.Get(hashes[0])
+	if _, ok := err.(*ErrObjectNotFound); !ok {
+		t.Errorf("Expected ErrObjectNotFound for a deleted object, Got: %v", err)
+	}
+	err = store.Delete(hashes[0])
+	if _, ok := err.(*ErrObjectNotFound); !ok {
+		t.Errorf("Expected ErrObjectNotFound deleting a missing object, Got: %v", err)
+	}
+}
+
+func TestLooseStore(t *testing.T) {
+	dir := t.TempDir()
+	testWritableStore(t, NewLooseStore(vfs.OS, dir, objects.SHA1))
+	// Temporary files mustn't be mistaken for objects
+	err := os.WriteFile(path.Join(dir, "tmp_obj_1"), []byte("x"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	matches, err := NewLooseStore(vfs.OS, dir, objects.SHA1).FindPrefix("")
+	if err != nil || len(matches) != 2 {
+		t.Errorf("Expected only the two remaining objects, Got: %v, %v", matches, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testWritableStore(t, NewMemoryStore(objects.SHA1))
+}
+
+// Test that objects are written to the first store and read from any of them
+func TestCompositeStore(t *testing.T) {
+	testWritableStore(t, NewCompositeStore(NewMemoryStore(objects.SHA1), NewMemoryStore(objects.SHA1)))
+
+	first, second := NewMemoryStore(objects.SHA1), NewMemoryStore(objects.SHA1)
+	hash, err := second.Put(objects.Blob, 5, strings.NewReader("other"))
+	if err != nil {
+		t.Fatalf("Error putting object: %s", err)
+	}
+	composite := NewCompositeStore(first, second)
+	_, content := readStoreObject(t, composite, hash)
+	if content != "other" {
+		t.Errorf("Expected to read 'other' from the second store, Got: %s", content)
+	}
+	newHash, err := composite.Put(objects.Blob, 3, strings.NewReader("new"))
+	if err != nil {
+		t.Fatalf("Error putting object: %s", err)
+	}
+	if found, _ := first.Has(newHash); !found {
+		t.Errorf("Expected new objects to be written to the first store")
+	}
+	if found, _ := second.Has(newHash); found {
+		t.Errorf("Expected new objects not to be written to the second store")
+	}
+}
+
+// An object to write into a test pack. Deltas are against base, which must come earlier
+type testPackEntry struct {
+	objType objects.GitObjectType
+	content string
+	// base The index of the entry the delta is against, or -1 for a whole object
+	base int
+	// refDelta Refer to the base by hash instead of by offset
+	refDelta bool
+}
+
+// Encode the type and size of a pack entry
+func packEntryHeader(entryType int, size int) []byte {
+	header := []byte{byte(entryType<<4 | size&0x0f)}
+	size >>= 4
+	for size > 0 {
+		header[len(header)-1] |= 0x80
+		header = append(header, byte(size&0x7f))
+		size >>= 7
+	}
+	return header
+}
+
+// Encode a size in a delta
+func deltaSize(size int) []byte {
+	encoded := []byte{}
+	for {
+		c := byte(size & 0x7f)
+		size >>= 7
+		if size == 0 {
+			return append(encoded, c)
+		}
+		encoded = append(encoded, c|0x80)
+	}
+}
+
+// Create a delta that copies the prefix shared by base and target and inserts the rest of target
+func makeDelta(base string, target string) []byte {
+	copyLen := 0
+	for copyLen < len(base) && copyLen < len(target) && base[copyLen] == target[copyLen] {
+		copyLen++
+	}
+	delta := append(deltaSize(len(base)), deltaSize(len(target))...)
+	delta = append(delta, 0x80|0x01|0x10, 0, byte(copyLen))
+	rest := target[copyLen:]
+	delta = append(delta, byte(len(rest)))
+	return append(delta, rest...)
+}
+
+func zlibCompress(data []byte) []byte {
+	buf := &bytes.Buffer{}
+	zWriter := zlib.NewWriter(buf)
+	zWriter.Write(data)
+	zWriter.Close()
+	return buf.Bytes()
+}
+
+// Write a pack and its index into dir. Returns the path of the pack and the object hashes
+func writeTestPack(t *testing.T, dir string, entries []testPackEntry) (string, []objects.ObjectID) {
+	pack := &bytes.Buffer{}
+	pack.WriteString("PACK")
+	binary.Write(pack, binary.BigEndian, uint32(2))
+	binary.Write(pack, binary.BigEndian, uint32(len(entries)))
+	hashes := make([]objects.ObjectID, len(entries))
+	offsets := make([]int, len(entries))
+	packTypes := map[objects.GitObjectType]int{objects.Commit: 1, objects.Tree: 2, objects.Blob: 3, objects.Tag: 4}
+	for i, entry := range entries {
+		offsets[i] = pack.Len()
+		hash, err := objects.HashReader(entry.objType, int64(len(entry.content)), strings.NewReader(entry.content))
+		if err != nil {
+			t.Fatalf("Error hashing object: %s", err)
+		}
+		hashes[i] = hash
+		data := []byte(entry.content)
+		switch {
+		case entry.base < 0:
+			pack.Write(packEntryHeader(packTypes[entry.objType], len(data)))
+		case entry.refDelta:
+			data = makeDelta(entries[entry.base].content, entry.content)
+			pack.Write(packEntryHeader(7, len(data)))
+			pack.Write(hashes[entry.base].Bytes())
+		default:
+			data = makeDelta(entries[entry.base].content, entry.content)
+			pack.Write(packEntryHeader(6, len(data)))
+			// Test packs are small so the distance always fits in one byte
+			pack.WriteByte(byte(offsets[i] - offsets[entry.base]))
+		}
+		pack.Write(zlibCompress(data))
+	}
+	packSum := sha1.Sum(pack.Bytes())
+	pack.Write(packSum[:])
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return hashes[order[i]].Compare(hashes[order[j]]) < 0 })
+	idx := &bytes.Buffer{}
+	idx.Write(packIdxSignature)
+	binary.Write(idx, binary.BigEndian, uint32(2))
+	for b := 0; b < 256; b++ {
+		count := 0
+		for _, hash := range hashes {
+			if int(hash.Bytes()[0]) <= b {
+				count++
+			}
+		}
+		binary.Write(idx, binary.BigEndian, uint32(count))
+	}
+	for _, i := range order {
+		idx.Write(hashes[i].Bytes())
+	}
+	for range order {
+		// CRCs aren't checked when reading
+		binary.Write(idx, binary.BigEndian, uint32(0))
+	}
+	for _, i := range order {
+		binary.Write(idx, binary.BigEndian, uint32(offsets[i]))
+	}
+	idx.Write(packSum[:])
+	idxSum := sha1.Sum(idx.Bytes())
+	idx.Write(idxSum[:])
+
+	name := "pack-" + hex.EncodeToString(packSum[:])
+	packPath := path.Join(dir, name+".pack")
+	err := os.WriteFile(packPath, pack.Bytes(), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing pack: %s", err)
+	}
+	err = os.WriteFile(path.Join(dir, name+".idx"), idx.Bytes(), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing index: %s", err)
+	}
+	return packPath, hashes
+}
+
+// Test that whole objects and both kinds of deltas are read from packs
+func TestPackStore(t *testing.T) {
+	base := strings.Repeat("base content\n", 10)
+	entries := []testPackEntry{
+		{objType: objects.Blob, content: base, base: -1},
+		{objType: objects.Blob, content: base[:len(base)/2] + "changed by offset\n", base: 0},
+		{objType: objects.Blob, content: base[:len(base)/2] + "changed by hash\n", base: 0, refDelta: true},
+		// A delta against another delta
+		{objType: objects.Blob, content: base[:len(base)/4] + "twice\n", base: 1},
+		{objType: objects.Commit, content: "tree 4b825dc642cb6eb9a060e54bf8d69288fbe4904b\n\nmsg\n", base: -1},
+	}
+	packPath, hashes := writeTestPack(t, t.TempDir(), entries)
+	store, err := OpenPack(vfs.OS, packPath, objects.SHA1)
+	if err != nil {
+		t.Fatalf("Error opening pack: %s", err)
+	}
+	defer store.Close()
+	for i, entry := range entries {
+		objType, content := readStoreObject(t, store, hashes[i])
+		if objType != entry.objType || content != entry.content {
+			t.Errorf("Expected %s '%s', Got %s '%s'", entry.objType, entry.content, objType, content)
+		}
+	}
+	matches, err := store.FindPrefix(hashes[4].Abbrev(5))
+	if err != nil || len(matches) != 1 || matches[0] != hashes[4] {
+		t.Errorf("Expected prefix to match %s, Got: %v, %v", hashes[4], matches, err)
+	}
+	_, err = store.Get(objects.MustParseObjectID("1111111111111111111111111111111111111111"))
+	if _, ok := err.(*ErrObjectNotFound); !ok {
+		t.Errorf("Expected ErrObjectNotFound for a missing object, Got: %v", err)
+	}
+	_, err = store.Put(objects.Blob, 1, strings.NewReader("x"))
+	if _, ok := err.(*ErrReadOnlyStore); !ok {
+		t.Errorf("Expected ErrReadOnlyStore when writing to a pack, Got: %v", err)
+	}
+}
+
+// Test that repos read objects from the packs in objects/pack
+func TestRepoReadsPacks(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	packDir := path.Join(repoStruct.GitDir, "objects", "pack")
+	err = os.MkdirAll(packDir, DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating pack dir: %s", err)
+	}
+	_, hashes := writeTestPack(t, packDir, []testPackEntry{{objType: objects.Blob, content: "packed\n", base: -1}})
+	repoStruct, err = OpenRepo(repoStruct.Worktree)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	obj, err := repoStruct.GetObject(hashes[0])
+	if err != nil {
+		t.Fatalf("Error reading packed object: %s", err)
+	}
+	if obj.String() != "packed\n" {
+		t.Errorf("Expected packed blob content, Got: %s", obj.String())
+	}
+	found, err := repoStruct.FindObject(hashes[0].Abbrev(7))
+	if err != nil || found != hashes[0] {
+		t.Errorf("Expected to find %s by prefix, Got: %s, %v", hashes[0], found, err)
+	}
+}
+
+// Test that a corrupt pack or a pack without an index doesn't stop the other packs from being
+// read, and that fsck reports the packs that were skipped
+func TestRepoSkipsBadPacks(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	packDir := path.Join(repoStruct.GitDir, "objects", "pack")
+	err = os.MkdirAll(packDir, DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating pack dir: %s", err)
+	}
+	_, hashes := writeTestPack(t, packDir, []testPackEntry{{objType: objects.Blob, content: "packed\n", base: -1}})
+	badPath, _ := writeTestPack(t, packDir, []testPackEntry{{objType: objects.Blob, content: "bad\n", base: -1}})
+	err = os.WriteFile(badPath, []byte("not a pack"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing pack: %s", err)
+	}
+	err = os.WriteFile(path.Join(packDir, "pack-noindex.pack"), []byte("PACK"), NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing pack: %s", err)
+	}
+
+	for _, reopen := range []bool{false, true} {
+		repoStruct, err = OpenRepo(repoStruct.Worktree)
+		if err != nil {
+			t.Fatalf("Error opening repo: %s", err)
+		}
+		// Repos without a store open the default one when it is first used
+		if reopen {
+			repoStruct.Objects = nil
+		}
+		obj, err := repoStruct.GetObject(hashes[0])
+		if err != nil || obj.String() != "packed\n" {
+			t.Fatalf("Expected to read the good pack, Got: %v, %v", obj, err)
+		}
+		skipped := repoStruct.objectStore().(*CompositeStore).Skipped()
+		if len(skipped) != 2 {
+			t.Fatalf("Expected 2 skipped packs, Got: %v", skipped)
+		}
+		for _, err := range skipped {
+			if _, ok := err.(*ErrBadPack); !ok {
+				t.Errorf("Expected ErrBadPack for a skipped pack, Got: %v", err)
+			}
+		}
+	}
+	lines := fsckLines(t, repoStruct, FsckOptions{})
+	badPacks := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "error: Could not read pack") {
+			badPacks++
+		}
+	}
+	if badPacks != 2 {
+		t.Errorf("Expected fsck to report 2 bad packs, Got: %v", lines)
+	}
+}
+
+// Test that entries and deltas whose sizes can't be right are reported instead of allocated
+func TestPackStoreBadSizes(t *testing.T) {
+	packPath, hashes := writeTestPack(t, t.TempDir(), []testPackEntry{{objType: objects.Blob, content: "packed\n", base: -1}})
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		t.Fatalf("Error reading pack: %s", err)
+	}
+	// Replace the one-byte header of the blob with one that claims it is about 2^60 bytes
+	corrupt := append([]byte{}, data[:12]...)
+	corrupt = append(corrupt, 0xbf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f)
+	corrupt = append(corrupt, data[13:]...)
+	err = os.WriteFile(packPath, corrupt, NormalFilemode)
+	if err != nil {
+		t.Fatalf("Error writing pack: %s", err)
+	}
+	store, err := OpenPack(vfs.OS, packPath, objects.SHA1)
+	if err != nil {
+		t.Fatalf("Error opening pack: %s", err)
+	}
+	defer store.Close()
+	_, err = store.Get(hashes[0])
+	if _, ok := err.(*ErrBadPack); !ok {
+		t.Errorf("Expected ErrBadPack for an entry larger than the pack, Got: %v", err)
+	}
+
+	delta := append(deltaSize(4), deltaSize(1<<40)...)
+	delta = append(delta, 0x80|0x01|0x10, 0, 4)
+	_, err = applyDelta([]byte("base"), delta)
+	if err == nil {
+		t.Errorf("Expected an error for a delta result larger than the delta can produce")
+	}
+}
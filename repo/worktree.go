@@ -0,0 +1,214 @@
+package repo
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SimonMTaye/gitgo/index"
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// IsModified Check if the worktree file for an index entry differs from what is staged.
+// Missing files count as modified. Mode changes are detected following core.filemode.
+// idx is the index the entry belongs to; its timestamp is used to detect racy entries
+func (repo *Repo) IsModified(idx *index.Index, entry *index.Entry) (bool, error) {
+	filePath := path.Join(repo.Worktree, entry.Name)
+	info, err := repo.FS().Lstat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !entry.ModeMatches(info, repo.TrustExecutableBit()) {
+		return true, nil
+	}
+	// If the 'stat' information is unchanged the file doesn't need to be hashed again, unless
+	// it was modified too close to when the index was written to trust it
+	if entry.StatMatches(info) && !entry.IsRacy(idx.Timestamp) {
+		return false, nil
+	}
+	content, size, err := repo.openFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer content.Close()
+	hash, err := repo.HashAlgorithm().HashReader(objects.Blob, size, content)
+	if err != nil {
+		return false, err
+	}
+	return hash != entry.Hash(), nil
+}
+
+// openFile Open a file in the repo's filesystem to read its blob content along with the size
+// of the content. Symbolic links aren't followed; their content is the target of the link
+func (repo *Repo) openFile(filePath string) (io.ReadCloser, int64, error) {
+	fsys := repo.FS()
+	info, err := fsys.Lstat(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := fsys.Readlink(filePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(strings.NewReader(target)), int64(len(target)), nil
+	}
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// ChangedFiles Compare the entries in the index to the worktree. Returns the names of entries
+// whose files are modified (which includes deleted files, like 'git ls-files -m') and the names
+// of those whose files are missing, both in index order
+func (repo *Repo) ChangedFiles(idx *index.Index) ([]string, []string, error) {
+	modified := make([]string, 0)
+	deleted := make([]string, 0)
+	for _, entry := range idx.Entries {
+		isModified, err := repo.IsModified(idx, entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isModified {
+			continue
+		}
+		modified = append(modified, entry.Name)
+		_, err = repo.FS().Lstat(path.Join(repo.Worktree, entry.Name))
+		if os.IsNotExist(err) {
+			deleted = append(deleted, entry.Name)
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+	return modified, deleted, nil
+}
+
+// UntrackedFiles List the files in the worktree that aren't in the index, skipping ignored
+// files and directories. The names are relative to the worktree and sorted
+func (repo *Repo) UntrackedFiles(idx *index.Index) ([]string, error) {
+	matcher, err := repo.IgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		tracked[entry.Name] = true
+	}
+	untracked := make([]string, 0)
+	err = repo.walkUntracked("", tracked, matcher, &untracked)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(untracked)
+	return untracked, nil
+}
+
+// Helper for UntrackedFiles. Adds the untracked files inside dir
+func (repo *Repo) walkUntracked(dir string, tracked map[string]bool, matcher *IgnoreMatcher,
+	untracked *[]string) error {
+	dirEntries, err := repo.FS().ReadDir(path.Join(repo.Worktree, dir))
+	if err != nil {
+		return err
+	}
+	for _, dirEntry := range dirEntries {
+		name := path.Join(dir, dirEntry.Name())
+		// Submodules are tracked as a single entry so their contents aren't untracked files
+		if dirEntry.Name() == ".git" || tracked[name] {
+			continue
+		}
+		ignored, err := matcher.Ignored(name, dirEntry.IsDir())
+		if err != nil {
+			return err
+		}
+		if ignored {
+			continue
+		}
+		if dirEntry.IsDir() {
+			err = repo.walkUntracked(name, tracked, matcher, untracked)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		*untracked = append(*untracked, name)
+	}
+	return nil
+}
+
+// CheckoutFile Write the blob with the given hash to the worktree at name (relative to the
+// worktree) using the given mode. Executable files get 0755 permissions and symbolic links are
+// recreated from the target stored in the blob. Missing parent directories are created.
+// An index entry refreshed after checking out a file won't be seen as modified
+func (repo *Repo) CheckoutFile(name string, hash objects.ObjectID, mode objects.EntryFileMode) error {
+	// Submodules point to commits in other repositories; all that can be done is to create
+	// the directory they live in
+	if mode == objects.GitLink {
+		return repo.FS().MkdirAll(path.Join(repo.Worktree, name), DirFilemode)
+	}
+	reader, err := repo.OpenObject(hash)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if reader.Type != objects.Blob {
+		return errors.New(hash.String() + " is not a blob")
+	}
+	filePath := path.Join(repo.Worktree, name)
+	err = repo.FS().MkdirAll(path.Dir(filePath), DirFilemode)
+	if err != nil {
+		return err
+	}
+	// Remove whatever is currently there so a symlink isn't followed when writing and so
+	// the permissions of an existing file don't stick around
+	err = repo.FS().Remove(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var perm os.FileMode
+	switch mode {
+	case objects.SymbolicLink:
+		target, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		return repo.FS().Symlink(string(target), filePath)
+	case objects.Executable:
+		perm = ExecutableFilemode
+	case objects.Normal:
+		perm = NormalFilemode
+	default:
+		return errors.New("cannot checkout " + name + " with mode " + string(mode))
+	}
+	file, err := repo.FS().OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, reader)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// checkoutEntry Write the blob an index entry points to into the worktree and refresh the
+// 'stat' information of the entry
+func (repo *Repo) checkoutEntry(entry *index.Entry) error {
+	err := repo.CheckoutFile(entry.Name, entry.Hash(), objects.FileModeFromBits(entry.Metadata.FileMode))
+	if err != nil {
+		return err
+	}
+	info, err := repo.FS().Lstat(path.Join(repo.Worktree, entry.Name))
+	if err != nil {
+		return err
+	}
+	return entry.SetStat(info)
+}
@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/SimonMTaye/gitgo/objects"
+)
+
+// Test that executable files and symbolic links keep their modes when added to the index, when
+// turned into trees and when checked out again
+func TestExecutableAndSymlinkModes(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	err = os.WriteFile(path.Join(repoStruct.Worktree, "script.sh"), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatalf("Error creating file: %s", err)
+	}
+	err = os.Symlink("script.sh", path.Join(repoStruct.Worktree, "link"))
+	if err != nil {
+		t.Fatalf("Error creating symlink: %s", err)
+	}
+	for _, file := range []string{"script.sh", "link"} {
+		err = repoStruct.AddFile(file)
+		if err != nil {
+			t.Fatalf("Error adding file: %s", err)
+		}
+	}
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error getting index: %s", err)
+	}
+	tree, err := indexToTreeMap(idx).toTree()
+	if err != nil {
+		t.Fatalf("Error creating tree: %s", err)
+	}
+	linkHash, err := tree.GetEntryHash("link")
+	if err != nil {
+		t.Fatalf("Error finding link in tree: %s", err)
+	}
+	blob := &objects.GitBlob{}
+	blob.Deserialize([]byte("script.sh"))
+	if linkHash != objects.Hash(blob) {
+		t.Errorf("Expected link to point to a blob of its target\nTree:\n%s", tree)
+	}
+	expected := "100755 script.sh"
+	if !containsLine(tree.String(), expected) {
+		t.Errorf("Expected tree to contain '%s'\nTree:\n%s", expected, tree)
+	}
+	expected = "120000 link"
+	if !containsLine(tree.String(), expected) {
+		t.Errorf("Expected tree to contain '%s'\nTree:\n%s", expected, tree)
+	}
+
+	for _, entry := range idx.Entries {
+		modified, err := repoStruct.IsModified(idx, entry)
+		if err != nil {
+			t.Fatalf("Error checking %s for modifications: %s", entry.Name, err)
+		}
+		if modified {
+			t.Errorf("Did not expect %s to be modified", entry.Name)
+		}
+	}
+
+	// Remove both files and check them out from the objects database
+	for _, file := range []string{"script.sh", "link"} {
+		err = os.Remove(path.Join(repoStruct.Worktree, file))
+		if err != nil {
+			t.Fatalf("Error removing file: %s", err)
+		}
+	}
+	scriptHash, _ := tree.GetEntryHash("script.sh")
+	err = repoStruct.CheckoutFile("script.sh", scriptHash, objects.Executable)
+	if err != nil {
+		t.Fatalf("Error checking out script: %s", err)
+	}
+	err = repoStruct.CheckoutFile("link", linkHash, objects.SymbolicLink)
+	if err != nil {
+		t.Fatalf("Error checking out link: %s", err)
+	}
+	info, err := os.Lstat(path.Join(repoStruct.Worktree, "script.sh"))
+	if err != nil {
+		t.Fatalf("Error reading script: %s", err)
+	}
+	if info.Mode()&0100 == 0 {
+		t.Errorf("Expected checked out script to be executable, Got: %s", info.Mode())
+	}
+	target, err := os.Readlink(path.Join(repoStruct.Worktree, "link"))
+	if err != nil {
+		t.Fatalf("Error reading link: %s", err)
+	}
+	if target != "script.sh" {
+		t.Errorf("Expected link to point to 'script.sh', Got: %s", target)
+	}
+
+	// Dropping the executable bit is a modification unless core.filemode is false
+	err = CreateAndWrite(path.Join(repoStruct.GitDir, "config"), "[core]\nfilemode = true\n")
+	if err != nil {
+		t.Fatalf("Error writing config: %s", err)
+	}
+	err = os.Chmod(path.Join(repoStruct.Worktree, "script.sh"), 0644)
+	if err != nil {
+		t.Fatalf("Error changing file mode: %s", err)
+	}
+	_, pos := idx.EntryExists("script.sh")
+	modified, err := repoStruct.IsModified(idx, idx.Entries[pos])
+	if err != nil {
+		t.Fatalf("Error checking script for modifications: %s", err)
+	}
+	if !modified {
+		t.Errorf("Expected mode change to be detected")
+	}
+	err = CreateAndWrite(path.Join(repoStruct.GitDir, "config"), "[core]\nfilemode = false\n")
+	if err != nil {
+		t.Fatalf("Error writing config: %s", err)
+	}
+	modified, err = repoStruct.IsModified(idx, idx.Entries[pos])
+	if err != nil {
+		t.Fatalf("Error checking script for modifications: %s", err)
+	}
+	if modified {
+		t.Errorf("Expected mode change to be ignored when core.filemode is false")
+	}
+}
+
+// Check if a line in text starts with prefix
+func containsLine(text string, prefix string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Test that modified, deleted and untracked files are found
+func TestChangedAndUntrackedFiles(t *testing.T) {
+	repoStruct, err := createNewRepo(t)
+	if err != nil {
+		t.Fatalf("Error opening repo: %s", err)
+	}
+	addTestFiles(t, repoStruct, "same.txt", "changed.txt", "dir/deleted.txt")
+	writeRepoFile(t, repoStruct, "changed.txt", "new content")
+	err = os.Remove(path.Join(repoStruct.Worktree, "dir/deleted.txt"))
+	if err != nil {
+		t.Fatalf("Error deleting file: %s", err)
+	}
+	err = os.MkdirAll(path.Join(repoStruct.Worktree, "new", "ignored"), DirFilemode)
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	writeRepoFile(t, repoStruct, ".gitignore", "ignored/\n*.log\n")
+	writeRepoFile(t, repoStruct, "new/file.txt", "untracked")
+	writeRepoFile(t, repoStruct, "new/ignored/file.txt", "ignored")
+	writeRepoFile(t, repoStruct, "debug.log", "ignored")
+
+	idx, err := repoStruct.Index()
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	modified, deleted, err := repoStruct.ChangedFiles(idx)
+	if err != nil {
+		t.Fatalf("Error finding changed files: %s", err)
+	}
+	if !samePaths(modified, []string{"changed.txt", "dir/deleted.txt"}) {
+		t.Errorf("Expected changed.txt and dir/deleted.txt to be modified, Got: %v", modified)
+	}
+	if !samePaths(deleted, []string{"dir/deleted.txt"}) {
+		t.Errorf("Expected dir/deleted.txt to be deleted, Got: %v", deleted)
+	}
+	untracked, err := repoStruct.UntrackedFiles(idx)
+	if err != nil {
+		t.Fatalf("Error finding untracked files: %s", err)
+	}
+	if !samePaths(untracked, []string{".gitignore", "new/file.txt"}) {
+		t.Errorf("Expected .gitignore and new/file.txt to be untracked, Got: %v", untracked)
+	}
+}
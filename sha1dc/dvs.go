@@ -0,0 +1,100 @@
+package sha1dc
+
+import "math/bits"
+
+// Disturbance vectors describe where the local collisions of an attack start. They are
+// codewords of the SHA-1 message expansion, so a vector is fixed by 16 consecutive words:
+//   - type I(K,b) has a single bit b in word K+15 and zeros in words K to K+14
+//   - type II(K,b) has bit b+31 in words K+1 and K+3, bit b in word K+15 and zeros elsewhere
+//     in words K to K+15
+// These are the vectors of the known attacks and the ones close enough to them to be usable,
+// the same 32 that sha1collisiondetection checks. SHAttered used II(52,0)
+
+// disturbanceVector A disturbance vector along with the message difference it causes (dm)
+// and the step it is tested at, which is one where the compressions of the two messages of a
+// collision have the same state
+type disturbanceVector struct {
+	dvType int
+	k      int
+	b      int
+	testt  int
+	dm     [80]uint32
+}
+
+// disturbanceVectors The vectors every block is tested against
+var disturbanceVectors = []disturbanceVector{
+	newDisturbanceVector(1, 43, 0, 58), newDisturbanceVector(1, 44, 0, 58),
+	newDisturbanceVector(1, 45, 0, 58), newDisturbanceVector(1, 46, 0, 58),
+	newDisturbanceVector(1, 46, 2, 58), newDisturbanceVector(1, 47, 0, 58),
+	newDisturbanceVector(1, 47, 2, 58), newDisturbanceVector(1, 48, 0, 58),
+	newDisturbanceVector(1, 48, 2, 58), newDisturbanceVector(1, 49, 0, 58),
+	newDisturbanceVector(1, 49, 2, 58), newDisturbanceVector(1, 50, 0, 65),
+	newDisturbanceVector(1, 50, 2, 65), newDisturbanceVector(1, 51, 0, 65),
+	newDisturbanceVector(1, 51, 2, 65), newDisturbanceVector(1, 52, 0, 65),
+	newDisturbanceVector(2, 45, 0, 58), newDisturbanceVector(2, 46, 0, 58),
+	newDisturbanceVector(2, 46, 2, 58), newDisturbanceVector(2, 47, 0, 58),
+	newDisturbanceVector(2, 48, 0, 58), newDisturbanceVector(2, 49, 0, 58),
+	newDisturbanceVector(2, 49, 2, 58), newDisturbanceVector(2, 50, 0, 65),
+	newDisturbanceVector(2, 50, 2, 65), newDisturbanceVector(2, 51, 0, 65),
+	newDisturbanceVector(2, 51, 2, 65), newDisturbanceVector(2, 52, 0, 65),
+	newDisturbanceVector(2, 53, 0, 65), newDisturbanceVector(2, 54, 0, 65),
+	newDisturbanceVector(2, 55, 0, 65), newDisturbanceVector(2, 56, 0, 65),
+}
+
+// dvOffset The disturbance vector is needed from step -5 for the message difference of the
+// first steps
+const dvOffset = 5
+
+// expandDisturbanceVector The words of a disturbance vector from step -5 to 79, indexed from
+// dvOffset
+func expandDisturbanceVector(dvType, k, b int) [80 + dvOffset]uint32 {
+	var dv [80 + dvOffset]uint32
+	at := func(step int) *uint32 { return &dv[step+dvOffset] }
+	*at(k + 15) = bits.RotateLeft32(1, b)
+	if dvType == 2 {
+		*at(k + 1) = bits.RotateLeft32(1, b+31)
+		*at(k + 3) = bits.RotateLeft32(1, b+31)
+	}
+	// Steps after the fixed words follow the message expansion, and the expansion can be run
+	// backwards for the steps before them
+	for i := k + 16; i < 80; i++ {
+		*at(i) = bits.RotateLeft32(*at(i - 3)^*at(i - 8)^*at(i - 14)^*at(i - 16), 1)
+	}
+	for i := k + 15; i-16 >= -dvOffset; i-- {
+		*at(i - 16) = bits.RotateLeft32(*at(i), -1) ^ *at(i - 3) ^ *at(i - 8) ^ *at(i - 14)
+	}
+	return dv
+}
+
+// newDisturbanceVector Build a disturbance vector and the message difference of its local
+// collisions: a disturbance in step i is corrected in the five steps after it
+func newDisturbanceVector(dvType, k, b, testt int) disturbanceVector {
+	dv := expandDisturbanceVector(dvType, k, b)
+	vector := disturbanceVector{dvType: dvType, k: k, b: b, testt: testt}
+	for t := 0; t < 80; t++ {
+		i := t + dvOffset
+		vector.dm[t] = dv[i] ^ bits.RotateLeft32(dv[i-1], 5) ^ dv[i-2] ^
+			bits.RotateLeft32(dv[i-3], 30) ^ bits.RotateLeft32(dv[i-4], 30) ^ bits.RotateLeft32(dv[i-5], 30)
+	}
+	return vector
+}
+
+// detectCollision Check if the block with expanded message w, whose compression ended in ihv,
+// is part of a collision following any of the vectors. For each vector the other message of
+// the collision is w with the vector's difference; it has the same state at the test step, so
+// the compression of it can be recomputed from there. The block is part of a collision if that
+// compression ends in the same chaining value. Only the vectors whose bit (bit n for vectors[n])
+// is set in mask are tested
+func detectCollision(ihv [5]uint32, w *[80]uint32, states *testStates, vectors []disturbanceVector, mask uint32) bool {
+	for i := range vectors {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		vector := &vectors[i]
+		_, ihvOut := recompress(vector.testt, w, &vector.dm, states.get(vector.testt))
+		if ihvOut == ihv {
+			return true
+		}
+	}
+	return false
+}
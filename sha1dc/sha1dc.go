@@ -0,0 +1,229 @@
+// Package sha1dc SHA-1 with detection of the collision attacks on it (e.g. SHAttered), like the
+// sha1collisiondetection library git uses. The hashes are those of plain SHA-1; input that is
+// part of a collision attack is hashed the same way but flagged, so it can be rejected
+//
+// Known attacks build a collision from near-collision blocks that follow one of a small set of
+// disturbance vectors. For every block, each disturbance vector gives the message the other
+// half of a collision would have; if compressing that message from the chaining value it would
+// need ends in the same chaining value as the real block, the block is part of a collision
+package sha1dc
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Size The size of a SHA-1 hash in bytes
+const Size = 20
+
+// BlockSize The block size of SHA-1 in bytes
+const BlockSize = 64
+
+const (
+	k0 = 0x5a827999
+	k1 = 0x6ed9eba1
+	k2 = 0x8f1bbcdc
+	k3 = 0xca62c1d6
+)
+
+var initialState = [5]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476, 0xc3d2e1f0}
+
+// Digest A SHA-1 hash that records whether any of the data written to it was part of a
+// collision attack. It implements hash.Hash
+type Digest struct {
+	h   [5]uint32
+	buf [BlockSize]byte
+	// nbuf The number of bytes in buf that haven't been compressed yet
+	nbuf   int
+	length uint64
+	// collision Whether a block that was compressed is part of a collision attack
+	collision bool
+}
+
+var _ hash.Hash = (*Digest)(nil)
+
+// New Start a new hash
+func New() *Digest {
+	d := &Digest{}
+	d.Reset()
+	return d
+}
+
+// Sum Return the SHA-1 hash of data and whether data is part of a collision attack
+func Sum(data []byte) ([Size]byte, bool) {
+	d := New()
+	d.Write(data)
+	var sum [Size]byte
+	copy(sum[:], d.Sum(nil))
+	return sum, d.CollisionDetected()
+}
+
+// Reset Clear the data written so far, including any collision that was detected
+func (d *Digest) Reset() {
+	d.h = initialState
+	d.nbuf = 0
+	d.length = 0
+	d.collision = false
+}
+
+// Size The size of the hash in bytes
+func (d *Digest) Size() int {
+	return Size
+}
+
+// BlockSize The block size of SHA-1 in bytes
+func (d *Digest) BlockSize() int {
+	return BlockSize
+}
+
+// CollisionDetected Whether the data written so far is part of a collision attack
+func (d *Digest) CollisionDetected() bool {
+	return d.collision
+}
+
+// Write Add data to the hash. It never returns an error
+func (d *Digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.length += uint64(n)
+	if d.nbuf > 0 {
+		copied := copy(d.buf[d.nbuf:], p)
+		d.nbuf += copied
+		p = p[copied:]
+		if d.nbuf < BlockSize {
+			return n, nil
+		}
+		d.block(d.buf[:])
+		d.nbuf = 0
+	}
+	for len(p) >= BlockSize {
+		d.block(p[:BlockSize])
+		p = p[BlockSize:]
+	}
+	d.nbuf = copy(d.buf[:], p)
+	return n, nil
+}
+
+// Sum Append the hash of the data written so far to b. The state of the hash isn't changed
+func (d *Digest) Sum(b []byte) []byte {
+	// Pad a copy so more data can still be written
+	final := *d
+	padding := make([]byte, BlockSize+8)
+	padding[0] = 0x80
+	padLen := BlockSize - (int(d.length)+8)%BlockSize
+	if padLen == 0 {
+		padLen = BlockSize
+	}
+	binary.BigEndian.PutUint64(padding[padLen:], d.length*8)
+	final.Write(padding[:padLen+8])
+	sum := make([]byte, Size)
+	for i, word := range final.h {
+		binary.BigEndian.PutUint32(sum[i*4:], word)
+	}
+	return append(b, sum...)
+}
+
+// block Compress a single block, checking it for collisions
+func (d *Digest) block(p []byte) {
+	var w [80]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[i*4:])
+	}
+	for i := 16; i < 80; i++ {
+		w[i] = bits.RotateLeft32(w[i-3]^w[i-8]^w[i-14]^w[i-16], 1)
+	}
+	var states testStates
+	d.h = compress(d.h, &w, &states)
+	mask := unavoidableMask(&w)
+	if mask != 0 && detectCollision(d.h, &w, &states, disturbanceVectors, mask) {
+		d.collision = true
+	}
+}
+
+// testStates The working state of a compression before steps 58 and 65, where the disturbance
+// vectors are tested
+type testStates [2][5]uint32
+
+// get The state before step t, which is either 58 or 65
+func (states *testStates) get(t int) [5]uint32 {
+	if t == 58 {
+		return states[0]
+	}
+	return states[1]
+}
+
+// forward Run steps from to to-1 of the compression of the expanded message w xor dm on state
+func forward(state [5]uint32, w, dm *[80]uint32, from, to int) [5]uint32 {
+	a, b, c, d, e := state[0], state[1], state[2], state[3], state[4]
+	t := from
+	for ; t < to && t < 20; t++ {
+		tmp := bits.RotateLeft32(a, 5) + (b&c | ^b&d) + e + k0 + (w[t] ^ dm[t])
+		a, b, c, d, e = tmp, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; t < to && t < 40; t++ {
+		tmp := bits.RotateLeft32(a, 5) + (b ^ c ^ d) + e + k1 + (w[t] ^ dm[t])
+		a, b, c, d, e = tmp, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; t < to && t < 60; t++ {
+		tmp := bits.RotateLeft32(a, 5) + (b&c | b&d | c&d) + e + k2 + (w[t] ^ dm[t])
+		a, b, c, d, e = tmp, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; t < to; t++ {
+		tmp := bits.RotateLeft32(a, 5) + (b ^ c ^ d) + e + k3 + (w[t] ^ dm[t])
+		a, b, c, d, e = tmp, a, bits.RotateLeft32(b, 30), c, d
+	}
+	return [5]uint32{a, b, c, d, e}
+}
+
+// backward Undo steps from-1 down to 0 of the compression of the expanded message w xor dm,
+// starting from the state before step from. Each step turned (a, b, c, d, e) into
+// (tmp, a, b<<<30, c, d)
+func backward(state [5]uint32, w, dm *[80]uint32, from int) [5]uint32 {
+	a, b, c, d, e := state[0], state[1], state[2], state[3], state[4]
+	t := from - 1
+	for ; t >= 60; t-- {
+		tmp := a
+		a, b, c, d = b, bits.RotateLeft32(c, -30), d, e
+		e = tmp - bits.RotateLeft32(a, 5) - (b ^ c ^ d) - k3 - (w[t] ^ dm[t])
+	}
+	for ; t >= 40; t-- {
+		tmp := a
+		a, b, c, d = b, bits.RotateLeft32(c, -30), d, e
+		e = tmp - bits.RotateLeft32(a, 5) - (b&c | b&d | c&d) - k2 - (w[t] ^ dm[t])
+	}
+	for ; t >= 20; t-- {
+		tmp := a
+		a, b, c, d = b, bits.RotateLeft32(c, -30), d, e
+		e = tmp - bits.RotateLeft32(a, 5) - (b ^ c ^ d) - k1 - (w[t] ^ dm[t])
+	}
+	for ; t >= 0; t-- {
+		tmp := a
+		a, b, c, d = b, bits.RotateLeft32(c, -30), d, e
+		e = tmp - bits.RotateLeft32(a, 5) - (b&c | ^b&d) - k0 - (w[t] ^ dm[t])
+	}
+	return [5]uint32{a, b, c, d, e}
+}
+
+// noDifference The difference used to run the compression of a message as it is
+var noDifference [80]uint32
+
+// add Add two chaining values word by word
+func add(x, y [5]uint32) [5]uint32 {
+	return [5]uint32{x[0] + y[0], x[1] + y[1], x[2] + y[2], x[3] + y[3], x[4] + y[4]}
+}
+
+// compress Run the SHA-1 compression function on the expanded message w starting from ihv,
+// storing the states needed to test disturbance vectors. Returns the new chaining value
+func compress(ihv [5]uint32, w *[80]uint32, states *testStates) [5]uint32 {
+	states[0] = forward(ihv, w, &noDifference, 0, 58)
+	states[1] = forward(states[0], w, &noDifference, 58, 65)
+	return add(ihv, forward(states[1], w, &noDifference, 65, 80))
+}
+
+// recompress Given the state before step t of compressing the expanded message w xor dm, run
+// the steps before t backwards to find the chaining value the compression started from and the
+// remaining steps forwards to find the chaining value it ends in
+func recompress(t int, w, dm *[80]uint32, state [5]uint32) ([5]uint32, [5]uint32) {
+	ihvIn := backward(state, w, dm, t)
+	return ihvIn, add(ihvIn, forward(state, w, dm, t, 80))
+}
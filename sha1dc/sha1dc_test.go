@@ -0,0 +1,166 @@
+package sha1dc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// Test that the hashes are those of SHA-1 for data of every length around the block size,
+// whether it is written at once or in pieces
+func TestSum(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for size := 0; size < 300; size++ {
+		data := make([]byte, size)
+		random.Read(data)
+		sum, collision := Sum(data)
+		if sum != sha1.Sum(data) || collision {
+			t.Fatalf("Expected the SHA-1 of %d bytes without a collision, Got: %x, %v", size, sum, collision)
+		}
+		d := New()
+		for rest := data; len(rest) > 0; {
+			n := random.Intn(len(rest)) + 1
+			d.Write(rest[:n])
+			rest = rest[n:]
+		}
+		expected := sha1.Sum(data)
+		if !bytes.Equal(d.Sum(nil), expected[:]) {
+			t.Fatalf("Expected the SHA-1 of %d bytes written in pieces, Got: %x", size, d.Sum(nil))
+		}
+	}
+}
+
+// Test the disturbance vectors against the message difference of I(43,0) published with
+// sha1collisiondetection and check that every vector can be tested at its step
+func TestDisturbanceVectors(t *testing.T) {
+	if len(disturbanceVectors) != 32 {
+		t.Errorf("Expected 32 disturbance vectors, Got: %d", len(disturbanceVectors))
+	}
+	first := []uint32{0x08000000, 0x9800000c, 0xd8000010, 0x08000010, 0xb8000010, 0x98000000}
+	for i, word := range first {
+		if disturbanceVectors[0].dm[i] != word {
+			t.Errorf("Expected word %d of the difference of I(43,0) to be %08x, Got: %08x", i, word,
+				disturbanceVectors[0].dm[i])
+		}
+	}
+	for _, vector := range disturbanceVectors {
+		// The difference of two expanded messages is itself an expanded message
+		for i := 16; i < 80; i++ {
+			dm := vector.dm
+			if dm[i] != bits.RotateLeft32(dm[i-3]^dm[i-8]^dm[i-14]^dm[i-16], 1) {
+				t.Errorf("Expected the difference of %d(%d,%d) to follow the message expansion at step %d",
+					vector.dvType, vector.k, vector.b, i)
+			}
+		}
+		// The two messages only have the same state at the test step if every local collision
+		// started before it has been corrected
+		dv := expandDisturbanceVector(vector.dvType, vector.k, vector.b)
+		for i := vector.testt - 5; i < vector.testt; i++ {
+			if dv[i+dvOffset] != 0 {
+				t.Errorf("Expected no disturbance in step %d of %d(%d,%d), which is tested at step %d",
+					i, vector.dvType, vector.k, vector.b, vector.testt)
+			}
+		}
+	}
+}
+
+// Test that recompressing from either test step gives back the chaining values of a block and
+// that a block is flagged when a vector's difference leads to the same chaining value, which a
+// vector without a difference always does
+func TestDetectCollision(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	var w [80]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = random.Uint32()
+	}
+	for i := 16; i < 80; i++ {
+		w[i] = bits.RotateLeft32(w[i-3]^w[i-8]^w[i-14]^w[i-16], 1)
+	}
+	ihv := [5]uint32{random.Uint32(), random.Uint32(), random.Uint32(), random.Uint32(), random.Uint32()}
+	var states testStates
+	out := compress(ihv, &w, &states)
+	for _, step := range []int{58, 65} {
+		ihvIn, ihvOut := recompress(step, &w, &noDifference, states.get(step))
+		if ihvIn != ihv || ihvOut != out {
+			t.Errorf("Expected recompressing from step %d to give %08x and %08x, Got: %08x and %08x",
+				step, ihv, out, ihvIn, ihvOut)
+		}
+	}
+	if detectCollision(out, &w, &states, disturbanceVectors, ^uint32(0)) {
+		t.Errorf("Expected a random block not to be part of a collision")
+	}
+	if !detectCollision(out, &w, &states, []disturbanceVector{{testt: 65}}, 1) {
+		t.Errorf("Expected a block to collide with itself")
+	}
+}
+
+// shatteredPrefix The first three blocks shared by the two PDFs of the SHAttered attack
+// (shattered-1.pdf and shattered-2.pdf), in hex
+const shatteredPrefix = "" +
+	"255044462d312e330a25e2e3cfd30a0a0a312030206f626a0a3c3c2f57696474" +
+	"682032203020522f4865696768742033203020522f547970652034203020522f" +
+	"537562747970652035203020522f46696c7465722036203020522f436f6c6f72" +
+	"53706163652037203020522f4c656e6774682038203020522f42697473506572" +
+	"436f6d706f6e656e7420383e3e0a73747265616d0affd8fffe00245348412d31" +
+	"20697320646561642121212121852fec092339759c39b1a1c63c4c97e1fffe01"
+
+// shatteredBlocks The colliding blocks that follow shatteredPrefix in each PDF, in hex. Both
+// PDFs have the same hash after them
+var shatteredBlocks = [2]string{
+	"" +
+		"7346dc9166b67e118f029ab621b2560ff9ca67cca8c7f85ba84c79030c2b3de2" +
+		"18f86db3a90901d5df45c14f26fedfb3dc38e96ac22fe7bd728f0e45bce046d2" +
+		"3c570feb141398bb552ef5a0a82be331fea48037b8b5d71f0e332edf93ac3500" +
+		"eb4ddc0decc1a864790c782c76215660dd309791d06bd0af3f98cda4bc4629b1",
+	"" +
+		"7f46dc93a6b67e013b029aaa1db2560b45ca67d688c7f84b8c4c791fe02b3df6" +
+		"14f86db1690901c56b45c1530afedfb76038e972722fe7ad728f0e4904e046c2" +
+		"30570fe9d41398abe12ef5bc942be33542a4802d98b5d70f2a332ec37fac3514" +
+		"e74ddc0f2cc1a874cd0c78305a21566461309789606bd0bf3f98cda8044629a1",
+}
+
+// Test that the first 320 bytes of both SHAttered PDFs are flagged as a collision while still
+// hashing to the same SHA-1, and that changing a byte of the colliding blocks isn't flagged
+func TestShattered(t *testing.T) {
+	expected := "f92d74e3874587aaf443d1db961d4e26dde13e9c"
+	for i, blocks := range shatteredBlocks {
+		data, err := hex.DecodeString(shatteredPrefix + blocks)
+		if err != nil {
+			t.Fatalf("Error decoding shattered-%d: %s", i+1, err)
+		}
+		sum, collision := Sum(data)
+		if hex.EncodeToString(sum[:]) != expected || !collision {
+			t.Errorf("Expected shattered-%d to hash to %s with a collision, Got: %x, %v", i+1, expected, sum, collision)
+		}
+		data[len(data)-1] ^= 1
+		sum, collision = Sum(data)
+		if sum != sha1.Sum(data) || collision {
+			t.Errorf("Expected a changed shattered-%d to hash like SHA-1 without a collision, Got: %x, %v", i+1, sum, collision)
+		}
+	}
+}
+
+// Benchmark hashing with collision detection. Compare with BenchmarkCryptoSHA1
+func BenchmarkWrite(b *testing.B) {
+	data := make([]byte, 1<<16)
+	rand.New(rand.NewSource(3)).Read(data)
+	b.SetBytes(int64(len(data)))
+	d := New()
+	for i := 0; i < b.N; i++ {
+		d.Write(data)
+	}
+}
+
+// Benchmark plain SHA-1 from the standard library on the same data as BenchmarkWrite
+func BenchmarkCryptoSHA1(b *testing.B) {
+	data := make([]byte, 1<<16)
+	rand.New(rand.NewSource(3)).Read(data)
+	b.SetBytes(int64(len(data)))
+	h := sha1.New()
+	for i := 0; i < b.N; i++ {
+		h.Write(data)
+	}
+}
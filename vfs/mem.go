@@ -0,0 +1,552 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by MemFS that have no equivalent in io/fs
+var (
+	ErrNotDir   = errors.New("not a directory")
+	ErrIsDir    = errors.New("is a directory")
+	ErrNotEmpty = errors.New("directory not empty")
+)
+
+// Symbolic links followed before giving up, the same limit Linux uses
+const maxSymlinks = 40
+
+// MemFS A filesystem held entirely in memory. Paths are treated as absolute, so "a/b" and
+// "/a/b" are the same file. Symbolic links are only followed when they are the last component
+// of a path. It is safe for concurrent use
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+	// dirs The cleaned paths of the direct children of each directory that has any
+	dirs map[string]map[string]bool
+}
+
+// A file, directory or symbolic link
+type memNode struct {
+	mode    fs.FileMode
+	data    []byte
+	target  string
+	modTime time.Time
+}
+
+// NewMemFS Create an empty in-memory filesystem with just a root directory
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes: map[string]*memNode{
+			"/": {mode: fs.ModeDir | 0777, modTime: time.Now()},
+		},
+		dirs: make(map[string]map[string]bool),
+	}
+}
+
+// Turn a name into the key used for its node
+func clean(name string) string {
+	return path.Clean("/" + name)
+}
+
+// Find the node for a cleaned path, following a symbolic link at the end of it if follow is
+// set. Returns the path of the node that was found. Must be called with the lock held
+func (mfs *MemFS) lookup(op string, name string, follow bool) (string, *memNode, error) {
+	p := clean(name)
+	for i := 0; i < maxSymlinks; i++ {
+		node, ok := mfs.nodes[p]
+		if !ok {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if !follow || node.mode&fs.ModeSymlink == 0 {
+			return p, node, nil
+		}
+		if path.IsAbs(node.target) {
+			p = clean(node.target)
+		} else {
+			p = clean(path.Join(path.Dir(p), node.target))
+		}
+	}
+	return "", nil, &fs.PathError{Op: op, Path: name, Err: errors.New("too many levels of symbolic links")}
+}
+
+// Check that the parent of a cleaned path is an existing directory. Must be called with the
+// lock held
+func (mfs *MemFS) checkParent(op string, name string, p string) error {
+	_, parent, err := mfs.lookup(op, path.Dir(p), true)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: ErrNotDir}
+	}
+	return nil
+}
+
+// The cleaned paths of the direct children of a directory, sorted. Must be called with the
+// lock held
+func (mfs *MemFS) children(dir string) []string {
+	names := make([]string, 0, len(mfs.dirs[dir]))
+	for p := range mfs.dirs[dir] {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Store a node at a cleaned path and record it as a child of its directory. Must be called
+// with the lock held
+func (mfs *MemFS) put(p string, node *memNode) {
+	mfs.nodes[p] = node
+	dir := path.Dir(p)
+	if mfs.dirs[dir] == nil {
+		mfs.dirs[dir] = make(map[string]bool)
+	}
+	mfs.dirs[dir][p] = true
+}
+
+// Delete the node at a cleaned path. Must be called with the lock held
+func (mfs *MemFS) delete(p string) {
+	delete(mfs.nodes, p)
+	dir := path.Dir(p)
+	delete(mfs.dirs[dir], p)
+	if len(mfs.dirs[dir]) == 0 {
+		delete(mfs.dirs, dir)
+	}
+}
+
+// Open Open a file or directory for reading
+func (mfs *MemFS) Open(name string) (fs.File, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	p, node, err := mfs.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return &memDir{fsys: mfs, path: p, name: name}, nil
+	}
+	return &memFile{fsys: mfs, node: node, name: name, readable: true}, nil
+}
+
+// Stat Return information about a file, following symbolic links
+func (mfs *MemFS) Stat(name string) (fs.FileInfo, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	p, node, err := mfs.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(path.Base(p)), nil
+}
+
+// Lstat Return information about a file without following symbolic links
+func (mfs *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	p, node, err := mfs.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(path.Base(p)), nil
+}
+
+// ReadDir List a directory, sorted by name
+func (mfs *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	p, node, err := mfs.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+	entries := make([]fs.DirEntry, 0)
+	for _, child := range mfs.children(p) {
+		entries = append(entries, dirEntry{mfs.nodes[child].info(path.Base(child))})
+	}
+	return entries, nil
+}
+
+// ReadFile Return the content of a file
+func (mfs *MemFS) ReadFile(name string) ([]byte, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	_, node, err := mfs.lookup("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: ErrIsDir}
+	}
+	return append([]byte{}, node.data...), nil
+}
+
+// Readlink Return the target of a symbolic link
+func (mfs *MemFS) Readlink(name string) (string, error) {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+	_, node, err := mfs.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return node.target, nil
+}
+
+// OpenFile Open a file with the flags used by os.OpenFile
+func (mfs *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	p, node, err := mfs.lookup("open", name, true)
+	if err != nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		// Create the file where a dangling symbolic link points, if there is one
+		p, _, err = mfs.lookupTarget(name)
+		if err != nil {
+			return nil, err
+		}
+		err = mfs.checkParent("open", name, p)
+		if err != nil {
+			return nil, err
+		}
+		node = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		mfs.put(p, node)
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	if node.mode.IsDir() {
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrIsDir}
+		}
+		return &memDir{fsys: mfs, path: p, name: name}, nil
+	}
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if writable && flag&os.O_TRUNC != 0 {
+		node.data = nil
+		node.modTime = time.Now()
+	}
+	file := &memFile{fsys: mfs, node: node, name: name, readable: flag&os.O_WRONLY == 0, writable: writable}
+	if flag&os.O_APPEND != 0 {
+		file.offset = int64(len(node.data))
+	}
+	return file, nil
+}
+
+// Follow symbolic links until a path that doesn't exist is reached. Must be called with the
+// lock held
+func (mfs *MemFS) lookupTarget(name string) (string, *memNode, error) {
+	p := clean(name)
+	for i := 0; i < maxSymlinks; i++ {
+		node, ok := mfs.nodes[p]
+		if !ok {
+			return p, nil, nil
+		}
+		if node.mode&fs.ModeSymlink == 0 {
+			return p, node, nil
+		}
+		if path.IsAbs(node.target) {
+			p = clean(node.target)
+		} else {
+			p = clean(path.Join(path.Dir(p), node.target))
+		}
+	}
+	return "", nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("too many levels of symbolic links")}
+}
+
+// WriteFile Write data to a file, creating it if needed
+func (mfs *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	file, err := mfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Mkdir Create a directory whose parent exists
+func (mfs *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	p := clean(name)
+	if _, ok := mfs.nodes[p]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	err := mfs.checkParent("mkdir", name, p)
+	if err != nil {
+		return err
+	}
+	mfs.put(p, &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()})
+	return nil
+}
+
+// MkdirAll Create a directory and any missing parents. Existing directories are fine
+func (mfs *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	p := clean(name)
+	current := "/"
+	for _, part := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current = path.Join(current, part)
+		_, node, err := mfs.lookup("mkdir", current, true)
+		if err == nil {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: ErrNotDir}
+			}
+			continue
+		}
+		mfs.put(current, &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()})
+	}
+	return nil
+}
+
+// Remove Remove a file, symbolic link or empty directory
+func (mfs *MemFS) Remove(name string) error {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	p, node, err := mfs.lookup("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if p == "/" || node.mode.IsDir() && len(mfs.children(p)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+	}
+	mfs.delete(p)
+	return nil
+}
+
+// Rename Move a file or directory (along with everything in it). An existing file at newName
+// is replaced; an existing directory is only replaced by a directory, and only if it is empty
+func (mfs *MemFS) Rename(oldName string, newName string) error {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	oldPath, node, err := mfs.lookup("rename", oldName, false)
+	if err != nil {
+		return err
+	}
+	newPath := clean(newName)
+	if oldPath == newPath {
+		return nil
+	}
+	if strings.HasPrefix(newPath, oldPath+"/") || oldPath == "/" {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrInvalid}
+	}
+	err = mfs.checkParent("rename", newName, newPath)
+	if err != nil {
+		return err
+	}
+	if existing, ok := mfs.nodes[newPath]; ok && existing.mode.IsDir() {
+		if !node.mode.IsDir() {
+			return &fs.PathError{Op: "rename", Path: newName, Err: ErrIsDir}
+		}
+		if len(mfs.children(newPath)) > 0 {
+			return &fs.PathError{Op: "rename", Path: newName, Err: ErrNotEmpty}
+		}
+	} else if ok && node.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: newName, Err: ErrNotDir}
+	}
+	moved := make(map[string]*memNode)
+	for p, n := range mfs.nodes {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			moved[newPath+strings.TrimPrefix(p, oldPath)] = n
+			mfs.delete(p)
+		}
+	}
+	for p, n := range moved {
+		mfs.put(p, n)
+	}
+	return nil
+}
+
+// Symlink Create a symbolic link called name pointing to target
+func (mfs *MemFS) Symlink(target string, name string) error {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	p := clean(name)
+	if _, ok := mfs.nodes[p]; ok {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrExist}
+	}
+	err := mfs.checkParent("symlink", name, p)
+	if err != nil {
+		return err
+	}
+	mfs.put(p, &memNode{mode: fs.ModeSymlink | 0777, target: target, modTime: time.Now()})
+	return nil
+}
+
+// Return the information about a node as a fs.FileInfo
+func (node *memNode) info(name string) fs.FileInfo {
+	size := int64(len(node.data))
+	if node.mode&fs.ModeSymlink != 0 {
+		size = int64(len(node.target))
+	}
+	return &memInfo{name: name, size: size, mode: node.mode, modTime: node.modTime}
+}
+
+// memInfo Information about a file in a MemFS. There is no system specific data
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (info *memInfo) Name() string       { return info.name }
+func (info *memInfo) Size() int64        { return info.size }
+func (info *memInfo) Mode() fs.FileMode  { return info.mode }
+func (info *memInfo) ModTime() time.Time { return info.modTime }
+func (info *memInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info *memInfo) Sys() interface{}   { return nil }
+
+// dirEntry A fs.DirEntry for a fs.FileInfo
+type dirEntry struct {
+	info fs.FileInfo
+}
+
+func (entry dirEntry) Name() string               { return entry.info.Name() }
+func (entry dirEntry) IsDir() bool                { return entry.info.IsDir() }
+func (entry dirEntry) Type() fs.FileMode          { return entry.info.Mode().Type() }
+func (entry dirEntry) Info() (fs.FileInfo, error) { return entry.info, nil }
+
+// memFile An open file in a MemFS. Reads and writes go directly to the file's node, so they
+// are seen by anyone else with the file open
+type memFile struct {
+	fsys     *MemFS
+	node     *memNode
+	name     string
+	offset   int64
+	readable bool
+	writable bool
+	closed   bool
+}
+
+func (file *memFile) Name() string {
+	return file.name
+}
+
+func (file *memFile) Stat() (fs.FileInfo, error) {
+	file.fsys.mu.RLock()
+	defer file.fsys.mu.RUnlock()
+	return file.node.info(path.Base(clean(file.name))), nil
+}
+
+func (file *memFile) Read(p []byte) (int, error) {
+	n, err := file.ReadAt(p, file.offset)
+	file.offset += int64(n)
+	return n, err
+}
+
+func (file *memFile) ReadAt(p []byte, offset int64) (int, error) {
+	if file.closed || !file.readable {
+		return 0, &fs.PathError{Op: "read", Path: file.name, Err: fs.ErrClosed}
+	}
+	file.fsys.mu.RLock()
+	defer file.fsys.mu.RUnlock()
+	if offset >= int64(len(file.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, file.node.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (file *memFile) Write(p []byte) (int, error) {
+	if file.closed || !file.writable {
+		return 0, &fs.PathError{Op: "write", Path: file.name, Err: fs.ErrClosed}
+	}
+	file.fsys.mu.Lock()
+	defer file.fsys.mu.Unlock()
+	end := file.offset + int64(len(p))
+	if end > int64(len(file.node.data)) {
+		grown := make([]byte, end)
+		copy(grown, file.node.data)
+		file.node.data = grown
+	}
+	copy(file.node.data[file.offset:], p)
+	file.offset = end
+	file.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (file *memFile) Close() error {
+	if file.closed {
+		return &fs.PathError{Op: "close", Path: file.name, Err: fs.ErrClosed}
+	}
+	file.closed = true
+	return nil
+}
+
+// memDir An open directory in a MemFS
+type memDir struct {
+	fsys   *MemFS
+	path   string
+	name   string
+	offset int
+}
+
+func (dir *memDir) Name() string {
+	return dir.name
+}
+
+func (dir *memDir) Stat() (fs.FileInfo, error) {
+	return dir.fsys.Stat(dir.path)
+}
+
+func (dir *memDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.name, Err: ErrIsDir}
+}
+
+func (dir *memDir) ReadAt(p []byte, offset int64) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.name, Err: ErrIsDir}
+}
+
+func (dir *memDir) Write(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: dir.name, Err: ErrIsDir}
+}
+
+// ReadDir Read the next n entries of the directory (or all of them if n <= 0)
+func (dir *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := dir.fsys.ReadDir(dir.path)
+	if err != nil {
+		return nil, err
+	}
+	// Entries may have been removed since the last call
+	if dir.offset > len(entries) {
+		dir.offset = len(entries)
+	}
+	entries = entries[dir.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	dir.offset += len(entries)
+	return entries, nil
+}
+
+func (dir *memDir) Close() error {
+	return nil
+}
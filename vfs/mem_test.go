@@ -0,0 +1,161 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+// Test reading and writing files and directories
+func TestMemFSFiles(t *testing.T) {
+	mfs := NewMemFS()
+	err := mfs.MkdirAll("/a/b", 0755)
+	if err != nil {
+		t.Fatalf("Error creating dirs: %s", err)
+	}
+	err = mfs.WriteFile("/a/b/file", []byte("hello"), 0644)
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	data, err := mfs.ReadFile("a/b/file")
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected 'hello', Got: '%s', %v", data, err)
+	}
+	err = mfs.WriteFile("/missing/file", []byte("x"), 0644)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist writing into a missing dir, Got: %v", err)
+	}
+	err = mfs.Mkdir("/a", 0755)
+	if !os.IsExist(err) {
+		t.Errorf("Expected an exists error from Mkdir, Got: %v", err)
+	}
+	err = mfs.MkdirAll("/a/b/file/c", 0755)
+	if !errors.Is(err, ErrNotDir) {
+		t.Errorf("Expected ErrNotDir from MkdirAll through a file, Got: %v", err)
+	}
+
+	file, err := mfs.OpenFile("/a/b/file", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Error opening file: %s", err)
+	}
+	file.Write([]byte(" world"))
+	file.Close()
+	info, err := mfs.Stat("/a/b/file")
+	if err != nil || info.Size() != 11 || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected an 11 byte file with mode 0644, Got: %v, %v", info, err)
+	}
+	_, err = mfs.OpenFile("/a/b/file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if !os.IsExist(err) {
+		t.Errorf("Expected an exists error with O_EXCL, Got: %v", err)
+	}
+	tmp, err := CreateTemp(mfs, "/a", "tmp_")
+	if err != nil {
+		t.Fatalf("Error creating temp file: %s", err)
+	}
+	tmp.Write([]byte("temp"))
+	tmp.Close()
+	buf := make([]byte, 2)
+	reader, _ := mfs.OpenFile(tmp.Name(), os.O_RDONLY, 0)
+	n, err := reader.ReadAt(buf, 2)
+	if n != 2 || string(buf) != "mp" || (err != nil && err != io.EOF) {
+		t.Errorf("Expected to read 'mp' at offset 2, Got: '%s', %v", buf[:n], err)
+	}
+
+	err = mfs.Remove("/a/b")
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Expected ErrNotEmpty removing a dir with files, Got: %v", err)
+	}
+	err = mfs.Rename("/a/b", "/c")
+	if err != nil {
+		t.Fatalf("Error renaming: %s", err)
+	}
+	if _, err := mfs.Stat("/c/file"); err != nil {
+		t.Errorf("Expected files to move with their dir, Got: %v", err)
+	}
+	if _, err := mfs.Stat("/a/b"); !os.IsNotExist(err) {
+		t.Errorf("Expected the old dir to be gone, Got: %v", err)
+	}
+	entries, err := mfs.ReadDir("/")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "a" || entries[1].Name() != "c" {
+		t.Errorf("Expected root to have a and c, Got: %v, %v", entries, err)
+	}
+
+	// The filesystem can be used with the io/fs helpers
+	walked := make([]string, 0)
+	err = fs.WalkDir(mfs, ".", func(p string, entry fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	if err != nil || len(walked) != 5 || walked[3] != "c" || walked[4] != "c/file" {
+		t.Errorf("Expected to walk ., a, the temp file, c and c/file, Got: %v, %v", walked, err)
+	}
+}
+
+// Test creating, reading and following symbolic links
+func TestMemFSSymlinks(t *testing.T) {
+	mfs := NewMemFS()
+	err := mfs.WriteFile("/target", []byte("content"), 0644)
+	if err != nil {
+		t.Fatalf("Error writing file: %s", err)
+	}
+	err = mfs.Symlink("target", "/link")
+	if err != nil {
+		t.Fatalf("Error creating link: %s", err)
+	}
+	info, err := mfs.Lstat("/link")
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 || info.Size() != 6 {
+		t.Errorf("Expected Lstat to return the link, Got: %v, %v", info, err)
+	}
+	target, err := mfs.Readlink("/link")
+	if err != nil || target != "target" {
+		t.Errorf("Expected link to 'target', Got: '%s', %v", target, err)
+	}
+	data, err := mfs.ReadFile("/link")
+	if err != nil || string(data) != "content" {
+		t.Errorf("Expected to read through the link, Got: '%s', %v", data, err)
+	}
+	err = mfs.Remove("/link")
+	if err != nil {
+		t.Fatalf("Error removing link: %s", err)
+	}
+	if _, err := mfs.Stat("/target"); err != nil {
+		t.Errorf("Expected removing a link to keep its target, Got: %v", err)
+	}
+}
+
+// Test reading a directory a few entries at a time while it changes
+func TestMemFSReadDirPaging(t *testing.T) {
+	mfs := NewMemFS()
+	for _, name := range []string{"/d/a", "/d/b", "/d/c"} {
+		err := mfs.MkdirAll(name, 0755)
+		if err != nil {
+			t.Fatalf("Error creating dirs: %s", err)
+		}
+	}
+	file, err := mfs.Open("/d")
+	if err != nil {
+		t.Fatalf("Error opening dir: %s", err)
+	}
+	dir := file.(fs.ReadDirFile)
+	entries, err := dir.ReadDir(2)
+	if err != nil || len(entries) != 2 || entries[0].Name() != "a" || entries[1].Name() != "b" {
+		t.Errorf("Expected a and b, Got: %v, %v", entries, err)
+	}
+	// Removing entries that were already read mustn't break the next call
+	for _, name := range []string{"/d/a", "/d/b", "/d/c"} {
+		mfs.Remove(name)
+	}
+	entries, err = dir.ReadDir(2)
+	if err != io.EOF || len(entries) != 0 {
+		t.Errorf("Expected io.EOF once the dir is empty, Got: %v, %v", entries, err)
+	}
+	entries, err = dir.ReadDir(-1)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries and no error reading the rest, Got: %v, %v", entries, err)
+	}
+	if entries, err := mfs.ReadDir("/"); err != nil || len(entries) != 1 || entries[0].Name() != "d" {
+		t.Errorf("Expected root to have only d, Got: %v, %v", entries, err)
+	}
+}
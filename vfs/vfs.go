@@ -0,0 +1,127 @@
+// Package vfs A filesystem abstraction for repositories. It extends io/fs with the operations
+// needed to write to a repository so one can be kept on disk or entirely in memory
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+)
+
+// FS A filesystem that can be read and written. Paths are slash separated; how they are
+// interpreted (e.g. whether they are absolute) is up to the implementation. Errors are
+// *fs.PathError values wrapping the usual fs errors (fs.ErrNotExist, fs.ErrExist...) so
+// os.IsNotExist and errors.Is work with them
+type FS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+	// Lstat Like Stat but symbolic links are not followed
+	Lstat(name string) (fs.FileInfo, error)
+	// Readlink Return the target of a symbolic link
+	Readlink(name string) (string, error)
+	// OpenFile Open a file with the same flags as os.OpenFile (os.O_CREATE, os.O_WRONLY...)
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	// WriteFile Write data to a file, creating it if needed
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// Mkdir Create a directory. Its parent must exist
+	Mkdir(name string, perm fs.FileMode) error
+	// MkdirAll Create a directory along with any missing parents
+	MkdirAll(name string, perm fs.FileMode) error
+	// Remove Remove a file or an empty directory
+	Remove(name string) error
+	// Rename Move a file or directory, replacing newName if it is a file
+	Rename(oldName string, newName string) error
+	// Symlink Create a symbolic link called name pointing to target
+	Symlink(target string, name string) error
+}
+
+// File An open file that can be read, written and read at arbitrary offsets
+type File interface {
+	fs.File
+	io.Writer
+	io.ReaderAt
+	// Name The name the file was opened with
+	Name() string
+}
+
+// OSFS The operating system's filesystem. Paths are used as they are given
+type OSFS struct{}
+
+// OS The operating system's filesystem
+var OS FS = OSFS{}
+
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (OSFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (OSFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OSFS) Mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) Rename(oldName string, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (OSFS) Symlink(target string, name string) error {
+	return os.Symlink(target, name)
+}
+
+// CreateTemp Create a new file in dir whose name starts with prefix followed by a random
+// string, opened for writing. It is up to the caller to remove the file
+func CreateTemp(fsys FS, dir string, prefix string) (File, error) {
+	for try := 0; try < 10000; try++ {
+		name := path.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		file, err := fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return file, err
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: path.Join(dir, prefix+"*"), Err: fs.ErrExist}
+}
+
+// Create Create or truncate a file for writing
+func Create(fsys FS, name string) (File, error) {
+	return fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}